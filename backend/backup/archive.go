@@ -0,0 +1,195 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+// ArchiveWriter writes files and directories to a tar.gz archive
+type ArchiveWriter struct {
+	file       *os.File
+	gzipWriter *gzip.Writer
+	tarWriter  *tar.Writer
+}
+
+func NewArchiveWriter(archivePath string) (*ArchiveWriter, error) {
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.Create: %w", err)
+	}
+
+	gzipWriter := gzip.NewWriter(file)
+	return &ArchiveWriter{
+		file:       file,
+		gzipWriter: gzipWriter,
+		tarWriter:  tar.NewWriter(gzipWriter),
+	}, nil
+}
+
+// AddDir adds all regular files inside the dir. The entry names are prefixed with the given name.
+// Directories and files in excluded are skipped
+func (w *ArchiveWriter) AddDir(dir string, name string, excluded []string) error {
+	dir = filepath.Clean(dir)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if slices.Contains(excluded, filepath.Clean(p)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() || slices.Contains(excluded, filepath.Clean(p)) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, p)
+		if err != nil {
+			return fmt.Errorf("filepath.Rel: %w", err)
+		}
+
+		return w.AddFile(p, path.Join(name, filepath.ToSlash(relPath)))
+	})
+	if err != nil {
+		return fmt.Errorf("filepath.WalkDir: %w", err)
+	}
+
+	return nil
+}
+
+// AddFile adds the file with the given entry name
+func (w *ArchiveWriter) AddFile(filePath string, name string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("os.Stat: %w", err)
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("tar.FileInfoHeader: %w", err)
+	}
+	header.Name = name
+
+	if err := w.tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("tarWriter.WriteHeader: %w", err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer file.Close()
+
+	// files like logs can grow while the archive is created. Only the size from the header is copied
+	if _, err := io.CopyN(w.tarWriter, file, header.Size); err != nil {
+		return fmt.Errorf("io.CopyN %v: %w", filePath, err)
+	}
+
+	return nil
+}
+
+// AddPath adds the file or directory. Does nothing if the path does not exist
+func (w *ArchiveWriter) AddPath(p string, name string) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("os.Stat: %w", err)
+	}
+
+	if info.IsDir() {
+		return w.AddDir(p, name, nil)
+	}
+
+	return w.AddFile(p, name)
+}
+
+// Close finishes the archive. The archive is only complete if Close returns no error
+func (w *ArchiveWriter) Close() error {
+	if err := w.tarWriter.Close(); err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("tarWriter.Close: %w", err)
+	}
+
+	if err := w.gzipWriter.Close(); err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("gzipWriter.Close: %w", err)
+	}
+
+	return w.file.Close()
+}
+
+// ExtractArchive extracts the regular files of the tar.gz archive.
+// targetPath returns the destination of the entry or an empty string if the entry should be skipped.
+// Entries with absolute paths or paths outside the archive root are rejected
+func ExtractArchive(archivePath string, targetPath func(name string) string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("gzip.NewReader: %w", err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("tarReader.Next: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if !filepath.IsLocal(filepath.FromSlash(header.Name)) {
+			return fmt.Errorf("invalid entry name '%v'", header.Name)
+		}
+
+		target := targetPath(header.Name)
+		if target == "" {
+			continue
+		}
+
+		if err := extractFile(tarReader, target, header.FileInfo().Mode()); err != nil {
+			return fmt.Errorf("extract '%v': %w", header.Name, err)
+		}
+	}
+}
+
+func extractFile(r io.Reader, target string, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return fmt.Errorf("io.Copy: %w", err)
+	}
+
+	return file.Close()
+}
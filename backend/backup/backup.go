@@ -0,0 +1,459 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+
+	"github.com/google/uuid"
+)
+
+const fileExtension = ".tar.gz"
+
+// the entries of the data dir and the editable files of the server dir are stored in different directories of the archive
+const (
+	dataEntryDir   = "data"
+	serverEntryDir = "server"
+)
+
+var (
+	ErrBackupNotFound = errors.New("backup not found")
+	ErrInvalidBackup  = errors.New("backup is not valid")
+)
+
+type Backup struct {
+	Id           string    `json:"id"`
+	CreatedAtUtc time.Time `json:"created_at_utc"`
+	Size         int64     `json:"size"`
+}
+
+// Editor returns the editable files of the server dir. The paths are relative to the server dir and start with a slash
+type Editor interface {
+	GetAllEditableFiles() ([]string, error)
+	CanBeEdited(path string) bool
+}
+
+type Config struct {
+	SourceDir string
+	BackupDir string
+	// directories inside the source dir that are not part of the backup, e.g. the server dir
+	ExcludedDirs []string
+	// files inside the source dir that are not part of the backup and never restored, e.g. files with passwords
+	ExcludedFiles []string
+	// directories inside the source dir that are part of the backup but are never restored, e.g. the logs
+	NotRestoredDirs []string
+	ServerDir       string
+	// number of backups that are kept. 0 keeps all backups
+	Retention int
+}
+
+// Instance creates tar.gz archives of the data dir and the editable files of an instance
+type Instance struct {
+	sourceDir string
+	backupDir string
+	// excluded dirs and files
+	excludedDirs    []string
+	notRestoredDirs []string
+	serverDir       string
+	retention       int
+
+	editor Editor
+	// validators of the files in the data dir by their path relative to the data dir
+	validators map[string]Validator
+
+	lock sync.Mutex
+}
+
+func New(config Config, editor Editor, validators map[string]Validator) (*Instance, error) {
+	if err := gvalidator.Instance().Var(config.SourceDir, "required,dir"); err != nil {
+		return nil, fmt.Errorf("sourceDir validation: %w", err)
+	}
+
+	if err := gvalidator.Instance().Var(config.BackupDir, "required,filepath"); err != nil {
+		return nil, fmt.Errorf("backupDir validation: %w", err)
+	}
+
+	if err := gvalidator.Instance().Var(config.ServerDir, "required,dir"); err != nil {
+		return nil, fmt.Errorf("serverDir validation: %w", err)
+	}
+
+	if config.Retention < 0 {
+		return nil, fmt.Errorf("retention must not be negative but is %v", config.Retention)
+	}
+
+	excluded := make([]string, 0, len(config.ExcludedDirs)+len(config.ExcludedFiles)+1)
+	excluded = append(excluded, filepath.Clean(config.BackupDir))
+	for _, p := range slices.Concat(config.ExcludedDirs, config.ExcludedFiles) {
+		excluded = append(excluded, filepath.Clean(p))
+	}
+
+	notRestored := make([]string, 0, len(config.NotRestoredDirs))
+	for _, dir := range config.NotRestoredDirs {
+		notRestored = append(notRestored, filepath.Clean(dir))
+	}
+
+	return &Instance{
+		sourceDir:       filepath.Clean(config.SourceDir),
+		backupDir:       filepath.Clean(config.BackupDir),
+		excludedDirs:    excluded,
+		notRestoredDirs: notRestored,
+		serverDir:       filepath.Clean(config.ServerDir),
+		retention:       config.Retention,
+		editor:          editor,
+		validators:      validators,
+	}, nil
+}
+
+func (i *Instance) backupPath(id string) string {
+	return filepath.Join(i.backupDir, id+fileExtension)
+}
+
+// Create writes a new backup to the backup dir and removes the oldest backups exceeding the retention.
+// The archive is written to a temporary file first, so incomplete backups are never listed
+func (i *Instance) Create() (Backup, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if err := os.MkdirAll(i.backupDir, os.ModePerm); err != nil {
+		return Backup{}, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	createdAt := time.Now().UTC()
+	id := fmt.Sprintf("%v_%v", createdAt.Format("2006-01-02_15-04-05"), strings.Split(uuid.New().String(), "-")[0])
+	backupPath := i.backupPath(id)
+	tempPath := backupPath + ".tmp"
+
+	if err := i.writeArchive(tempPath); err != nil {
+		_ = os.Remove(tempPath)
+		return Backup{}, err
+	}
+
+	if err := os.Rename(tempPath, backupPath); err != nil {
+		_ = os.Remove(tempPath)
+		return Backup{}, fmt.Errorf("os.Rename: %w", err)
+	}
+
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return Backup{}, fmt.Errorf("os.Stat: %w", err)
+	}
+
+	if err := i.prune(); err != nil {
+		return Backup{}, fmt.Errorf("prune backups: %w", err)
+	}
+
+	return Backup{
+		Id:           id,
+		CreatedAtUtc: createdAt,
+		Size:         info.Size(),
+	}, nil
+}
+
+func (i *Instance) writeArchive(path string) error {
+	archiveWriter, err := NewArchiveWriter(path)
+	if err != nil {
+		return err
+	}
+
+	if err := i.addFiles(archiveWriter); err != nil {
+		_ = archiveWriter.Close()
+		return err
+	}
+
+	return archiveWriter.Close()
+}
+
+func (i *Instance) addFiles(archiveWriter *ArchiveWriter) error {
+	if err := archiveWriter.AddDir(i.sourceDir, dataEntryDir, i.excludedDirs); err != nil {
+		return err
+	}
+
+	if i.editor == nil {
+		return nil
+	}
+
+	editableFiles, err := i.editor.GetAllEditableFiles()
+	if err != nil {
+		return fmt.Errorf("get editable files: %w", err)
+	}
+
+	for _, editableFile := range editableFiles {
+		name := path.Join(serverEntryDir, filepath.ToSlash(editableFile))
+		if err := archiveWriter.AddPath(filepath.Join(i.serverDir, editableFile), name); err != nil {
+			return fmt.Errorf("add '%v': %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// prune removes the oldest backups exceeding the retention
+func (i *Instance) prune() error {
+	if i.retention == 0 {
+		return nil
+	}
+
+	backups, err := i.List()
+	if err != nil {
+		return err
+	}
+
+	if len(backups) <= i.retention {
+		return nil
+	}
+
+	for _, b := range backups[i.retention:] {
+		if err := os.Remove(i.backupPath(b.Id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove backup '%v': %w", b.Id, err)
+		}
+	}
+
+	return nil
+}
+
+// List returns all backups, newest backup first
+func (i *Instance) List() ([]Backup, error) {
+	entries, err := os.ReadDir(i.backupDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Backup{}, nil
+		}
+		return nil, fmt.Errorf("os.ReadDir: %w", err)
+	}
+
+	result := make([]Backup, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileExtension) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("entry.Info: %w", err)
+		}
+
+		result = append(result, Backup{
+			Id:           strings.TrimSuffix(entry.Name(), fileExtension),
+			CreatedAtUtc: info.ModTime().UTC(),
+			Size:         info.Size(),
+		})
+	}
+
+	slices.SortFunc(result, func(a, b Backup) int {
+		return b.CreatedAtUtc.Compare(a.CreatedAtUtc)
+	})
+
+	return result, nil
+}
+
+// Get returns the backup and the path of its archive
+func (i *Instance) Get(id string) (Backup, string, error) {
+	backups, err := i.List()
+	if err != nil {
+		return Backup{}, "", err
+	}
+
+	index := slices.IndexFunc(backups, func(b Backup) bool {
+		return b.Id == id
+	})
+	if index == -1 {
+		return Backup{}, "", ErrBackupNotFound
+	}
+
+	return backups[index], i.backupPath(id), nil
+}
+
+// Delete removes the backup
+func (i *Instance) Delete(id string) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	_, backupPath, err := i.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(backupPath); err != nil {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+
+	return nil
+}
+
+// Restore overwrites the files of the data dir and the editable files with the content of the backup.
+// The backup is extracted to a staging dir and every file is validated first, so nothing is overwritten if the backup is not valid.
+// Files that are not part of the backup are not removed. Returns the names of the restored archive entries.
+// Backups without any file that can be restored are not valid
+func (i *Instance) Restore(id string) ([]string, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	_, backupPath, err := i.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	legacy, err := isLegacyArchive(backupPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read backup: %w", ErrInvalidBackup, err)
+	}
+
+	stagingDir := filepath.Join(i.backupDir, fmt.Sprintf("restore_%v", uuid.New()))
+	defer func() {
+		_ = os.RemoveAll(stagingDir)
+	}()
+
+	// destination of every staged archive entry
+	targets := make(map[string]string)
+	// staged path of every archive entry. The entries of legacy backups are staged like the entries of the data dir
+	staged := make(map[string]string)
+	err = ExtractArchive(backupPath, func(name string) string {
+		entryName := name
+		if legacy {
+			entryName = path.Join(dataEntryDir, name)
+		}
+
+		target := i.restoreTarget(entryName)
+		if target == "" {
+			return ""
+		}
+
+		targets[name] = target
+		staged[name] = filepath.Join(stagingDir, filepath.FromSlash(entryName))
+		return staged[name]
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: extract backup: %w", ErrInvalidBackup, err)
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: backup does not contain any file that can be restored", ErrInvalidBackup)
+	}
+
+	if err := i.validateStagedFiles(filepath.Join(stagingDir, dataEntryDir)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+
+	restored := make([]string, 0, len(targets))
+	for name, target := range targets {
+		if err := copyFile(staged[name], target); err != nil {
+			return restored, fmt.Errorf("restore '%v': %w", name, err)
+		}
+		restored = append(restored, name)
+	}
+
+	slices.Sort(restored)
+	return restored, nil
+}
+
+// isLegacyArchive reports whether the backup was created before the files of the data dir and the server dir were separated.
+// Those backups only contain the files of the data dir at the root of the archive
+func isLegacyArchive(backupPath string) (bool, error) {
+	legacy := true
+	err := ExtractArchive(backupPath, func(name string) string {
+		if strings.HasPrefix(name, dataEntryDir+"/") {
+			legacy = false
+		}
+		return ""
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return legacy, nil
+}
+
+// restoreTarget returns the destination of the archive entry or an empty string if the entry is not restored
+func (i *Instance) restoreTarget(name string) string {
+	if relPath, ok := strings.CutPrefix(name, dataEntryDir+"/"); ok {
+		target := filepath.Join(i.sourceDir, filepath.FromSlash(relPath))
+		if isInsideAny(target, i.excludedDirs) || isInsideAny(target, i.notRestoredDirs) {
+			return ""
+		}
+		return target
+	}
+
+	if relPath, ok := strings.CutPrefix(name, serverEntryDir+"/"); ok {
+		if i.editor == nil || !i.editor.CanBeEdited("/"+relPath) {
+			return ""
+		}
+		return filepath.Join(i.serverDir, filepath.FromSlash(relPath))
+	}
+
+	return ""
+}
+
+// validateStagedFiles validates the json files of the data dir
+func (i *Instance) validateStagedFiles(stagedDataDir string) error {
+	err := filepath.WalkDir(stagedDataDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if d.IsDir() || filepath.Ext(p) != ".json" {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(stagedDataDir, p)
+		if err != nil {
+			return fmt.Errorf("filepath.Rel: %w", err)
+		}
+		name := filepath.ToSlash(relPath)
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("os.ReadFile: %w", err)
+		}
+
+		validator, ok := i.validators[name]
+		if !ok {
+			validator = validJson
+		}
+
+		if err := validator(content); err != nil {
+			return fmt.Errorf("'%v': %w", name, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("validate files: %w", err)
+	}
+
+	return nil
+}
+
+func isInsideAny(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func copyFile(sourcePath string, targetPath string) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("source.Stat: %w", err)
+	}
+
+	return extractFile(source, targetPath, info.Mode())
+}
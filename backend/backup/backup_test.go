@@ -0,0 +1,352 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/backup"
+	"github.com/google/uuid"
+)
+
+type testEditor struct {
+	files []string
+}
+
+func (e testEditor) GetAllEditableFiles() ([]string, error) {
+	return e.files, nil
+}
+
+func (e testEditor) CanBeEdited(path string) bool {
+	return slices.Contains(e.files, path)
+}
+
+type testStartParameters struct {
+	Hostname string `json:"hostname" validate:"required"`
+}
+
+func writeTestFiles(t *testing.T, dir string, testFiles map[string]string) {
+	for name, content := range testFiles {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func createTestBackup(t *testing.T, retention int) (*backup.Instance, string) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_backup_test_%v", uuid.New()))
+	writeTestFiles(t, tempDirPath, map[string]string{
+		"start-parameters.json":             `{"hostname": "before"}`,
+		"steamcmd-branch.json":              `{"name": "beta", "password": "s3cr3t"}`,
+		"logs/user.log":                     "log",
+		"server/game/csgo/cfg/server.cfg":   "hostname before",
+		"server/game/csgo/cfg/gamemode.cfg": "not editable",
+	})
+
+	b, err := backup.New(
+		backup.Config{
+			SourceDir:       tempDirPath,
+			BackupDir:       filepath.Join(tempDirPath, "backups"),
+			ExcludedDirs:    []string{filepath.Join(tempDirPath, "server")},
+			ExcludedFiles:   []string{filepath.Join(tempDirPath, "steamcmd-branch.json")},
+			NotRestoredDirs: []string{filepath.Join(tempDirPath, "logs")},
+			ServerDir:       filepath.Join(tempDirPath, "server"),
+			Retention:       retention,
+		},
+		testEditor{files: []string{"/game/csgo/cfg/server.cfg"}},
+		map[string]backup.Validator{
+			"start-parameters.json": backup.JsonValidator[testStartParameters](),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b, tempDirPath
+}
+
+func TestInstance_Create(t *testing.T) {
+	b, tempDirPath := createTestBackup(t, 0)
+
+	// the second backup must not contain the first one
+	if _, err := b.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := b.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := b.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups but got %+v", backups)
+	}
+
+	file, err := os.Open(filepath.Join(tempDirPath, "backups", created.Id+".tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+
+	slices.Sort(names)
+	expected := []string{"data/logs/user.log", "data/start-parameters.json", "server/game/csgo/cfg/server.cfg"}
+	if !slices.Equal(names, expected) {
+		t.Fatalf("unexpected files in backup %v", names)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Restore(t *testing.T) {
+	b, tempDirPath := createTestBackup(t, 0)
+
+	created, err := b.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFiles(t, tempDirPath, map[string]string{
+		"start-parameters.json":           `{"hostname": "after"}`,
+		"server/game/csgo/cfg/server.cfg": "hostname after",
+		"logs/user.log":                   "log after",
+		"logs/new.log":                    "new",
+	})
+
+	restored, err := b.Restore(created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(restored, []string{"data/start-parameters.json", "server/game/csgo/cfg/server.cfg"}) {
+		t.Fatalf("unexpected restored files %v", restored)
+	}
+
+	if content := readTestFile(t, filepath.Join(tempDirPath, "start-parameters.json")); content != `{"hostname": "before"}` {
+		t.Fatal("start-parameters.json not restored. content:", content)
+	}
+
+	if content := readTestFile(t, filepath.Join(tempDirPath, "server", "game", "csgo", "cfg", "server.cfg")); content != "hostname before" {
+		t.Fatal("editable file not restored. content:", content)
+	}
+
+	// the logs are part of the backup but are not restored
+	if content := readTestFile(t, filepath.Join(tempDirPath, "logs", "user.log")); content != "log after" {
+		t.Fatal("log restored. content:", content)
+	}
+
+	// files that are not part of the backup are kept
+	if content := readTestFile(t, filepath.Join(tempDirPath, "logs", "new.log")); content != "new" {
+		t.Fatal("file that is not part of the backup changed. content:", content)
+	}
+
+	if _, err := b.Restore(uuid.New().String()); !errors.Is(err, backup.ErrBackupNotFound) {
+		t.Fatal("expected ErrBackupNotFound but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Restore_Invalid(t *testing.T) {
+	b, tempDirPath := createTestBackup(t, 0)
+
+	// the hostname is required
+	writeTestFiles(t, tempDirPath, map[string]string{
+		"start-parameters.json": `{"hostname": ""}`,
+	})
+
+	created, err := b.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFiles(t, tempDirPath, map[string]string{
+		"start-parameters.json":           `{"hostname": "after"}`,
+		"server/game/csgo/cfg/server.cfg": "hostname after",
+	})
+
+	if _, err := b.Restore(created.Id); !errors.Is(err, backup.ErrInvalidBackup) {
+		t.Fatal("expected ErrInvalidBackup but got", err)
+	}
+
+	// nothing is overwritten if one file is not valid
+	if content := readTestFile(t, filepath.Join(tempDirPath, "start-parameters.json")); content != `{"hostname": "after"}` {
+		t.Fatal("start-parameters.json overwritten. content:", content)
+	}
+
+	if content := readTestFile(t, filepath.Join(tempDirPath, "server", "game", "csgo", "cfg", "server.cfg")); content != "hostname after" {
+		t.Fatal("editable file overwritten. content:", content)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+// writeTestArchive replaces the archive of the backup with the given entries
+func writeTestArchive(t *testing.T, archivePath string, entries map[string]string) {
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range entries {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstance_Restore_LegacyLayout(t *testing.T) {
+	b, tempDirPath := createTestBackup(t, 0)
+
+	created, err := b.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// backups created before the editable files were added contain the files of the data dir at the root of the archive
+	writeTestArchive(t, filepath.Join(tempDirPath, "backups", created.Id+".tar.gz"), map[string]string{
+		"start-parameters.json": `{"hostname": "legacy"}`,
+		"logs/user.log":         "legacy log",
+	})
+
+	restored, err := b.Restore(created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(restored, []string{"start-parameters.json"}) {
+		t.Fatalf("unexpected restored files %v", restored)
+	}
+
+	if content := readTestFile(t, filepath.Join(tempDirPath, "start-parameters.json")); content != `{"hostname": "legacy"}` {
+		t.Fatal("start-parameters.json not restored. content:", content)
+	}
+
+	if content := readTestFile(t, filepath.Join(tempDirPath, "logs", "user.log")); content != "log" {
+		t.Fatal("log restored. content:", content)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Restore_NothingToRestore(t *testing.T) {
+	b, tempDirPath := createTestBackup(t, 0)
+
+	created, err := b.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestArchive(t, filepath.Join(tempDirPath, "backups", created.Id+".tar.gz"), map[string]string{
+		"logs/user.log": "log after",
+	})
+
+	if _, err := b.Restore(created.Id); !errors.Is(err, backup.ErrInvalidBackup) {
+		t.Fatal("expected ErrInvalidBackup but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Create_Retention(t *testing.T) {
+	b, tempDirPath := createTestBackup(t, 2)
+
+	var created []backup.Backup
+	for range 3 {
+		c, err := b.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, c)
+	}
+
+	backups, err := b.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backups) != 2 || backups[0].Id != created[2].Id || backups[1].Id != created[1].Id {
+		t.Fatalf("expected the newest 2 backups but got %+v", backups)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+)
+
+// Validator checks the content of a file before it is restored
+type Validator func(content []byte) error
+
+// JsonValidator parses the content into T and validates it with the validate tags of T.
+// Slices and maps are validated element by element. A json null is valid for pointer types
+func JsonValidator[T any]() Validator {
+	return func(content []byte) error {
+		var result T
+		if err := json.Unmarshal(content, &result); err != nil {
+			return fmt.Errorf("json.Unmarshal: %w", err)
+		}
+
+		v := reflect.ValueOf(&result).Elem()
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			if err := gvalidator.Instance().Struct(v.Interface()); err != nil {
+				return fmt.Errorf("validation: %w", err)
+			}
+		case reflect.Slice, reflect.Array, reflect.Map:
+			if err := gvalidator.Instance().Var(v.Interface(), "dive"); err != nil {
+				return fmt.Errorf("validation: %w", err)
+			}
+		}
+
+		return nil
+	}
+}
+
+func validJson(content []byte) error {
+	if !json.Valid(content) {
+		return errors.New("invalid json")
+	}
+	return nil
+}
@@ -11,8 +11,10 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/plugins"
 
 	"github.com/joho/godotenv"
 )
@@ -26,6 +28,20 @@ type Config struct {
 	SteamcmdDir                string
 	EnableWebUi                bool
 	EnableSwagger              bool
+	AutoRestart                bool
+	AutoRestartMaxRestarts     int
+	AutoRestartWindow          time.Duration
+	AutoRestartInitialBackoff  time.Duration
+	AutoRestartMaxBackoff      time.Duration
+	UpdateCheck                bool
+	UpdateCheckInterval        time.Duration
+	AutoUpdate                 bool
+	UpdateSnapshotRetention    int
+	BackupRetention            int
+	PluginSignaturePublicKey   string
+	PluginCatalogSources       []string
+	RconPort                   string
+	RconPassword               string
 	Ip                         string
 	ipSetByEnvironmentVariable bool
 }
@@ -81,6 +97,24 @@ func getEnvWithDefaultValueIfEmpty(key string, validationString string, defaultV
 	return v, nil
 }
 
+func getDurationEnvWithDefaultValueIfEmpty(key string, defaultValue string) (time.Duration, error) {
+	v, err := getEnvWithDefaultValueIfEmpty(key, "printascii", defaultValue)
+	if err != nil {
+		return 0, err
+	}
+
+	duration, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse environment variable '%v' with value '%v' to duration: %w", key, v, err)
+	}
+
+	if duration <= 0 {
+		return 0, fmt.Errorf("environment variable '%v' with value '%v' has to be a positive duration", key, v)
+	}
+
+	return duration, nil
+}
+
 func getPublicIp() (string, error) {
 	resp, err := http.Get("https://api.ipify.org/?format=text")
 	if err != nil {
@@ -217,6 +251,154 @@ func GetConfig() (Config, error) {
 		return Config{}, fmt.Errorf("failed to parse environment variable '%v' with value '%v' to bool: %w", enableSwaggerKey, enableSwaggerStr, err)
 	}
 
+	// AUTO_RESTART
+	const autoRestartKey = "AUTO_RESTART"
+	autoRestartStr, err := getEnvWithDefaultValueIfEmpty(autoRestartKey, "boolean", "true")
+	if err != nil {
+		return Config{}, err
+	}
+
+	autoRestart, err := strconv.ParseBool(autoRestartStr)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse environment variable '%v' with value '%v' to bool: %w", autoRestartKey, autoRestartStr, err)
+	}
+
+	// AUTO_RESTART_MAX_RESTARTS
+	const autoRestartMaxRestartsKey = "AUTO_RESTART_MAX_RESTARTS"
+	autoRestartMaxRestartsStr, err := getEnvWithDefaultValueIfEmpty(autoRestartMaxRestartsKey, "number", "5")
+	if err != nil {
+		return Config{}, err
+	}
+
+	autoRestartMaxRestarts, err := strconv.Atoi(autoRestartMaxRestartsStr)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse environment variable '%v' with value '%v' to int: %w", autoRestartMaxRestartsKey, autoRestartMaxRestartsStr, err)
+	}
+
+	// AUTO_RESTART_WINDOW
+	autoRestartWindow, err := getDurationEnvWithDefaultValueIfEmpty("AUTO_RESTART_WINDOW", "10m")
+	if err != nil {
+		return Config{}, err
+	}
+
+	// AUTO_RESTART_INITIAL_BACKOFF
+	autoRestartInitialBackoff, err := getDurationEnvWithDefaultValueIfEmpty("AUTO_RESTART_INITIAL_BACKOFF", "5s")
+	if err != nil {
+		return Config{}, err
+	}
+
+	// AUTO_RESTART_MAX_BACKOFF
+	autoRestartMaxBackoff, err := getDurationEnvWithDefaultValueIfEmpty("AUTO_RESTART_MAX_BACKOFF", "2m")
+	if err != nil {
+		return Config{}, err
+	}
+
+	// UPDATE_CHECK
+	const updateCheckKey = "UPDATE_CHECK"
+	updateCheckStr, err := getEnvWithDefaultValueIfEmpty(updateCheckKey, "boolean", "true")
+	if err != nil {
+		return Config{}, err
+	}
+
+	updateCheck, err := strconv.ParseBool(updateCheckStr)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse environment variable '%v' with value '%v' to bool: %w", updateCheckKey, updateCheckStr, err)
+	}
+
+	// UPDATE_CHECK_INTERVAL
+	updateCheckInterval, err := getDurationEnvWithDefaultValueIfEmpty("UPDATE_CHECK_INTERVAL", "1h")
+	if err != nil {
+		return Config{}, err
+	}
+
+	// AUTO_UPDATE
+	const autoUpdateKey = "AUTO_UPDATE"
+	autoUpdateStr, err := getEnvWithDefaultValueIfEmpty(autoUpdateKey, "boolean", "false")
+	if err != nil {
+		return Config{}, err
+	}
+
+	autoUpdate, err := strconv.ParseBool(autoUpdateStr)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse environment variable '%v' with value '%v' to bool: %w", autoUpdateKey, autoUpdateStr, err)
+	}
+
+	if autoUpdate && !updateCheck {
+		return Config{}, fmt.Errorf("environment variable '%v' requires '%v' to be enabled", autoUpdateKey, updateCheckKey)
+	}
+
+	// UPDATE_SNAPSHOT_RETENTION
+	const updateSnapshotRetentionKey = "UPDATE_SNAPSHOT_RETENTION"
+	updateSnapshotRetentionStr, err := getEnvWithDefaultValueIfEmpty(updateSnapshotRetentionKey, "number", "3")
+	if err != nil {
+		return Config{}, err
+	}
+
+	updateSnapshotRetention, err := strconv.Atoi(updateSnapshotRetentionStr)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse environment variable '%v' with value '%v' to int: %w", updateSnapshotRetentionKey, updateSnapshotRetentionStr, err)
+	}
+
+	// BACKUP_RETENTION
+	const backupRetentionKey = "BACKUP_RETENTION"
+	backupRetentionStr, err := getEnvWithDefaultValueIfEmpty(backupRetentionKey, "number", "10")
+	if err != nil {
+		return Config{}, err
+	}
+
+	backupRetention, err := strconv.Atoi(backupRetentionStr)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse environment variable '%v' with value '%v' to int: %w", backupRetentionKey, backupRetentionStr, err)
+	}
+
+	// PLUGIN_SIGNATURE_PUBLIC_KEY
+	const pluginSignaturePublicKeyKey = "PLUGIN_SIGNATURE_PUBLIC_KEY"
+	// the content of a minisign .pub file has more than one line. The key is validated by parsing it
+	pluginSignaturePublicKey, err := getEnvWithDefaultValueIfEmpty(pluginSignaturePublicKeyKey, "", "")
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := plugins.ValidateSignaturePublicKey(pluginSignaturePublicKey); err != nil {
+		return Config{}, fmt.Errorf("validation of environment variable '%v' failed: %w", pluginSignaturePublicKeyKey, err)
+	}
+
+	// PLUGIN_CATALOG_SOURCES
+	const pluginCatalogSourcesKey = "PLUGIN_CATALOG_SOURCES"
+	pluginCatalogSourcesStr, err := getEnvWithDefaultValueIfEmpty(pluginCatalogSourcesKey, "printascii", "")
+	if err != nil {
+		return Config{}, err
+	}
+
+	pluginCatalogSources := make([]string, 0)
+	for _, source := range strings.Split(pluginCatalogSourcesStr, ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			pluginCatalogSources = append(pluginCatalogSources, source)
+		}
+	}
+
+	// RCON_PASSWORD
+	const rconPasswordKey = "RCON_PASSWORD"
+	rconPassword, err := getEnvWithDefaultValueIfEmpty(rconPasswordKey, "alphanum,lte=64", "")
+	if err != nil {
+		return Config{}, err
+	}
+
+	// RCON_PORT
+	const rconPortKey = "RCON_PORT"
+	rconPort, err := getEnvWithDefaultValueIfEmpty(rconPortKey, "port", "")
+	if err != nil {
+		return Config{}, err
+	}
+
+	if rconPort != "" && rconPassword == "" {
+		return Config{}, fmt.Errorf("environment variable '%v' is set but '%v' is empty", rconPortKey, rconPasswordKey)
+	}
+
+	if rconPort != "" && rconPort == csPort {
+		return Config{}, fmt.Errorf("environment variable '%v' can not be the same as '%v'", rconPortKey, csPortKey)
+	}
+
 	//
 	cfg := Config{
 		httpPort,
@@ -227,6 +409,20 @@ func GetConfig() (Config, error) {
 		steamcmdDir,
 		enableWebUi,
 		enableSwagger,
+		autoRestart,
+		autoRestartMaxRestarts,
+		autoRestartWindow,
+		autoRestartInitialBackoff,
+		autoRestartMaxBackoff,
+		updateCheck,
+		updateCheckInterval,
+		autoUpdate,
+		updateSnapshotRetention,
+		backupRetention,
+		pluginSignaturePublicKey,
+		pluginCatalogSources,
+		rconPort,
+		rconPassword,
 		ip,
 		ipSetByEnvironmentVariable,
 	}
@@ -238,6 +434,11 @@ func GetConfig() (Config, error) {
 			continue
 		}
 
+		if v.Type().Field(i).Name == "RconPassword" {
+			slog.Info("config", "RconPassword", "***")
+			continue
+		}
+
 		slog.Info("config", v.Type().Field(i).Name, v.Field(i).Interface())
 	}
 
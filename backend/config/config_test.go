@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func Test_getEnvWithDefaultValueIfEmpty_OK(t *testing.T) {
@@ -45,6 +46,33 @@ func Test_getEnvWithDefaultValueIfEmpty_DefaultValue(t *testing.T) {
 	}
 }
 
+func Test_getDurationEnvWithDefaultValueIfEmpty(t *testing.T) {
+	testEnvKey := "test-env-key"
+
+	defer func(key string) {
+		_ = os.Unsetenv(key)
+	}(testEnvKey)
+
+	value, err := getDurationEnvWithDefaultValueIfEmpty(testEnvKey, "10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != 10*time.Minute {
+		t.Fatalf("unexpected value received '%v'. expected value: '%v'", value, 10*time.Minute)
+	}
+
+	for _, invalidValue := range []string{"abc", "0s", "-5s"} {
+		if err := os.Setenv(testEnvKey, invalidValue); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := getDurationEnvWithDefaultValueIfEmpty(testEnvKey, "10m"); err == nil {
+			t.Fatalf("error expected but nil returned for value '%v'", invalidValue)
+		}
+	}
+}
+
 const IP_KEY = "IP"
 
 func Test_ipSetByEnvironmentVariable_false(t *testing.T) {
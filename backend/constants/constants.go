@@ -39,3 +39,39 @@ const PluginsKey pluginsKeyType = 0
 type editorKeyType uint
 
 const EditorKey editorKeyType = 0
+
+type registryKeyType uint
+
+const RegistryKey registryKeyType = 0
+
+type instanceKeyType uint
+
+const InstanceKey instanceKeyType = 0
+
+type supervisorKeyType uint
+
+const SupervisorKey supervisorKeyType = 0
+
+type profilesKeyType uint
+
+const ProfilesKey profilesKeyType = 0
+
+type mapsKeyType uint
+
+const MapsKey mapsKeyType = 0
+
+type schedulerKeyType uint
+
+const SchedulerKey schedulerKeyType = 0
+
+type updateCheckerKeyType uint
+
+const UpdateCheckerKey updateCheckerKeyType = 0
+
+type snapshotsKeyType uint
+
+const SnapshotsKey snapshotsKeyType = 0
+
+type backupsKeyType uint
+
+const BackupsKey backupsKeyType = 0
@@ -1,46 +1,377 @@
 package download
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func Download(url string, destDir string) (FilePath string, error error) {
-	split := strings.Split(url, "/")
-	fileName := split[len(split)-1]
-	destFilePath := filepath.Join(destDir, fileName)
+var (
+	// ErrUnexpectedContentType is returned if the server responds with a html page instead of a file, e.g. a login or error page
+	ErrUnexpectedContentType = errors.New("unexpected content type")
+	// ErrStalled is returned if no data was received within the timeout
+	ErrStalled = errors.New("download stalled")
+)
+
+const (
+	progressInterval = 500 * time.Millisecond
+	maxBackoff       = time.Minute
+)
+
+type Progress struct {
+	BytesDone int64 `json:"bytes_done"`
+	// 0 if the server did not send the size
+	BytesTotal int64   `json:"bytes_total"`
+	Percent    float64 `json:"percent"`
+}
+
+type Options struct {
+	// an attempt fails if no data is received for this duration, including the wait for the response
+	Timeout time.Duration
+	// number of retries after the first failed attempt. Retries resume the download if the server supports range requests
+	Retries int
+	// wait before the first retry. Doubled for every further retry
+	Backoff time.Duration
+	// called at most every 500ms while the download is running and once it is complete. Can be nil
+	OnProgress func(Progress)
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Timeout: 30 * time.Second,
+		Retries: 3,
+		Backoff: time.Second,
+	}
+}
+
+// statusError is the response status of a failed attempt
+type statusError struct {
+	statusCode int
+	status     string
+}
+
+func (e statusError) Error() string {
+	return "unexpected response status " + e.status
+}
+
+func (e statusError) retryable() bool {
+	return e.statusCode >= 500 || e.statusCode == http.StatusRequestTimeout || e.statusCode == http.StatusTooManyRequests
+}
+
+// permanentError is not retried, e.g. a html page instead of the file or a file that can not be written
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Download downloads the url to the dest dir and returns the path of the downloaded file.
+// The file name is taken from the Content-Disposition header or the last path segment of the url after all redirects.
+// Until the download is complete, the data is written to a .part file
+func Download(ctx context.Context, url string, destDir string, options Options) (string, error) {
+	d := downloader{
+		url:     url,
+		destDir: destDir,
+		options: options,
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := d.attempt(ctx)
+		if err == nil {
+			break
+		}
+
+		if ctx.Err() != nil {
+			d.removePart()
+			return "", fmt.Errorf("failed to download %v: %w", url, context.Cause(ctx))
+		}
+
+		if !isRetryable(err) || attempt >= options.Retries {
+			d.removePart()
+			return "", fmt.Errorf("failed to download %v after %v attempt(s): %w", url, attempt+1, err)
+		}
+
+		backoff := min(options.Backoff<<attempt, maxBackoff)
+		slog.Warn("download failed. Retrying", "url", url, "attempt", attempt+1, "bytes_done", d.received, "backoff", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			d.removePart()
+			return "", fmt.Errorf("failed to download %v: %w", url, context.Cause(ctx))
+		case <-time.After(backoff):
+		}
+	}
+
+	destFilePath := filepath.Join(destDir, d.fileName)
+	if err := os.Rename(d.partPath(), destFilePath); err != nil {
+		d.removePart()
+		return "", fmt.Errorf("failed to move downloaded file: %w", err)
+	}
+
+	return destFilePath, nil
+}
+
+func isRetryable(err error) bool {
+	var statusErr statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.retryable()
+	}
+
+	// an unknown host does not resolve on the next attempt either
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+
+	var permanentErr permanentError
+	return !errors.As(err, &permanentErr)
+}
+
+type downloader struct {
+	url     string
+	destDir string
+	options Options
+
+	// set by the first response
+	fileName string
+	// ETag or Last-Modified of the first response. Sent with range requests, so a changed file is downloaded again from the start
+	validator string
+	received  int64
+	total     int64
+}
+
+func (d *downloader) partPath() string {
+	return filepath.Join(d.destDir, d.fileName+".part")
+}
+
+func (d *downloader) removePart() {
+	if d.fileName == "" {
+		return
+	}
+
+	if err := os.Remove(d.partPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("failed to remove partial download", "path", d.partPath(), "error", err)
+	}
+}
+
+func (d *downloader) attempt(ctx context.Context) error {
+	attemptCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	stalled := fmt.Errorf("%w: no data received for %v", ErrStalled, d.options.Timeout)
+	timer := time.AfterFunc(d.options.Timeout, func() { cancel(stalled) })
+	defer timer.Stop()
+
+	err := d.request(attemptCtx, func() { timer.Reset(d.options.Timeout) })
+	if err != nil && context.Cause(attemptCtx) == stalled {
+		return stalled
+	}
+	return err
+}
 
-	resp, err := http.Get(url)
+// request sends a single request. If a part of the file was already received, only the rest is requested.
+// activity is called whenever data is received
+func (d *downloader) request(ctx context.Context, activity func()) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to download %s: %w", url, err)
+		return permanentError{fmt.Errorf("http.NewRequest: %w", err)}
+	}
+
+	resume := d.received > 0
+	if resume {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%v-", d.received))
+		if d.validator != "" {
+			req.Header.Set("If-Range", d.validator)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
 	}
 	defer func() {
-		err := resp.Body.Close()
-		if err != nil {
-			slog.Warn("resp.Body.Close: finished successfully but failed to close body after download %w", "error", err)
+		if err := resp.Body.Close(); err != nil {
+			slog.Warn("resp.Body.Close: failed to close body after download", "error", err)
 		}
 	}()
+	activity()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && resume:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != d.received {
+			d.received = 0
+			return fmt.Errorf("unexpected content range '%v'", resp.Header.Get("Content-Range"))
+		}
+		d.total = total
+	case resp.StatusCode == http.StatusOK:
+		// the server does not support range requests or the file changed
+		resume = false
+		d.received = 0
+		d.total = max(resp.ContentLength, 0)
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && resume:
+		// the next attempt downloads the whole file again
+		d.received = 0
+		return fmt.Errorf("range of the partial download is not satisfiable")
+	default:
+		return statusError{statusCode: resp.StatusCode, status: resp.Status}
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mediaType == "text/html" {
+		return permanentError{fmt.Errorf("%w: %v", ErrUnexpectedContentType, mediaType)}
+	}
+
+	if d.fileName == "" {
+		d.fileName = fileNameOf(resp)
+		d.validator = resp.Header.Get("ETag")
+		if strings.HasPrefix(d.validator, "W/") {
+			d.validator = ""
+		}
+		if d.validator == "" {
+			d.validator = resp.Header.Get("Last-Modified")
+		}
+	}
 
-	out, err := os.Create(destFilePath)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	out, err := os.OpenFile(d.partPath(), flags, 0644)
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+		return permanentError{fmt.Errorf("failed to create file: %w", err)}
 	}
 	defer func() {
-		err := out.Close()
-		if err != nil {
-			slog.Warn("out.Close: finished successfully but failed to close output file after download %w", "error", err)
+		if err := out.Close(); err != nil {
+			slog.Warn("out.Close: failed to close output file after download", "error", err)
 		}
 	}()
 
-	_, err = io.Copy(out, resp.Body)
+	reporter := progressReporter{onProgress: d.options.OnProgress}
+	buffer := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buffer)
+		if n > 0 {
+			activity()
+			if _, err := out.Write(buffer[:n]); err != nil {
+				return permanentError{fmt.Errorf("failed to write file: %w", err)}
+			}
+			d.received += int64(n)
+			reporter.report(d.received, d.total, false)
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	if d.total > 0 && d.received != d.total {
+		return fmt.Errorf("received %v of %v bytes: %w", d.received, d.total, io.ErrUnexpectedEOF)
+	}
+
+	reporter.report(d.received, d.total, true)
+	return nil
+}
+
+type progressReporter struct {
+	onProgress func(Progress)
+	lastReport time.Time
+}
+
+func (r *progressReporter) report(done int64, total int64, complete bool) {
+	if r.onProgress == nil || (!complete && time.Since(r.lastReport) < progressInterval) {
+		return
+	}
+	r.lastReport = time.Now()
+
+	progress := Progress{BytesDone: done, BytesTotal: total}
+	if total > 0 {
+		progress.Percent = float64(done) / float64(total) * 100
+	} else if complete {
+		progress.Percent = 100
+	}
+	r.onProgress(progress)
+}
+
+// parseContentRange parses the start and the total size of e.g. "bytes 100-199/200". The total is 0 if it is unknown
+func parseContentRange(contentRange string) (int64, int64, bool) {
+	rangeAndTotal, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+
+	byteRange, totalString, ok := strings.Cut(rangeAndTotal, "/")
+	if !ok {
+		return 0, 0, false
+	}
+
+	startString, _, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(startString, 10, 64)
 	if err != nil {
-		return "", fmt.Errorf("failed to create result file: %w", err)
+		return 0, 0, false
 	}
 
-	return destFilePath, nil
+	if totalString == "*" {
+		return start, 0, true
+	}
+
+	total, err := strconv.ParseInt(totalString, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return start, total, true
+}
+
+// fileNameOf returns the file name of the Content-Disposition header or the last path segment of the url after all redirects.
+// Query strings are never part of the file name
+func fileNameOf(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := sanitizeFileName(params["filename"]); name != "" {
+			return name
+		}
+	}
+
+	if resp.Request != nil && resp.Request.URL != nil {
+		if name := sanitizeFileName(resp.Request.URL.Path); name != "" {
+			return name
+		}
+	}
+
+	return "download"
+}
+
+func sanitizeFileName(name string) string {
+	if index := strings.LastIndexAny(name, `/\`); index != -1 {
+		name = name[index+1:]
+	}
+
+	if name == "." || name == ".." {
+		return ""
+	}
+
+	return name
 }
@@ -0,0 +1,250 @@
+package download_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/download"
+	"github.com/google/uuid"
+)
+
+var content = bytes.Repeat([]byte("0123456789"), 10_000)
+
+func createTempDir(t *testing.T) string {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	if err := os.MkdirAll(tempDirPath, os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+	return tempDirPath
+}
+
+func testOptions() download.Options {
+	return download.Options{
+		Timeout: time.Second,
+		Retries: 2,
+		Backoff: time.Millisecond,
+	}
+}
+
+func expectDownloadedFile(t *testing.T, path string, expectedPath string) {
+	t.Helper()
+
+	if path != expectedPath {
+		t.Fatalf("expected path '%v' but got '%v'", expectedPath, path)
+	}
+
+	downloaded, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("os.ReadFile", err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Fatalf("expected %v bytes but got %v bytes", len(content), len(downloaded))
+	}
+
+	if _, err := os.Stat(path + ".part"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected the .part file to be removed but got", err)
+	}
+}
+
+func TestDownload_FileName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			http.Redirect(w, r, "/files/plugin.tar.gz?X-Amz-Signature=abc&response-content-type=application%2Foctet-stream", http.StatusFound)
+		case "/attachment":
+			w.Header().Set("Content-Disposition", `attachment; filename="plugin.zip"`)
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			_, _ = w.Write(content)
+		case "/files/plugin.tar.gz":
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			_, _ = w.Write(content)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	testData := []struct {
+		url      string
+		fileName string
+	}{
+		{url: server.URL + "/latest?token=123", fileName: "plugin.tar.gz"},
+		{url: server.URL + "/attachment?download=1", fileName: "plugin.zip"},
+		{url: server.URL + "/files/plugin.tar.gz?version=1.0.0", fileName: "plugin.tar.gz"},
+	}
+
+	for _, td := range testData {
+		tempDirPath := createTempDir(t)
+
+		var progress []download.Progress
+		options := testOptions()
+		options.OnProgress = func(p download.Progress) {
+			progress = append(progress, p)
+		}
+
+		path, err := download.Download(context.Background(), td.url, tempDirPath, options)
+		if err != nil {
+			t.Fatalf("%v: Download: %v", td.url, err)
+		}
+
+		expectDownloadedFile(t, path, filepath.Join(tempDirPath, td.fileName))
+
+		last := progress[len(progress)-1]
+		if last.BytesDone != int64(len(content)) || last.BytesTotal != int64(len(content)) || last.Percent != 100 {
+			t.Fatalf("%v: expected complete progress but got %+v", td.url, last)
+		}
+
+		if !t.Failed() {
+			_ = os.RemoveAll(tempDirPath)
+		}
+	}
+}
+
+func TestDownload_Resume(t *testing.T) {
+	var requests atomic.Int32
+	var rangeHeader, ifRangeHeader atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if requests.Add(1) == 1 {
+			// the connection is closed after the first half
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				_ = conn.Close()
+			}
+			return
+		}
+
+		rangeHeader.Store(r.Header.Get("Range"))
+		ifRangeHeader.Store(r.Header.Get("If-Range"))
+		http.ServeContent(w, r, "plugin.zip", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tempDirPath := createTempDir(t)
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	path, err := download.Download(context.Background(), server.URL+"/plugin.zip", tempDirPath, testOptions())
+	if err != nil {
+		t.Fatal("Download", err)
+	}
+
+	expectDownloadedFile(t, path, filepath.Join(tempDirPath, "plugin.zip"))
+
+	if requests.Load() != 2 {
+		t.Fatalf("expected 2 requests but got %v", requests.Load())
+	}
+	if rangeHeader.Load() != fmt.Sprintf("bytes=%v-", len(content)/2) || ifRangeHeader.Load() != `"v1"` {
+		t.Fatalf("expected range request of the second half but got Range '%v' If-Range '%v'", rangeHeader.Load(), ifRangeHeader.Load())
+	}
+}
+
+func TestDownload_Retry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	tempDirPath := createTempDir(t)
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	path, err := download.Download(context.Background(), server.URL+"/plugin.zip", tempDirPath, testOptions())
+	if err != nil {
+		t.Fatal("Download", err)
+	}
+	expectDownloadedFile(t, path, filepath.Join(tempDirPath, "plugin.zip"))
+
+	// all retries fail
+	requests.Store(-10)
+	if _, err := download.Download(context.Background(), server.URL+"/plugin.zip", tempDirPath, testOptions()); err == nil || !strings.Contains(err.Error(), "3 attempt(s)") {
+		t.Fatal("expected error after 3 attempts but got", err)
+	}
+}
+
+func TestDownload_Errors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html>login</html>"))
+		case "/stall":
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			_, _ = w.Write(content[:100])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tempDirPath := createTempDir(t)
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	options := testOptions()
+	options.Timeout = 100 * time.Millisecond
+	options.Retries = 1
+
+	testData := []struct {
+		path        string
+		expectedErr error
+		requests    int32
+	}{
+		{path: "/missing.zip", requests: 1},
+		{path: "/html", expectedErr: download.ErrUnexpectedContentType, requests: 1},
+		{path: "/stall", expectedErr: download.ErrStalled, requests: 2},
+	}
+
+	for _, td := range testData {
+		requests.Store(0)
+		_, err := download.Download(context.Background(), server.URL+td.path, tempDirPath, options)
+		if err == nil || (td.expectedErr != nil && !errors.Is(err, td.expectedErr)) {
+			t.Fatalf("%v: expected error %v but got %v", td.path, td.expectedErr, err)
+		}
+
+		if requests.Load() != td.requests {
+			t.Fatalf("%v: expected %v requests but got %v", td.path, td.requests, requests.Load())
+		}
+	}
+
+	// cancelled while the download is running
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := download.Download(ctx, server.URL+"/stall", tempDirPath, options); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled but got", err)
+	}
+
+	// no partial downloads are left behind
+	entries, err := os.ReadDir(tempDirPath)
+	if err != nil {
+		t.Fatal("os.ReadDir", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected empty dir but got %v entries", len(entries))
+	}
+}
@@ -3,16 +3,109 @@ package unzip
 import (
 	"archive/tar"
 	"archive/zip"
+	"bytes"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+var (
+	// ErrUnsafeArchive is returned for entries that would be written outside the target dir, symlinks that point outside of it and device files
+	ErrUnsafeArchive = errors.New("unsafe archive")
+	// ErrLimitExceeded is returned if the archive extracts to more data or files than allowed
+	ErrLimitExceeded = errors.New("archive exceeds the extraction limits")
+	// ErrInvalidArchive is returned if the content of the archive can not be read, e.g. a truncated download or an unsupported entry.
+	// Errors while writing the extracted files are not wrapped
+	ErrInvalidArchive = errors.New("invalid archive")
+)
+
+// Limits protect against archives that expand to a huge amount of data or files (zip bombs)
+type Limits struct {
+	// maximum size of all extracted files in bytes
+	MaxTotalSize int64
+	// maximum number of extracted files, directories and symlinks
+	MaxFiles int
+}
+
+// DefaultLimits are large enough for plugins that ship their own runtime, e.g. CounterStrikeSharp with the .NET runtime
+func DefaultLimits() Limits {
+	return Limits{
+		MaxTotalSize: 2 * 1024 * 1024 * 1024,
+		MaxFiles:     20_000,
+	}
+}
+
+type Format string
+
+const (
+	FormatZip   Format = "zip"
+	FormatTarGz Format = "tar.gz"
+)
+
+var ErrUnknownFormat = errors.New("unknown archive format")
+
+// DetectFormat returns the format of the archive by its magic bytes. The file name is not used, so downloads with any name are supported
+func DetectFormat(archivePath string) (Format, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("os.Open: %w", err)
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	n, err := io.ReadFull(file, magic)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read '%v': %w", archivePath, err)
+	}
+	magic = magic[:n]
+
+	switch {
+	// local file header or the end of central directory of an empty archive
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return FormatTarGz, nil
+	default:
+		return "", fmt.Errorf("%w: '%v' is neither a zip nor a tar.gz archive", ErrUnknownFormat, filepath.Base(archivePath))
+	}
+}
+
+// Extract detects the format of the archive and extracts it to the target dir
+func Extract(archivePath, targetDir string) ([]string, error) {
+	format, err := DetectFormat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatZip:
+		files, err := Zip(archivePath, targetDir)
+		if err != nil {
+			return nil, fmt.Errorf("unzip zip: %w", err)
+		}
+		return files, nil
+	default:
+		files, err := TarGz(archivePath, targetDir)
+		if err != nil {
+			return nil, fmt.Errorf("unzip tar.gz: %w", err)
+		}
+		return files, nil
+	}
+}
+
 func TarGz(gzFilePath, targetDir string) ([]string, error) {
+	return TarGzWithLimits(gzFilePath, targetDir, DefaultLimits())
+}
+
+// TarGzWithLimits extracts the tar.gz archive to the target dir. The archive itself is not removed.
+// Fails with ErrUnsafeArchive, ErrLimitExceeded or ErrInvalidArchive. Files that were already extracted are not removed, except symlinks that point outside the target dir
+func TarGzWithLimits(gzFilePath, targetDir string, limits Limits) ([]string, error) {
 	gzFile, err := os.Open(gzFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open zipped file: %w", err)
@@ -26,7 +119,7 @@ func TarGz(gzFilePath, targetDir string) ([]string, error) {
 
 	gzipReader, err := gzip.NewReader(gzFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip gzipReader: %w", err)
+		return nil, fmt.Errorf("%w: failed to create gzip gzipReader: %w", ErrInvalidArchive, err)
 	}
 
 	defer func() {
@@ -37,17 +130,11 @@ func TarGz(gzFilePath, targetDir string) ([]string, error) {
 
 	tarReader := tar.NewReader(gzipReader)
 
-	filesToClose := make([]*os.File, 0)
-	defer func() {
-		for _, f := range filesToClose {
-			err := f.Close()
-			if err != nil {
-				slog.Warn("UnzipTarGz successful but failed to close file result file", "error", err)
-			}
-		}
-	}()
+	e, err := newExtractor(targetDir, limits)
+	if err != nil {
+		return nil, err
+	}
 
-	extractedFiles := make([]string, 0)
 	for {
 		header, err := tarReader.Next()
 
@@ -56,44 +143,48 @@ func TarGz(gzFilePath, targetDir string) ([]string, error) {
 		}
 
 		if err != nil {
-			return nil, fmt.Errorf("failed to extract targetFile %w", err)
+			return nil, fmt.Errorf("%w: failed to extract targetFile %w", ErrInvalidArchive, err)
 		}
 
-		targetFilePath := filepath.Join(targetDir, header.Name)
-
 		switch header.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(targetFilePath, os.ModePerm); err != nil {
-				return nil, fmt.Errorf("failed to create new directory for targetFile %v %w", header.Name, err)
+			if err := e.dir(header.Name); err != nil {
+				return nil, err
 			}
 		case tar.TypeReg:
-			targetDir := filepath.Dir(targetFilePath)
-			if err := os.MkdirAll(targetDir, os.ModePerm); err != nil {
-				return nil, fmt.Errorf("failed to create new directory for targetFile %v %w", header.Name, err)
+			if err := e.file(header.Name, header.Size, os.ModePerm, tarReader); err != nil {
+				return nil, err
 			}
-
-			targetFile, err := os.OpenFile(targetFilePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.ModePerm)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create result targetFile for %v %w", header.Name, err)
-			}
-			filesToClose = append(filesToClose, targetFile)
-
-			if _, err := io.Copy(targetFile, tarReader); err != nil {
-				return nil, fmt.Errorf("failed to copy zipped file '%v' to destination '%v' %w", header.Name, targetFilePath, err)
+		case tar.TypeSymlink:
+			if err := e.symlink(header.Name, header.Linkname); err != nil {
+				return nil, err
 			}
-			extractedFiles = append(extractedFiles, targetFilePath)
+		case tar.TypeLink, tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			return nil, fmt.Errorf("%w: '%v' is a hard link or device file", ErrUnsafeArchive, header.Name)
+		case tar.TypeXGlobalHeader:
+			// pax headers for the whole archive, e.g. the commit id of archives created by git
+			continue
 		default:
-			return nil, fmt.Errorf("unsupported type: %c in %s", header.Typeflag, header.Name)
+			return nil, fmt.Errorf("%w: unsupported type: %c in %s", ErrInvalidArchive, header.Typeflag, header.Name)
 		}
 	}
 
-	_ = os.Remove(gzFilePath)
-	return extractedFiles, nil
+	return e.result()
 }
 
 func Zip(zipFilePath, targetDir string) ([]string, error) {
+	return ZipWithLimits(zipFilePath, targetDir, DefaultLimits())
+}
+
+// ZipWithLimits extracts the zip archive to the target dir. The archive itself is not removed.
+// Fails with ErrUnsafeArchive, ErrLimitExceeded or ErrInvalidArchive. Files that were already extracted are not removed, except symlinks that point outside the target dir
+func ZipWithLimits(zipFilePath, targetDir string, limits Limits) ([]string, error) {
 	reader, err := zip.OpenReader(zipFilePath)
 	if err != nil {
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) {
+			err = fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
 		return nil, fmt.Errorf("failed to open zip file %w", err)
 	}
 	defer func() {
@@ -102,63 +193,295 @@ func Zip(zipFilePath, targetDir string) ([]string, error) {
 		}
 	}()
 
-	filesToClose := make([]*os.File, 0)
-	zipFilesToClose := make([]io.ReadCloser, 0)
-	defer func() {
-		for _, f := range filesToClose {
-			if err := f.Close(); err != nil {
-				slog.Warn("UnzipTarGz successful but failed to close file result file", "error", err)
-			}
+	// the central directory is checked first, so obvious zip bombs are rejected before anything is written.
+	// The declared sizes are not trusted while extracting
+	if len(reader.File) > limits.MaxFiles {
+		return nil, fmt.Errorf("%w: %v entries, max %v", ErrLimitExceeded, len(reader.File), limits.MaxFiles)
+	}
+
+	var declaredSize uint64
+	for _, f := range reader.File {
+		declaredSize += f.UncompressedSize64
+		if declaredSize > uint64(limits.MaxTotalSize) {
+			return nil, fmt.Errorf("%w: more than %v bytes", ErrLimitExceeded, limits.MaxTotalSize)
 		}
+	}
 
-		for _, closer := range zipFilesToClose {
-			if err := closer.Close(); err != nil {
-				slog.Warn("UnzipTarGz successful but failed to close reader result file", "error", err)
+	e, err := newExtractor(targetDir, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range reader.File {
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := e.dir(f.Name); err != nil {
+				return nil, err
+			}
+		case mode&fs.ModeSymlink != 0:
+			if err := e.zipSymlink(f); err != nil {
+				return nil, err
 			}
+		case mode.IsRegular():
+			if err := e.zipFile(f); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: '%v' is a device file", ErrUnsafeArchive, f.Name)
 		}
-	}()
+	}
+
+	return e.result()
+}
 
-	extractedFiles := make([]string, 0)
+type extractor struct {
+	targetDir string
+	limits    Limits
 
-	for _, f := range reader.File {
-		filePath := filepath.Join(targetDir, f.Name)
-		if !strings.HasPrefix(filePath, filepath.Clean(targetDir)+string(os.PathSeparator)) {
-			return nil, fmt.Errorf("invalid file path: %s", filePath)
+	entries        int
+	totalSize      int64
+	extractedFiles []string
+	symlinks       []string
+}
+
+func newExtractor(targetDir string, limits Limits) (*extractor, error) {
+	targetDir, err := filepath.Abs(targetDir)
+	if err != nil {
+		return nil, fmt.Errorf("filepath.Abs: %w", err)
+	}
+
+	if err := os.MkdirAll(targetDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create target dir '%v' %w", targetDir, err)
+	}
+
+	return &extractor{
+		targetDir:      targetDir,
+		limits:         limits,
+		extractedFiles: make([]string, 0),
+		symlinks:       make([]string, 0),
+	}, nil
+}
+
+// result verifies the extracted symlinks and returns the extracted files. Archives without any files are rejected
+func (e *extractor) result() ([]string, error) {
+	if err := e.verifySymlinks(); err != nil {
+		return nil, err
+	}
+
+	if len(e.extractedFiles) == 0 {
+		return nil, fmt.Errorf("%w: no files extracted", ErrInvalidArchive)
+	}
+
+	return e.extractedFiles, nil
+}
+
+// targetPath returns the path of the entry in the target dir.
+// Entries with absolute paths, paths outside the target dir or paths that contain a symlink are rejected, so nothing is ever written through a symlink
+func (e *extractor) targetPath(name string) (string, error) {
+	e.entries++
+	if e.entries > e.limits.MaxFiles {
+		return "", fmt.Errorf("%w: more than %v entries", ErrLimitExceeded, e.limits.MaxFiles)
+	}
+
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("%w: '%v' is outside of the target dir", ErrUnsafeArchive, name)
+	}
+
+	path := filepath.Join(e.targetDir, filepath.FromSlash(name))
+
+	current := e.targetDir
+	relative, err := filepath.Rel(e.targetDir, path)
+	if err != nil {
+		return "", fmt.Errorf("filepath.Rel: %w", err)
+	}
+	for _, element := range strings.Split(relative, string(os.PathSeparator)) {
+		current = filepath.Join(current, element)
+		info, err := os.Lstat(current)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				break
+			}
+			return "", fmt.Errorf("os.Lstat: %w", err)
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("%w: '%v' is written through a symlink", ErrUnsafeArchive, name)
 		}
+	}
+
+	return path, nil
+}
+
+func (e *extractor) dir(name string) error {
+	path, err := e.targetPath(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory '%v' %w", path, err)
+	}
+
+	return nil
+}
+
+// file writes the content of the entry. The declared size is checked first, but the limit also applies to the content actually read
+func (e *extractor) file(name string, declaredSize int64, mode fs.FileMode, r io.Reader) error {
+	path, err := e.targetPath(name)
+	if err != nil {
+		return err
+	}
+
+	remaining := e.limits.MaxTotalSize - e.totalSize
+	if declaredSize > remaining {
+		return fmt.Errorf("%w: more than %v bytes", ErrLimitExceeded, e.limits.MaxTotalSize)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for file '%v' %w", path, err)
+	}
 
-		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(filePath, os.ModePerm); err != nil {
-				return nil, fmt.Errorf("failed to create directory '%v' %w", filePath, err)
+	destinationFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("failed to open destination file '%v' %w", path, err)
+	}
+
+	// the file is only part of the extracted files if it is written completely, so a partly written file is removed again
+	completed := false
+	defer func() {
+		_ = destinationFile.Close()
+		if !completed {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.Error("failed to remove partly extracted file", "path", path, "error", err)
 			}
-			continue
 		}
+	}()
+
+	written, err := io.Copy(destinationFile, io.LimitReader(archiveReader{r}, remaining+1))
+	if err != nil {
+		return fmt.Errorf("failed to copy '%v' to destination '%v' %w", name, path, err)
+	}
+
+	e.totalSize += written
+	if e.totalSize > e.limits.MaxTotalSize {
+		return fmt.Errorf("%w: more than %v bytes", ErrLimitExceeded, e.limits.MaxTotalSize)
+	}
+
+	if err := destinationFile.Close(); err != nil {
+		return fmt.Errorf("failed to close destination file '%v' %w", path, err)
+	}
+
+	completed = true
+	e.extractedFiles = append(e.extractedFiles, path)
+	return nil
+}
 
-		if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
-			return nil, fmt.Errorf("failed to create directory for file '%v' %w", filePath, err)
+// symlink creates the symlink if its target is inside the target dir.
+// Symlinks can point to other symlinks, so every symlink is resolved again by verifySymlinks after the extraction
+func (e *extractor) symlink(name string, linkname string) error {
+	path, err := e.targetPath(name)
+	if err != nil {
+		return err
+	}
+
+	if filepath.IsAbs(linkname) || !e.isInside(filepath.Join(filepath.Dir(path), linkname)) {
+		return fmt.Errorf("%w: symlink '%v' points to '%v' outside of the target dir", ErrUnsafeArchive, name, linkname)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for symlink '%v' %w", path, err)
+	}
+
+	if err := os.Symlink(linkname, path); err != nil {
+		return fmt.Errorf("failed to create symlink '%v' %w", path, err)
+	}
+
+	e.symlinks = append(e.symlinks, path)
+	e.extractedFiles = append(e.extractedFiles, path)
+	return nil
+}
+
+// verifySymlinks resolves all extracted symlinks. If one of them does not resolve to a path inside the target dir, all extracted symlinks are removed
+func (e *extractor) verifySymlinks() error {
+	if len(e.symlinks) == 0 {
+		return nil
+	}
+
+	realTargetDir, err := filepath.EvalSymlinks(e.targetDir)
+	if err != nil {
+		return fmt.Errorf("filepath.EvalSymlinks: %w", err)
+	}
+
+	for _, symlink := range e.symlinks {
+		resolved, err := filepath.EvalSymlinks(symlink)
+		if err == nil && isInside(realTargetDir, resolved) {
+			continue
 		}
 
-		destinationFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			return nil, fmt.Errorf("failed to open destination file '%v' %w", filePath, err)
+		for _, symlink := range e.symlinks {
+			if err := os.Remove(symlink); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.Error("failed to remove unsafe symlink", "path", symlink, "error", err)
+			}
 		}
-		filesToClose = append(filesToClose, destinationFile)
 
-		zippedFile, err := f.Open()
+		name, relErr := filepath.Rel(e.targetDir, symlink)
+		if relErr != nil {
+			name = symlink
+		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to open zipped file %w", err)
+			return fmt.Errorf("%w: symlink '%v' can not be resolved: %w", ErrUnsafeArchive, name, err)
 		}
-		zipFilesToClose = append(zipFilesToClose, zippedFile)
+		return fmt.Errorf("%w: symlink '%v' resolves to a path outside of the target dir", ErrUnsafeArchive, name)
+	}
 
-		if _, err := io.Copy(destinationFile, zippedFile); err != nil {
-			return nil, fmt.Errorf("failed to copy unzipped file '%v' to destination '%v' %w",
-				f.Name, destinationFile, err)
-		}
-		extractedFiles = append(extractedFiles, filePath)
+	return nil
+}
+
+func (e *extractor) zipFile(f *zip.File) error {
+	zippedFile, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: failed to open zipped file %w", ErrInvalidArchive, err)
+	}
+	defer zippedFile.Close()
+
+	return e.file(f.Name, int64(f.UncompressedSize64), f.Mode(), zippedFile)
+}
+
+// zipSymlink creates the symlink of the entry. Zip archives store the target of a symlink as its content
+func (e *extractor) zipSymlink(f *zip.File) error {
+	zippedFile, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: failed to open zipped file %w", ErrInvalidArchive, err)
 	}
+	defer zippedFile.Close()
 
-	if len(extractedFiles) == 0 {
-		return nil, fmt.Errorf("no files extracted")
+	linkname, err := io.ReadAll(io.LimitReader(archiveReader{zippedFile}, 4096))
+	if err != nil {
+		return fmt.Errorf("failed to read symlink '%v' %w", f.Name, err)
+	}
+
+	return e.symlink(f.Name, string(linkname))
+}
+
+// archiveReader marks read errors of the archive content with ErrInvalidArchive, so they can be told apart from write errors
+type archiveReader struct {
+	r io.Reader
+}
+
+func (r archiveReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %w", ErrInvalidArchive, err)
 	}
+	return n, err
+}
+
+func (e *extractor) isInside(path string) bool {
+	return isInside(e.targetDir, path)
+}
 
-	return extractedFiles, nil
+func isInside(dir string, path string) bool {
+	relative, err := filepath.Rel(dir, path)
+	return err == nil && (relative == "." || filepath.IsLocal(relative))
 }
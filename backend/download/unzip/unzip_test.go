@@ -0,0 +1,440 @@
+package unzip_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/download/unzip"
+	"github.com/google/uuid"
+)
+
+type testEntry struct {
+	name    string
+	mode    fs.FileMode
+	content string
+	// target of symlinks and hard links
+	linkname string
+}
+
+func file(name string, content string) testEntry {
+	return testEntry{name: name, mode: 0644, content: content}
+}
+
+func dir(name string) testEntry {
+	return testEntry{name: name, mode: fs.ModeDir | 0755}
+}
+
+func symlink(name string, linkname string) testEntry {
+	return testEntry{name: name, mode: fs.ModeSymlink | 0777, linkname: linkname}
+}
+
+func createTarGz(t *testing.T, path string, entries []testEntry) {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: int64(entry.mode.Perm()), Linkname: entry.linkname}
+		switch {
+		case entry.mode.IsDir():
+			header.Typeflag = tar.TypeDir
+		case entry.mode&fs.ModeSymlink != 0:
+			header.Typeflag = tar.TypeSymlink
+		case entry.mode&fs.ModeCharDevice != 0:
+			header.Typeflag = tar.TypeChar
+		case entry.mode&fs.ModeNamedPipe != 0:
+			header.Typeflag = tar.TypeFifo
+		case entry.linkname != "":
+			header.Typeflag = tar.TypeLink
+		default:
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(entry.content))
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal("tarWriter.WriteHeader", err)
+		}
+		if _, err := tarWriter.Write([]byte(entry.content)); err != nil {
+			t.Fatal("tarWriter.Write", err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal("tarWriter.Close", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal("gzipWriter.Close", err)
+	}
+	if err := os.WriteFile(path, buffer.Bytes(), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+}
+
+func createZip(t *testing.T, path string, entries []testEntry) {
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		header.SetMode(entry.mode)
+
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			t.Fatal("zipWriter.CreateHeader", err)
+		}
+
+		content := entry.content
+		if entry.mode&fs.ModeSymlink != 0 {
+			content = entry.linkname
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal("writer.Write", err)
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal("zipWriter.Close", err)
+	}
+	if err := os.WriteFile(path, buffer.Bytes(), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+}
+
+type extractFunc func(archivePath string, targetDir string, limits unzip.Limits) ([]string, error)
+
+var formats = []struct {
+	name    string
+	create  func(t *testing.T, path string, entries []testEntry)
+	extract extractFunc
+}{
+	{name: "tar.gz", create: createTarGz, extract: unzip.TarGzWithLimits},
+	{name: "zip", create: createZip, extract: unzip.ZipWithLimits},
+}
+
+var testLimits = unzip.Limits{MaxTotalSize: 1024, MaxFiles: 10}
+
+func createTempDir(t *testing.T) string {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	if err := os.MkdirAll(tempDirPath, os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+	return tempDirPath
+}
+
+func TestExtract(t *testing.T) {
+	for _, format := range formats {
+		tempDirPath := createTempDir(t)
+		archivePath := filepath.Join(tempDirPath, "archive")
+		targetDir := filepath.Join(tempDirPath, "target")
+
+		format.create(t, archivePath, []testEntry{
+			dir("addons/"),
+			file("addons/plugin/plugin.dll", "plugin"),
+			dir("addons/plugin/configs/"),
+			symlink("addons/plugin/current.dll", "plugin.dll"),
+			symlink("addons/configs", "plugin/configs"),
+		})
+
+		files, err := format.extract(archivePath, targetDir, testLimits)
+		if err != nil {
+			t.Fatalf("%v: extract: %v", format.name, err)
+		}
+
+		expectedFiles := []string{
+			filepath.Join(targetDir, "addons", "configs"),
+			filepath.Join(targetDir, "addons", "plugin", "current.dll"),
+			filepath.Join(targetDir, "addons", "plugin", "plugin.dll"),
+		}
+		slices.Sort(files)
+		if !slices.Equal(files, expectedFiles) {
+			t.Fatalf("%v: expected files %v but got %v", format.name, expectedFiles, files)
+		}
+
+		content, err := os.ReadFile(filepath.Join(targetDir, "addons", "plugin", "current.dll"))
+		if err != nil || string(content) != "plugin" {
+			t.Fatalf("%v: expected symlink to plugin.dll but got '%v' %v", format.name, string(content), err)
+		}
+
+		// the archive itself is kept
+		if _, err := os.Stat(archivePath); err != nil {
+			t.Fatalf("%v: expected archive to be kept but got %v", format.name, err)
+		}
+
+		if !t.Failed() {
+			_ = os.RemoveAll(tempDirPath)
+		}
+	}
+}
+
+func TestExtract_Malicious(t *testing.T) {
+	bomb := strings.Repeat("0", 1025)
+	many := make([]testEntry, 0)
+	for i := range 11 {
+		many = append(many, file(fmt.Sprintf("file%v.txt", i), "file"))
+	}
+
+	testData := []struct {
+		name        string
+		entries     []testEntry
+		expectedErr error
+		// formats that can not contain the entries
+		skip []string
+	}{
+		{name: "parent dir", entries: []testEntry{file("../evil.txt", "evil")}, expectedErr: unzip.ErrUnsafeArchive},
+		{name: "nested parent dir", entries: []testEntry{file("addons/../../evil.txt", "evil")}, expectedErr: unzip.ErrUnsafeArchive},
+		{name: "absolute path", entries: []testEntry{file("/evil.txt", "evil")}, expectedErr: unzip.ErrUnsafeArchive},
+		{name: "parent dir directory", entries: []testEntry{dir("../evil/")}, expectedErr: unzip.ErrUnsafeArchive},
+		{name: "absolute symlink", entries: []testEntry{symlink("evil", "/etc")}, expectedErr: unzip.ErrUnsafeArchive},
+		{name: "relative symlink", entries: []testEntry{symlink("addons/evil", "../../evil.txt")}, expectedErr: unzip.ErrUnsafeArchive},
+		{
+			name:        "write through symlink",
+			entries:     []testEntry{dir("addons/"), symlink("link", "addons"), file("link/evil.txt", "evil")},
+			expectedErr: unzip.ErrUnsafeArchive,
+		},
+		{
+			name:        "overwrite symlink",
+			entries:     []testEntry{file("plugin.dll", "plugin"), symlink("link", "plugin.dll"), file("link", "evil")},
+			expectedErr: unzip.ErrUnsafeArchive,
+		},
+		{
+			// every symlink points inside on its own, but resolved together they point outside
+			name:        "symlink chain",
+			entries:     []testEntry{symlink("self", "."), symlink("evil", "self/..")},
+			expectedErr: unzip.ErrUnsafeArchive,
+		},
+		{name: "dangling symlink", entries: []testEntry{symlink("evil", "missing/file")}, expectedErr: unzip.ErrUnsafeArchive},
+		{name: "hard link", entries: []testEntry{{name: "evil", linkname: "/etc/passwd"}}, expectedErr: unzip.ErrUnsafeArchive, skip: []string{"zip"}},
+		{name: "char device", entries: []testEntry{{name: "evil", mode: fs.ModeDevice | fs.ModeCharDevice | 0644}}, expectedErr: unzip.ErrUnsafeArchive},
+		{name: "fifo", entries: []testEntry{{name: "evil", mode: fs.ModeNamedPipe | 0644}}, expectedErr: unzip.ErrUnsafeArchive},
+		{name: "total size", entries: []testEntry{file("bomb.txt", bomb)}, expectedErr: unzip.ErrLimitExceeded},
+		{name: "total size of multiple files", entries: []testEntry{file("a.txt", bomb[:600]), file("b.txt", bomb[:600])}, expectedErr: unzip.ErrLimitExceeded},
+		{name: "file count", entries: many, expectedErr: unzip.ErrLimitExceeded},
+	}
+
+	for _, format := range formats {
+		for _, td := range testData {
+			if slices.Contains(td.skip, format.name) {
+				continue
+			}
+
+			tempDirPath := createTempDir(t)
+			archivePath := filepath.Join(tempDirPath, "archive")
+			targetDir := filepath.Join(tempDirPath, "dir", "target")
+
+			format.create(t, archivePath, td.entries)
+
+			_, err := format.extract(archivePath, targetDir, testLimits)
+			if !errors.Is(err, td.expectedErr) {
+				t.Fatalf("%v %v: expected %v but got %v", format.name, td.name, td.expectedErr, err)
+			}
+
+			// nothing is written outside the target dir and no symlink that points outside is left behind
+			if _, err := os.Lstat(filepath.Join(tempDirPath, "dir", "evil.txt")); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("%v %v: file outside of the target dir was written", format.name, td.name)
+			}
+			if _, err := os.Lstat(filepath.Join(tempDirPath, "dir", "evil")); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("%v %v: directory outside of the target dir was created", format.name, td.name)
+			}
+			if _, err := os.Lstat(filepath.Join(targetDir, "evil")); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("%v %v: unsafe entry was extracted", format.name, td.name)
+			}
+
+			if !t.Failed() {
+				_ = os.RemoveAll(tempDirPath)
+			}
+		}
+	}
+}
+
+func TestZip_Bomb(t *testing.T) {
+	tempDirPath := createTempDir(t)
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	// compresses to a few kilobytes
+	archivePath := filepath.Join(tempDirPath, "bomb.zip")
+	createZip(t, archivePath, []testEntry{file("bomb.txt", strings.Repeat("0", 10*1024*1024))})
+
+	targetDir := filepath.Join(tempDirPath, "target")
+	if _, err := unzip.ZipWithLimits(archivePath, targetDir, unzip.Limits{MaxTotalSize: 1024 * 1024, MaxFiles: 10}); !errors.Is(err, unzip.ErrLimitExceeded) {
+		t.Fatal("expected ErrLimitExceeded but got", err)
+	}
+
+	// rejected before anything is written
+	if _, err := os.Stat(filepath.Join(targetDir, "bomb.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected bomb.txt to not exist but got", err)
+	}
+}
+
+func TestExtract_DetectFormat(t *testing.T) {
+	for _, format := range formats {
+		tempDirPath := createTempDir(t)
+
+		// downloads of urls with query strings or redirects have no archive suffix
+		archivePath := filepath.Join(tempDirPath, "download?token=123")
+		format.create(t, archivePath, []testEntry{file("addons/plugin.dll", "plugin")})
+
+		if detected, err := unzip.DetectFormat(archivePath); err != nil || string(detected) != format.name {
+			t.Fatalf("%v: expected format %v but got '%v' %v", format.name, format.name, detected, err)
+		}
+
+		files, err := unzip.Extract(archivePath, filepath.Join(tempDirPath, "target"))
+		if err != nil {
+			t.Fatalf("%v: Extract: %v", format.name, err)
+		}
+		if !slices.Equal(files, []string{filepath.Join(tempDirPath, "target", "addons", "plugin.dll")}) {
+			t.Fatalf("%v: unexpected files %v", format.name, files)
+		}
+
+		if !t.Failed() {
+			_ = os.RemoveAll(tempDirPath)
+		}
+	}
+
+	tempDirPath := createTempDir(t)
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	htmlPath := filepath.Join(tempDirPath, "plugin.zip")
+	if err := os.WriteFile(htmlPath, []byte("<html>login</html>"), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+	if _, err := unzip.Extract(htmlPath, filepath.Join(tempDirPath, "target")); !errors.Is(err, unzip.ErrUnknownFormat) {
+		t.Fatal("expected ErrUnknownFormat but got", err)
+	}
+}
+
+func TestExtract_Invalid(t *testing.T) {
+	for _, format := range formats {
+		tempDirPath := createTempDir(t)
+		archivePath := filepath.Join(tempDirPath, "archive")
+		format.create(t, archivePath, []testEntry{file("addons/plugin.dll", strings.Repeat("plugin", 100))})
+
+		content, err := os.ReadFile(archivePath)
+		if err != nil {
+			t.Fatal("os.ReadFile", err)
+		}
+
+		// a truncated archive is not valid
+		truncatedPath := filepath.Join(tempDirPath, "truncated")
+		if err := os.WriteFile(truncatedPath, content[:len(content)/2], os.ModePerm); err != nil {
+			t.Fatal("os.WriteFile", err)
+		}
+
+		if _, err := format.extract(truncatedPath, filepath.Join(tempDirPath, "truncated_target"), testLimits); !errors.Is(err, unzip.ErrInvalidArchive) {
+			t.Fatalf("%v: expected ErrInvalidArchive but got %v", format.name, err)
+		}
+
+		// errors while writing the files are not caused by the archive
+		targetDir := filepath.Join(tempDirPath, "target")
+		if err := os.MkdirAll(targetDir, os.ModePerm); err != nil {
+			t.Fatal("os.MkdirAll", err)
+		}
+		if err := os.WriteFile(filepath.Join(targetDir, "addons"), []byte("not a directory"), os.ModePerm); err != nil {
+			t.Fatal("os.WriteFile", err)
+		}
+
+		_, err = format.extract(archivePath, targetDir, testLimits)
+		if err == nil || errors.Is(err, unzip.ErrInvalidArchive) {
+			t.Fatalf("%v: expected write error but got %v", format.name, err)
+		}
+
+		if !t.Failed() {
+			_ = os.RemoveAll(tempDirPath)
+		}
+	}
+}
+
+func TestExtract_PartlyWrittenFile(t *testing.T) {
+	content := make([]byte, 0)
+	for i := range 2000 {
+		content = fmt.Appendf(content, "%v", i*i)
+	}
+
+	tempDirPath := createTempDir(t)
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	// the content of the tar.gz archive ends in the middle of the file
+	tarGzPath := filepath.Join(tempDirPath, "archive.tar.gz")
+	createTarGz(t, tarGzPath, []testEntry{file("addons/plugin.dll", string(content))})
+	tarGzContent, err := os.ReadFile(tarGzPath)
+	if err != nil {
+		t.Fatal("os.ReadFile", err)
+	}
+	if err := os.WriteFile(tarGzPath, tarGzContent[:len(tarGzContent)/2], os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	// the zip archive contains more data than declared
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	writer, err := zipWriter.CreateRaw(&zip.FileHeader{
+		Name:               "addons/plugin.dll",
+		Method:             zip.Store,
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: 10,
+	})
+	if err != nil {
+		t.Fatal("zipWriter.CreateRaw", err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		t.Fatal("writer.Write", err)
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal("zipWriter.Close", err)
+	}
+	zipPath := filepath.Join(tempDirPath, "archive.zip")
+	if err := os.WriteFile(zipPath, buffer.Bytes(), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	limits := unzip.Limits{MaxTotalSize: int64(len(content) * 2), MaxFiles: 10}
+	for _, format := range formats {
+		archivePath := tarGzPath
+		if format.name == "zip" {
+			archivePath = zipPath
+		}
+
+		targetDir := filepath.Join(tempDirPath, format.name)
+		if _, err := format.extract(archivePath, targetDir, limits); !errors.Is(err, unzip.ErrInvalidArchive) {
+			t.Fatalf("%v: expected ErrInvalidArchive but got %v", format.name, err)
+		}
+
+		if _, err := os.Stat(filepath.Join(targetDir, "addons", "plugin.dll")); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%v: expected partly written file to be removed but got %v", format.name, err)
+		}
+	}
+}
+
+func TestExtract_Empty(t *testing.T) {
+	for _, format := range formats {
+		tempDirPath := createTempDir(t)
+		archivePath := filepath.Join(tempDirPath, "archive")
+
+		format.create(t, archivePath, []testEntry{dir("addons/")})
+		if _, err := format.extract(archivePath, filepath.Join(tempDirPath, "target"), testLimits); !errors.Is(err, unzip.ErrInvalidArchive) {
+			t.Fatalf("%v: expected ErrInvalidArchive but got %v", format.name, err)
+		}
+
+		format.create(t, archivePath, nil)
+		if _, err := format.extract(archivePath, filepath.Join(tempDirPath, "target"), testLimits); !errors.Is(err, unzip.ErrInvalidArchive) {
+			t.Fatalf("%v: expected ErrInvalidArchive but got %v", format.name, err)
+		}
+
+		if !t.Failed() {
+			_ = os.RemoveAll(tempDirPath)
+		}
+	}
+}
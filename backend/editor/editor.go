@@ -80,6 +80,11 @@ func (i *Instance) GetAllEditableFiles() ([]string, error) {
 	return result, nil
 }
 
+// CanBeEdited reports whether the file is editable. The path is relative to the server dir
+func (i *Instance) CanBeEdited(path string) bool {
+	return i.fileCanBeEdited(path)
+}
+
 func (i *Instance) fileCanBeEdited(path string) bool {
 	for _, f := range i.filesToEdit {
 		if f.Extensions == nil {
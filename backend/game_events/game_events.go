@@ -15,8 +15,16 @@ type PlayerConnected struct {
 	Port uint16
 }
 
+// MatchEnded is detected via the "Game Over" log line that is printed after the last round of a match
+type MatchEnded struct {
+	GameMode string
+	Map      string
+	Score    string
+}
+
 type Instance struct {
 	onMapChanged         event.InstanceWithData[string]
+	onMatchEnded         event.InstanceWithData[MatchEnded]
 	onPlayerConnected    event.InstanceWithData[PlayerConnected]
 	onPlayerDisconnected event.InstanceWithData[string]
 }
@@ -25,6 +33,10 @@ func (g *Instance) OnMapChanged(handler func(p event.PayloadWithData[string])) {
 	g.onMapChanged.Register(handler)
 }
 
+func (g *Instance) OnMatchEnded(handler func(p event.PayloadWithData[MatchEnded])) {
+	g.onMatchEnded.Register(handler)
+}
+
 func (g *Instance) OnPlayerConnected(handler func(p event.PayloadWithData[PlayerConnected])) {
 	g.onPlayerConnected.Register(handler)
 }
@@ -35,6 +47,7 @@ func (g *Instance) OnPlayerDisconnected(handler func(p event.PayloadWithData[str
 
 func (g *Instance) DetectGameEvent(msg string) {
 	g.detectMapChange(msg)
+	g.detectMatchEnded(msg)
 	g.detectPlayerConnected(msg)
 	g.detectPlayerDisconnected(msg)
 }
@@ -55,6 +68,27 @@ func (g *Instance) detectMapChange(msg string) {
 	g.onMapChanged.Trigger(groups[1])
 }
 
+func (g *Instance) detectMatchEnded(msg string) {
+	// the map group is optional. Without map group, the game mode and the map are separated by two spaces
+	regexExpr := `Game Over: (\S+)\s+(?:\S+\s+)?(\S+) score (\d+:\d+)`
+	r, err := regexp.Compile(regexExpr)
+	if err != nil {
+		slog.Error("regex is not valid", "regex_expr", regexExpr)
+		return
+	}
+
+	groups := r.FindStringSubmatch(msg)
+	if len(groups) != 4 {
+		return
+	}
+
+	g.onMatchEnded.Trigger(MatchEnded{
+		GameMode: groups[1],
+		Map:      groups[2],
+		Score:    groups[3],
+	})
+}
+
 func (g *Instance) detectPlayerConnected(msg string) {
 	regexExpr := `CServerSideClientBase::Connect\( name='(.+)', userid=(\d), fake=\d, chan->addr=(\d{1,3}.\d{1,3}.\d{1,3}.\d{1,3}):(\d{1,5}) \)`
 	r, err := regexp.Compile(regexExpr)
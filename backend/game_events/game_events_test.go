@@ -71,3 +71,25 @@ func TestDetectGameEvent_PlayerDisconnected_Ok(t *testing.T) {
 		t.Fatalf("test failed. OnPlayerConnected not triggered. Message: %v", msg)
 	}
 }
+
+func TestDetectGameEvent_MatchEnded_Ok(t *testing.T) {
+	testData := map[string]string{
+		"Game Over: competitive mg_active de_inferno score 13:11 after 41 min": "de_inferno",
+		"Game Over: wingman  de_vertigo score 9:4 after 18 min":                "de_vertigo",
+	}
+
+	for msg, expectedMap := range testData {
+		ge := game_events.Instance{}
+		var matchEnded *game_events.MatchEnded
+		ge.OnMatchEnded(func(p event.PayloadWithData[game_events.MatchEnded]) { matchEnded = &p.Data })
+
+		ge.DetectGameEvent(msg)
+		if matchEnded == nil {
+			t.Fatalf("test failed. OnMatchEnded not triggered. Message: %v", msg)
+		}
+
+		if matchEnded.Map != expectedMap {
+			t.Fatalf("test failed. Expected map %v but got %v. Message: %v", expectedMap, matchEnded.Map, msg)
+		}
+	}
+}
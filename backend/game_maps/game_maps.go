@@ -0,0 +1,247 @@
+package game_maps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/game_events"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+)
+
+// ChangeTimeout is the time the server gets to load the new map after the changelevel command was sent
+const ChangeTimeout = time.Second * 60
+
+// OfficialMaps are shipped with the game server and can always be used with changelevel
+var OfficialMaps = []string{
+	"ar_baggage",
+	"ar_pool_day",
+	"ar_shoots",
+	"cs_italy",
+	"cs_office",
+	"de_ancient",
+	"de_anubis",
+	"de_dust2",
+	"de_inferno",
+	"de_mirage",
+	"de_nuke",
+	"de_overpass",
+	"de_train",
+	"de_vertigo",
+}
+
+// vpk files in the maps folder that are not playable maps
+var nonPlayableMaps = []string{
+	"graphics_settings",
+	"lobby_mapveto",
+}
+
+var (
+	ErrMapNotFound        = errors.New("map not found")
+	ErrChangeInProgress   = errors.New("map change already in progress")
+	ErrChangeTimeout      = errors.New("timeout reached while waiting for map change")
+	ErrServerNotStarted   = errors.New("server is not started")
+	ErrRotationNotEnabled = errors.New("map rotation is not enabled")
+)
+
+type Server interface {
+	IsRunning() bool
+	SendCommand(command string) (string, error)
+}
+
+type Map struct {
+	Name      string
+	Official  bool
+	Installed bool
+}
+
+type Instance struct {
+	serverDir        string
+	rotationJsonPath string
+	server           Server
+
+	changeLock sync.Mutex
+
+	waiterLock sync.Mutex
+	waiter     chan string
+
+	rotationLock      sync.Mutex
+	onRotationApplied event.InstanceWithData[string]
+}
+
+func New(serverDir string, rotationJsonPath string, server Server, gameEvents *game_events.Instance) (*Instance, error) {
+	if err := gvalidator.Instance().Var(serverDir, "required,dir"); err != nil {
+		return nil, fmt.Errorf("serverDir validation: %w", err)
+	}
+
+	if err := gvalidator.Instance().Var(rotationJsonPath, "required,filepath"); err != nil {
+		return nil, fmt.Errorf("rotationJsonPath validation: %w", err)
+	}
+
+	i := &Instance{
+		serverDir:        serverDir,
+		rotationJsonPath: rotationJsonPath,
+		server:           server,
+	}
+
+	gameEvents.OnMapChanged(func(p event.PayloadWithData[string]) {
+		i.waiterLock.Lock()
+		defer i.waiterLock.Unlock()
+
+		if i.waiter == nil {
+			return
+		}
+
+		// never block the game events. If nobody is reading, the map change is not of interest
+		select {
+		case i.waiter <- p.Data:
+		default:
+		}
+	})
+
+	return i, nil
+}
+
+// OnRotationApplied is triggered with the next map of the rotation after it was set on the server
+func (m *Instance) OnRotationApplied(handler func(p event.PayloadWithData[string])) {
+	m.onRotationApplied.Register(handler)
+}
+
+func (m *Instance) mapsDir() string {
+	return filepath.Join(m.serverDir, "game", "csgo", "maps")
+}
+
+// List returns all official maps and all maps found in the maps folder of the server, sorted by name
+func (m *Instance) List() ([]Map, error) {
+	installed, err := m.installedMaps()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Map, 0, len(OfficialMaps)+len(installed))
+	for _, name := range OfficialMaps {
+		result = append(result, Map{
+			Name:      name,
+			Official:  true,
+			Installed: slices.Contains(installed, name),
+		})
+	}
+
+	for _, name := range installed {
+		if slices.Contains(OfficialMaps, name) {
+			continue
+		}
+
+		result = append(result, Map{
+			Name:      name,
+			Official:  false,
+			Installed: true,
+		})
+	}
+
+	slices.SortFunc(result, func(a, b Map) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return result, nil
+}
+
+func (m *Instance) installedMaps() ([]string, error) {
+	entries, err := os.ReadDir(m.mapsDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("os.ReadDir: %w", err)
+	}
+
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".vpk" {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ".vpk")
+		if strings.HasSuffix(name, "_vanity") || slices.Contains(nonPlayableMaps, name) {
+			continue
+		}
+
+		if err := gvalidator.Instance().Var(name, "map_name"); err != nil {
+			continue
+		}
+
+		result = append(result, name)
+	}
+
+	return result, nil
+}
+
+func (m *Instance) isKnown(mapName string) (bool, error) {
+	maps, err := m.List()
+	if err != nil {
+		return false, err
+	}
+
+	return slices.ContainsFunc(maps, func(gameMap Map) bool {
+		return gameMap.Name == mapName
+	}), nil
+}
+
+func (m *Instance) setWaiter(waiter chan string) {
+	m.waiterLock.Lock()
+	defer m.waiterLock.Unlock()
+	m.waiter = waiter
+}
+
+// Change sends the changelevel command and waits until the server reports the map change
+func (m *Instance) Change(mapName string, timeout time.Duration) error {
+	if err := gvalidator.Instance().Var(mapName, "map_name"); err != nil {
+		return fmt.Errorf("%w: map name '%v' is not valid", ErrMapNotFound, mapName)
+	}
+
+	isKnown, err := m.isKnown(mapName)
+	if err != nil {
+		return fmt.Errorf("list maps: %w", err)
+	}
+
+	if !isKnown {
+		return fmt.Errorf("%w: '%v'", ErrMapNotFound, mapName)
+	}
+
+	if !m.server.IsRunning() {
+		return ErrServerNotStarted
+	}
+
+	if !m.changeLock.TryLock() {
+		return ErrChangeInProgress
+	}
+	defer m.changeLock.Unlock()
+
+	changed := make(chan string, 8)
+	m.setWaiter(changed)
+	defer m.setWaiter(nil)
+
+	if _, err := m.server.SendCommand("changelevel " + mapName); err != nil {
+		return fmt.Errorf("send changelevel command: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case changedTo := <-changed:
+			if strings.EqualFold(changedTo, mapName) {
+				return nil
+			}
+		case <-timer.C:
+			return fmt.Errorf("%w: '%v'", ErrChangeTimeout, mapName)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package game_maps_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/game_events"
+	"github.com/Phi-S/cs-server-manager/game_maps"
+	"github.com/google/uuid"
+)
+
+type fakeServer struct {
+	lock       sync.Mutex
+	commands   []string
+	gameEvents *game_events.Instance
+	// if set, the server prints the changelevel output for the given command
+	changelevelOutput func(mapName string) string
+}
+
+func (f *fakeServer) IsRunning() bool {
+	return true
+}
+
+func (f *fakeServer) SendCommand(command string) (string, error) {
+	f.lock.Lock()
+	f.commands = append(f.commands, command)
+	f.lock.Unlock()
+
+	if mapName, ok := strings.CutPrefix(command, "changelevel "); ok && f.changelevelOutput != nil {
+		f.gameEvents.DetectGameEvent(f.changelevelOutput(mapName))
+	}
+
+	return "", nil
+}
+
+func (f *fakeServer) Commands() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return slices.Clone(f.commands)
+}
+
+func createTestMaps(t *testing.T, srv *fakeServer) (*game_maps.Instance, string) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_game_maps_test_%v", uuid.New()))
+	mapsDir := filepath.Join(tempDirPath, "server", "game", "csgo", "maps")
+	if err := os.MkdirAll(mapsDir, os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll maps dir", err)
+	}
+
+	for _, name := range []string{"de_dust2.vpk", "de_dust2_vanity.vpk", "graphics_settings.vpk", "aim_map.vpk", "readme.txt"} {
+		if err := os.WriteFile(filepath.Join(mapsDir, name), []byte{}, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv.gameEvents = &game_events.Instance{}
+	m, err := game_maps.New(filepath.Join(tempDirPath, "server"), filepath.Join(tempDirPath, "map-rotation.json"), srv, srv.gameEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, tempDirPath
+}
+
+func TestInstance_List(t *testing.T) {
+	m, tempDirPath := createTestMaps(t, &fakeServer{})
+
+	maps, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(maps) != len(game_maps.OfficialMaps)+1 {
+		t.Fatalf("expected all official maps and aim_map but got %+v", maps)
+	}
+
+	for _, gameMap := range maps {
+		switch gameMap.Name {
+		case "de_dust2":
+			if !gameMap.Official || !gameMap.Installed {
+				t.Fatalf("de_dust2 should be official and installed. %+v", gameMap)
+			}
+		case "de_mirage":
+			if !gameMap.Official || gameMap.Installed {
+				t.Fatalf("de_mirage should be official but not installed. %+v", gameMap)
+			}
+		case "aim_map":
+			if gameMap.Official || !gameMap.Installed {
+				t.Fatalf("aim_map should be installed but not official. %+v", gameMap)
+			}
+		}
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Change(t *testing.T) {
+	srv := &fakeServer{
+		changelevelOutput: func(mapName string) string {
+			return fmt.Sprintf("Host activate: Changelevel (%v)", mapName)
+		},
+	}
+	m, tempDirPath := createTestMaps(t, srv)
+
+	if err := m.Change("aim_map", time.Second*5); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(srv.Commands(), []string{"changelevel aim_map"}) {
+		t.Fatalf("unexpected commands %q", srv.Commands())
+	}
+
+	if err := m.Change("de_unknown", time.Second*5); !errors.Is(err, game_maps.ErrMapNotFound) {
+		t.Fatal("expected ErrMapNotFound but got", err)
+	}
+
+	if err := m.Change("de_dust2;quit", time.Second*5); !errors.Is(err, game_maps.ErrMapNotFound) {
+		t.Fatal("expected ErrMapNotFound but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Change_Timeout(t *testing.T) {
+	m, tempDirPath := createTestMaps(t, &fakeServer{})
+
+	if err := m.Change("de_dust2", time.Millisecond*100); !errors.Is(err, game_maps.ErrChangeTimeout) {
+		t.Fatal("expected ErrChangeTimeout but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_ApplyRotation(t *testing.T) {
+	srv := &fakeServer{}
+	m, tempDirPath := createTestMaps(t, srv)
+
+	if _, err := m.ApplyRotation("de_dust2"); !errors.Is(err, game_maps.ErrRotationNotEnabled) {
+		t.Fatal("expected ErrRotationNotEnabled but got", err)
+	}
+
+	if err := m.SetRotation(game_maps.Rotation{Enabled: true, Maps: []string{"de_dust2", "de_mirage", "de_inferno"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	testData := map[string]string{
+		"de_dust2":   "de_mirage",
+		"de_inferno": "de_dust2",
+		"aim_map":    "de_dust2",
+	}
+
+	for currentMap, expectedNextMap := range testData {
+		nextMap, err := m.ApplyRotation(currentMap)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if nextMap != expectedNextMap {
+			t.Fatalf("expected next map %v after %v but got %v", expectedNextMap, currentMap, nextMap)
+		}
+
+		commands := srv.Commands()
+		if commands[len(commands)-1] != "nextlevel "+expectedNextMap {
+			t.Fatalf("nextlevel not set. commands: %q", commands)
+		}
+	}
+
+	if err := m.SetRotation(game_maps.Rotation{Enabled: true, Maps: []string{}}); err == nil {
+		t.Fatal("error expected for enabled rotation without maps but nil returned")
+	}
+
+	if err := m.SetRotation(game_maps.Rotation{Enabled: true, Maps: []string{"de_dust2; quit"}}); err == nil {
+		t.Fatal("error expected for invalid map name but nil returned")
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
@@ -0,0 +1,112 @@
+package game_maps
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+)
+
+// Rotation is the list of maps the server cycles through.
+// After each match the next map of the rotation is set via the nextlevel convar,
+// so the server changes to it after the match end scoreboard
+type Rotation struct {
+	Enabled bool     `json:"enabled"`
+	Maps    []string `json:"maps" validate:"lte=64,dive,map_name"`
+}
+
+func (m *Instance) readRotation() (Rotation, error) {
+	content, err := os.ReadFile(m.rotationJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Rotation{Enabled: false, Maps: []string{}}, nil
+		}
+		return Rotation{}, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	var rotation Rotation
+	if err := json.Unmarshal(content, &rotation); err != nil {
+		return Rotation{}, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if err := gvalidator.Instance().Struct(rotation); err != nil {
+		return Rotation{}, fmt.Errorf("rotation validation: %w", err)
+	}
+
+	return rotation, nil
+}
+
+func (m *Instance) Rotation() (Rotation, error) {
+	m.rotationLock.Lock()
+	defer m.rotationLock.Unlock()
+	return m.readRotation()
+}
+
+func (m *Instance) SetRotation(rotation Rotation) error {
+	if err := gvalidator.Instance().Struct(rotation); err != nil {
+		return fmt.Errorf("rotation validation: %w", err)
+	}
+
+	if rotation.Enabled && len(rotation.Maps) == 0 {
+		return errors.New("rotation validation: an enabled rotation requires at least one map")
+	}
+
+	if rotation.Maps == nil {
+		rotation.Maps = []string{}
+	}
+
+	m.rotationLock.Lock()
+	defer m.rotationLock.Unlock()
+
+	jsonContent, err := json.MarshalIndent(rotation, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
+	if err := os.WriteFile(m.rotationJsonPath, jsonContent, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
+
+// NextMap returns the map after the current map. If the current map is not part of the rotation, the rotation starts from the beginning
+func (r Rotation) NextMap(currentMap string) string {
+	if len(r.Maps) == 0 {
+		return ""
+	}
+
+	i := slices.IndexFunc(r.Maps, func(mapName string) bool {
+		return strings.EqualFold(mapName, currentMap)
+	})
+
+	return r.Maps[(i+1)%len(r.Maps)]
+}
+
+// ApplyRotation sets the map after the current map as next level. Returns ErrRotationNotEnabled if the rotation is disabled
+func (m *Instance) ApplyRotation(currentMap string) (string, error) {
+	rotation, err := m.Rotation()
+	if err != nil {
+		return "", err
+	}
+
+	if !rotation.Enabled || len(rotation.Maps) == 0 {
+		return "", ErrRotationNotEnabled
+	}
+
+	if !m.server.IsRunning() {
+		return "", ErrServerNotStarted
+	}
+
+	nextMap := rotation.NextMap(currentMap)
+	if _, err := m.server.SendCommand("nextlevel " + nextMap); err != nil {
+		return "", fmt.Errorf("send nextlevel command: %w", err)
+	}
+
+	m.onRotationApplied.Trigger(nextMap)
+	return nextMap, nil
+}
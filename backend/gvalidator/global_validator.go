@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/go-playground/validator/v10/non-standard/validators"
 	"reflect"
+	"regexp"
 	"strconv"
 
 	"github.com/go-playground/validator/v10"
@@ -12,6 +13,30 @@ import (
 var instance = validator.New(validator.WithRequiredStructEnabled())
 var customTagsRegistered = false
 
+// GameModePreset are the game_type and game_mode convars the server is started with
+type GameModePreset struct {
+	GameType int
+	GameMode int
+}
+
+// GameModePresets are all supported game modes. The game_mode tag accepts the names of the presets
+// https://developer.valvesoftware.com/wiki/Counter-Strike_2/Dedicated_Servers#Game_Modes
+var GameModePresets = map[string]GameModePreset{
+	"casual":      {GameType: 0, GameMode: 0},
+	"competitive": {GameType: 0, GameMode: 1},
+	"wingman":     {GameType: 0, GameMode: 2},
+	"armsrace":    {GameType: 1, GameMode: 0},
+	"demolition":  {GameType: 1, GameMode: 1},
+	"deathmatch":  {GameType: 1, GameMode: 2},
+}
+
+var mapNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]{1,64}$`)
+
+var steamBranchRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// printable ascii without space, '"' and '+'. steamcmd would interpret them as separate arguments or commands
+var steamBranchPasswordRegex = regexp.MustCompile(`^[!#-*,-~]{1,64}$`)
+
 func Instance() *validator.Validate {
 	err := RegisterCustomTags()
 	if err != nil {
@@ -34,6 +59,22 @@ func RegisterCustomTags() error {
 		return fmt.Errorf("failed to register notblank tag %w", err)
 	}
 
+	if err := registerGameModeTag(); err != nil {
+		return fmt.Errorf("failed to register game_mode tag: %w", err)
+	}
+
+	if err := registerWorkshopIdTag(); err != nil {
+		return fmt.Errorf("failed to register workshop_id tag: %w", err)
+	}
+
+	if err := registerMapNameTag(); err != nil {
+		return fmt.Errorf("failed to register map_name tag: %w", err)
+	}
+
+	if err := registerSteamBranchTags(); err != nil {
+		return fmt.Errorf("failed to register steam branch tags: %w", err)
+	}
+
 	customTagsRegistered = true
 	return nil
 }
@@ -62,3 +103,67 @@ func registerPortTag() error {
 		return v >= 1 && v <= 65535
 	})
 }
+
+func registerGameModeTag() error {
+	return instance.RegisterValidation("game_mode", func(fl validator.FieldLevel) bool {
+		field := fl.Field()
+		if field.Kind() != reflect.String {
+			panic(fmt.Sprintf("Bad field type %T", field.Interface()))
+		}
+
+		_, ok := GameModePresets[field.String()]
+		return ok
+	})
+}
+
+// workshop ids are the numeric ids of steam workshop items, e.g. 3070284539
+func registerWorkshopIdTag() error {
+	return instance.RegisterValidation("workshop_id", func(fl validator.FieldLevel) bool {
+		field := fl.Field()
+		if field.Kind() != reflect.String {
+			panic(fmt.Sprintf("Bad field type %T", field.Interface()))
+		}
+
+		v, err := strconv.ParseUint(field.String(), 10, 64)
+		if err != nil {
+			return false
+		}
+
+		return v > 0
+	})
+}
+
+// map names are the file names of the map vpk files without extension, e.g. de_dust2
+func registerMapNameTag() error {
+	return instance.RegisterValidation("map_name", func(fl validator.FieldLevel) bool {
+		field := fl.Field()
+		if field.Kind() != reflect.String {
+			panic(fmt.Sprintf("Bad field type %T", field.Interface()))
+		}
+
+		return mapNameRegex.MatchString(field.String())
+	})
+}
+
+// branch names and passwords are passed as arguments to steamcmd, e.g. -beta "name" -betapassword "password"
+func registerSteamBranchTags() error {
+	if err := instance.RegisterValidation("steam_branch", func(fl validator.FieldLevel) bool {
+		field := fl.Field()
+		if field.Kind() != reflect.String {
+			panic(fmt.Sprintf("Bad field type %T", field.Interface()))
+		}
+
+		return steamBranchRegex.MatchString(field.String())
+	}); err != nil {
+		return err
+	}
+
+	return instance.RegisterValidation("steam_branch_password", func(fl validator.FieldLevel) bool {
+		field := fl.Field()
+		if field.Kind() != reflect.String {
+			panic(fmt.Sprintf("Bad field type %T", field.Interface()))
+		}
+
+		return steamBranchPasswordRegex.MatchString(field.String())
+	})
+}
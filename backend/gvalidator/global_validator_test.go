@@ -32,3 +32,91 @@ func TestGlobalValidator_PortTag_Ok(t *testing.T) {
 		}
 	}
 }
+
+func TestGlobalValidator_GameModeTag(t *testing.T) {
+	testData := map[string]bool{
+		"competitive": false,
+		"wingman":     false,
+		"deathmatch":  false,
+		"Competitive": true,
+		"retake":      true,
+		"":            true,
+	}
+
+	for gameMode, shouldError := range testData {
+		err := globalvalidator.Instance().Var(gameMode, "game_mode")
+		if (err != nil) != shouldError {
+			t.Fatalf("unexpected result for game mode %q. Error: %v", gameMode, err)
+		}
+	}
+}
+
+func TestGlobalValidator_WorkshopIdTag(t *testing.T) {
+	testData := map[string]bool{
+		"3070284539": false,
+		"1":          false,
+		"0":          true,
+		"-1":         true,
+		"abc":        true,
+		"":           true,
+	}
+
+	for workshopId, shouldError := range testData {
+		err := globalvalidator.Instance().Var(workshopId, "workshop_id")
+		if (err != nil) != shouldError {
+			t.Fatalf("unexpected result for workshop id %q. Error: %v", workshopId, err)
+		}
+	}
+}
+
+func TestGlobalValidator_MapNameTag(t *testing.T) {
+	testData := map[string]bool{
+		"de_dust2":             false,
+		"cs_office":            false,
+		"de_dust2;quit":        true,
+		"de_dust2 +map":        true,
+		"../../maps/de_mirage": true,
+		"":                     true,
+	}
+
+	for mapName, shouldError := range testData {
+		err := globalvalidator.Instance().Var(mapName, "map_name")
+		if (err != nil) != shouldError {
+			t.Fatalf("unexpected result for map name %q. Error: %v", mapName, err)
+		}
+	}
+}
+
+func TestGlobalValidator_SteamBranchTags(t *testing.T) {
+	branchTestData := map[string]bool{
+		"public":               false,
+		"beta":                 false,
+		"1.39.9.2-release_old": false,
+		"beta +quit":           true,
+		"beta;quit":            true,
+		"":                     true,
+	}
+
+	for branch, shouldError := range branchTestData {
+		err := globalvalidator.Instance().Var(branch, "steam_branch")
+		if (err != nil) != shouldError {
+			t.Fatalf("unexpected result for branch %q. Error: %v", branch, err)
+		}
+	}
+
+	passwordTestData := map[string]bool{
+		"s3cr3t!":        false,
+		"p@ss#word$%&()": false,
+		"pass word":      true,
+		"pass+quit":      true,
+		`pass"word`:      true,
+		"":               true,
+	}
+
+	for password, shouldError := range passwordTestData {
+		err := globalvalidator.Instance().Var(password, "steam_branch_password")
+		if (err != nil) != shouldError {
+			t.Fatalf("unexpected result for password %q. Error: %v", password, err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Phi-S/cs-server-manager/backup"
+	"github.com/Phi-S/cs-server-manager/constants"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type RestoreBackupModel struct {
+	Id string `json:"id" validate:"required,lte=64"`
+}
+
+type RestoreBackupResponse struct {
+	Backup        backup.Backup `json:"backup"`
+	RestoredFiles []string      `json:"restored_files"`
+}
+
+func RegisterBackups(r fiber.Router) {
+	r.Get("/backups", getBackupsHandler)
+	r.Post("/backups", createBackupHandler)
+	r.Post("/backups/restore", restoreBackupHandler)
+	r.Get("/backups/:id", downloadBackupHandler)
+	r.Delete("/backups/:id", deleteBackupHandler)
+}
+
+func newBackupErrorResponse(c fiber.Ctx, err error) error {
+	if errors.Is(err, backup.ErrBackupNotFound) {
+		return NewErrorWithInternal(c, fiber.StatusNotFound, "backup not found", err)
+	}
+
+	if errors.Is(err, backup.ErrInvalidBackup) {
+		return NewErrorWithInternal(c, fiber.StatusUnprocessableEntity, err.Error(), err)
+	}
+
+	return NewInternalServerErrorWithInternal(c, err)
+}
+
+// @Summary				Get all backups
+// @Description 		Newest backup first
+// @Tags         		backups
+// @Produce      		json
+// @Success     		200  {object}  []backup.Backup
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/backups [get]
+func getBackupsHandler(c fiber.Ctx) error {
+	backupsInstance, err := GetFromLocals[*backup.Instance](c, constants.BackupsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	backups, err := backupsInstance.List()
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("backupsInstance.List: %w", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(backups)
+}
+
+// @Summary				Create backup
+// @Description 		Creates a tar.gz archive of the data dir and all editable files
+// @Tags         		backups
+// @Produce      		json
+// @Success     		201  {object}  backup.Backup
+// @Failure				409  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/backups [post]
+func createBackupHandler(c fiber.Ctx) error {
+	backupsInstance, err := GetFromLocals[*backup.Instance](c, constants.BackupsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	lock, err := GetFromLocals[*sync.Mutex](c, constants.ServerSteamcmdLockKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	// no plugin installation or update changes the files while the backup is created
+	if err := TryLockServerSteamcmd(c, lock); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	created, err := backupsInstance.Create()
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("backupsInstance.Create: %w", err))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// @Summary				Download backup
+// @Tags         		backups
+// @Produce      		application/gzip
+// @Param		 		id path string true "Backup id"
+// @Success     		200
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/backups/{id} [get]
+func downloadBackupHandler(c fiber.Ctx) error {
+	backupsInstance, err := GetFromLocals[*backup.Instance](c, constants.BackupsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	b, backupPath, err := backupsInstance.Get(c.Params("id"))
+	if err != nil {
+		return newBackupErrorResponse(c, err)
+	}
+
+	return c.Download(backupPath, b.Id+".tar.gz")
+}
+
+// @Summary				Delete backup
+// @Tags         		backups
+// @Param		 		id path string true "Backup id"
+// @Success     		200
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/backups/{id} [delete]
+func deleteBackupHandler(c fiber.Ctx) error {
+	backupsInstance, err := GetFromLocals[*backup.Instance](c, constants.BackupsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	if err := backupsInstance.Delete(c.Params("id")); err != nil {
+		return newBackupErrorResponse(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// @Summary				Restore backup
+// @Description 		Overwrites the files of the data dir and the editable files with the content of the backup.
+// @Description 		All json files are validated before any file is overwritten. Files that are not part of the backup are kept
+// @Tags         		backups
+// @Accept       		json
+// @Produce      		json
+// @Param		 		backup body RestoreBackupModel true "The backup to restore"
+// @Success     		200  {object}  RestoreBackupResponse
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
+// @Failure				422  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/backups/restore [post]
+func restoreBackupHandler(c fiber.Ctx) error {
+	backupsInstance, err := GetFromLocals[*backup.Instance](c, constants.BackupsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	lock, serverInstance, steamcmdInstance, err := GetServerSteamcmdInstances(c)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	var restoreModel RestoreBackupModel
+	if err := c.Bind().JSON(&restoreModel); err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, "request body is not valid", err)
+	}
+
+	if err := gvalidator.Instance().Struct(restoreModel); err != nil {
+		return NewErrorValidation(c, err)
+	}
+
+	if err := TryLockServerSteamcmd(c, lock); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if serverInstance.IsRunning() {
+		return NewErrorWithMessage(c, fiber.StatusConflict, "can not restore a backup while the server is running")
+	}
+
+	if steamcmdInstance.IsRunning() {
+		return NewErrorWithMessage(c, fiber.StatusConflict, "can not restore a backup while the server is updating")
+	}
+
+	b, _, err := backupsInstance.Get(restoreModel.Id)
+	if err != nil {
+		return newBackupErrorResponse(c, err)
+	}
+
+	restoredFiles, err := backupsInstance.Restore(restoreModel.Id)
+	if err != nil {
+		return newBackupErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(RestoreBackupResponse{
+		Backup:        b,
+		RestoredFiles: restoredFiles,
+	})
+}
@@ -76,6 +76,16 @@ func GetFromLocals[T any](c fiber.Ctx, key any) (T, error) {
 	return parsedValue, nil
 }
 
+// TryLockServerSteamcmd acquires the lock of the server and steamcmd instance without waiting.
+// Scheduled jobs hold the lock while they stop or start the server, install plugins or create backups.
+// Requests during that time are answered with 409 instead of waiting until the job is finished
+func TryLockServerSteamcmd(c fiber.Ctx, lock *sync.Mutex) error {
+	if !lock.TryLock() {
+		return NewErrorWithMessage(c, fiber.StatusConflict, "server is busy with another operation. Try again later")
+	}
+	return nil
+}
+
 func GetServerSteamcmdInstances(c fiber.Ctx) (*sync.Mutex, *server.Instance, *steamcmd.Instance, error) {
 
 	lock, err := GetFromLocals[*sync.Mutex](c, constants.ServerSteamcmdLockKey)
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Phi-S/cs-server-manager/constants"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/instances"
+	"github.com/Phi-S/cs-server-manager/status"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type InstanceResponse struct {
+	Id        string                `json:"id"`
+	CsPort    string                `json:"cs_port"`
+	ServerDir string                `json:"server_dir"`
+	RconPort  string                `json:"rcon_port"`
+	Status    status.InternalStatus `json:"status"`
+}
+
+type CreateInstanceRequest struct {
+	Id        string `json:"id" validate:"required,alphanum,lte=32"`
+	CsPort    string `json:"cs_port" validate:"required,numeric,port"`
+	ServerDir string `json:"server_dir" validate:"omitempty,dirpath"`
+	RconPort  string `json:"rcon_port" validate:"omitempty,numeric,port"`
+}
+
+func RegisterInstances(r fiber.Router) {
+	r.Get("/instances", getInstancesHandler)
+	r.Post("/instances", createInstanceHandler)
+	r.Delete("/instances/:id", removeInstanceHandler)
+}
+
+func mapInstanceToInstanceResponse(instance *instances.Instance) InstanceResponse {
+	return InstanceResponse{
+		Id:        instance.Id,
+		CsPort:    instance.CsPort,
+		ServerDir: instance.ServerDir,
+		RconPort:  instance.RconPort,
+		Status:    instance.Status.Status(),
+	}
+}
+
+// @Summary				Get all instances
+// @Tags         		instances
+// @Produce      		json
+// @Success     		200  {object}  []InstanceResponse
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/instances [get]
+func getInstancesHandler(c fiber.Ctx) error {
+	registry, err := GetFromLocals[*instances.Registry](c, constants.RegistryKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	all := registry.All()
+	result := make([]InstanceResponse, 0, len(all))
+	for _, instance := range all {
+		result = append(result, mapInstanceToInstanceResponse(instance))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// @Summary				Create a new instance
+// @Description 		Creates a new server instance. All routes are available for the new instance under /instances/{id}
+// @Tags         		instances
+// @Accept       		json
+// @Produce      		json
+// @Param		 		instance body CreateInstanceRequest true "The instance that should be created"
+// @Success     		201  {object}  InstanceResponse
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/instances [post]
+func createInstanceHandler(c fiber.Ctx) error {
+	registry, err := GetFromLocals[*instances.Registry](c, constants.RegistryKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	createInstanceRequest := new(CreateInstanceRequest)
+	if err := c.Bind().JSON(createInstanceRequest); err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, "request body is not valid", err)
+	}
+
+	if err := gvalidator.Instance().Struct(createInstanceRequest); err != nil {
+		return NewErrorValidation(c, err)
+	}
+
+	instance, err := registry.Create(instances.Definition{
+		Id:        createInstanceRequest.Id,
+		CsPort:    createInstanceRequest.CsPort,
+		ServerDir: createInstanceRequest.ServerDir,
+		RconPort:  createInstanceRequest.RconPort,
+	})
+	if err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, fmt.Sprintf("failed to create instance. %v", err), err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(mapInstanceToInstanceResponse(instance))
+}
+
+// @Summary				Remove instance
+// @Description 		Removes the instance from the manager. The server files of the instance are not deleted
+// @Tags         		instances
+// @Param 				id	path	string true "Instance id"
+// @Success     		200
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/instances/{id} [delete]
+func removeInstanceHandler(c fiber.Ctx) error {
+	registry, err := GetFromLocals[*instances.Registry](c, constants.RegistryKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	if err := registry.Remove(c.Params("id")); err != nil {
+		if errors.Is(err, instances.ErrInstanceNotFound) {
+			return NewErrorWithInternal(c, fiber.StatusNotFound, "instance not found", err)
+		}
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, fmt.Sprintf("failed to remove instance. %v", err), err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Phi-S/cs-server-manager/constants"
+	"github.com/Phi-S/cs-server-manager/game_maps"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/status"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type MapResponse struct {
+	Name      string `json:"name"`
+	Official  bool   `json:"official"`
+	Installed bool   `json:"installed"`
+}
+
+type ChangeMapRequest struct {
+	Map string `json:"map" validate:"required,map_name"`
+}
+
+type MapRotationModel struct {
+	Enabled bool     `json:"enabled"`
+	Maps    []string `json:"maps" validate:"lte=64,dive,map_name"`
+}
+
+func RegisterMaps(r fiber.Router) {
+	r.Get("/maps", getMapsHandler)
+	r.Post("/maps/change", changeMapHandler)
+	r.Get("/maps/rotation", getMapRotationHandler)
+	r.Put("/maps/rotation", setMapRotationHandler)
+}
+
+// @Summary				Get all maps
+// @Description 		Returns the official maps and all maps found in the game/csgo/maps folder of the server
+// @Tags         		maps
+// @Produce      		json
+// @Success     		200  {object}  []MapResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/maps [get]
+func getMapsHandler(c fiber.Ctx) error {
+	mapsInstance, err := GetFromLocals[*game_maps.Instance](c, constants.MapsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	maps, err := mapsInstance.List()
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("mapsInstance.List: %w", err))
+	}
+
+	result := make([]MapResponse, 0, len(maps))
+	for _, gameMap := range maps {
+		result = append(result, MapResponse{
+			Name:      gameMap.Name,
+			Official:  gameMap.Official,
+			Installed: gameMap.Installed,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// @Summary				Change the map
+// @Description 		Sends the changelevel command and waits until the server has loaded the new map
+// @Tags         		maps
+// @Accept       		json
+// @Param		 		map body ChangeMapRequest true "The map to change to"
+// @Success     		200
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Failure				504  {object}  handlers.ErrorResponse
+// @Router       		/maps/change [post]
+func changeMapHandler(c fiber.Ctx) error {
+	mapsInstance, err := GetFromLocals[*game_maps.Instance](c, constants.MapsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	var changeMapRequest ChangeMapRequest
+	if err := c.Bind().JSON(&changeMapRequest); err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, "request is not valid", err)
+	}
+
+	if err := gvalidator.Instance().Struct(changeMapRequest); err != nil {
+		return NewErrorValidation(c, err)
+	}
+
+	if err := mapsInstance.Change(changeMapRequest.Map, game_maps.ChangeTimeout); err != nil {
+		switch {
+		case errors.Is(err, game_maps.ErrMapNotFound):
+			return NewErrorWithInternal(c, fiber.StatusNotFound, "map not found", err)
+		case errors.Is(err, game_maps.ErrServerNotStarted):
+			return NewErrorWithInternal(c, fiber.StatusInternalServerError, "server is not running", err)
+		case errors.Is(err, game_maps.ErrChangeInProgress):
+			return NewErrorWithInternal(c, fiber.StatusConflict, "map change already in progress", err)
+		case errors.Is(err, game_maps.ErrChangeTimeout):
+			return NewErrorWithInternal(c, fiber.StatusGatewayTimeout, "timeout reached while waiting for map change", err)
+		default:
+			return NewInternalServerErrorWithInternal(c, err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// @Summary				Get the map rotation
+// @Tags         		maps
+// @Produce      		json
+// @Success     		200  {object}  MapRotationModel
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/maps/rotation [get]
+func getMapRotationHandler(c fiber.Ctx) error {
+	mapsInstance, err := GetFromLocals[*game_maps.Instance](c, constants.MapsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	rotation, err := mapsInstance.Rotation()
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("mapsInstance.Rotation: %w", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(MapRotationModel{
+		Enabled: rotation.Enabled,
+		Maps:    rotation.Maps,
+	})
+}
+
+// @Summary				Update the map rotation
+// @Description 		The rotation is applied on server start and after each match end. If the server is running, the rotation is applied immediately
+// @Tags         		maps
+// @Accept       		json
+// @Produce      		json
+// @Param		 		rotation body MapRotationModel true "The new map rotation"
+// @Success     		200  {object}  MapRotationModel
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/maps/rotation [put]
+func setMapRotationHandler(c fiber.Ctx) error {
+	mapsInstance, err := GetFromLocals[*game_maps.Instance](c, constants.MapsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	statusInstance, err := GetFromLocals[*status.Status](c, constants.StatusKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	rotationModel := new(MapRotationModel)
+	if err := c.Bind().JSON(rotationModel); err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, "request body is not valid", err)
+	}
+
+	if err := gvalidator.Instance().Struct(rotationModel); err != nil {
+		return NewErrorValidation(c, err)
+	}
+
+	if rotationModel.Enabled && len(rotationModel.Maps) == 0 {
+		return NewErrorWithMessage(c, fiber.StatusBadRequest, "an enabled map rotation requires at least one map")
+	}
+
+	if rotationModel.Maps == nil {
+		rotationModel.Maps = []string{}
+	}
+
+	rotation := game_maps.Rotation{
+		Enabled: rotationModel.Enabled,
+		Maps:    rotationModel.Maps,
+	}
+
+	if err := mapsInstance.SetRotation(rotation); err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("mapsInstance.SetRotation: %w", err))
+	}
+
+	if currentStatus := statusInstance.Status(); currentStatus.State == status.ServerStarted {
+		if _, err := mapsInstance.ApplyRotation(currentStatus.Map); err != nil && !errors.Is(err, game_maps.ErrRotationNotEnabled) {
+			return NewInternalServerErrorWithInternal(c, fmt.Errorf("mapsInstance.ApplyRotation: %w", err))
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(rotationModel)
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/Phi-S/cs-server-manager/constants"
 	"github.com/Phi-S/cs-server-manager/plugins"
@@ -14,32 +17,60 @@ type PluginResponse struct {
 	Description string                  `json:"description"`
 	URL         string                  `json:"url"`
 	Versions    []PluginVersionResponse `json:"versions"`
+	// names of the installed plugins that depend on this plugin
+	RequiredBy []string `json:"required_by"`
+	// true if the installed plugin was uploaded
+	Uploaded bool `json:"uploaded"`
 }
 
 type PluginVersionResponse struct {
-	Name         string                     `json:"name"`
-	Installed    bool                       `json:"installed"`
-	Dependencies []PluginDependencyResponse `json:"dependencies"`
+	Name         string                      `json:"name"`
+	Installed    bool                        `json:"installed"`
+	Dependencies []PluginRequirementResponse `json:"dependencies"`
 }
 
-type PluginDependencyResponse struct {
-	Name         string                     `json:"name"`
-	InstallDir   string                     `json:"install_dir"`
-	Version      string                     `json:"version"`
-	DownloadURL  string                     `json:"download_url"`
-	Dependencies []PluginDependencyResponse `json:"dependencies"`
+type PluginRequirementResponse struct {
+	Name string `json:"name"`
+	// version constraint
+	Version string `json:"version"`
 }
 
 func RegisterPlugins(r fiber.Router) {
 	r.Get("/plugins", getPluginsHandler)
 	r.Post("/plugins", installPluginHandler)
-	r.Delete("/plugins", uninstallPluginHandler)
+	r.Post("/plugins/upload", uploadPluginHandler)
+	r.Put("/plugins/:name", upgradePluginHandler)
+	r.Delete("/plugins/:name", uninstallPluginHandler)
+	r.Get("/plugins/:name/:version/plan", getPluginPlanHandler)
+	r.Post("/plugins/catalog/refresh", refreshPluginCatalogHandler)
+	r.Post("/plugins/verify", verifyPluginsHandler)
+}
+
+func newPluginErrorResponse(c fiber.Ctx, err error) error {
+	if errors.Is(err, plugins.ErrPluginNotFound) || errors.Is(err, plugins.ErrPluginNotInstalled) {
+		return NewErrorWithInternal(c, fiber.StatusNotFound, err.Error(), err)
+	}
+
+	if errors.Is(err, plugins.ErrInvalidUpload) {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, err.Error(), err)
+	}
+
+	if errors.Is(err, plugins.ErrPluginRequired) ||
+		errors.Is(err, plugins.ErrPluginAlreadyInstalled) ||
+		errors.Is(err, plugins.ErrVersionConflict) ||
+		errors.Is(err, plugins.ErrDependencyConflict) ||
+		errors.Is(err, plugins.ErrDependencyCycle) {
+		return NewErrorWithInternal(c, fiber.StatusConflict, err.Error(), err)
+	}
+
+	return NewInternalServerErrorWithInternal(c, err)
 }
 
 // @Summary				Get all available plugins
+// @Description 		Installed plugins that are not part of the plugins list, e.g. dependencies, are included as well
 // @Tags         		plugins
 // @Produce      		json
-// @Success     		200  {object}  PluginResponse
+// @Success     		200  {object}  []PluginResponse
 // @Failure				400  {object}  handlers.ErrorResponse
 // @Failure				500  {object}  handlers.ErrorResponse
 // @Router       		/plugins [get]
@@ -49,16 +80,12 @@ func getPluginsHandler(c fiber.Ctx) error {
 		return NewInternalServerErrorWithInternal(c, fmt.Errorf("failed to get plugins instance from context: %w", err))
 	}
 
-	installedPlugin, err := pluginsInstance.GetInstalledPlugin()
+	installedPlugins, err := pluginsInstance.GetInstalledPlugins()
 	if err != nil {
 		return NewInternalServerErrorWithInternal(c, fmt.Errorf("failed to get installed plugins: %w", err))
 	}
 
 	availablePlugins := pluginsInstance.GetAllAvailablePlugins()
-	if availablePlugins == nil {
-		return c.Status(fiber.StatusOK).JSON(make([]PluginResponse, 0))
-	}
-
 	result := make([]PluginResponse, 0, len(availablePlugins))
 
 	for _, plugin := range availablePlugins {
@@ -66,8 +93,8 @@ func getPluginsHandler(c fiber.Ctx) error {
 		for _, version := range plugin.Versions {
 			versions = append(versions, PluginVersionResponse{
 				Name:         version.Name,
-				Installed:    installedPlugin != nil && installedPlugin.Name == plugin.Name && installedPlugin.Version == version.Name,
-				Dependencies: mapPluginDependencyToPluginDependencyResponses(version.Dependencies),
+				Installed:    isPluginVersionInstalled(installedPlugins, plugin.Name, version.Name),
+				Dependencies: mapRequirementsToPluginRequirementResponses(version.Requires),
 			})
 		}
 
@@ -76,42 +103,180 @@ func getPluginsHandler(c fiber.Ctx) error {
 			Description: plugin.Description,
 			URL:         plugin.URL,
 			Versions:    versions,
+			RequiredBy:  plugins.RequiredBy(installedPlugins, plugin.Name),
 		})
 	}
 
+	for _, installedPlugin := range installedPlugins {
+		index := slices.IndexFunc(result, func(p PluginResponse) bool {
+			return p.Name == installedPlugin.Name
+		})
+
+		installedVersion := PluginVersionResponse{
+			Name:         installedPlugin.Version,
+			Installed:    true,
+			Dependencies: make([]PluginRequirementResponse, 0),
+		}
+		if index == -1 {
+			result = append(result, PluginResponse{
+				Name:       installedPlugin.Name,
+				Versions:   []PluginVersionResponse{installedVersion},
+				RequiredBy: plugins.RequiredBy(installedPlugins, installedPlugin.Name),
+				Uploaded:   installedPlugin.Uploaded,
+			})
+			continue
+		}
+
+		result[index].Uploaded = installedPlugin.Uploaded
+
+		// the installed version is no longer part of the plugins list
+		if !slices.ContainsFunc(result[index].Versions, func(v PluginVersionResponse) bool { return v.Installed }) {
+			result[index].Versions = append(result[index].Versions, installedVersion)
+		}
+	}
+
 	return c.Status(fiber.StatusOK).JSON(result)
 }
 
-func mapPluginDependencyToPluginDependencyResponses(dependencies []plugins.PluginDependency) []PluginDependencyResponse {
-	if len(dependencies) == 0 {
-		return nil
-	}
+func isPluginVersionInstalled(installedPlugins []plugins.InstalledPlugin, pluginName string, versionName string) bool {
+	return slices.ContainsFunc(installedPlugins, func(p plugins.InstalledPlugin) bool {
+		return p.Name == pluginName && p.Version == versionName
+	})
+}
 
-	var result = make([]PluginDependencyResponse, 0, len(dependencies))
-	for _, d := range dependencies {
-		result = append(result, PluginDependencyResponse{
-			Name:         d.Name,
-			InstallDir:   d.InstallDir,
-			Version:      d.Version,
-			DownloadURL:  d.DownloadURL,
-			Dependencies: mapPluginDependencyToPluginDependencyResponses(d.Dependencies),
+func mapRequirementsToPluginRequirementResponses(requirements []plugins.Requirement) []PluginRequirementResponse {
+	result := make([]PluginRequirementResponse, 0, len(requirements))
+	for _, requirement := range requirements {
+		result = append(result, PluginRequirementResponse{
+			Name:    requirement.Name,
+			Version: requirement.Version,
 		})
 	}
 
 	return result
 }
 
+// @Summary				Preview the installation of a plugin
+// @Description 		Resolves the dependencies of the plugin version and returns all plugins that are installed or changed in install order.
+// @Description 		Conflicting version constraints and dependency cycles are reported with status 409
+// @Tags         		plugins
+// @Produce      		json
+// @Param		 		name path string true "Plugin name"
+// @Param		 		version path string true "Plugin version"
+// @Success     		200  {object}  plugins.Plan
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/plugins/{name}/{version}/plan [get]
+func getPluginPlanHandler(c fiber.Ctx) error {
+	pluginsInstance, err := GetFromLocals[*plugins.Instance](c, constants.PluginsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	plan, err := pluginsInstance.Plan(c.Params("name"), c.Params("version"))
+	if err != nil {
+		return newPluginErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(plan)
+}
+
+// @Summary				Refresh the plugins list
+// @Description 		Fetches the configured catalog sources and reads the local plugins.json again.
+// @Description 		Cached catalogs are only downloaded again if they were modified. If a source is not available, its cached catalog is used
+// @Tags         		plugins
+// @Produce      		json
+// @Success     		200  {object}  plugins.CatalogStatus
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/plugins/catalog/refresh [post]
+func refreshPluginCatalogHandler(c fiber.Ctx) error {
+	pluginsInstance, err := GetFromLocals[*plugins.Instance](c, constants.PluginsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	status, err := pluginsInstance.RefreshCatalog(c.UserContext())
+	if err != nil {
+		return NewErrorWithInternal(c, fiber.StatusInternalServerError, err.Error(), err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(status)
+}
+
+type VerifyPluginsRequest struct {
+	// restore missing and modified files and execute actions that are not in place anymore again
+	Repair bool `json:"repair"`
+}
+
+// @Summary				Verify the files of the installed plugins
+// @Description 		Compares the files of the installed plugins with their hashes at install time and checks that the changes of the plugin actions are still in place, e.g. the metamod entry of the gameinfo.gi.
+// @Description 		Only plugins with drift are part of the result. Modified config files and unexpected files are reported but never replaced or removed
+// @Tags         		plugins
+// @Param		 		verify body VerifyPluginsRequest false "Set repair to restore the drifted files and actions"
+// @Accept       		json
+// @Produce      		json
+// @Success     		200  {object}  plugins.VerifyResult
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/plugins/verify [post]
+func verifyPluginsHandler(c fiber.Ctx) error {
+	pluginsInstance, err := GetFromLocals[*plugins.Instance](c, constants.PluginsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	var verifyPluginsRequest VerifyPluginsRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&verifyPluginsRequest); err != nil {
+			return NewErrorWithInternal(c, fiber.StatusBadRequest, "request body is not valid", err)
+		}
+	}
+
+	if verifyPluginsRequest.Repair {
+		lock, serverInstance, steamcmdInstance, err := GetServerSteamcmdInstances(c)
+		if err != nil {
+			return NewInternalServerErrorWithInternal(c, fmt.Errorf("GetServerSteamcmdInstances: %w", err))
+		}
+
+		if err := TryLockServerSteamcmd(c, lock); err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		if serverInstance.IsRunning() {
+			return NewErrorWithMessage(c, fiber.StatusInternalServerError, "can not repair plugins while server is running")
+		}
+
+		if steamcmdInstance.IsRunning() {
+			return NewErrorWithMessage(c, fiber.StatusInternalServerError, "can not repair plugins while steamcmd is running")
+		}
+	}
+
+	result, err := pluginsInstance.Verify(c.UserContext(), verifyPluginsRequest.Repair)
+	if err != nil {
+		return newPluginErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
 type InstallPluginRequest struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
 }
 
 // @Summary				Install given plugin
+// @Description 		Dependencies that are already installed with the same version are reused
 // @Tags         		plugins
 // @Param		 		plugin body InstallPluginRequest true "The plugin and version that should be installed"
 // @Accept       		json
 // @Success     		200
+// @Success     		208
 // @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
 // @Failure				500  {object}  handlers.ErrorResponse
 // @Router       		/plugins [post]
 func installPluginHandler(c fiber.Ctx) error {
@@ -125,7 +290,9 @@ func installPluginHandler(c fiber.Ctx) error {
 		return NewInternalServerErrorWithInternal(c, fmt.Errorf("GetServerSteamcmdInstances: %w", err))
 	}
 
-	lock.Lock()
+	if err := TryLockServerSteamcmd(c, lock); err != nil {
+		return err
+	}
 	defer lock.Unlock()
 
 	if serverInstance.IsRunning() {
@@ -141,28 +308,154 @@ func installPluginHandler(c fiber.Ctx) error {
 		return NewErrorWithInternal(c, fiber.StatusBadRequest, "request is not valid", err)
 	}
 
-	installedPlugin, err := pluginsInstance.GetInstalledPlugin()
+	if err := pluginsInstance.InstallPluginByName(c.UserContext(), installPluginRequest.Name, installPluginRequest.Version); err != nil {
+		if errors.Is(err, plugins.ErrPluginAlreadyInstalled) {
+			return c.SendStatus(fiber.StatusAlreadyReported)
+		}
+		return newPluginErrorResponse(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// @Summary				Upload and install a plugin
+// @Description 		Installs a plugin archive that is not part of the plugins list. The plugin is tracked like any other plugin and can be uninstalled the same way
+// @Tags         		plugins
+// @Accept       		mpfd
+// @Param		 		file formData file true "The .zip or .tar.gz archive of the plugin"
+// @Param		 		name formData string true "Plugin name"
+// @Param		 		version formData string true "Plugin version"
+// @Param		 		install_dir formData string true "Directory the archive is extracted to. / is the game/csgo directory"
+// @Param		 		dependencies formData string false "Comma separated names of installed plugins the plugin depends on"
+// @Success     		200
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/plugins/upload [post]
+func uploadPluginHandler(c fiber.Ctx) error {
+	pluginsInstance, err := GetFromLocals[*plugins.Instance](c, constants.PluginsKey)
 	if err != nil {
-		return NewInternalServerErrorWithInternal(c, fmt.Errorf("GetInstalledPlugin: %w", err))
+		return NewInternalServerErrorWithInternal(c, err)
 	}
 
-	if installedPlugin != nil && installedPlugin.Name == installPluginRequest.Name && installedPlugin.Version == installPluginRequest.Version {
-		return c.SendStatus(fiber.StatusAlreadyReported)
+	lock, serverInstance, steamcmdInstance, err := GetServerSteamcmdInstances(c)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("GetServerSteamcmdInstances: %w", err))
 	}
 
-	if err := pluginsInstance.InstallPluginByName(installPluginRequest.Name, installPluginRequest.Version); err != nil {
-		return NewInternalServerErrorWithInternal(c, err)
+	if err := TryLockServerSteamcmd(c, lock); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if serverInstance.IsRunning() {
+		return NewErrorWithMessage(c, fiber.StatusInternalServerError, "can not install plugins while server is running")
+	}
+
+	if steamcmdInstance.IsRunning() {
+		return NewErrorWithMessage(c, fiber.StatusInternalServerError, "can not install plugins while steamcmd is running")
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, "form file 'file' is missing", err)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("fileHeader.Open: %w", err))
+	}
+	defer file.Close()
+
+	dependencies := make([]string, 0)
+	for _, dependency := range strings.Split(c.FormValue("dependencies"), ",") {
+		if dependency = strings.TrimSpace(dependency); dependency != "" {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+
+	upload := plugins.Upload{
+		Name:         c.FormValue("name"),
+		Version:      c.FormValue("version"),
+		InstallDir:   c.FormValue("install_dir"),
+		Dependencies: dependencies,
+	}
+
+	if err := pluginsInstance.InstallUpload(upload, file, fileHeader.Filename); err != nil {
+		return newPluginErrorResponse(c, err)
 	}
 
 	return c.SendStatus(fiber.StatusOK)
 }
 
-// @Summary				Uninstall the currently installed plugin
+type UpgradePluginRequest struct {
+	Version string `json:"version"`
+}
+
+// @Summary				Upgrade or downgrade an installed plugin
+// @Description 		The new version is staged before the installed files are replaced. Config files that were modified after the installation are kept. If the upgrade fails, the installed version stays untouched
 // @Tags         		plugins
+// @Param		 		name path string true "Plugin name"
+// @Param		 		plugin body UpgradePluginRequest true "The version the plugin should be switched to"
+// @Accept       		json
+// @Produce      		json
+// @Success     		200  {object}  plugins.UpgradeResult
+// @Success     		208
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/plugins/{name} [put]
+func upgradePluginHandler(c fiber.Ctx) error {
+	pluginsInstance, err := GetFromLocals[*plugins.Instance](c, constants.PluginsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	lock, serverInstance, steamcmdInstance, err := GetServerSteamcmdInstances(c)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("GetServerSteamcmdInstances: %w", err))
+	}
+
+	if err := TryLockServerSteamcmd(c, lock); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if serverInstance.IsRunning() {
+		return NewErrorWithMessage(c, fiber.StatusInternalServerError, "can not upgrade plugins while server is running")
+	}
+
+	if steamcmdInstance.IsRunning() {
+		return NewErrorWithMessage(c, fiber.StatusInternalServerError, "can not upgrade plugins while steamcmd is running")
+	}
+
+	var upgradePluginRequest UpgradePluginRequest
+	if err := c.Bind().JSON(&upgradePluginRequest); err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, "request is not valid", err)
+	}
+
+	result, err := pluginsInstance.Upgrade(c.UserContext(), c.Params("name"), upgradePluginRequest.Version)
+	if err != nil {
+		if errors.Is(err, plugins.ErrPluginAlreadyInstalled) {
+			return c.SendStatus(fiber.StatusAlreadyReported)
+		}
+		return newPluginErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// @Summary				Uninstall plugin
+// @Description 		Dependencies that are not required by another plugin and were not installed explicitly are uninstalled as well
+// @Tags         		plugins
+// @Param		 		name path string true "Plugin name"
 // @Success     		200
 // @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
 // @Failure				500  {object}  handlers.ErrorResponse
-// @Router       		/plugins [delete]
+// @Router       		/plugins/{name} [delete]
 func uninstallPluginHandler(c fiber.Ctx) error {
 	pluginsInstance, err := GetFromLocals[*plugins.Instance](c, constants.PluginsKey)
 	if err != nil {
@@ -174,7 +467,9 @@ func uninstallPluginHandler(c fiber.Ctx) error {
 		return NewInternalServerErrorWithInternal(c, fmt.Errorf("GetServerSteamcmdInstances: %w", err))
 	}
 
-	lock.Lock()
+	if err := TryLockServerSteamcmd(c, lock); err != nil {
+		return err
+	}
 	defer lock.Unlock()
 	if serverInstance.IsRunning() {
 		return NewErrorWithMessage(c, fiber.StatusInternalServerError, "can not uninstall plugins while server is running")
@@ -184,8 +479,8 @@ func uninstallPluginHandler(c fiber.Ctx) error {
 		return NewErrorWithMessage(c, fiber.StatusInternalServerError, "can not uninstall plugins while steamcmd is running")
 	}
 
-	if err := pluginsInstance.Uninstall(); err != nil {
-		return NewInternalServerErrorWithInternal(c, fmt.Errorf("pluginsInstance.Uninstall: %w", err))
+	if err := pluginsInstance.Uninstall(c.Params("name")); err != nil {
+		return newPluginErrorResponse(c, err)
 	}
 
 	return c.SendStatus(fiber.StatusOK)
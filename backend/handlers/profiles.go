@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/Phi-S/cs-server-manager/constants"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/server"
+	"github.com/Phi-S/cs-server-manager/start_parameters_json"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type ProfileResponse struct {
+	Name      string        `json:"name"`
+	IsDefault bool          `json:"is_default"`
+	IsActive  bool          `json:"is_active"`
+	Settings  SettingsModel `json:"settings"`
+}
+
+func RegisterProfiles(r fiber.Router) {
+	r.Get("/profiles", getProfilesHandler)
+	r.Get("/profiles/:name", getProfileHandler)
+	r.Put("/profiles/:name", saveProfileHandler)
+	r.Delete("/profiles/:name", deleteProfileHandler)
+	r.Post("/profiles/:name/activate", activateProfileHandler)
+	r.Post("/profiles/:name/default", setDefaultProfileHandler)
+	r.Delete("/profiles/:name/default", removeDefaultProfileHandler)
+}
+
+// profile names can contain spaces, so the path parameter has to be unescaped
+func profileNameParam(c fiber.Ctx) (string, error) {
+	name, err := url.PathUnescape(c.Params("name"))
+	if err != nil {
+		return "", NewErrorWithInternal(c, fiber.StatusBadRequest, "profile name is not valid", err)
+	}
+	return name, nil
+}
+
+func newProfileErrorResponse(c fiber.Ctx, err error) error {
+	if errors.Is(err, start_parameters_json.ErrProfileNotFound) {
+		return NewErrorWithInternal(c, fiber.StatusNotFound, "profile not found", err)
+	}
+	return NewInternalServerErrorWithInternal(c, err)
+}
+
+// @Summary				Get all start parameter profiles
+// @Tags         		profiles
+// @Produce      		json
+// @Success     		200  {object}  []ProfileResponse
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/profiles [get]
+func getProfilesHandler(c fiber.Ctx) error {
+	profiles, err := GetFromLocals[*start_parameters_json.Profiles](c, constants.ProfilesKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	all, defaultProfile, activeProfile, err := profiles.All()
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	result := make([]ProfileResponse, 0, len(all))
+	for _, profile := range all {
+		result = append(result, ProfileResponse{
+			Name:      profile.Name,
+			IsDefault: profile.Name == defaultProfile,
+			IsActive:  profile.Name == activeProfile,
+			Settings:  newSettingsModel(profile.StartParameters),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// @Summary				Get start parameter profile
+// @Tags         		profiles
+// @Produce      		json
+// @Param 				name	path	string true "Profile name"
+// @Success     		200  {object}  SettingsModel
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/profiles/{name} [get]
+func getProfileHandler(c fiber.Ctx) error {
+	profiles, err := GetFromLocals[*start_parameters_json.Profiles](c, constants.ProfilesKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	name, err := profileNameParam(c)
+	if err != nil {
+		return err
+	}
+
+	sp, err := profiles.Get(name)
+	if err != nil {
+		return newProfileErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newSettingsModel(sp))
+}
+
+// @Summary				Create or update start parameter profile
+// @Description 		If the profile is the active profile, the current settings are updated as well
+// @Tags         		profiles
+// @Accept       		json
+// @Produce      		json
+// @Param 				name	path	string true "Profile name"
+// @Param		 		settings body SettingsModel true "The start parameters of the profile"
+// @Success     		200  {object}  SettingsModel
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/profiles/{name} [put]
+func saveProfileHandler(c fiber.Ctx) error {
+	profiles, err := GetFromLocals[*start_parameters_json.Profiles](c, constants.ProfilesKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	name, err := profileNameParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := start_parameters_json.ValidateProfileName(name); err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, err.Error(), err)
+	}
+
+	settings := new(SettingsModel)
+	if err := c.Bind().JSON(settings); err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, "request body is not valid", err)
+	}
+
+	if err := gvalidator.Instance().Struct(settings); err != nil {
+		return NewErrorValidation(c, err)
+	}
+
+	startParameters, err := profiles.Get(name)
+	if err != nil {
+		if !errors.Is(err, start_parameters_json.ErrProfileNotFound) {
+			return NewInternalServerErrorWithInternal(c, err)
+		}
+		startParameters = *server.DefaultStartParameters()
+	}
+
+	settings.applyTo(&startParameters)
+
+	if _, err := startParameters.LaunchArgs(); err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, err.Error(), err)
+	}
+
+	if err := profiles.Save(name, startParameters); err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("profiles.Save: %w", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newSettingsModel(startParameters))
+}
+
+// @Summary				Delete start parameter profile
+// @Description 		The current settings are not changed, even if the profile is active
+// @Tags         		profiles
+// @Param 				name	path	string true "Profile name"
+// @Success     		200
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/profiles/{name} [delete]
+func deleteProfileHandler(c fiber.Ctx) error {
+	profiles, err := GetFromLocals[*start_parameters_json.Profiles](c, constants.ProfilesKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	name, err := profileNameParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := profiles.Delete(name); err != nil {
+		return newProfileErrorResponse(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// @Summary				Activate start parameter profile
+// @Description 		Replaces the current settings with the start parameters of the profile. The new settings are used on the next server start
+// @Tags         		profiles
+// @Produce      		json
+// @Param 				name	path	string true "Profile name"
+// @Success     		200  {object}  SettingsModel
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/profiles/{name}/activate [post]
+func activateProfileHandler(c fiber.Ctx) error {
+	profiles, err := GetFromLocals[*start_parameters_json.Profiles](c, constants.ProfilesKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	name, err := profileNameParam(c)
+	if err != nil {
+		return err
+	}
+
+	sp, err := profiles.Activate(name)
+	if err != nil {
+		return newProfileErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newSettingsModel(sp))
+}
+
+// @Summary				Mark start parameter profile as default
+// @Description 		The default profile is activated when the server manager starts
+// @Tags         		profiles
+// @Param 				name	path	string true "Profile name"
+// @Success     		200
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/profiles/{name}/default [post]
+func setDefaultProfileHandler(c fiber.Ctx) error {
+	profiles, err := GetFromLocals[*start_parameters_json.Profiles](c, constants.ProfilesKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	name, err := profileNameParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := profiles.SetDefault(name); err != nil {
+		return newProfileErrorResponse(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// @Summary				Remove default mark from start parameter profile
+// @Tags         		profiles
+// @Param 				name	path	string true "Profile name"
+// @Success     		200
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/profiles/{name}/default [delete]
+func removeDefaultProfileHandler(c fiber.Ctx) error {
+	profiles, err := GetFromLocals[*start_parameters_json.Profiles](c, constants.ProfilesKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	_, defaultProfile, _, err := profiles.All()
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	name, err := profileNameParam(c)
+	if err != nil {
+		return err
+	}
+
+	if defaultProfile != name {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	if err := profiles.SetDefault(""); err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
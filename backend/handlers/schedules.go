@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/constants"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/scheduler"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type ScheduleJobModel struct {
+	Type          string `json:"type" validate:"required,oneof=restart update command install_plugin backup"`
+	Command       string `json:"command,omitempty" validate:"required_if=Type command,lt=128"`
+	PluginName    string `json:"plugin_name,omitempty" validate:"required_if=Type install_plugin,lte=64"`
+	PluginVersion string `json:"plugin_version,omitempty" validate:"required_if=Type install_plugin,lte=64"`
+}
+
+type ScheduleModel struct {
+	Name    string           `json:"name" validate:"required,notblank,lte=64"`
+	Cron    string           `json:"cron" validate:"required,lte=128"`
+	Enabled bool             `json:"enabled"`
+	Job     ScheduleJobModel `json:"job"`
+}
+
+type ScheduleResponse struct {
+	Id         string           `json:"id"`
+	Name       string           `json:"name"`
+	Cron       string           `json:"cron"`
+	Enabled    bool             `json:"enabled"`
+	Job        ScheduleJobModel `json:"job"`
+	NextRunUtc *time.Time       `json:"next_run_utc"`
+}
+
+type ScheduleRunResponse struct {
+	ScheduleId    string    `json:"schedule_id"`
+	ScheduleName  string    `json:"schedule_name"`
+	JobType       string    `json:"job_type"`
+	StartedAtUtc  time.Time `json:"started_at_utc"`
+	FinishedAtUtc time.Time `json:"finished_at_utc"`
+	Success       bool      `json:"success"`
+	Output        string    `json:"output"`
+	Error         string    `json:"error"`
+}
+
+func RegisterSchedules(r fiber.Router) {
+	r.Get("/schedules", getSchedulesHandler)
+	r.Post("/schedules", createScheduleHandler)
+	r.Get("/schedules/runs", getScheduleRunsHandler)
+	r.Get("/schedules/:id", getScheduleHandler)
+	r.Put("/schedules/:id", updateScheduleHandler)
+	r.Delete("/schedules/:id", deleteScheduleHandler)
+	r.Post("/schedules/:id/run", runScheduleHandler)
+	r.Get("/schedules/:id/runs", getScheduleRunsHandler)
+}
+
+func newScheduleResponse(schedulerInstance *scheduler.Instance, schedule scheduler.Schedule) ScheduleResponse {
+	var nextRunUtc *time.Time
+	if nextRun := schedulerInstance.NextRun(schedule); !nextRun.IsZero() {
+		nextRun = nextRun.UTC()
+		nextRunUtc = &nextRun
+	}
+
+	return ScheduleResponse{
+		Id:      schedule.Id,
+		Name:    schedule.Name,
+		Cron:    schedule.Cron,
+		Enabled: schedule.Enabled,
+		Job: ScheduleJobModel{
+			Type:          string(schedule.Job.Type),
+			Command:       schedule.Job.Command,
+			PluginName:    schedule.Job.PluginName,
+			PluginVersion: schedule.Job.PluginVersion,
+		},
+		NextRunUtc: nextRunUtc,
+	}
+}
+
+func (m ScheduleModel) toSchedule() scheduler.Schedule {
+	return scheduler.Schedule{
+		Name:    m.Name,
+		Cron:    m.Cron,
+		Enabled: m.Enabled,
+		Job: scheduler.Job{
+			Type:          scheduler.JobType(m.Job.Type),
+			Command:       m.Job.Command,
+			PluginName:    m.Job.PluginName,
+			PluginVersion: m.Job.PluginVersion,
+		},
+	}
+}
+
+func bindScheduleModel(c fiber.Ctx) (ScheduleModel, error) {
+	var scheduleModel ScheduleModel
+	if err := c.Bind().JSON(&scheduleModel); err != nil {
+		return ScheduleModel{}, NewErrorWithInternal(c, fiber.StatusBadRequest, "request body is not valid", err)
+	}
+
+	if err := gvalidator.Instance().Struct(scheduleModel); err != nil {
+		return ScheduleModel{}, NewErrorValidation(c, err)
+	}
+
+	if _, err := scheduler.ParseCron(scheduleModel.Cron); err != nil {
+		return ScheduleModel{}, NewErrorWithInternal(c, fiber.StatusBadRequest, err.Error(), err)
+	}
+
+	return scheduleModel, nil
+}
+
+func newScheduleErrorResponse(c fiber.Ctx, err error) error {
+	if errors.Is(err, scheduler.ErrScheduleNotFound) {
+		return NewErrorWithInternal(c, fiber.StatusNotFound, "schedule not found", err)
+	}
+	return NewInternalServerErrorWithInternal(c, err)
+}
+
+// @Summary				Get all schedules
+// @Tags         		schedules
+// @Produce      		json
+// @Success     		200  {object}  []ScheduleResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/schedules [get]
+func getSchedulesHandler(c fiber.Ctx) error {
+	schedulerInstance, err := GetFromLocals[*scheduler.Instance](c, constants.SchedulerKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	schedules, err := schedulerInstance.All()
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("schedulerInstance.All: %w", err))
+	}
+
+	result := make([]ScheduleResponse, 0, len(schedules))
+	for _, schedule := range schedules {
+		result = append(result, newScheduleResponse(schedulerInstance, schedule))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// @Summary				Get schedule
+// @Tags         		schedules
+// @Produce      		json
+// @Param 				id	path	string true "Schedule id"
+// @Success     		200  {object}  ScheduleResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/schedules/{id} [get]
+func getScheduleHandler(c fiber.Ctx) error {
+	schedulerInstance, err := GetFromLocals[*scheduler.Instance](c, constants.SchedulerKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	schedule, err := schedulerInstance.Get(c.Params("id"))
+	if err != nil {
+		return newScheduleErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newScheduleResponse(schedulerInstance, schedule))
+}
+
+// @Summary				Create schedule
+// @Description 		The cron expression uses the standard five field format "minute hour day-of-month month day-of-week" and is evaluated in the local time zone of the server manager
+// @Tags         		schedules
+// @Accept       		json
+// @Produce      		json
+// @Param		 		schedule body ScheduleModel true "The new schedule"
+// @Success     		200  {object}  ScheduleResponse
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/schedules [post]
+func createScheduleHandler(c fiber.Ctx) error {
+	schedulerInstance, err := GetFromLocals[*scheduler.Instance](c, constants.SchedulerKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	scheduleModel, err := bindScheduleModel(c)
+	if err != nil {
+		return err
+	}
+
+	schedule, err := schedulerInstance.Create(scheduleModel.toSchedule())
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("schedulerInstance.Create: %w", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newScheduleResponse(schedulerInstance, schedule))
+}
+
+// @Summary				Update schedule
+// @Tags         		schedules
+// @Accept       		json
+// @Produce      		json
+// @Param 				id	path	string true "Schedule id"
+// @Param		 		schedule body ScheduleModel true "The updated schedule"
+// @Success     		200  {object}  ScheduleResponse
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/schedules/{id} [put]
+func updateScheduleHandler(c fiber.Ctx) error {
+	schedulerInstance, err := GetFromLocals[*scheduler.Instance](c, constants.SchedulerKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	scheduleModel, err := bindScheduleModel(c)
+	if err != nil {
+		return err
+	}
+
+	schedule, err := schedulerInstance.Update(c.Params("id"), scheduleModel.toSchedule())
+	if err != nil {
+		return newScheduleErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newScheduleResponse(schedulerInstance, schedule))
+}
+
+// @Summary				Delete schedule
+// @Description 		The runs of the schedule are kept in the run history
+// @Tags         		schedules
+// @Param 				id	path	string true "Schedule id"
+// @Success     		200
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/schedules/{id} [delete]
+func deleteScheduleHandler(c fiber.Ctx) error {
+	schedulerInstance, err := GetFromLocals[*scheduler.Instance](c, constants.SchedulerKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	if err := schedulerInstance.Delete(c.Params("id")); err != nil {
+		return newScheduleErrorResponse(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// @Summary				Run schedule now
+// @Description 		Starts the job of the schedule in the background, even if the schedule is disabled. The result is added to the run history
+// @Tags         		schedules
+// @Param 				id	path	string true "Schedule id"
+// @Success     		202
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/schedules/{id}/run [post]
+func runScheduleHandler(c fiber.Ctx) error {
+	schedulerInstance, err := GetFromLocals[*scheduler.Instance](c, constants.SchedulerKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	if err := schedulerInstance.RunNow(c.Params("id")); err != nil {
+		if errors.Is(err, scheduler.ErrAlreadyRunning) {
+			return NewErrorWithInternal(c, fiber.StatusConflict, "schedule is already running", err)
+		}
+		return newScheduleErrorResponse(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// @Summary				Get run history
+// @Description 		Returns the last runs of all schedules or, if an id is given, of one schedule. Newest run first
+// @Tags         		schedules
+// @Produce      		json
+// @Success     		200  {object}  []ScheduleRunResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/schedules/runs [get]
+// @Router       		/schedules/{id}/runs [get]
+func getScheduleRunsHandler(c fiber.Ctx) error {
+	schedulerInstance, err := GetFromLocals[*scheduler.Instance](c, constants.SchedulerKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	runs, err := schedulerInstance.Runs(c.Params("id"))
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("schedulerInstance.Runs: %w", err))
+	}
+
+	result := make([]ScheduleRunResponse, 0, len(runs))
+	for _, run := range runs {
+		result = append(result, ScheduleRunResponse{
+			ScheduleId:    run.ScheduleId,
+			ScheduleName:  run.ScheduleName,
+			JobType:       string(run.JobType),
+			StartedAtUtc:  run.StartedAtUtc,
+			FinishedAtUtc: run.FinishedAtUtc,
+			Success:       run.Success,
+			Output:        run.Output,
+			Error:         run.Error,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
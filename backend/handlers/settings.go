@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Phi-S/cs-server-manager/constants"
 	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/server"
 	"github.com/Phi-S/cs-server-manager/start_parameters_json"
 
 	"github.com/gofiber/fiber/v3"
@@ -16,6 +18,19 @@ type SettingsModel struct {
 	StartMap        string `json:"start_map" validate:"required,printascii,lte=32"`
 	MaxPlayers      uint8  `json:"max_players" validate:"required,number,lte=128"`
 	SteamLoginToken string `json:"steam_login_token" validate:"omitempty,alphanum,len=32"`
+	// One of: casual, competitive, wingman, deathmatch, armsrace, demolition. If empty, the server default is used
+	GameMode           string `json:"game_mode" validate:"omitempty,game_mode"`
+	WorkshopMap        string `json:"workshop_map" validate:"omitempty,workshop_id"`
+	WorkshopCollection string `json:"workshop_collection" validate:"omitempty,workshop_id"`
+	TvEnable           bool   `json:"tv_enable"`
+	// Additional launch options like "-tickrate 128". If launch_options and convars are not set, the saved values are kept
+	LaunchOptions []string      `json:"launch_options" validate:"omitempty,lte=32,dive,required,startswith=-,lte=128"`
+	Convars       []ConvarModel `json:"convars" validate:"omitempty,lte=64,dive"`
+}
+
+type ConvarModel struct {
+	Name  string `json:"name" validate:"required,lte=64"`
+	Value string `json:"value" validate:"omitempty,lte=128"`
 }
 
 var loginTokenVisibleCount = 4
@@ -43,23 +58,7 @@ func getSettingsHandler(c fiber.Ctx) error {
 		return NewInternalServerErrorWithInternal(c, err)
 	}
 
-	redactedSteamLoginToken := ""
-	for i, v := range sp.SteamLoginToken {
-		if i <= loginTokenVisibleCount {
-			redactedSteamLoginToken += string(v)
-		} else {
-			redactedSteamLoginToken += "X"
-		}
-	}
-
-	resp := SettingsModel{
-		Hostname:        sp.Hostname,
-		Password:        sp.Password,
-		StartMap:        sp.StartMap,
-		MaxPlayers:      sp.MaxPlayers,
-		SteamLoginToken: redactedSteamLoginToken,
-	}
-
+	resp := newSettingsModel(sp)
 	return c.Status(fiber.StatusOK).JSON(resp)
 }
 
@@ -92,6 +91,48 @@ func updateSettingsHandler(c fiber.Ctx) error {
 		return NewInternalServerErrorWithInternal(c, fmt.Errorf("startParametersJsonFile.Read(): %w", err))
 	}
 
+	sp.applyTo(&startParameters)
+
+	if _, err := startParameters.LaunchArgs(); err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, err.Error(), err)
+	}
+
+	if err := startParametersJsonFile.Write(startParameters); err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("startParametersJsonFile.Write: %w", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(startParameters)
+}
+
+func newSettingsModel(sp server.StartParameters) SettingsModel {
+	redactedSteamLoginToken := ""
+	for i, v := range sp.SteamLoginToken {
+		if i <= loginTokenVisibleCount {
+			redactedSteamLoginToken += string(v)
+		} else {
+			redactedSteamLoginToken += "X"
+		}
+	}
+
+	launchOptions, convars := splitAdditional(sp.Additional)
+
+	return SettingsModel{
+		Hostname:           sp.Hostname,
+		Password:           sp.Password,
+		StartMap:           sp.StartMap,
+		MaxPlayers:         sp.MaxPlayers,
+		SteamLoginToken:    redactedSteamLoginToken,
+		GameMode:           sp.GameMode,
+		WorkshopMap:        sp.WorkshopMap,
+		WorkshopCollection: sp.WorkshopCollection,
+		TvEnable:           sp.TvEnable,
+		LaunchOptions:      launchOptions,
+		Convars:            convars,
+	}
+}
+
+// applyTo updates the start parameters with the settings. The redacted steam login token is ignored
+func (sp SettingsModel) applyTo(startParameters *server.StartParameters) {
 	if sp.Hostname != startParameters.Hostname {
 		startParameters.Hostname = sp.Hostname
 	}
@@ -118,9 +159,45 @@ func updateSettingsHandler(c fiber.Ctx) error {
 		}
 	}
 
-	if err := startParametersJsonFile.Write(startParameters); err != nil {
-		return NewInternalServerErrorWithInternal(c, fmt.Errorf("startParametersJsonFile.Write: %w", err))
+	startParameters.GameMode = sp.GameMode
+	startParameters.WorkshopMap = sp.WorkshopMap
+	startParameters.WorkshopCollection = sp.WorkshopCollection
+	startParameters.TvEnable = sp.TvEnable
+
+	if sp.LaunchOptions != nil || sp.Convars != nil {
+		startParameters.Additional = joinAdditional(sp.LaunchOptions, sp.Convars)
 	}
+}
 
-	return c.Status(fiber.StatusOK).JSON(startParameters)
+// splitAdditional splits the additional launch arguments saved in the start parameters into launch options and convars
+func splitAdditional(additional []string) ([]string, []ConvarModel) {
+	launchOptions := make([]string, 0)
+	convars := make([]ConvarModel, 0)
+	for _, a := range additional {
+		launchArg, err := server.ParseLaunchArg(a)
+		if err != nil || launchArg.Prefix == server.FlagPrefix {
+			launchOptions = append(launchOptions, a)
+			continue
+		}
+
+		convars = append(convars, ConvarModel{
+			Name:  launchArg.Name,
+			Value: launchArg.Value,
+		})
+	}
+
+	return launchOptions, convars
+}
+
+func joinAdditional(launchOptions []string, convars []ConvarModel) []string {
+	additional := make([]string, 0, len(launchOptions)+len(convars))
+	for _, launchOption := range launchOptions {
+		additional = append(additional, strings.TrimSpace(launchOption))
+	}
+
+	for _, convar := range convars {
+		additional = append(additional, server.LaunchArg{Prefix: server.ConvarPrefix, Name: strings.TrimSpace(convar.Name), Value: strings.TrimSpace(convar.Value)}.String())
+	}
+
+	return additional
 }
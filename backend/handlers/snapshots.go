@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Phi-S/cs-server-manager/constants"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/server_snapshots"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type RollbackModel struct {
+	// empty restores the newest snapshot
+	Id string `json:"id" validate:"omitempty,lte=64"`
+}
+
+func RegisterSnapshots(r fiber.Router) {
+	r.Get("/update/snapshots", getSnapshotsHandler)
+	r.Delete("/update/snapshots/:id", deleteSnapshotHandler)
+	r.Post("/update/rollback", rollbackHandler)
+}
+
+func newSnapshotErrorResponse(c fiber.Ctx, err error) error {
+	if errors.Is(err, server_snapshots.ErrSnapshotNotFound) || errors.Is(err, server_snapshots.ErrNoSnapshot) {
+		return NewErrorWithInternal(c, fiber.StatusNotFound, err.Error(), err)
+	}
+	return NewInternalServerErrorWithInternal(c, err)
+}
+
+// @Summary				Get all snapshots
+// @Description 		Snapshots of the configs and plugins are created before every server update. The newest snapshot is first
+// @Tags         		update
+// @Produce      		json
+// @Success     		200  {object}  []server_snapshots.Snapshot
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/update/snapshots [get]
+func getSnapshotsHandler(c fiber.Ctx) error {
+	snapshotsInstance, err := GetFromLocals[*server_snapshots.Instance](c, constants.SnapshotsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	snapshots, err := snapshotsInstance.List()
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("snapshotsInstance.List: %w", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(snapshots)
+}
+
+// @Summary				Delete snapshot
+// @Tags         		update
+// @Param		 		id path string true "Snapshot id"
+// @Success     		200
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/update/snapshots/{id} [delete]
+func deleteSnapshotHandler(c fiber.Ctx) error {
+	snapshotsInstance, err := GetFromLocals[*server_snapshots.Instance](c, constants.SnapshotsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	if err := snapshotsInstance.Delete(c.Params("id")); err != nil {
+		return newSnapshotErrorResponse(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// @Summary				Roll back configs and plugins
+// @Description 		Restores the configs, plugins and gameinfo.gi from a snapshot. The request body is optional. Without id, the newest snapshot is restored.
+// @Description 		The game files are not part of the snapshot and stay on the installed build
+// @Tags         		update
+// @Accept       		json
+// @Produce      		json
+// @Param		 		snapshot body RollbackModel false "The snapshot to restore"
+// @Success     		200  {object}  server_snapshots.Snapshot
+// @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/update/rollback [post]
+func rollbackHandler(c fiber.Ctx) error {
+	snapshotsInstance, err := GetFromLocals[*server_snapshots.Instance](c, constants.SnapshotsKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	lock, serverInstance, steamcmdInstance, err := GetServerSteamcmdInstances(c)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	var rollbackModel RollbackModel
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&rollbackModel); err != nil {
+			return NewErrorWithInternal(c, fiber.StatusBadRequest, "request body is not valid", err)
+		}
+	}
+
+	if err := gvalidator.Instance().Struct(rollbackModel); err != nil {
+		return NewErrorValidation(c, err)
+	}
+
+	if err := TryLockServerSteamcmd(c, lock); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if serverInstance.IsRunning() {
+		return NewErrorWithMessage(c, fiber.StatusConflict, "can not roll back while the server is running")
+	}
+
+	if steamcmdInstance.IsRunning() {
+		return NewErrorWithMessage(c, fiber.StatusConflict, "can not roll back while the server is updating")
+	}
+
+	snapshot, err := snapshotsInstance.Restore(rollbackModel.Id)
+	if err != nil {
+		return newSnapshotErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(snapshot)
+}
@@ -7,18 +7,24 @@ import (
 
 	"github.com/Phi-S/cs-server-manager/constants"
 	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/server"
 	"github.com/Phi-S/cs-server-manager/start_parameters_json"
 	"github.com/Phi-S/cs-server-manager/status"
+	"github.com/Phi-S/cs-server-manager/supervisor"
 
 	"github.com/gofiber/fiber/v3"
 )
 
 type StartBody struct {
-	Hostname        string `json:"hostname" validate:"omitempty,lte=128"`
-	Password        string `json:"password" validate:"omitempty,alphanum,lte=32"`
-	StartMap        string `json:"start_map" validate:"omitempty,printascii,lte=32"`
-	MaxPlayers      uint8  `json:"max_players" validate:"omitempty,number,lte=128"`
-	SteamLoginToken string `json:"steam_login_token" validate:"omitempty,alphanum,len=32"`
+	Hostname           string `json:"hostname" validate:"omitempty,lte=128"`
+	Password           string `json:"password" validate:"omitempty,alphanum,lte=32"`
+	StartMap           string `json:"start_map" validate:"omitempty,printascii,lte=32"`
+	MaxPlayers         uint8  `json:"max_players" validate:"omitempty,number,lte=128"`
+	SteamLoginToken    string `json:"steam_login_token" validate:"omitempty,alphanum,len=32"`
+	GameMode           string `json:"game_mode" validate:"omitempty,game_mode"`
+	WorkshopMap        string `json:"workshop_map" validate:"omitempty,workshop_id"`
+	WorkshopCollection string `json:"workshop_collection" validate:"omitempty,workshop_id"`
+	TvEnable           *bool  `json:"tv_enable"`
 }
 
 func RegisterStartStop(r fiber.Router) {
@@ -30,9 +36,11 @@ func RegisterStartStop(r fiber.Router) {
 // @Description	 Starts the server with the given start parameters
 // @Tags         server
 // @Accept       json
+// @Param 		 profile query string false "Start the server with the start parameters of this profile instead of the current settings. The profile becomes the active profile"
 // @Param 		 startParameters body StartBody false "You can provide no, all or only a few start parameters. The provided start parameters will overwrite the saved start parameters in the start-parameters.json file if the server started successfully."
 // @Success      200
 // @Failure      400  {object}  handlers.ErrorResponse
+// @Failure      409  {object}  handlers.ErrorResponse
 // @Failure      500  {object}  handlers.ErrorResponse
 // @Router       /start [post]
 func startHandler(c fiber.Ctx) error {
@@ -50,7 +58,9 @@ func startHandler(c fiber.Ctx) error {
 		return NewErrorWithMessage(c, fiber.StatusInternalServerError, "Game server is not yet installed")
 	}
 
-	lock.Lock()
+	if err := TryLockServerSteamcmd(c, lock); err != nil {
+		return err
+	}
 	defer lock.Unlock()
 
 	if steamcmd.IsRunning() {
@@ -66,9 +76,23 @@ func startHandler(c fiber.Ctx) error {
 		return NewInternalServerErrorWithInternal(c, err)
 	}
 
-	startParameters, err := startParameterJsonFile.Read()
+	profiles, err := GetFromLocals[*start_parameters_json.Profiles](c, constants.ProfilesKey)
 	if err != nil {
-		return NewInternalServerErrorWithInternal(c, fmt.Errorf("startParameterJsonFile.Read(): %w", err))
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	var startParameters server.StartParameters
+	profile := c.Query("profile")
+	if profile != "" {
+		startParameters, err = profiles.Get(profile)
+		if err != nil {
+			return newProfileErrorResponse(c, err)
+		}
+	} else {
+		startParameters, err = startParameterJsonFile.Read()
+		if err != nil {
+			return NewInternalServerErrorWithInternal(c, fmt.Errorf("startParameterJsonFile.Read(): %w", err))
+		}
 	}
 
 	if len(c.Body()) > 0 {
@@ -100,6 +124,26 @@ func startHandler(c fiber.Ctx) error {
 		if loginToken := strings.TrimSpace(startBody.SteamLoginToken); loginToken != "" {
 			startParameters.SteamLoginToken = loginToken
 		}
+
+		if gameMode := strings.TrimSpace(startBody.GameMode); gameMode != "" {
+			startParameters.GameMode = gameMode
+		}
+
+		if workshopMap := strings.TrimSpace(startBody.WorkshopMap); workshopMap != "" {
+			startParameters.WorkshopMap = workshopMap
+		}
+
+		if workshopCollection := strings.TrimSpace(startBody.WorkshopCollection); workshopCollection != "" {
+			startParameters.WorkshopCollection = workshopCollection
+		}
+
+		if startBody.TvEnable != nil {
+			startParameters.TvEnable = *startBody.TvEnable
+		}
+	}
+
+	if _, err := startParameters.LaunchArgs(); err != nil {
+		return NewErrorWithInternal(c, fiber.StatusBadRequest, err.Error(), err)
 	}
 
 	if err := serverInstance.Start(startParameters); err != nil {
@@ -110,14 +154,27 @@ func startHandler(c fiber.Ctx) error {
 		slog.Warn("server started but failed to save valid start parameters to file. " + err.Error())
 	}
 
+	if profile != "" {
+		if err := profiles.SetActive(profile); err != nil {
+			slog.Warn("server started but failed to mark profile as active. " + err.Error())
+		}
+	}
+
+	supervisorInstance, err := GetFromLocals[*supervisor.Instance](c, constants.SupervisorKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+	supervisorInstance.Reset()
+
 	return c.SendStatus(fiber.StatusOK)
 }
 
 // @Summary 	Stop the server
-// @Description Stops the server of if the server is not running, returns 200 OK
+// @Description Stops the server of if the server is not running, returns 200 OK. A pending automatic restart after a crash is cancelled
 // @Tags        server
 // @Success     200
 // @Failure     400  {object}  handlers.ErrorResponse
+// @Failure     409  {object}  handlers.ErrorResponse
 // @Failure     500  {object}  handlers.ErrorResponse
 // @Router      /stop [post]
 func stopHandler(c fiber.Ctx) error {
@@ -126,7 +183,17 @@ func stopHandler(c fiber.Ctx) error {
 		return NewInternalServerErrorWithInternal(c, err)
 	}
 
-	lock.Lock()
+	supervisorInstance, err := GetFromLocals[*supervisor.Instance](c, constants.SupervisorKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	// a manual stop also cancels a pending automatic restart
+	supervisorInstance.Cancel()
+
+	if err := TryLockServerSteamcmd(c, lock); err != nil {
+		return err
+	}
 	defer lock.Unlock()
 
 	if !server.IsRunning() {
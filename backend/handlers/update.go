@@ -1,18 +1,53 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/constants"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/steamcmd"
+	"github.com/Phi-S/cs-server-manager/update_checker"
+
 	"github.com/gofiber/fiber/v3"
 )
 
+type UpdateCheckResponse struct {
+	InstalledBuildId string    `json:"installed_build_id"`
+	LatestBuildId    string    `json:"latest_build_id"`
+	Branch           string    `json:"branch"`
+	UpdateAvailable  bool      `json:"update_available"`
+	CheckedAtUtc     time.Time `json:"checked_at_utc"`
+}
+
 func RegisterUpdate(r fiber.Router) {
 	r.Post("/update", startUpdateHandler)
 	r.Post("/update/cancel", cancelUpdateHandler)
+	r.Post("/update/check", checkUpdateHandler)
+}
+
+type UpdateModel struct {
+	// empty uses the last used branch
+	Branch         string `json:"branch" validate:"omitempty,steam_branch"`
+	BranchPassword string `json:"branch_password" validate:"omitempty,steam_branch_password"`
+	// defaults to true
+	Validate  *bool `json:"validate"`
+	CheckOnly bool  `json:"check_only"`
 }
 
 // @Summary				Start server update
+// @Description 		The request body is optional. Without body, the last used branch is updated and all files are validated.
+// @Description 		With "check_only", no update is started and the installed build is compared with the latest build of the branch
 // @Tags         		update
-// @Success     		200
+// @Accept       		json
+// @Produce      		json
+// @Param		 		options body UpdateModel false "Update options"
+// @Success     		200  {object}  UpdateCheckResponse
+// @Success     		202
 // @Failure				400  {object}  handlers.ErrorResponse
+// @Failure				404  {object}  handlers.ErrorResponse
+// @Failure				409  {object}  handlers.ErrorResponse
 // @Failure				500  {object}  handlers.ErrorResponse
 // @Router       		/update [post]
 func startUpdateHandler(c fiber.Ctx) error {
@@ -21,7 +56,31 @@ func startUpdateHandler(c fiber.Ctx) error {
 		return NewInternalServerErrorWithInternal(c, err)
 	}
 
-	lock.Lock()
+	var updateModel UpdateModel
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&updateModel); err != nil {
+			return NewErrorWithInternal(c, fiber.StatusBadRequest, "request body is not valid", err)
+		}
+	}
+
+	if err := gvalidator.Instance().Struct(updateModel); err != nil {
+		return NewErrorValidation(c, err)
+	}
+
+	options := steamcmd.DefaultUpdateOptions()
+	options.Branch = updateModel.Branch
+	options.BranchPassword = updateModel.BranchPassword
+	if updateModel.Validate != nil {
+		options.Validate = *updateModel.Validate
+	}
+
+	if updateModel.CheckOnly {
+		return checkUpdateBranch(c, steamcmdInstance, options)
+	}
+
+	if err := TryLockServerSteamcmd(c, lock); err != nil {
+		return err
+	}
 	defer lock.Unlock()
 
 	if steamcmdInstance.IsRunning() {
@@ -32,13 +91,50 @@ func startUpdateHandler(c fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "can not update server. Server is still running")
 	}
 
-	if err := steamcmdInstance.Update(false); err != nil {
+	if err := steamcmdInstance.Update(false, options); err != nil {
 		return NewErrorWithInternal(c, fiber.StatusInternalServerError, "failed to update server", err)
 	}
 
 	return c.SendStatus(fiber.StatusAccepted)
 }
 
+// checkUpdateBranch compares the installed build with the latest build of the branch without changing anything
+func checkUpdateBranch(c fiber.Ctx, steamcmdInstance *steamcmd.Instance, options steamcmd.UpdateOptions) error {
+	if steamcmdInstance.IsRunning() {
+		return NewErrorWithMessage(c, fiber.StatusConflict, "can not check for updates while the server is updating")
+	}
+
+	branch := options.Branch
+	if branch == "" {
+		lastBranch, err := steamcmdInstance.LastBranch()
+		if err != nil {
+			return NewInternalServerErrorWithInternal(c, fmt.Errorf("steamcmdInstance.LastBranch: %w", err))
+		}
+		branch = lastBranch.Name
+	}
+
+	installed, err := steamcmdInstance.InstalledBuild()
+	if err != nil && !errors.Is(err, steamcmd.ErrNotInstalled) {
+		return NewInternalServerErrorWithInternal(c, fmt.Errorf("steamcmdInstance.InstalledBuild: %w", err))
+	}
+
+	latestBuildId, err := steamcmdInstance.LatestBuildId(branch)
+	if err != nil {
+		if errors.Is(err, steamcmd.ErrBranchNotFound) {
+			return NewErrorWithInternal(c, fiber.StatusNotFound, fmt.Sprintf("branch '%v' not found", branch), err)
+		}
+		return NewErrorWithInternal(c, fiber.StatusInternalServerError, "failed to check for updates", fmt.Errorf("steamcmdInstance.LatestBuildId: %w", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(UpdateCheckResponse{
+		InstalledBuildId: installed.BuildId,
+		LatestBuildId:    latestBuildId,
+		Branch:           branch,
+		UpdateAvailable:  installed.BuildId != latestBuildId,
+		CheckedAtUtc:     time.Now().UTC(),
+	})
+}
+
 // @Summary				Cancel the server update
 // @Description 		Cancel the currently running server update or if no update is currently running, returns 200 OK
 // @Tags         		update
@@ -62,3 +158,40 @@ func cancelUpdateHandler(c fiber.Ctx) error {
 
 	return c.SendStatus(fiber.StatusOK)
 }
+
+// @Summary				Check for server updates
+// @Description 		Compares the build id of the installed server with the latest build id on steam. If the server is not installed, no update is available
+// @Tags         		update
+// @Produce      		json
+// @Success     		200  {object}  UpdateCheckResponse
+// @Failure				409  {object}  handlers.ErrorResponse
+// @Failure				500  {object}  handlers.ErrorResponse
+// @Router       		/update/check [post]
+func checkUpdateHandler(c fiber.Ctx) error {
+	_, _, steamcmdInstance, err := GetServerSteamcmdInstances(c)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	updateCheckerInstance, err := GetFromLocals[*update_checker.Instance](c, constants.UpdateCheckerKey)
+	if err != nil {
+		return NewInternalServerErrorWithInternal(c, err)
+	}
+
+	if steamcmdInstance.IsRunning() {
+		return NewErrorWithMessage(c, fiber.StatusConflict, "can not check for updates while the server is updating")
+	}
+
+	result, err := updateCheckerInstance.Check()
+	if err != nil {
+		return NewErrorWithInternal(c, fiber.StatusInternalServerError, "failed to check for updates", fmt.Errorf("updateCheckerInstance.Check: %w", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(UpdateCheckResponse{
+		InstalledBuildId: result.InstalledBuildId,
+		LatestBuildId:    result.LatestBuildId,
+		Branch:           result.Branch,
+		UpdateAvailable:  result.UpdateAvailable,
+		CheckedAtUtc:     result.CheckedAtUtc,
+	})
+}
@@ -1,24 +1,23 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/Phi-S/cs-server-manager/config"
-	"github.com/Phi-S/cs-server-manager/editor"
 	"github.com/Phi-S/cs-server-manager/event"
-	"github.com/Phi-S/cs-server-manager/files"
 	"github.com/Phi-S/cs-server-manager/game_events"
-	"github.com/Phi-S/cs-server-manager/gvalidator"
-	"github.com/Phi-S/cs-server-manager/logwrt"
+	"github.com/Phi-S/cs-server-manager/game_maps"
+	"github.com/Phi-S/cs-server-manager/instances"
 	"github.com/Phi-S/cs-server-manager/plugins"
 	"github.com/Phi-S/cs-server-manager/server"
-	"github.com/Phi-S/cs-server-manager/start_parameters_json"
 	"github.com/Phi-S/cs-server-manager/status"
 	"github.com/Phi-S/cs-server-manager/steamcmd"
+	"github.com/Phi-S/cs-server-manager/supervisor"
+	"github.com/Phi-S/cs-server-manager/update_checker"
+	"github.com/Phi-S/cs-server-manager/websocket_server"
 )
 
 func createdRequiredDirs(cfg config.Config) error {
@@ -41,105 +40,19 @@ func createdRequiredDirs(cfg config.Config) error {
 	return nil
 }
 
-func createRequiredServices(cfg config.Config) (
-	*steamcmd.Instance,
-	*server.Instance,
-	*start_parameters_json.Instance,
-	*logwrt.LogWriter,
-	*status.Status,
-	*WebSocketServer,
-	*game_events.Instance,
-	*plugins.Instance,
-	*editor.Instance,
-	error,
-) {
-	steamcmdInstance, err := steamcmd.NewInstance(cfg.SteamcmdDir, cfg.ServerDir)
-	if err != nil {
-		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("create steamcmd instance: %w", err)
-	}
-
-	serverInstance, err := server.NewInstance(cfg.ServerDir, cfg.CsPort, cfg.SteamcmdDir)
-	if err != nil {
-		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("create server instance: %w", err)
-	}
-
-	startParametersJsonPath := filepath.Join(cfg.DataDir, "start-parameters.json")
-	startParametersJsonFile, err := start_parameters_json.New(startParametersJsonPath, *server.DefaultStartParameters())
-	if err != nil {
-		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("create start parameter json instance: %w", err)
-	}
-
-	logDir := filepath.Join(cfg.DataDir, "logs")
-	userLogWriter, err := logwrt.NewLogWriter(logDir, "user")
-	if err != nil {
-		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("create user log writer: %w", err)
-	}
-
-	startParameters, err := startParametersJsonFile.Read()
-	if err != nil {
-		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("read start-parameters.json: %w", err)
-	}
-
-	isGameServerInstalled, err := isGameServerInstalled(cfg.ServerDir)
-	if err != nil {
-		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("check if game server is installed: %w", err)
-	}
-
-	statusInstance := status.NewStatus(
-		isGameServerInstalled,
-		startParameters.Hostname,
-		cfg.Ip,
-		cfg.CsPort,
-		startParameters.Password,
-		startParameters.MaxPlayers,
-		startParameters.StartMap,
-	)
-
-	webSocketServer := NewWebSocketServer()
-
-	gameEventsInstance := game_events.Instance{}
-
-	pluginsJsonFilePath := filepath.Join(cfg.DataDir, "plugins.json")
-	installedPluginsJsonPath := filepath.Join(cfg.DataDir, "installed-plugin.json")
-	csgoDir := filepath.Join(cfg.ServerDir, "game", "csgo")
-	if !strings.HasSuffix(csgoDir, string(filepath.Separator)) {
-		csgoDir = csgoDir + string(filepath.Separator)
-	}
-	pluginsInstance, err := plugins.New(csgoDir, pluginsJsonFilePath, installedPluginsJsonPath)
-	if err != nil {
-		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("create plugins instance: %w", err)
-	}
-
-	editorFilesJsonPath := filepath.Join(cfg.DataDir, "editor-files.json")
-	editorInstance, err := editor.New(editorFilesJsonPath, cfg.ServerDir)
-	if err != nil {
-		return nil, nil, nil, nil, nil, nil, nil, nil, nil, fmt.Errorf("create editor instance: %w", err)
-	}
-
-	return steamcmdInstance,
-		serverInstance,
-		startParametersJsonFile,
-		userLogWriter,
-		statusInstance,
-		webSocketServer,
-		&gameEventsInstance,
-		pluginsInstance,
-		editorInstance,
-		nil
-}
+func registerEvents(configInstance config.Config, instance *instances.Instance) {
+	serverInstance := instance.Server
+	steamcmdInstance := instance.Steamcmd
+	startParametersJfileInstance := instance.StartParametersJson
+	statusInstance := instance.Status
+	webSocketServerInstance := instance.WebSocketServer
+	gameEventsInstance := instance.GameEvents
+	mapsInstance := instance.Maps
+	pluginsInstance := instance.Plugins
+	updateCheckerInstance := instance.UpdateChecker
+	snapshotsInstance := instance.Snapshots
 
-func registerEvents(
-	configInstance config.Config,
-	serverInstance *server.Instance,
-	steamcmdInstance *steamcmd.Instance,
-	startParametersJfileInstance *start_parameters_json.Instance,
-	logWriterInstance *logwrt.LogWriter,
-	statusInstance *status.Status,
-	webSocketServerInstance *WebSocketServer,
-	gameEventsInstance *game_events.Instance,
-	pluginsInstance *plugins.Instance,
-) {
-	logEvents(logWriterInstance, webSocketServerInstance, serverInstance, steamcmdInstance, gameEventsInstance, pluginsInstance)
+	logEvents(instance)
 
 	// detect game events via server output
 	serverInstance.OnOutput(func(p event.PayloadWithData[string]) {
@@ -160,10 +73,7 @@ func registerEvents(
 			if internalStatus.State != status.ServerStarted &&
 				internalStatus.State != status.ServerStarting {
 
-				internalStatus.Hostname = data.Data.Hostname
-				internalStatus.Map = data.Data.StartMap
-				internalStatus.MaxPlayerCount = data.Data.MaxPlayers
-				internalStatus.Password = data.Data.Password
+				setStartParametersInStatus(internalStatus, data.Data)
 			}
 		})
 	})
@@ -184,13 +94,26 @@ func registerEvents(
 	serverInstance.OnStarted(func(e event.PayloadWithData[server.StartParameters]) {
 		statusInstance.Update(func(internalStatus *status.InternalStatus) {
 			internalStatus.State = status.ServerStarted
-			internalStatus.Hostname = e.Data.Hostname
-			internalStatus.MaxPlayerCount = e.Data.MaxPlayers
-			internalStatus.Map = e.Data.StartMap
-			internalStatus.Password = e.Data.Password
+			setStartParametersInStatus(internalStatus, e.Data)
 		})
 	})
 
+	// the rotation is applied in a new goroutine, so the server start does not wait for the nextlevel command
+	serverInstance.OnStarted(func(e event.PayloadWithData[server.StartParameters]) {
+		// workshop maps are loaded via host_workshop_map. The rotation starts from the beginning
+		currentMap := e.Data.StartMap
+		if e.Data.WorkshopMap != "" || e.Data.WorkshopCollection != "" {
+			currentMap = ""
+		}
+
+		go applyMapRotation(mapsInstance, currentMap)
+	})
+
+	// commands of plugin actions are executed once on the next server start
+	serverInstance.OnStarted(func(e event.PayloadWithData[server.StartParameters]) {
+		go sendPendingPluginCommands(serverInstance, pluginsInstance)
+	})
+
 	serverInstance.OnCrashed(func(p event.PayloadWithData[error]) {
 		statusInstance.Update(func(internalStatus *status.InternalStatus) {
 			internalStatus.State = status.Idle
@@ -201,10 +124,16 @@ func registerEvents(
 				return
 			}
 
-			internalStatus.Hostname = startParametersJson.Hostname
-			internalStatus.MaxPlayerCount = startParametersJson.MaxPlayers
-			internalStatus.Map = startParametersJson.StartMap
-			internalStatus.Password = startParametersJson.Password
+			setStartParametersInStatus(internalStatus, startParametersJson)
+		})
+
+		// called after the status update so the crash-loop state can not be overwritten by the idle state
+		instance.Supervisor.HandleCrash(p.Data)
+	})
+
+	instance.Supervisor.OnCrashLoopDetected(func(p event.PayloadWithData[supervisor.CrashLoop]) {
+		statusInstance.Update(func(internalStatus *status.InternalStatus) {
+			internalStatus.State = status.CrashLoop
 		})
 	})
 
@@ -218,23 +147,56 @@ func registerEvents(
 				return
 			}
 
-			internalStatus.Hostname = startParametersJson.Hostname
-			internalStatus.MaxPlayerCount = startParametersJson.MaxPlayers
-			internalStatus.Map = startParametersJson.StartMap
-			internalStatus.Password = startParametersJson.Password
+			setStartParametersInStatus(internalStatus, startParametersJson)
 		})
 	})
 
 	steamcmdInstance.OnStarted(func(p event.DefaultPayload) {
 		statusInstance.Update(func(internalStatus *status.InternalStatus) {
 			internalStatus.State = status.SteamcmdUpdating
+			internalStatus.UpdateProgress = nil
+		})
+	})
+
+	// the update fails if the snapshot can not be created, so the configs and plugins can always be rolled back
+	steamcmdInstance.SetBeforeUpdate(func() error {
+		if !snapshotsInstance.Enabled() {
+			return nil
+		}
+
+		build, err := steamcmdInstance.InstalledBuild()
+		if err != nil {
+			if errors.Is(err, steamcmd.ErrNotInstalled) {
+				return nil
+			}
+			return fmt.Errorf("get installed build for snapshot: %w", err)
+		}
+
+		if _, err := snapshotsInstance.Create(build.BuildId, build.Branch); err != nil {
+			return fmt.Errorf("create snapshot: %w", err)
+		}
+
+		return nil
+	})
+
+	steamcmdInstance.OnProgress(func(p event.PayloadWithData[steamcmd.Progress]) {
+		if err := webSocketServerInstance.Broadcast("update_progress", p.Data); err != nil {
+			slog.Error("after steamcmd progress: send update progress message", "progress", p.Data, "error", err)
+		}
+
+		statusInstance.Update(func(internalStatus *status.InternalStatus) {
+			if internalStatus.State == status.SteamcmdUpdating {
+				progress := p.Data
+				internalStatus.UpdateProgress = &progress
+			}
 		})
 	})
 
 	steamcmdInstance.OnFinished(func(p event.DefaultPayload) {
 		statusInstance.Update(func(internalStatus *status.InternalStatus) {
 			internalStatus.State = status.Idle
-			isServerInstalled, err := isGameServerInstalled(configInstance.ServerDir)
+			internalStatus.UpdateProgress = nil
+			isServerInstalled, err := instances.IsGameServerInstalled(instance.ServerDir)
 			if err != nil {
 				slog.Warn("after steamcmd finished: check if game server is installed", "error", err)
 				return
@@ -244,11 +206,23 @@ func registerEvents(
 		})
 	})
 
+	// the appmanifest contains the new build id after the update
+	steamcmdInstance.OnFinished(func(p event.DefaultPayload) {
+		if _, err := updateCheckerInstance.RefreshInstalled(); err != nil {
+			slog.Warn("after steamcmd finished: refresh installed build", "error", err)
+		}
+	})
+
+	// the update can overwrite plugin changes like the metamod entry of the gameinfo.gi.
+	// The plugins are repaired before steamcmd reports the update as finished, so the server is never started with broken plugins
+	steamcmdInstance.SetAfterUpdate(instance.RepairPluginsAfterUpdate)
+
 	steamcmdInstance.OnCancelled(func(p event.DefaultPayload) {
 		statusInstance.Update(func(internalStatus *status.InternalStatus) {
 			internalStatus.State = status.Idle
+			internalStatus.UpdateProgress = nil
 
-			isServerInstalled, err := isGameServerInstalled(configInstance.ServerDir)
+			isServerInstalled, err := instances.IsGameServerInstalled(instance.ServerDir)
 			if err != nil {
 				slog.Warn("after steamcmd go canceled: check if game server is installed", "error", err)
 				return
@@ -261,8 +235,9 @@ func registerEvents(
 	steamcmdInstance.OnFailed(func(p event.PayloadWithData[error]) {
 		statusInstance.Update(func(internalStatus *status.InternalStatus) {
 			internalStatus.State = status.Idle
+			internalStatus.UpdateProgress = nil
 
-			isServerInstalled, err := isGameServerInstalled(configInstance.ServerDir)
+			isServerInstalled, err := instances.IsGameServerInstalled(instance.ServerDir)
 			if err != nil {
 				slog.Warn("after steamcmd failed: check if game server is installed", "error", err)
 				return
@@ -272,6 +247,25 @@ func registerEvents(
 		})
 	})
 
+	// update checker
+	updateCheckerInstance.OnChecked(func(p event.PayloadWithData[update_checker.Result]) {
+		statusInstance.Update(func(internalStatus *status.InternalStatus) {
+			internalStatus.UpdateAvailable = p.Data.UpdateAvailable
+			internalStatus.InstalledBuildId = p.Data.InstalledBuildId
+			internalStatus.InstalledBranch = ""
+			if p.Data.InstalledBuildId != "" {
+				internalStatus.InstalledBranch = p.Data.Branch
+			}
+			internalStatus.LatestBuildId = p.Data.LatestBuildId
+		})
+	})
+
+	updateCheckerInstance.OnUpdateAvailable(func(p event.PayloadWithData[update_checker.Result]) {
+		if configInstance.AutoUpdate {
+			go autoUpdate(instance)
+		}
+	})
+
 	//game_events
 	gameEventsInstance.OnMapChanged(func(p event.PayloadWithData[string]) {
 		statusInstance.Update(func(internalStatus *status.InternalStatus) {
@@ -279,6 +273,11 @@ func registerEvents(
 		})
 	})
 
+	// game events are detected while handling the server output. Commands can only be sent after the output is handled
+	gameEventsInstance.OnMatchEnded(func(p event.PayloadWithData[game_events.MatchEnded]) {
+		go applyMapRotation(mapsInstance, p.Data.Map)
+	})
+
 	gameEventsInstance.OnPlayerConnected(func(p event.PayloadWithData[game_events.PlayerConnected]) {
 		statusInstance.Update(func(internalStatus *status.InternalStatus) {
 			internalStatus.PlayerCount++
@@ -286,9 +285,16 @@ func registerEvents(
 	})
 
 	gameEventsInstance.OnPlayerDisconnected(func(p event.PayloadWithData[string]) {
+		var playerCount uint8
 		statusInstance.Update(func(internalStatus *status.InternalStatus) {
 			internalStatus.PlayerCount--
+			playerCount = internalStatus.PlayerCount
 		})
+
+		// an available update was postponed until the last player left
+		if configInstance.AutoUpdate && playerCount == 0 && updateCheckerInstance.LastResult().UpdateAvailable {
+			go autoUpdate(instance)
+		}
 	})
 
 	//plugins
@@ -327,53 +333,91 @@ func registerEvents(
 			internalStatus.State = status.Idle
 		})
 	})
-}
 
-func isGameServerInstalled(serverDir string) (bool, error) {
-	if err := gvalidator.Instance().Var(serverDir, "dir"); err != nil {
-		return false, nil
-	}
+	pluginsInstance.OnPluginDownloadProgressEvent(func(p event.PayloadWithData[plugins.PluginDownloadProgressPayload]) {
+		if err := webSocketServerInstance.Broadcast("plugin_download_progress", p.Data); err != nil {
+			slog.Error("after plugin download progress: send plugin download progress message", "progress", p.Data, "error", err)
+		}
+	})
 
-	size, err := files.GetDirSize(serverDir)
-	if err != nil {
-		return false, fmt.Errorf("failed to get serverDir '%v' size: %w", serverDir, err)
-	}
+	pluginsInstance.OnPluginUpgradingEvent(func(p event.PayloadWithData[plugins.PluginUpgradeEventsPayload]) {
+		statusInstance.Update(func(internalStatus *status.InternalStatus) {
+			internalStatus.State = status.PluginUpgrading
+		})
+		broadcastPluginUpgrade(webSocketServerInstance, "upgrading", p.Data)
+	})
 
-	gib := size / 1024 / 1024 / 1024
-	if gib < 30 {
-		return false, nil
-	}
+	pluginsInstance.OnPluginUpgradedEvent(func(p event.PayloadWithData[plugins.PluginUpgradeEventsPayload]) {
+		statusInstance.Update(func(internalStatus *status.InternalStatus) {
+			internalStatus.State = status.Idle
+		})
+		broadcastPluginUpgrade(webSocketServerInstance, "upgraded", p.Data)
+	})
 
-	csgoDir := filepath.Join(serverDir, "game", "csgo")
-	if err := gvalidator.Instance().Var(csgoDir, "dir"); err != nil {
-		return false, nil
-	}
+	pluginsInstance.OnPluginUpgradeFailedEvent(func(p event.PayloadWithData[plugins.PluginUpgradeEventsPayload]) {
+		statusInstance.Update(func(internalStatus *status.InternalStatus) {
+			internalStatus.State = status.Idle
+		})
+		broadcastPluginUpgrade(webSocketServerInstance, "failed", p.Data)
+	})
+}
 
-	if err := gvalidator.Instance().Var(filepath.Join(csgoDir, "pak01_001.vpk"), "file"); err != nil {
-		return false, nil
-	}
+type pluginUpgradeMessage struct {
+	// upgrading, upgraded or failed
+	State string `json:"state"`
+	plugins.PluginUpgradeEventsPayload
+}
 
-	if err := gvalidator.Instance().Var(filepath.Join(csgoDir, "pak01_001.vpk"), "file"); err != nil {
-		return false, nil
+func broadcastPluginUpgrade(webSocketServerInstance *websocket_server.Instance, state string, payload plugins.PluginUpgradeEventsPayload) {
+	message := pluginUpgradeMessage{State: state, PluginUpgradeEventsPayload: payload}
+	if err := webSocketServerInstance.Broadcast("plugin_upgrade", message); err != nil {
+		slog.Error("send plugin upgrade message", "message", message, "error", err)
 	}
+}
 
-	if err := gvalidator.Instance().Var(filepath.Join(csgoDir, "pak01_002.vpk"), "file"); err != nil {
-		return false, nil
+func setStartParametersInStatus(internalStatus *status.InternalStatus, sp server.StartParameters) {
+	internalStatus.Hostname = sp.Hostname
+	internalStatus.MaxPlayerCount = sp.MaxPlayers
+	internalStatus.Map = sp.StartMap
+	internalStatus.Password = sp.Password
+	internalStatus.GameMode = sp.GameMode
+	internalStatus.WorkshopMap = sp.WorkshopMap
+	internalStatus.WorkshopCollection = sp.WorkshopCollection
+	internalStatus.TvEnable = sp.TvEnable
+}
+
+func applyMapRotation(mapsInstance *game_maps.Instance, currentMap string) {
+	if _, err := mapsInstance.ApplyRotation(currentMap); err != nil && !errors.Is(err, game_maps.ErrRotationNotEnabled) {
+		slog.Error("apply map rotation", "current_map", currentMap, "error", err)
 	}
+}
 
-	cfgFolder := filepath.Join(csgoDir, "cfg")
-	if err := gvalidator.Instance().Var(csgoDir, "dir"); err != nil {
-		return false, nil
+func sendPendingPluginCommands(serverInstance *server.Instance, pluginsInstance *plugins.Instance) {
+	commands, err := pluginsInstance.TakePendingCommands()
+	if err != nil {
+		slog.Error("take pending plugin commands", "error", err)
+		return
 	}
 
-	if err := gvalidator.Instance().Var(filepath.Join(cfgFolder, "gamemode_competitive.cfg"), "file"); err != nil {
-		return false, nil
+	for _, command := range commands {
+		if _, err := serverInstance.SendCommand(command); err != nil {
+			slog.Error("send pending plugin command", "command", command, "error", err)
+		}
 	}
+}
 
-	if err := gvalidator.Instance().Var(filepath.Join(cfgFolder, "gamemode_deathmatch.cfg"), "file"); err != nil {
-		return false, nil
+func autoUpdate(instance *instances.Instance) {
+	err := instance.AutoUpdate()
+	if errors.Is(err, instances.ErrNoUpdateAvailable) {
+		return
 	}
 
-	return true, nil
+	if errors.Is(err, instances.ErrPlayersConnected) {
+		slog.Info("auto update postponed until no players are connected", "instance", instance.Id)
+		return
+	}
 
+	if err != nil {
+		slog.Error("auto update", "instance", instance.Id, "error", err)
+	}
 }
@@ -0,0 +1,30 @@
+package instances
+
+import "errors"
+
+var (
+	ErrNoUpdateAvailable = errors.New("no update available")
+	ErrPlayersConnected  = errors.New("players are connected to the server")
+)
+
+// AutoUpdate updates the server if the last update check found a newer build and no players are connected.
+// A running server is stopped for the update and started again afterward.
+// The ServerSteamcmdLock is not held while steamcmd is updating the server, see updateServer
+func (i *Instance) AutoUpdate() error {
+	_, err := i.updateServer(func() error {
+		if !i.UpdateChecker.LastResult().UpdateAvailable {
+			return ErrNoUpdateAvailable
+		}
+
+		if i.Steamcmd.IsRunning() {
+			return errors.New("another update is already running")
+		}
+
+		if i.Server.IsRunning() && i.Status.Status().PlayerCount > 0 {
+			return ErrPlayersConnected
+		}
+
+		return nil
+	})
+	return err
+}
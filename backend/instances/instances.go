@@ -0,0 +1,457 @@
+package instances
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Phi-S/cs-server-manager/backup"
+	"github.com/Phi-S/cs-server-manager/config"
+	"github.com/Phi-S/cs-server-manager/editor"
+	"github.com/Phi-S/cs-server-manager/files"
+	"github.com/Phi-S/cs-server-manager/game_events"
+	"github.com/Phi-S/cs-server-manager/game_maps"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/logwrt"
+	"github.com/Phi-S/cs-server-manager/plugins"
+	"github.com/Phi-S/cs-server-manager/rcon"
+	"github.com/Phi-S/cs-server-manager/scheduler"
+	"github.com/Phi-S/cs-server-manager/server"
+	"github.com/Phi-S/cs-server-manager/server_snapshots"
+	"github.com/Phi-S/cs-server-manager/start_parameters_json"
+	"github.com/Phi-S/cs-server-manager/status"
+	"github.com/Phi-S/cs-server-manager/steamcmd"
+	"github.com/Phi-S/cs-server-manager/supervisor"
+	"github.com/Phi-S/cs-server-manager/update_checker"
+	"github.com/Phi-S/cs-server-manager/websocket_server"
+)
+
+const DefaultId = "default"
+
+type Definition struct {
+	Id        string `json:"id" validate:"required,alphanum,lte=32"`
+	CsPort    string `json:"cs_port" validate:"required,numeric,port"`
+	ServerDir string `json:"server_dir" validate:"omitempty,dirpath"`
+	RconPort  string `json:"rcon_port" validate:"omitempty,numeric,port"`
+}
+
+// Instance bundles all services that belong to one game server
+type Instance struct {
+	Id        string
+	CsPort    string
+	DataDir   string
+	ServerDir string
+	RconPort  string
+
+	// this lock is used to prevent collision between the server and steamcmd instance
+	// Fox example the lock is used to prevent the server from being started while a steamcmd updated is getting started at the same time.
+	// This can occur if two http request are coming in at the same time and the internal status of the steamcmd and/or server instances is not yet updated
+	ServerSteamcmdLock *sync.Mutex
+
+	Server              *server.Instance
+	Steamcmd            *steamcmd.Instance
+	UpdateChecker       *update_checker.Instance
+	Snapshots           *server_snapshots.Instance
+	Supervisor          *supervisor.Instance
+	StartParametersJson *start_parameters_json.Instance
+	Profiles            *start_parameters_json.Profiles
+	UserLogWriter       *logwrt.LogWriter
+	Status              *status.Status
+	WebSocketServer     *websocket_server.Instance
+	GameEvents          *game_events.Instance
+	Maps                *game_maps.Instance
+	Plugins             *plugins.Instance
+	Editor              *editor.Instance
+	Backups             *backup.Instance
+	Scheduler           *scheduler.Instance
+
+	// nil if no rcon port is configured for this instance
+	RconServer *rcon.Server
+
+	// cancelled by Close. Stops the downloads of scheduled jobs and other background work
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (i *Instance) Definition() Definition {
+	return Definition{
+		Id:        i.Id,
+		CsPort:    i.CsPort,
+		ServerDir: i.ServerDir,
+		RconPort:  i.RconPort,
+	}
+}
+
+// ports returns all ports used by this instance
+func (d Definition) ports() []string {
+	if d.RconPort == "" {
+		return []string{d.CsPort}
+	}
+	return []string{d.CsPort, d.RconPort}
+}
+
+// Close stops the server and steamcmd if they are running and releases all resources
+func (i *Instance) Close() {
+	i.cancel()
+	i.Scheduler.Close()
+	i.UpdateChecker.Close()
+	i.Supervisor.Cancel()
+
+	if i.RconServer != nil {
+		_ = i.RconServer.Close()
+	}
+
+	_ = i.Steamcmd.Cancel()
+	i.Steamcmd.Close()
+
+	_ = i.Server.Stop()
+	i.Server.Close()
+
+	i.UserLogWriter.Close()
+}
+
+// The default instance keeps using the data dir, server dir and steamcmd dir from the config,
+// so existing installations continue to work without any migration.
+// Every other instance has its own steamcmd dir. steamcmd can not run multiple times from the same dir,
+// and a forced update removes the steamcmd dir while the servers of other instances might still load the steamclient.so from it
+func instanceDirs(cfg config.Config, definition Definition) (dataDir string, serverDir string, steamcmdDir string) {
+	if definition.Id == DefaultId {
+		return cfg.DataDir, cfg.ServerDir, cfg.SteamcmdDir
+	}
+
+	dataDir = filepath.Join(cfg.DataDir, "instances", definition.Id)
+	serverDir = definition.ServerDir
+	if serverDir == "" {
+		serverDir = filepath.Join(dataDir, "server")
+	}
+
+	return dataDir, serverDir, filepath.Join(dataDir, "steamcmd")
+}
+
+func newInstance(cfg config.Config, definition Definition) (*Instance, error) {
+	if err := gvalidator.Instance().Struct(definition); err != nil {
+		return nil, fmt.Errorf("definition validation: %w", err)
+	}
+
+	dataDir, serverDir, steamcmdDir := instanceDirs(cfg, definition)
+
+	if err := os.MkdirAll(dataDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create data dir '%v' %w", dataDir, err)
+	}
+
+	if err := os.MkdirAll(serverDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create server dir '%v' %w", serverDir, err)
+	}
+
+	if err := os.MkdirAll(steamcmdDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create steamcmd dir '%v' %w", steamcmdDir, err)
+	}
+
+	// everything that is already created is closed again if a later step fails
+	created := false
+	var closers []func()
+	defer func() {
+		if created {
+			return
+		}
+		for _, closer := range slices.Backward(closers) {
+			closer()
+		}
+	}()
+
+	steamcmdBranchJsonPath := filepath.Join(dataDir, "steamcmd-branch.json")
+	steamcmdInstance, err := steamcmd.NewInstance(steamcmdDir, serverDir, steamcmdBranchJsonPath)
+	if err != nil {
+		return nil, fmt.Errorf("create steamcmd instance: %w", err)
+	}
+	closers = append(closers, steamcmdInstance.Close)
+
+	updateCheckerInstance, err := update_checker.New(
+		update_checker.Config{
+			Enabled:  cfg.UpdateCheck,
+			Interval: cfg.UpdateCheckInterval,
+		},
+		steamcmdInstance,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create update checker instance: %w", err)
+	}
+	closers = append(closers, updateCheckerInstance.Close)
+
+	// only the configs and plugins are restored. The appmanifest documents the installed build,
+	// restoring it would not match the game files which are not part of the snapshot
+	snapshotDir := filepath.Join(dataDir, "snapshots")
+	snapshotsInstance, err := server_snapshots.New(snapshotDir, cfg.UpdateSnapshotRetention, []server_snapshots.Source{
+		{
+			Name:  "server",
+			Dir:   serverDir,
+			Paths: []string{"game/csgo/cfg", "game/csgo/addons", "game/csgo/gameinfo.gi"},
+		},
+		{
+			Name:  "data",
+			Dir:   dataDir,
+			Paths: []string{"installed-plugin.json"},
+		},
+		{
+			Name:       "manifest",
+			Dir:        serverDir,
+			Paths:      []string{"steamapps/appmanifest_730.acf"},
+			RecordOnly: true,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create snapshots instance: %w", err)
+	}
+
+	serverInstance, err := server.NewInstance(serverDir, definition.CsPort, steamcmdDir)
+	if err != nil {
+		return nil, fmt.Errorf("create server instance: %w", err)
+	}
+	closers = append(closers, serverInstance.Close)
+
+	if cfg.RconPassword != "" {
+		serverInstance.SetRconPassword(cfg.RconPassword)
+	}
+
+	serverSteamcmdLock := &sync.Mutex{}
+
+	supervisorInstance, err := supervisor.New(
+		supervisor.Config{
+			Enabled:        cfg.AutoRestart,
+			MaxRestarts:    cfg.AutoRestartMaxRestarts,
+			Window:         cfg.AutoRestartWindow,
+			InitialBackoff: cfg.AutoRestartInitialBackoff,
+			MaxBackoff:     cfg.AutoRestartMaxBackoff,
+		},
+		serverInstance,
+		steamcmdInstance,
+		serverSteamcmdLock,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create supervisor instance: %w", err)
+	}
+	closers = append(closers, supervisorInstance.Cancel)
+
+	startParametersJsonPath := filepath.Join(dataDir, "start-parameters.json")
+	startParametersJsonFile, err := start_parameters_json.New(startParametersJsonPath, *server.DefaultStartParameters())
+	if err != nil {
+		return nil, fmt.Errorf("create start parameter json instance: %w", err)
+	}
+
+	profilesJsonPath := filepath.Join(dataDir, "start-parameter-profiles.json")
+	profiles, err := start_parameters_json.NewProfiles(profilesJsonPath, startParametersJsonFile)
+	if err != nil {
+		return nil, fmt.Errorf("create start parameter profiles instance: %w", err)
+	}
+
+	if err := profiles.ActivateDefault(); err != nil {
+		return nil, fmt.Errorf("activate default start parameter profile: %w", err)
+	}
+
+	logDir := filepath.Join(dataDir, "logs")
+	userLogWriter, err := logwrt.NewLogWriter(logDir, "user")
+	if err != nil {
+		return nil, fmt.Errorf("create user log writer: %w", err)
+	}
+	closers = append(closers, userLogWriter.Close)
+
+	startParameters, err := startParametersJsonFile.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read start-parameters.json: %w", err)
+	}
+
+	isGameServerInstalled, err := IsGameServerInstalled(serverDir)
+	if err != nil {
+		return nil, fmt.Errorf("check if game server is installed: %w", err)
+	}
+
+	statusInstance := status.NewStatus(
+		isGameServerInstalled,
+		startParameters.Hostname,
+		cfg.Ip,
+		definition.CsPort,
+		startParameters.Password,
+		startParameters.MaxPlayers,
+		startParameters.StartMap,
+		startParameters.GameMode,
+		startParameters.WorkshopMap,
+		startParameters.WorkshopCollection,
+		startParameters.TvEnable,
+	)
+
+	gameEventsInstance := &game_events.Instance{}
+
+	mapRotationJsonPath := filepath.Join(dataDir, "map-rotation.json")
+	mapsInstance, err := game_maps.New(serverDir, mapRotationJsonPath, serverInstance, gameEventsInstance)
+	if err != nil {
+		return nil, fmt.Errorf("create maps instance: %w", err)
+	}
+
+	pluginsJsonFilePath := filepath.Join(dataDir, "plugins.json")
+	installedPluginsJsonPath := filepath.Join(dataDir, "installed-plugin.json")
+	csgoDir := filepath.Join(serverDir, "game", "csgo")
+	if !strings.HasSuffix(csgoDir, string(filepath.Separator)) {
+		csgoDir = csgoDir + string(filepath.Separator)
+	}
+	pluginCatalogCacheDir := filepath.Join(dataDir, "plugin-catalog")
+	pluginsInstance, err := plugins.New(plugins.Config{
+		CsgoDir:                 csgoDir,
+		PluginsJsonFilePath:     pluginsJsonFilePath,
+		InstalledPluginJsonPath: installedPluginsJsonPath,
+		SignaturePublicKey:      cfg.PluginSignaturePublicKey,
+		CatalogSources:          cfg.PluginCatalogSources,
+		CatalogCacheDir:         pluginCatalogCacheDir,
+		PendingCommandsJsonPath: filepath.Join(dataDir, "plugin-commands.json"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create plugins instance: %w", err)
+	}
+
+	editorFilesJsonPath := filepath.Join(dataDir, "editor-files.json")
+	editorInstance, err := editor.New(editorFilesJsonPath, serverDir)
+	if err != nil {
+		return nil, fmt.Errorf("create editor instance: %w", err)
+	}
+
+	// the server dir, the steamcmd dir and the data dirs of other instances can be inside the data dir
+	backupDir := filepath.Join(dataDir, "backups")
+	backupExcludedDirs := []string{serverDir, steamcmdDir, filepath.Join(dataDir, "instances"), snapshotDir, pluginCatalogCacheDir}
+	backupInstance, err := backup.New(
+		backup.Config{
+			SourceDir:    dataDir,
+			BackupDir:    backupDir,
+			ExcludedDirs: backupExcludedDirs,
+			// contains the password of the beta branch. Backups can be downloaded
+			ExcludedFiles:   []string{steamcmdBranchJsonPath},
+			NotRestoredDirs: []string{logDir},
+			ServerDir:       serverDir,
+			Retention:       cfg.BackupRetention,
+		},
+		editorInstance,
+		backupValidators(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create backup instance: %w", err)
+	}
+
+	var rconServer *rcon.Server
+	if definition.RconPort != "" {
+		rconServer, err = rcon.NewServer(cfg.RconPassword, serverInstance.SendCommand)
+		if err != nil {
+			return nil, fmt.Errorf("create rcon server: %w", err)
+		}
+
+		if err := rconServer.Listen(":" + definition.RconPort); err != nil {
+			return nil, fmt.Errorf("start rcon server: %w", err)
+		}
+		closers = append(closers, func() {
+			_ = rconServer.Close()
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	closers = append(closers, cancel)
+	instance := &Instance{
+		Id:                  definition.Id,
+		CsPort:              definition.CsPort,
+		DataDir:             dataDir,
+		ServerDir:           serverDir,
+		RconPort:            definition.RconPort,
+		ServerSteamcmdLock:  serverSteamcmdLock,
+		Server:              serverInstance,
+		Steamcmd:            steamcmdInstance,
+		UpdateChecker:       updateCheckerInstance,
+		Snapshots:           snapshotsInstance,
+		Supervisor:          supervisorInstance,
+		StartParametersJson: startParametersJsonFile,
+		Profiles:            profiles,
+		UserLogWriter:       userLogWriter,
+		Status:              statusInstance,
+		WebSocketServer:     websocket_server.New(),
+		GameEvents:          gameEventsInstance,
+		Maps:                mapsInstance,
+		Plugins:             pluginsInstance,
+		Editor:              editorInstance,
+		Backups:             backupInstance,
+		RconServer:          rconServer,
+		ctx:                 ctx,
+		cancel:              cancel,
+	}
+
+	schedulesJsonPath := filepath.Join(dataDir, "schedules.json")
+	scheduleRunsJsonPath := filepath.Join(dataDir, "schedule-runs.json")
+	schedulerInstance, err := scheduler.New(schedulesJsonPath, scheduleRunsJsonPath, instance.scheduleJobRunners())
+	if err != nil {
+		return nil, fmt.Errorf("create scheduler instance: %w", err)
+	}
+	instance.Scheduler = schedulerInstance
+
+	created = true
+	return instance, nil
+}
+
+// backupValidators validates the json files of the data dir before a backup is restored
+func backupValidators() map[string]backup.Validator {
+	return map[string]backup.Validator{
+		"start-parameters.json":         backup.JsonValidator[server.StartParameters](),
+		"start-parameter-profiles.json": start_parameters_json.ValidateProfilesJson,
+		"plugins.json":                  backup.JsonValidator[[]plugins.Plugin](),
+		"installed-plugin.json":         plugins.ValidateInstalledPluginJson,
+		"editor-files.json":             backup.JsonValidator[[]editor.FilesToEdit](),
+		"map-rotation.json":             backup.JsonValidator[game_maps.Rotation](),
+		"schedules.json":                scheduler.ValidateSchedulesJson,
+		"instances.json":                backup.JsonValidator[[]Definition](),
+	}
+}
+
+func IsGameServerInstalled(serverDir string) (bool, error) {
+	if err := gvalidator.Instance().Var(serverDir, "dir"); err != nil {
+		return false, nil
+	}
+
+	size, err := files.GetDirSize(serverDir)
+	if err != nil {
+		return false, fmt.Errorf("failed to get serverDir '%v' size: %w", serverDir, err)
+	}
+
+	gib := size / 1024 / 1024 / 1024
+	if gib < 30 {
+		return false, nil
+	}
+
+	csgoDir := filepath.Join(serverDir, "game", "csgo")
+	if err := gvalidator.Instance().Var(csgoDir, "dir"); err != nil {
+		return false, nil
+	}
+
+	if err := gvalidator.Instance().Var(filepath.Join(csgoDir, "pak01_001.vpk"), "file"); err != nil {
+		return false, nil
+	}
+
+	if err := gvalidator.Instance().Var(filepath.Join(csgoDir, "pak01_001.vpk"), "file"); err != nil {
+		return false, nil
+	}
+
+	if err := gvalidator.Instance().Var(filepath.Join(csgoDir, "pak01_002.vpk"), "file"); err != nil {
+		return false, nil
+	}
+
+	cfgFolder := filepath.Join(csgoDir, "cfg")
+	if err := gvalidator.Instance().Var(csgoDir, "dir"); err != nil {
+		return false, nil
+	}
+
+	if err := gvalidator.Instance().Var(filepath.Join(cfgFolder, "gamemode_competitive.cfg"), "file"); err != nil {
+		return false, nil
+	}
+
+	if err := gvalidator.Instance().Var(filepath.Join(cfgFolder, "gamemode_deathmatch.cfg"), "file"); err != nil {
+		return false, nil
+	}
+
+	return true, nil
+}
@@ -0,0 +1,29 @@
+package instances
+
+import (
+	"log/slog"
+)
+
+// RepairPluginsAfterUpdate verifies and repairs all installed plugins and logs the result.
+// It restores the plugin files and actions a server update changed, e.g. the metamod entry of the gameinfo.gi.
+// Called by steamcmd after a successful update. Steamcmd is still running, so the server can not be started and the plugins can not be changed in the meantime
+func (i *Instance) RepairPluginsAfterUpdate() {
+	result, err := i.Plugins.Verify(i.ctx, true)
+	if err != nil {
+		slog.Error("verify plugins after update", "instance", i.Id, "error", err)
+		return
+	}
+
+	for _, plugin := range result.Plugins {
+		if plugin.Error != "" {
+			slog.Error("failed to repair plugin after update", "instance", i.Id, "plugin", plugin.Name, "version", plugin.Version, "error", plugin.Error)
+			continue
+		}
+
+		slog.Info("plugin repaired after update", "instance", i.Id, "plugin", plugin.Name, "version", plugin.Version, "files", len(plugin.Files), "actions", len(plugin.Actions))
+	}
+
+	if len(result.UnexpectedFiles) > 0 {
+		slog.Warn("unexpected files in plugin directories", "instance", i.Id, "files", result.UnexpectedFiles)
+	}
+}
@@ -0,0 +1,279 @@
+package instances
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/Phi-S/cs-server-manager/config"
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+)
+
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// Registry holds all game server instances managed by this process.
+// The default instance is always created from the config. Additional instances are persisted in the instances.json file
+type Registry struct {
+	cfg                   config.Config
+	instancesJsonFilePath string
+
+	lock      sync.RWMutex
+	instances map[string]*Instance
+
+	onInstanceCreated event.InstanceWithData[*Instance]
+	onInstanceRemoved event.InstanceWithData[*Instance]
+}
+
+func NewRegistry(cfg config.Config, instancesJsonFilePath string) (*Registry, error) {
+	if err := gvalidator.Instance().Var(instancesJsonFilePath, "required,filepath"); err != nil {
+		return nil, fmt.Errorf("instancesJsonFilePath '%v' is not valid %w", instancesJsonFilePath, err)
+	}
+
+	return &Registry{
+		cfg:                   cfg,
+		instancesJsonFilePath: instancesJsonFilePath,
+		instances:             make(map[string]*Instance),
+	}, nil
+}
+
+// Load creates the default instance and all instances defined in the instances.json file.
+// Handlers registered with OnInstanceCreated before calling Load are triggered for every loaded instance
+func (r *Registry) Load() error {
+	definitions, err := r.readInstancesJsonFile()
+	if err != nil {
+		return fmt.Errorf("readInstancesJsonFile: %w", err)
+	}
+
+	definitions = slices.Insert(definitions, 0, Definition{Id: DefaultId, CsPort: r.cfg.CsPort, RconPort: r.cfg.RconPort})
+	for _, definition := range definitions {
+		if _, err := r.add(definition); err != nil {
+			return fmt.Errorf("add instance '%v': %w", definition.Id, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Registry) add(definition Definition) (*Instance, error) {
+	instance, err := r.addInternal(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	r.onInstanceCreated.Trigger(instance)
+
+	// started after the instance created event, so the scheduler and update checker events are already registered
+	instance.Scheduler.Start()
+	instance.UpdateChecker.Start()
+
+	// the cached plugin catalogs are used until the refresh is done
+	if len(r.cfg.PluginCatalogSources) > 0 {
+		go func() {
+			if _, err := instance.Plugins.RefreshCatalog(instance.ctx); err != nil {
+				slog.Error("failed to refresh plugin catalog", "instance", instance.Id, "error", err)
+			}
+		}()
+	}
+
+	return instance, nil
+}
+
+func (r *Registry) addInternal(definition Definition) (*Instance, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.instances[definition.Id]; ok {
+		return nil, fmt.Errorf("instance with id '%v' already exists", definition.Id)
+	}
+
+	if definition.CsPort == definition.RconPort {
+		return nil, fmt.Errorf("rcon port can not be the same as the cs port %v", definition.CsPort)
+	}
+
+	for _, existing := range r.instances {
+		for _, port := range definition.ports() {
+			if slices.Contains(existing.Definition().ports(), port) {
+				return nil, fmt.Errorf("port %v is already used by instance '%v'", port, existing.Id)
+			}
+		}
+	}
+
+	_, serverDir, _ := instanceDirs(r.cfg, definition)
+	for _, existing := range r.instances {
+		if existing.ServerDir == serverDir {
+			return nil, fmt.Errorf("server dir '%v' is already used by instance '%v'", serverDir, existing.Id)
+		}
+	}
+
+	instance, err := newInstance(r.cfg, definition)
+	if err != nil {
+		return nil, fmt.Errorf("newInstance: %w", err)
+	}
+
+	r.instances[instance.Id] = instance
+	return instance, nil
+}
+
+// Create adds a new instance and persists its definition in the instances.json file
+func (r *Registry) Create(definition Definition) (*Instance, error) {
+	if definition.Id == DefaultId {
+		return nil, fmt.Errorf("instance id '%v' is reserved", DefaultId)
+	}
+
+	instance, err := r.add(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.writeInstancesJsonFile(); err != nil {
+		_ = r.Remove(instance.Id)
+		return nil, fmt.Errorf("writeInstancesJsonFile: %w", err)
+	}
+
+	return instance, nil
+}
+
+// Remove closes the instance and removes its definition from the instances.json file.
+// The data dir and server dir of the instance are not deleted
+func (r *Registry) Remove(id string) error {
+	if id == DefaultId {
+		return errors.New("the default instance can not be removed")
+	}
+
+	r.lock.Lock()
+	instance, ok := r.instances[id]
+	if !ok {
+		r.lock.Unlock()
+		return ErrInstanceNotFound
+	}
+
+	if instance.Server.IsRunning() || instance.Steamcmd.IsRunning() {
+		r.lock.Unlock()
+		return errors.New("instance is busy. Stop the server and wait for the update to finish")
+	}
+
+	delete(r.instances, id)
+	r.lock.Unlock()
+
+	instance.Close()
+	r.onInstanceRemoved.Trigger(instance)
+
+	if err := r.writeInstancesJsonFile(); err != nil {
+		return fmt.Errorf("writeInstancesJsonFile: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Registry) Get(id string) (*Instance, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	instance, ok := r.instances[id]
+	if !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	return instance, nil
+}
+
+func (r *Registry) Default() *Instance {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.instances[DefaultId]
+}
+
+// All returns all instances sorted by id with the default instance first
+func (r *Registry) All() []*Instance {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	result := make([]*Instance, 0, len(r.instances))
+	for _, instance := range r.instances {
+		result = append(result, instance)
+	}
+
+	slices.SortFunc(result, func(a, b *Instance) int {
+		if a.Id == DefaultId {
+			return -1
+		}
+		if b.Id == DefaultId {
+			return 1
+		}
+		if a.Id < b.Id {
+			return -1
+		}
+		if a.Id > b.Id {
+			return 1
+		}
+		return 0
+	})
+
+	return result
+}
+
+func (r *Registry) Close() {
+	for _, instance := range r.All() {
+		instance.Close()
+	}
+}
+
+func (r *Registry) OnInstanceCreated(handler func(event.PayloadWithData[*Instance])) {
+	r.onInstanceCreated.Register(handler)
+}
+
+func (r *Registry) OnInstanceRemoved(handler func(event.PayloadWithData[*Instance])) {
+	r.onInstanceRemoved.Register(handler)
+}
+
+func (r *Registry) readInstancesJsonFile() ([]Definition, error) {
+	content, err := os.ReadFile(r.instancesJsonFilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	var definitions []Definition
+	if err := json.Unmarshal(content, &definitions); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if err := gvalidator.Instance().Var(definitions, "dive"); err != nil {
+		return nil, fmt.Errorf("validate: %w", err)
+	}
+
+	return definitions, nil
+}
+
+func (r *Registry) writeInstancesJsonFile() error {
+	definitions := make([]Definition, 0)
+	for _, instance := range r.All() {
+		if instance.Id == DefaultId {
+			continue
+		}
+		definitions = append(definitions, instance.Definition())
+	}
+
+	jsonContent, err := json.MarshalIndent(definitions, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.instancesJsonFilePath), os.ModePerm); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	if err := os.WriteFile(r.instancesJsonFilePath, jsonContent, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
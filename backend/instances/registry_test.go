@@ -0,0 +1,251 @@
+package instances_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/config"
+	"github.com/Phi-S/cs-server-manager/instances"
+	"github.com/Phi-S/cs-server-manager/rcon"
+
+	"github.com/google/uuid"
+)
+
+func createTestConfig(t *testing.T) config.Config {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_instances_test_%v", uuid.New()))
+	if err := os.Mkdir(tempDirPath, os.ModePerm); err != nil {
+		t.Fatal("os.Mkdir temp dir", err)
+	}
+
+	cfg := config.Config{
+		CsPort:      "27015",
+		DataDir:     tempDirPath,
+		ServerDir:   filepath.Join(tempDirPath, "server"),
+		SteamcmdDir: filepath.Join(tempDirPath, "steamcmd"),
+		Ip:          "127.0.0.1",
+	}
+
+	for _, dir := range []string{cfg.ServerDir, cfg.SteamcmdDir} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatal("os.MkdirAll", err)
+		}
+	}
+
+	return cfg
+}
+
+func TestRegistry_Load_DefaultInstance(t *testing.T) {
+	cfg := createTestConfig(t)
+
+	registry, err := instances.NewRegistry(cfg, filepath.Join(cfg.DataDir, "instances.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+
+	defaultInstance := registry.Default()
+	if defaultInstance == nil {
+		t.Fatal("default instance not created")
+	}
+
+	if defaultInstance.ServerDir != cfg.ServerDir {
+		t.Fatalf("default instance server dir should be %v but is %v", cfg.ServerDir, defaultInstance.ServerDir)
+	}
+
+	if _, err := os.Stat(filepath.Join(cfg.DataDir, "start-parameters.json")); err != nil {
+		t.Fatal("default instance start-parameters.json not created in data dir", err)
+	}
+}
+
+func TestRegistry_Create_PersistedAndLoaded(t *testing.T) {
+	cfg := createTestConfig(t)
+	instancesJsonPath := filepath.Join(cfg.DataDir, "instances.json")
+
+	registry, err := instances.NewRegistry(cfg, instancesJsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	scrim, err := registry.Create(instances.Definition{Id: "scrim", CsPort: "27016"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scrim.ServerDir != filepath.Join(cfg.DataDir, "instances", "scrim", "server") {
+		t.Fatalf("unexpected server dir %v", scrim.ServerDir)
+	}
+
+	if _, err := os.Stat(filepath.Join(scrim.DataDir, "start-parameters.json")); err != nil {
+		t.Fatal("instance start-parameters.json not created in instance data dir", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(scrim.DataDir, "steamcmd")); err != nil {
+		t.Fatal("instance steamcmd dir not created in instance data dir", err)
+	}
+	registry.Close()
+
+	// log file names only have a precision of one second
+	time.Sleep(time.Second)
+
+	reloadedRegistry, err := instances.NewRegistry(cfg, instancesJsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reloadedRegistry.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer reloadedRegistry.Close()
+
+	all := reloadedRegistry.All()
+	if len(all) != 2 {
+		t.Fatalf("expected 2 instances after reload but got %v", len(all))
+	}
+
+	if all[0].Id != instances.DefaultId || all[1].Id != "scrim" {
+		t.Fatalf("unexpected instances after reload %v, %v", all[0].Id, all[1].Id)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(cfg.DataDir)
+		}()
+	}
+}
+
+func TestRegistry_Create_Conflicts(t *testing.T) {
+	cfg := createTestConfig(t)
+
+	registry, err := instances.NewRegistry(cfg, filepath.Join(cfg.DataDir, "instances.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+
+	testData := []instances.Definition{
+		{Id: instances.DefaultId, CsPort: "27020"},
+		{Id: "practice", CsPort: cfg.CsPort},
+		{Id: "retake", CsPort: "27021", ServerDir: cfg.ServerDir},
+		{Id: "invalid-id", CsPort: "27022"},
+		{Id: "invalidport", CsPort: "abc"},
+		{Id: "rcon", CsPort: "27023", RconPort: cfg.CsPort},
+		{Id: "rconsame", CsPort: "27024", RconPort: "27024"},
+	}
+
+	for _, td := range testData {
+		if _, err := registry.Create(td); err == nil {
+			t.Fatalf("error expected but nil returned. definition: %v", td)
+		}
+	}
+
+	if len(registry.All()) != 1 {
+		t.Fatalf("expected only the default instance but got %v instances", len(registry.All()))
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(cfg.DataDir)
+		}()
+	}
+}
+
+func TestRegistry_Remove(t *testing.T) {
+	cfg := createTestConfig(t)
+
+	registry, err := instances.NewRegistry(cfg, filepath.Join(cfg.DataDir, "instances.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+
+	if _, err := registry.Create(instances.Definition{Id: "retake", CsPort: "27016"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Remove(instances.DefaultId); err == nil {
+		t.Fatal("default instance should not be removable")
+	}
+
+	if err := registry.Remove("retake"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := registry.Get("retake"); err == nil {
+		t.Fatal("removed instance is still in registry")
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(cfg.DataDir)
+		}()
+	}
+}
+
+func TestRegistry_Create_RconServer(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.RconPassword = "secret"
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rconPort, _ := net.SplitHostPort(listener.Addr().String())
+	_ = listener.Close()
+
+	registry, err := instances.NewRegistry(cfg, filepath.Join(cfg.DataDir, "instances.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Close()
+
+	if _, err := registry.Create(instances.Definition{Id: "rcon", CsPort: "27016", RconPort: rconPort}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := rcon.Dial(net.JoinHostPort("127.0.0.1", rconPort), cfg.RconPassword, time.Second*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// the command is proxied to the game server, which is not running
+	output, err := client.Execute("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "server is not yet started") {
+		t.Fatalf("unexpected rcon output %q", output)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(cfg.DataDir)
+		}()
+	}
+}
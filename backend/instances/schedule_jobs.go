@@ -0,0 +1,179 @@
+package instances
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Phi-S/cs-server-manager/plugins"
+	"github.com/Phi-S/cs-server-manager/scheduler"
+	"github.com/Phi-S/cs-server-manager/steamcmd"
+)
+
+func (i *Instance) scheduleJobRunners() map[scheduler.JobType]scheduler.Runner {
+	return map[scheduler.JobType]scheduler.Runner{
+		scheduler.JobTypeRestart:       i.restartJob,
+		scheduler.JobTypeUpdate:        i.updateJob,
+		scheduler.JobTypeCommand:       i.commandJob,
+		scheduler.JobTypeInstallPlugin: i.installPluginJob,
+		scheduler.JobTypeBackup:        i.backupJob,
+	}
+}
+
+// startServer starts the server with the saved start parameters. Requires the ServerSteamcmdLock
+func (i *Instance) startServer() error {
+	startParameters, err := i.StartParametersJson.Read()
+	if err != nil {
+		return fmt.Errorf("read start parameters: %w", err)
+	}
+
+	if err := i.Server.Start(startParameters); err != nil {
+		return fmt.Errorf("start server: %w", err)
+	}
+
+	i.Supervisor.Reset()
+	return nil
+}
+
+// withServerStopped stops the server if it is running, executes the job and starts the server again afterward.
+// Requires the ServerSteamcmdLock
+func (i *Instance) withServerStopped(job func() (string, error)) (string, error) {
+	wasRunning := i.Server.IsRunning()
+	if wasRunning {
+		i.Supervisor.Cancel()
+		if err := i.Server.Stop(); err != nil {
+			return "", fmt.Errorf("stop server: %w", err)
+		}
+	}
+
+	output, jobErr := job()
+
+	if wasRunning {
+		if err := i.startServer(); err != nil {
+			return output, errors.Join(jobErr, fmt.Errorf("start server after job: %w", err))
+		}
+		output += "\nserver started again"
+	}
+
+	return output, jobErr
+}
+
+// restartJob restarts the server. If the server is not running, nothing is done
+func (i *Instance) restartJob(job scheduler.Job) (string, error) {
+	i.ServerSteamcmdLock.Lock()
+	defer i.ServerSteamcmdLock.Unlock()
+
+	if !i.Server.IsRunning() {
+		return "server is not running. Restart skipped", nil
+	}
+
+	return i.withServerStopped(func() (string, error) {
+		return "server stopped", nil
+	})
+}
+
+func (i *Instance) updateJob(job scheduler.Job) (string, error) {
+	return i.updateServer(func() error {
+		if i.Steamcmd.IsRunning() {
+			return errors.New("another update is already running")
+		}
+		return nil
+	})
+}
+
+// updateServer stops the server if it is running, updates the last used branch and starts the server again afterward.
+// canUpdate is called with the ServerSteamcmdLock before anything is changed. The lock is only held while the server is stopped
+// and the update is started and while the server is started again. In between, the running update prevents the server from being started
+func (i *Instance) updateServer(canUpdate func() error) (string, error) {
+	i.ServerSteamcmdLock.Lock()
+	if err := canUpdate(); err != nil {
+		i.ServerSteamcmdLock.Unlock()
+		return "", err
+	}
+
+	wasRunning := i.Server.IsRunning()
+	if wasRunning {
+		i.Supervisor.Cancel()
+		if err := i.Server.Stop(); err != nil {
+			i.ServerSteamcmdLock.Unlock()
+			return "", fmt.Errorf("stop server: %w", err)
+		}
+	}
+
+	wait, updateErr := i.Steamcmd.StartUpdate(false, steamcmd.DefaultUpdateOptions())
+	i.ServerSteamcmdLock.Unlock()
+
+	output := "server updated"
+	if updateErr == nil {
+		updateErr = wait()
+	}
+	if updateErr != nil {
+		output = ""
+		updateErr = fmt.Errorf("update server: %w", updateErr)
+	}
+
+	i.ServerSteamcmdLock.Lock()
+	defer i.ServerSteamcmdLock.Unlock()
+
+	// the server could have been started in the meantime
+	if wasRunning && !i.Server.IsRunning() && !i.Steamcmd.IsRunning() {
+		if err := i.startServer(); err != nil {
+			return output, errors.Join(updateErr, fmt.Errorf("start server after update: %w", err))
+		}
+		output += "\nserver started again"
+	}
+
+	return output, updateErr
+}
+
+func (i *Instance) commandJob(job scheduler.Job) (string, error) {
+	i.ServerSteamcmdLock.Lock()
+	defer i.ServerSteamcmdLock.Unlock()
+
+	if !i.Server.IsRunning() {
+		return "", errors.New("server is not running")
+	}
+
+	return i.Server.SendCommand(job.Command)
+}
+
+func (i *Instance) installPluginJob(job scheduler.Job) (string, error) {
+	i.ServerSteamcmdLock.Lock()
+	defer i.ServerSteamcmdLock.Unlock()
+
+	if i.Steamcmd.IsRunning() {
+		return "", errors.New("can not install plugins while steamcmd is running")
+	}
+
+	installedPlugins, err := i.Plugins.GetInstalledPlugins()
+	if err != nil {
+		return "", fmt.Errorf("get installed plugins: %w", err)
+	}
+
+	alreadyInstalled := slices.ContainsFunc(installedPlugins, func(p plugins.InstalledPlugin) bool {
+		return p.Name == job.PluginName && p.Version == job.PluginVersion && p.Explicit
+	})
+	if alreadyInstalled {
+		return fmt.Sprintf("plugin '%v' version '%v' is already installed", job.PluginName, job.PluginVersion), nil
+	}
+
+	return i.withServerStopped(func() (string, error) {
+		if err := i.Plugins.InstallPluginByName(i.ctx, job.PluginName, job.PluginVersion); err != nil {
+			return "", fmt.Errorf("install plugin: %w", err)
+		}
+		return fmt.Sprintf("plugin '%v' version '%v' installed", job.PluginName, job.PluginVersion), nil
+	})
+}
+
+// backupJob holds the ServerSteamcmdLock, so no plugin installation or update changes the data dir while the backup is created
+func (i *Instance) backupJob(job scheduler.Job) (string, error) {
+	i.ServerSteamcmdLock.Lock()
+	defer i.ServerSteamcmdLock.Unlock()
+
+	b, err := i.Backups.Create()
+	if err != nil {
+		return "", fmt.Errorf("create backup: %w", err)
+	}
+
+	return fmt.Sprintf("backup '%v' created (%v bytes)", b.Id, b.Size), nil
+}
@@ -0,0 +1,280 @@
+package keyvalues
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// SetValue sets the value of the first node with the given path and returns the changed document.
+// Only the value is replaced, so comments and the formatting of the document are kept.
+// If the key does not exist, it is added as last child of its parent section. The parent section has to exist
+func SetValue(document string, value string, path ...string) (string, error) {
+	if len(path) == 0 {
+		return "", fmt.Errorf("path is empty")
+	}
+
+	input := []rune(document)
+	nodes, err := locate(input)
+	if err != nil {
+		return "", err
+	}
+
+	if node := find(nodes, path); node != nil {
+		if node.section {
+			return "", fmt.Errorf("'%v' is a section", strings.Join(path, "/"))
+		}
+		return splice(input, node.value.start, node.value.end, formatString(value, node.value.quoted)), nil
+	}
+
+	key := path[len(path)-1]
+	if len(path) == 1 {
+		newline := lineEnding(input)
+		separator := ""
+		if len(input) > 0 && input[len(input)-1] != '\n' {
+			separator = newline
+		}
+		return document + separator + formatString(key, true) + "\t" + formatString(value, true) + newline, nil
+	}
+
+	parent, err := findSection(nodes, path[:len(path)-1])
+	if err != nil {
+		return "", err
+	}
+
+	return appendChild(input, parent, key, value), nil
+}
+
+// AddEntry adds the key with the value to its section, even if the section already contains the key.
+// Sections like SearchPaths of the gameinfo.gi contain the same key multiple times.
+// The entry is inserted before the first entry with the same key, so it is used before the existing entries.
+// If the section does not contain the key, the entry is added as last child. The section has to exist
+func AddEntry(document string, value string, path ...string) (string, error) {
+	if len(path) < 2 {
+		return "", fmt.Errorf("path has to contain the section and the key")
+	}
+
+	input := []rune(document)
+	nodes, err := locate(input)
+	if err != nil {
+		return "", err
+	}
+
+	parent, err := findSection(nodes, path[:len(path)-1])
+	if err != nil {
+		return "", err
+	}
+
+	key := path[len(path)-1]
+	for _, child := range parent.children {
+		if !strings.EqualFold(child.key.value, key) {
+			continue
+		}
+
+		// the entry is formatted like the existing entry
+		separator := "\t"
+		if !child.section {
+			between := string(input[child.key.end:child.value.start])
+			if strings.TrimSpace(between) == "" && !strings.Contains(between, "\n") {
+				separator = between
+			}
+		}
+
+		entry := formatString(key, child.key.quoted) + separator + formatString(value, child.value.quoted)
+		indent := lineIndent(input, child.key.start)
+		lineStart := child.key.start - len([]rune(indent))
+		if lineStart == 0 || input[lineStart-1] == '\n' {
+			return splice(input, lineStart, lineStart, indent+entry+lineEnding(input)), nil
+		}
+
+		// the existing entry does not start its own line
+		return splice(input, child.key.start, child.key.start, entry+" "), nil
+	}
+
+	return appendChild(input, parent, key, value), nil
+}
+
+// RemoveEntry removes the first entry of the section with the key and the value.
+// Values are compared case-sensitive, because they are usually paths
+func RemoveEntry(document string, value string, path ...string) (string, error) {
+	if len(path) < 2 {
+		return "", fmt.Errorf("path has to contain the section and the key")
+	}
+
+	input := []rune(document)
+	nodes, err := locate(input)
+	if err != nil {
+		return "", err
+	}
+
+	parent, err := findSection(nodes, path[:len(path)-1])
+	if err != nil {
+		return "", err
+	}
+
+	key := path[len(path)-1]
+	for _, child := range parent.children {
+		if !child.section && strings.EqualFold(child.key.value, key) && child.value.value == value {
+			start, end := lineRange(input, child.key.start, child.end())
+			return splice(input, start, end, ""), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: '%v' with value '%v'", ErrNotFound, strings.Join(path, "/"), value)
+}
+
+func findSection(nodes []*locatedNode, path []string) (*locatedNode, error) {
+	section := find(nodes, path)
+	if section == nil || !section.section {
+		return nil, fmt.Errorf("%w: section '%v'", ErrNotFound, strings.Join(path, "/"))
+	}
+	return section, nil
+}
+
+// appendChild adds the key with the value as last child of the section
+func appendChild(input []rune, parent *locatedNode, key string, value string) string {
+	// the new entry uses the quoting of its siblings
+	quoted := parent.key.quoted
+	after := parent.value.end
+	if len(parent.children) > 0 {
+		lastChild := parent.children[len(parent.children)-1]
+		quoted = lastChild.key.quoted
+		after = lastChild.end()
+	}
+
+	parentIndent := lineIndent(input, parent.key.start)
+	entry := parentIndent + "\t" + formatString(key, quoted) + "\t" + formatString(value, quoted)
+	newline := lineEnding(input)
+
+	// the entry is added after the line of the last child, so a comment at the end of that line stays there
+	if lineEnd := indexRune(input, after, '\n'); lineEnd != -1 && lineEnd < parent.close.start {
+		return splice(input, lineEnd+1, lineEnd+1, entry+newline)
+	}
+
+	// the section is closed on the same line
+	return splice(input, parent.close.start, parent.close.start, newline+entry+newline+parentIndent)
+}
+
+// RemoveKey removes the first node with the given path and returns the changed document.
+// The whole line is removed if nothing but whitespace, a conditional or a comment is left on it
+func RemoveKey(document string, path ...string) (string, error) {
+	input := []rune(document)
+	nodes, err := locate(input)
+	if err != nil {
+		return "", err
+	}
+
+	node := find(nodes, path)
+	if node == nil {
+		return "", fmt.Errorf("%w: '%v'", ErrNotFound, strings.Join(path, "/"))
+	}
+
+	start, end := lineRange(input, node.key.start, node.end())
+	return splice(input, start, end, ""), nil
+}
+
+// find returns the first node with the given path. Keys are compared case-insensitive
+func find(nodes []*locatedNode, path []string) *locatedNode {
+	var current *locatedNode
+	for _, key := range path {
+		current = nil
+		for _, node := range nodes {
+			if strings.EqualFold(node.key.value, key) {
+				current = node
+				break
+			}
+		}
+
+		if current == nil {
+			return nil
+		}
+		nodes = current.children
+	}
+
+	return current
+}
+
+// lineRange extends the range to the whole line if the line contains nothing else
+func lineRange(input []rune, start int, end int) (int, int) {
+	lineStart := start
+	for lineStart > 0 && (input[lineStart-1] == ' ' || input[lineStart-1] == '\t') {
+		lineStart--
+	}
+
+	if lineStart > 0 && input[lineStart-1] != '\n' {
+		return start, end
+	}
+
+	lineEnd := end
+	for lineEnd < len(input) && input[lineEnd] != '\n' {
+		switch {
+		case input[lineEnd] == ' ' || input[lineEnd] == '\t' || input[lineEnd] == '\r':
+			lineEnd++
+		case input[lineEnd] == '[':
+			for lineEnd < len(input) && input[lineEnd] != ']' && input[lineEnd] != '\n' {
+				lineEnd++
+			}
+			if lineEnd < len(input) && input[lineEnd] == ']' {
+				lineEnd++
+			}
+		case input[lineEnd] == '/' && lineEnd+1 < len(input) && input[lineEnd+1] == '/':
+			for lineEnd < len(input) && input[lineEnd] != '\n' {
+				lineEnd++
+			}
+		default:
+			return start, end
+		}
+	}
+
+	if lineEnd < len(input) {
+		lineEnd++
+	}
+	return lineStart, lineEnd
+}
+
+// lineIndent returns the whitespace at the start of the line that contains the offset
+func lineIndent(input []rune, offset int) string {
+	lineStart := offset
+	for lineStart > 0 && input[lineStart-1] != '\n' {
+		lineStart--
+	}
+
+	indentEnd := lineStart
+	for indentEnd < len(input) && (input[indentEnd] == ' ' || input[indentEnd] == '\t') {
+		indentEnd++
+	}
+	return string(input[lineStart:indentEnd])
+}
+
+// lineEnding returns the line ending of the first line, so added lines match the rest of the document
+func lineEnding(input []rune) string {
+	if index := indexRune(input, 0, '\n'); index > 0 && input[index-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+func indexRune(input []rune, from int, r rune) int {
+	for i := from; i < len(input); i++ {
+		if input[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func splice(input []rune, start int, end int, replacement string) string {
+	return string(input[:start]) + replacement + string(input[end:])
+}
+
+// formatString quotes the string if it is requested or required
+func formatString(s string, quoted bool) string {
+	if !quoted && s != "" && !strings.ContainsAny(s, " \t\r\n\"{}[\\") && !strings.Contains(s, "//") {
+		return s
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + replacer.Replace(s) + `"`
+}
@@ -0,0 +1,350 @@
+package keyvalues
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrSyntax = errors.New("keyvalues syntax error")
+
+// Node is a key with either a value or child nodes.
+// https://developer.valvesoftware.com/wiki/KeyValues
+type Node struct {
+	Key      string
+	Value    string
+	Children []*Node
+}
+
+// IsSection reports whether the node has child nodes instead of a value
+func (n *Node) IsSection() bool {
+	return n.Children != nil
+}
+
+// Get returns the first child node with the given path. Keys are compared case-insensitive. Returns nil if not found
+func (n *Node) Get(path ...string) *Node {
+	current := n
+	for _, key := range path {
+		var next *Node
+		for _, child := range current.Children {
+			if strings.EqualFold(child.Key, key) {
+				next = child
+				break
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+		current = next
+	}
+
+	return current
+}
+
+// GetValue returns the value of the node with the given path or an empty string if not found
+func (n *Node) GetValue(path ...string) string {
+	node := n.Get(path...)
+	if node == nil {
+		return ""
+	}
+	return node.Value
+}
+
+// Parse parses all root nodes of the KeyValues document
+func Parse(r io.Reader) ([]*Node, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	nodes, err := locate([]rune(string(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Node, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, node.node())
+	}
+	return result, nil
+}
+
+// locate parses all root nodes together with the positions of their tokens
+func locate(input []rune) ([]*locatedNode, error) {
+	p := &parser{lexer: lexer{input: input, line: 1}}
+	nodes, _, err := p.parseNodes(false)
+	return nodes, err
+}
+
+// ParseString parses the first root node of the KeyValues document. Content after the first root node is ignored,
+// so the node can be parsed from a larger output, e.g. the output of steamcmd
+func ParseString(s string) (*Node, error) {
+	p := &parser{lexer: lexer{input: []rune(s), line: 1}}
+
+	tok, err := p.lexer.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.kind {
+	case tokenEOF:
+		return nil, fmt.Errorf("%w: document is empty", ErrSyntax)
+	case tokenString:
+		node, err := p.parseNode(tok)
+		if err != nil {
+			return nil, err
+		}
+		return node.node(), nil
+	default:
+		return nil, fmt.Errorf("%w: line %v: document has to start with a key", ErrSyntax, tok.line)
+	}
+}
+
+type parser struct {
+	lexer lexer
+}
+
+// locatedNode is a node with the tokens it was parsed from
+type locatedNode struct {
+	key token
+	// the value of the node or the '{' of a section
+	value token
+	// the '}' of a section
+	close    token
+	section  bool
+	children []*locatedNode
+}
+
+func (n *locatedNode) node() *Node {
+	if !n.section {
+		return &Node{Key: n.key.value, Value: n.value.value}
+	}
+
+	children := make([]*Node, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child.node())
+	}
+	return &Node{Key: n.key.value, Children: children}
+}
+
+// end returns the offset after the last token of the node
+func (n *locatedNode) end() int {
+	if n.section {
+		return n.close.end
+	}
+	return n.value.end
+}
+
+// parseNodes returns the nodes and the '}' token of nested sections
+func (p *parser) parseNodes(nested bool) ([]*locatedNode, token, error) {
+	nodes := make([]*locatedNode, 0)
+	for {
+		tok, err := p.lexer.next()
+		if err != nil {
+			return nil, token{}, err
+		}
+
+		switch tok.kind {
+		case tokenEOF:
+			if nested {
+				return nil, token{}, fmt.Errorf("%w: line %v: missing '}'", ErrSyntax, tok.line)
+			}
+			return nodes, tok, nil
+		case tokenClose:
+			if !nested {
+				return nil, token{}, fmt.Errorf("%w: line %v: unexpected '}'", ErrSyntax, tok.line)
+			}
+			return nodes, tok, nil
+		case tokenOpen:
+			return nil, token{}, fmt.Errorf("%w: line %v: unexpected '{' without key", ErrSyntax, tok.line)
+		case tokenString:
+			node, err := p.parseNode(tok)
+			if err != nil {
+				return nil, token{}, err
+			}
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+func (p *parser) parseNode(key token) (*locatedNode, error) {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.kind {
+	case tokenOpen:
+		children, closeToken, err := p.parseNodes(true)
+		if err != nil {
+			return nil, err
+		}
+		return &locatedNode{key: key, value: tok, close: closeToken, section: true, children: children}, nil
+	case tokenString:
+		return &locatedNode{key: key, value: tok}, nil
+	default:
+		return nil, fmt.Errorf("%w: line %v: missing value for key '%v'", ErrSyntax, tok.line, key.value)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenString
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+	// rune offsets of the token in the input. The quotes of quoted strings are included
+	start int
+	end   int
+	// true if the string was quoted
+	quoted bool
+}
+
+type lexer struct {
+	input []rune
+	pos   int
+	line  int
+}
+
+func (l *lexer) peek() (rune, bool) {
+	if l.pos >= len(l.input) {
+		return 0, false
+	}
+	return l.input[l.pos], true
+}
+
+func (l *lexer) skipWhitespaceAndComments() {
+	for {
+		r, ok := l.peek()
+		if !ok {
+			return
+		}
+
+		switch {
+		case r == '\n':
+			l.line++
+			l.pos++
+		case r == ' ' || r == '\t' || r == '\r' || r == '\ufeff':
+			l.pos++
+		case r == '/' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '/':
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+		case r == '[':
+			// conditionals like [$WIN32] are ignored
+			for l.pos < len(l.input) && l.input[l.pos] != ']' && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+			if l.pos < len(l.input) && l.input[l.pos] == ']' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipWhitespaceAndComments()
+
+	start := l.pos
+	tok, err := l.nextToken()
+	if err != nil {
+		return token{}, err
+	}
+
+	tok.start = start
+	tok.end = l.pos
+	return tok, nil
+}
+
+func (l *lexer) nextToken() (token, error) {
+	r, ok := l.peek()
+	if !ok {
+		return token{kind: tokenEOF, line: l.line}, nil
+	}
+
+	switch r {
+	case '{':
+		l.pos++
+		return token{kind: tokenOpen, line: l.line}, nil
+	case '}':
+		l.pos++
+		return token{kind: tokenClose, line: l.line}, nil
+	case '"':
+		return l.quoted()
+	default:
+		return l.unquoted(), nil
+	}
+}
+
+func (l *lexer) quoted() (token, error) {
+	startLine := l.line
+	l.pos++
+
+	var sb strings.Builder
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		l.pos++
+
+		switch r {
+		case '"':
+			return token{kind: tokenString, value: sb.String(), line: startLine, quoted: true}, nil
+		case '\\':
+			if l.pos >= len(l.input) {
+				sb.WriteRune(r)
+				continue
+			}
+
+			escaped := l.input[l.pos]
+			l.pos++
+			switch escaped {
+			case 'n':
+				sb.WriteRune('\n')
+			case 't':
+				sb.WriteRune('\t')
+			case '\\', '"':
+				sb.WriteRune(escaped)
+			default:
+				// windows paths like "C:\Program Files" are not escaped
+				sb.WriteRune(r)
+				sb.WriteRune(escaped)
+			}
+		case '\n':
+			l.line++
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return token{}, fmt.Errorf("%w: line %v: missing closing quote", ErrSyntax, startLine)
+}
+
+func (l *lexer) unquoted() token {
+	startLine := l.line
+	start := l.pos
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' || r == '{' || r == '}' || r == '"' {
+			break
+		}
+
+		if r == '/' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '/' {
+			break
+		}
+
+		l.pos++
+	}
+
+	return token{kind: tokenString, value: string(l.input[start:l.pos]), line: startLine}
+}
@@ -0,0 +1,459 @@
+package keyvalues_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/keyvalues"
+)
+
+func TestParse_Ok(t *testing.T) {
+	document := `// comment before the first node
+"GameInfo"
+{
+	game		"Counter-Strike 2" // comment after value
+	"FileSystem"
+	{
+		SearchPaths
+		{
+			Game_LowViolence	csgo_lv // Perfect World content override
+			Game	csgo/addons/metamod
+			Game	csgo
+		}
+	}
+	"path"	"C:\Program Files\cs2"
+	"escaped"	"say \"hello\""
+	"tool"	"1"	[$WIN32]
+}
+"Second" { }
+`
+
+	nodes, err := keyvalues.Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 2 || nodes[0].Key != "GameInfo" || nodes[1].Key != "Second" {
+		t.Fatalf("unexpected root nodes %+v", nodes)
+	}
+
+	gameInfo := nodes[0]
+	if gameInfo.GetValue("game") != "Counter-Strike 2" {
+		t.Fatal("unexpected game value", gameInfo.GetValue("game"))
+	}
+
+	searchPaths := gameInfo.Get("filesystem", "searchpaths")
+	if searchPaths == nil || !searchPaths.IsSection() || len(searchPaths.Children) != 3 {
+		t.Fatalf("unexpected search paths %+v", searchPaths)
+	}
+
+	if searchPaths.Children[1].Key != "Game" || searchPaths.Children[1].Value != "csgo/addons/metamod" {
+		t.Fatalf("unexpected search path %+v", searchPaths.Children[1])
+	}
+
+	if gameInfo.GetValue("path") != `C:\Program Files\cs2` {
+		t.Fatal("unexpected path value", gameInfo.GetValue("path"))
+	}
+
+	if gameInfo.GetValue("escaped") != `say "hello"` {
+		t.Fatal("unexpected escaped value", gameInfo.GetValue("escaped"))
+	}
+
+	if gameInfo.GetValue("tool") != "1" {
+		t.Fatal("unexpected tool value", gameInfo.GetValue("tool"))
+	}
+
+	if !nodes[1].IsSection() || len(nodes[1].Children) != 0 {
+		t.Fatalf("unexpected empty section %+v", nodes[1])
+	}
+
+	if gameInfo.Get("filesystem", "does_not_exist") != nil {
+		t.Fatal("expected nil for not existing path")
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	testData := []string{
+		`"key" { "a" "b"`,
+		`"key" "value" }`,
+		`{ "a" "b" }`,
+		`"key" "value`,
+		`"key"`,
+	}
+
+	for _, td := range testData {
+		if _, err := keyvalues.Parse(strings.NewReader(td)); !errors.Is(err, keyvalues.ErrSyntax) {
+			t.Fatalf("expected ErrSyntax for %q but got %v", td, err)
+		}
+	}
+}
+
+func TestParseString_IgnoresTrailingContent(t *testing.T) {
+	node, err := keyvalues.ParseString(`"730" { "buildid" "1" } Unloading Steam API...OK }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.Key != "730" || node.GetValue("buildid") != "1" {
+		t.Fatalf("unexpected node %+v", node)
+	}
+
+	if _, err := keyvalues.ParseString("  // only a comment"); !errors.Is(err, keyvalues.ErrSyntax) {
+		t.Fatal("expected ErrSyntax for empty document but got", err)
+	}
+}
+
+const editDocument = `"GameInfo"
+{
+	game		"Counter-Strike 2" // comment after value
+	FileSystem
+	{
+		SearchPaths
+		{
+			Game	csgo // comment after the last child
+		}
+	}
+	"tool"	"1"	[$WIN32]
+}
+"Second" { }
+`
+
+func TestSetValue(t *testing.T) {
+	testData := []struct {
+		name     string
+		path     []string
+		value    string
+		expected string
+	}{
+		{
+			name:     "replace quoted value",
+			path:     []string{"gameinfo", "game"},
+			value:    `CS "2"`,
+			expected: strings.Replace(editDocument, `"Counter-Strike 2"`, `"CS \"2\""`, 1),
+		},
+		{
+			name:     "replace unquoted value",
+			path:     []string{"GameInfo", "FileSystem", "SearchPaths", "Game"},
+			value:    "csgo_core",
+			expected: strings.Replace(editDocument, "Game\tcsgo //", "Game\tcsgo_core //", 1),
+		},
+		{
+			name:     "add key after the last child",
+			path:     []string{"GameInfo", "FileSystem", "SearchPaths", "Mod"},
+			value:    "csgo",
+			expected: strings.Replace(editDocument, "child\n", "child\n\t\t\tMod\tcsgo\n", 1),
+		},
+		{
+			name:     "add key to empty section on one line",
+			path:     []string{"Second", "key"},
+			value:    "value",
+			expected: strings.Replace(editDocument, `"Second" { }`, "\"Second\" { \n\t\"key\"\t\"value\"\n}", 1),
+		},
+		{
+			name:     "add root key",
+			path:     []string{"Third"},
+			value:    "value",
+			expected: editDocument + "\"Third\"\t\"value\"\n",
+		},
+	}
+
+	for _, td := range testData {
+		actual, err := keyvalues.SetValue(editDocument, td.value, td.path...)
+		if err != nil {
+			t.Fatalf("%v: %v", td.name, err)
+		}
+
+		if actual != td.expected {
+			t.Fatalf("%v: expected\n%v\nbut got\n%v", td.name, td.expected, actual)
+		}
+
+		// the changed document is still valid
+		nodes, err := keyvalues.Parse(strings.NewReader(actual))
+		if err != nil {
+			t.Fatalf("%v: %v", td.name, err)
+		}
+		if value := (&keyvalues.Node{Children: nodes}).GetValue(td.path...); value != td.value {
+			t.Fatalf("%v: expected value '%v' but got '%v'", td.name, td.value, value)
+		}
+	}
+
+	if _, err := keyvalues.SetValue(editDocument, "value", "GameInfo", "Missing", "key"); !errors.Is(err, keyvalues.ErrNotFound) {
+		t.Fatal("expected ErrNotFound but got", err)
+	}
+
+	if _, err := keyvalues.SetValue(editDocument, "value", "GameInfo", "FileSystem"); err == nil {
+		t.Fatal("expected error for section")
+	}
+}
+
+func TestRemoveKey(t *testing.T) {
+	testData := []struct {
+		name     string
+		path     []string
+		expected string
+	}{
+		{
+			name:     "line with comment",
+			path:     []string{"GameInfo", "game"},
+			expected: strings.Replace(editDocument, "\tgame\t\t\"Counter-Strike 2\" // comment after value\n", "", 1),
+		},
+		{
+			name:     "line with conditional",
+			path:     []string{"GameInfo", "tool"},
+			expected: strings.Replace(editDocument, "\t\"tool\"\t\"1\"\t[$WIN32]\n", "", 1),
+		},
+		{
+			name:     "section",
+			path:     []string{"GameInfo", "FileSystem", "SearchPaths"},
+			expected: strings.Replace(editDocument, "\t\tSearchPaths\n\t\t{\n\t\t\tGame\tcsgo // comment after the last child\n\t\t}\n", "", 1),
+		},
+		{
+			name:     "section on one line",
+			path:     []string{"Second"},
+			expected: strings.Replace(editDocument, "\"Second\" { }\n", "", 1),
+		},
+	}
+
+	for _, td := range testData {
+		actual, err := keyvalues.RemoveKey(editDocument, td.path...)
+		if err != nil {
+			t.Fatalf("%v: %v", td.name, err)
+		}
+
+		if actual != td.expected {
+			t.Fatalf("%v: expected\n%v\nbut got\n%v", td.name, td.expected, actual)
+		}
+	}
+
+	if _, err := keyvalues.RemoveKey(editDocument, "GameInfo", "missing"); !errors.Is(err, keyvalues.ErrNotFound) {
+		t.Fatal("expected ErrNotFound but got", err)
+	}
+}
+
+// SearchPaths of gameinfo.gi files shipped with different cs2 versions and formatted by hand
+var gameinfoVariants = []struct {
+	name        string
+	searchPaths string
+	// searchPaths with the metamod entry
+	expected string
+}{
+	{
+		name:        "cs2 with empty lines between entries",
+		searchPaths: "\t\t\tGame_LowViolence\tcsgo_lv // Perfect World content override\n\n\t\t\tGame\tcsgo\n\t\t\tGame\tcsgo_imported\n\t\t\tGame\tcsgo_core\n\t\t\tGame\tcore\n\n\t\t\tMod\t\tcsgo\n\t\t\tAddonRoot\t\t\tcsgo_addons\n\t\t\tLayeredGameRoot\t\t\"../game_otherplatforms/low_bitrate\" [$MOBILE]\n",
+		expected:    "\t\t\tGame_LowViolence\tcsgo_lv // Perfect World content override\n\n\t\t\tGame\tcsgo/addons/metamod\n\t\t\tGame\tcsgo\n\t\t\tGame\tcsgo_imported\n\t\t\tGame\tcsgo_core\n\t\t\tGame\tcore\n\n\t\t\tMod\t\tcsgo\n\t\t\tAddonRoot\t\t\tcsgo_addons\n\t\t\tLayeredGameRoot\t\t\"../game_otherplatforms/low_bitrate\" [$MOBILE]\n",
+	},
+	{
+		name:        "cs2 release",
+		searchPaths: "\t\t\tGame_LowViolence\tcsgo_lv // Perfect World content override\n\t\t\tGame\tcsgo\n\t\t\tGame\tcsgo_imported\n\t\t\tGame\tcore\n\t\t\tMod\t\tcsgo\n",
+		expected:    "\t\t\tGame_LowViolence\tcsgo_lv // Perfect World content override\n\t\t\tGame\tcsgo/addons/metamod\n\t\t\tGame\tcsgo\n\t\t\tGame\tcsgo_imported\n\t\t\tGame\tcore\n\t\t\tMod\t\tcsgo\n",
+	},
+	{
+		name:        "changed comment and whitespace",
+		searchPaths: "    Game_LowViolence   csgo_lv   // low violence\n    Game    csgo // main content\n    Mod    csgo\n",
+		expected:    "    Game_LowViolence   csgo_lv   // low violence\n    Game    csgo/addons/metamod\n    Game    csgo // main content\n    Mod    csgo\n",
+	},
+	{
+		name:        "quoted",
+		searchPaths: "\t\t\t\"Game\"\t\"csgo\"\n\t\t\t\"Mod\"\t\"csgo\"\n",
+		expected:    "\t\t\t\"Game\"\t\"csgo/addons/metamod\"\n\t\t\t\"Game\"\t\"csgo\"\n\t\t\t\"Mod\"\t\"csgo\"\n",
+	},
+	{
+		name:        "crlf",
+		searchPaths: "\t\t\tGame_LowViolence\tcsgo_lv\r\n\t\t\tGame\tcsgo\r\n",
+		expected:    "\t\t\tGame_LowViolence\tcsgo_lv\r\n\t\t\tGame\tcsgo/addons/metamod\r\n\t\t\tGame\tcsgo\r\n",
+	},
+	{
+		name:        "without game entries",
+		searchPaths: "\t\t\tMod\tcsgo // mod\n",
+		expected:    "\t\t\tMod\tcsgo // mod\n\t\t\tGame\tcsgo/addons/metamod\n",
+	},
+}
+
+func gameinfoDocument(searchPaths string) string {
+	newline := "\n"
+	if strings.Contains(searchPaths, "\r\n") {
+		newline = "\r\n"
+	}
+
+	return strings.Join([]string{
+		`"GameInfo"`,
+		`{`,
+		"\tgame\t\t\"Counter-Strike 2\" // DO NOT EDIT",
+		`	FileSystem`,
+		`	{`,
+		`		SearchPaths`,
+		`		{`,
+		searchPaths + "\t\t}",
+		"\t\t\"UserSettingsPathID\"\t\"USRLOCAL\"",
+		`	}`,
+		`}`,
+		``,
+	}, newline)
+}
+
+func TestAddEntry_Gameinfo(t *testing.T) {
+	path := []string{"GameInfo", "FileSystem", "SearchPaths", "Game"}
+
+	for _, td := range gameinfoVariants {
+		document := gameinfoDocument(td.searchPaths)
+		actual, err := keyvalues.AddEntry(document, "csgo/addons/metamod", path...)
+		if err != nil {
+			t.Fatalf("%v: %v", td.name, err)
+		}
+
+		expected := gameinfoDocument(td.expected)
+		if actual != expected {
+			t.Fatalf("%v: expected\n%v\nbut got\n%v", td.name, expected, actual)
+		}
+
+		nodes, err := keyvalues.Parse(strings.NewReader(actual))
+		if err != nil {
+			t.Fatalf("%v: %v", td.name, err)
+		}
+		searchPaths := (&keyvalues.Node{Children: nodes}).Get(path[:3]...)
+		if searchPaths.Get("Game").Value != "csgo/addons/metamod" && td.name != "without game entries" {
+			t.Fatalf("%v: metamod is not the first game search path", td.name)
+		}
+
+		// removing the entry restores the document
+		removed, err := keyvalues.RemoveEntry(actual, "csgo/addons/metamod", path...)
+		if err != nil {
+			t.Fatalf("%v: %v", td.name, err)
+		}
+		if removed != document {
+			t.Fatalf("%v: expected\n%v\nbut got\n%v", td.name, document, removed)
+		}
+
+		if _, err := keyvalues.RemoveEntry(document, "csgo/addons/metamod", path...); !errors.Is(err, keyvalues.ErrNotFound) {
+			t.Fatalf("%v: expected ErrNotFound but got %v", td.name, err)
+		}
+	}
+
+	if _, err := keyvalues.AddEntry(gameinfoDocument(""), "value", "GameInfo", "Missing", "Game"); !errors.Is(err, keyvalues.ErrNotFound) {
+		t.Fatal("expected ErrNotFound but got", err)
+	}
+}
+
+// gameinfo.gi files of different cs2 versions. The windows variant starts with a BOM and uses crlf line endings
+var gameinfoFixtures = []string{"gameinfo_2023.gi", "gameinfo_2024.gi", "gameinfo_2024_windows.gi"}
+
+func TestGameinfoFixtures(t *testing.T) {
+	for _, name := range gameinfoFixtures {
+		content, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		document := string(content)
+
+		newline := "\n"
+		if strings.Contains(document, "\r\n") {
+			newline = "\r\n"
+		}
+
+		nodes, err := keyvalues.Parse(strings.NewReader(document))
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if len(nodes) != 1 || nodes[0].Key != "GameInfo" {
+			t.Fatalf("%v: expected the GameInfo root node but got %+v", name, nodes)
+		}
+
+		// the sections after SearchPaths are parsed as well
+		root := &keyvalues.Node{Children: nodes}
+		for _, expected := range []struct {
+			path  []string
+			value string
+		}{
+			{path: []string{"GameInfo", "FileSystem", "SearchPaths", "Game"}, value: "csgo"},
+			{path: []string{"GameInfo", "FileSystem", "SearchPaths", "Mod"}, value: "csgo"},
+			{path: []string{"GameInfo", "FileSystem", "UserSettingsPathID"}, value: "USRLOCAL"},
+			{path: []string{"GameInfo", "Engine2", "RenderingPipeline", "UsesDepthPrepass"}, value: "1"},
+			{path: []string{"GameInfo", "ToolsEnvironment", "ToolsDir"}, value: "../sdktools"},
+		} {
+			if value := root.GetValue(expected.path...); value != expected.value {
+				t.Fatalf("%v: expected '%v' for %v but got '%v'", name, expected.value, expected.path, value)
+			}
+		}
+
+		// only the metamod line is added
+		added, err := keyvalues.AddEntry(document, "csgo/addons/metamod", "GameInfo", "FileSystem", "SearchPaths", "Game")
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		expected := strings.Replace(document, "\t\t\tGame\tcsgo"+newline, "\t\t\tGame\tcsgo/addons/metamod"+newline+"\t\t\tGame\tcsgo"+newline, 1)
+		if added != expected {
+			t.Fatalf("%v: expected\n%v\nbut got\n%v", name, expected, added)
+		}
+
+		removed, err := keyvalues.RemoveEntry(added, "csgo/addons/metamod", "GameInfo", "FileSystem", "SearchPaths", "Game")
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if removed != document {
+			t.Fatalf("%v: removing the entry did not restore the document", name)
+		}
+
+		changed, err := keyvalues.SetValue(document, "CS2 Server", "GameInfo", "title")
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if expected := strings.Replace(document, "title\t\t\"Counter-Strike 2\"", "title\t\t\"CS2 Server\"", 1); changed != expected {
+			t.Fatalf("%v: expected\n%v\nbut got\n%v", name, expected, changed)
+		}
+
+		changed, err = keyvalues.RemoveKey(document, "GameInfo", "NetworkSystem", "SkipRedundantChangeCallbacks")
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if expected := strings.Replace(document, "\t\t\"SkipRedundantChangeCallbacks\"\t\"1\""+newline, "", 1); changed != expected {
+			t.Fatalf("%v: expected\n%v\nbut got\n%v", name, expected, changed)
+		}
+	}
+}
+
+func TestGameinfoFixtures_Conditionals(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "gameinfo_2024.gi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := string(content)
+
+	nodes, err := keyvalues.Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// conditionals are ignored, so keys with different conditionals are separate nodes
+	engine := (&keyvalues.Node{Children: nodes}).Get("GameInfo", "Engine2")
+	capable := make([]string, 0)
+	for _, child := range engine.Children {
+		if child.Key == "Capable64Bit" {
+			capable = append(capable, child.Value)
+		}
+	}
+	if strings.Join(capable, ",") != "1,0" {
+		t.Fatal("unexpected Capable64Bit values", capable)
+	}
+
+	// the conditional is removed together with the key
+	changed, err := keyvalues.RemoveKey(document, "GameInfo", "Engine2", "Capable64Bit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := strings.Replace(document, "\t\t\"Capable64Bit\" \"1\" [$WIN64]\n", "", 1); changed != expected {
+		t.Fatalf("expected\n%v\nbut got\n%v", expected, changed)
+	}
+
+	// the value of a key with a conditional is replaced without the conditional
+	changed, err = keyvalues.SetValue(document, "../game_otherplatforms/high_bitrate", "GameInfo", "FileSystem", "SearchPaths", "LayeredGameRoot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(document, "\"../game_otherplatforms/low_bitrate\" [$MOBILE || $ANDROID]", "\"../game_otherplatforms/high_bitrate\" [$MOBILE || $ANDROID]", 1)
+	if changed != expected {
+		t.Fatalf("expected\n%v\nbut got\n%v", expected, changed)
+	}
+}
@@ -1,31 +1,44 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/Phi-S/cs-server-manager/event"
 	"github.com/Phi-S/cs-server-manager/game_events"
+	"github.com/Phi-S/cs-server-manager/instances"
 	"github.com/Phi-S/cs-server-manager/logwrt"
 	"github.com/Phi-S/cs-server-manager/plugins"
+	"github.com/Phi-S/cs-server-manager/scheduler"
 	"github.com/Phi-S/cs-server-manager/server"
+	"github.com/Phi-S/cs-server-manager/server_snapshots"
 	"github.com/Phi-S/cs-server-manager/steamcmd"
+	"github.com/Phi-S/cs-server-manager/supervisor"
+	"github.com/Phi-S/cs-server-manager/update_checker"
 )
 
 // Handles server and steamcmd events to log them to the console and log file.
 // Also sends the events to all connected websocket clients
-func logEvents(
-	logWriter *logwrt.LogWriter,
-	webSocketServer *WebSocketServer,
-	serverInstance *server.Instance,
-	steamcmdInstance *steamcmd.Instance,
-	gameEventsInstance *game_events.Instance,
-	pluginsInstance *plugins.Instance,
-) {
+func logEvents(instance *instances.Instance) {
+	logWriter := instance.UserLogWriter
+	webSocketServer := instance.WebSocketServer
+	serverInstance := instance.Server
+	steamcmdInstance := instance.Steamcmd
+	supervisorInstance := instance.Supervisor
+	gameEventsInstance := instance.GameEvents
+	mapsInstance := instance.Maps
+	schedulerInstance := instance.Scheduler
+	pluginsInstance := instance.Plugins
+	updateCheckerInstance := instance.UpdateChecker
+	snapshotsInstance := instance.Snapshots
+
 	handleEvent := func(logType string, timestampUtc time.Time, message string, args ...any) {
 		logEntry := logwrt.NewLogEntry(timestampUtc, logType, message)
 
+		args = append(args, "instance")
+		args = append(args, instance.Id)
 		args = append(args, "timestamp-utc")
 		args = append(args, timestampUtc)
 		args = append(args, "message")
@@ -67,6 +80,23 @@ func logEvents(
 		handleEvent(serverLogType, p.TriggeredAtUtc, p.Data)
 	})
 
+	// supervisor
+	supervisorInstance.OnRestarting(func(p event.PayloadWithData[supervisor.RestartAttempt]) {
+		handleEvent(systemErrorLogType, p.TriggeredAtUtc, fmt.Sprintf("server crashed. Restart attempt %v in %v", p.Data.Attempt, p.Data.Backoff))
+	})
+
+	supervisorInstance.OnRestarted(func(p event.PayloadWithData[supervisor.RestartAttempt]) {
+		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("server restarted after %v attempt(s)", p.Data.Attempt))
+	})
+
+	supervisorInstance.OnRestartFailed(func(p event.PayloadWithData[error]) {
+		handleEvent(systemErrorLogType, p.TriggeredAtUtc, "server restart failed with error", "error", p.Data)
+	})
+
+	supervisorInstance.OnCrashLoopDetected(func(p event.PayloadWithData[supervisor.CrashLoop]) {
+		handleEvent(systemErrorLogType, p.TriggeredAtUtc, fmt.Sprintf("crash loop detected. Server restarted %v times in %v. No further restarts will be attempted", p.Data.Restarts, p.Data.Window))
+	})
+
 	// steamcmd
 	steamcmdInstance.OnStarted(func(p event.DefaultPayload) {
 		handleEvent(systemInfoLogType, p.TriggeredAtUtc, "server update started")
@@ -88,11 +118,37 @@ func logEvents(
 		handleEvent(steamcmdLogType, p.TriggeredAtUtc, p.Data)
 	})
 
+	// update_checker
+	updateCheckerInstance.OnUpdateAvailable(func(p event.PayloadWithData[update_checker.Result]) {
+		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Server update available. Installed build: %v, latest build: %v", p.Data.InstalledBuildId, p.Data.LatestBuildId))
+	})
+
+	updateCheckerInstance.OnCheckFailed(func(p event.PayloadWithData[error]) {
+		// steamcmd is downloaded with the first server update
+		if errors.Is(p.Data, steamcmd.ErrSteamcmdNotInstalled) {
+			return
+		}
+		handleEvent(systemErrorLogType, p.TriggeredAtUtc, fmt.Sprintf("Update check failed: %v", p.Data))
+	})
+
+	// snapshots
+	snapshotsInstance.OnCreated(func(p event.PayloadWithData[server_snapshots.Snapshot]) {
+		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Snapshot %v of build %v created", p.Data.Id, p.Data.BuildId))
+	})
+
+	snapshotsInstance.OnRestored(func(p event.PayloadWithData[server_snapshots.Snapshot]) {
+		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Snapshot %v of build %v restored", p.Data.Id, p.Data.BuildId))
+	})
+
 	// game_events
 	gameEventsInstance.OnMapChanged(func(p event.PayloadWithData[string]) {
 		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Map changed to %v", p.Data))
 	})
 
+	gameEventsInstance.OnMatchEnded(func(p event.PayloadWithData[game_events.MatchEnded]) {
+		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Match on %v ended with score %v", p.Data.Map, p.Data.Score))
+	})
+
 	gameEventsInstance.OnPlayerConnected(func(p event.PayloadWithData[game_events.PlayerConnected]) {
 		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("New player '%v'(%v) connected from '%v:%v'", p.Data.Name, p.Data.Id, p.Data.Ip, p.Data.Port))
 	})
@@ -101,6 +157,11 @@ func logEvents(
 		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Player '%v' disconnected", p.Data))
 	})
 
+	// game_maps
+	mapsInstance.OnRotationApplied(func(p event.PayloadWithData[string]) {
+		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Map rotation: next map is %v", p.Data))
+	})
+
 	// plugins
 	pluginsInstance.OnPluginInstalled(func(p event.PayloadWithData[plugins.PluginEventsPayload]) {
 		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Plugin '%v(%v)' installed", p.Data.Name, p.Data.Version))
@@ -109,4 +170,25 @@ func logEvents(
 	pluginsInstance.OnPluginUninstalledEvent(func(p event.PayloadWithData[plugins.PluginEventsPayload]) {
 		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Plugin '%v' uninstalled", p.Data.Name))
 	})
+
+	pluginsInstance.OnPluginUpgradedEvent(func(p event.PayloadWithData[plugins.PluginUpgradeEventsPayload]) {
+		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Plugin '%v' upgraded from %v to %v", p.Data.Name, p.Data.FromVersion, p.Data.ToVersion))
+	})
+
+	pluginsInstance.OnPluginUpgradeFailedEvent(func(p event.PayloadWithData[plugins.PluginUpgradeEventsPayload]) {
+		handleEvent(systemErrorLogType, p.TriggeredAtUtc, fmt.Sprintf("Plugin '%v' upgrade from %v to %v failed: %v", p.Data.Name, p.Data.FromVersion, p.Data.ToVersion, p.Data.Error))
+	})
+
+	// scheduler
+	schedulerInstance.OnRunStarted(func(p event.PayloadWithData[scheduler.Run]) {
+		handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Schedule '%v' started job '%v'", p.Data.ScheduleName, p.Data.JobType))
+	})
+
+	schedulerInstance.OnRunFinished(func(p event.PayloadWithData[scheduler.Run]) {
+		if p.Data.Success {
+			handleEvent(systemInfoLogType, p.TriggeredAtUtc, fmt.Sprintf("Schedule '%v' finished job '%v'. %v", p.Data.ScheduleName, p.Data.JobType, p.Data.Output))
+		} else {
+			handleEvent(systemErrorLogType, p.TriggeredAtUtc, fmt.Sprintf("Schedule '%v' failed job '%v': %v", p.Data.ScheduleName, p.Data.JobType, p.Data.Error))
+		}
+	})
 }
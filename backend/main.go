@@ -8,20 +8,14 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
-	"sync"
 	"time"
 
 	"github.com/Phi-S/cs-server-manager/config"
 	"github.com/Phi-S/cs-server-manager/constants"
-	"github.com/Phi-S/cs-server-manager/editor"
+	"github.com/Phi-S/cs-server-manager/event"
 	"github.com/Phi-S/cs-server-manager/gvalidator"
 	"github.com/Phi-S/cs-server-manager/handlers"
-	"github.com/Phi-S/cs-server-manager/logwrt"
-	"github.com/Phi-S/cs-server-manager/plugins"
-	"github.com/Phi-S/cs-server-manager/server"
-	"github.com/Phi-S/cs-server-manager/start_parameters_json"
-	"github.com/Phi-S/cs-server-manager/status"
-	"github.com/Phi-S/cs-server-manager/steamcmd"
+	"github.com/Phi-S/cs-server-manager/instances"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/adaptor"
@@ -58,60 +52,25 @@ func main() {
 		os.Exit(1)
 	}
 
-	steamcmdInstance,
-		serverInstance,
-		startParametersJsonFileHandler,
-		userLogWriter,
-		statusInstance,
-		webSocketServerInstance,
-		gameEventsInstance,
-		pluginsInstance,
-		editorInstance,
-		err := createRequiredServices(cfg)
+	instancesJsonPath := filepath.Join(cfg.DataDir, "instances.json")
+	registry, err := instances.NewRegistry(cfg, instancesJsonPath)
 	if err != nil {
-		slog.Error("FATAL: failed to create required services", "error", err)
+		slog.Error("FATAL: failed to create instance registry", "error", err)
 		os.Exit(1)
 	}
 
-	registerEvents(
-		cfg,
-		serverInstance,
-		steamcmdInstance,
-		startParametersJsonFileHandler,
-		userLogWriter,
-		statusInstance,
-		webSocketServerInstance,
-		gameEventsInstance,
-		pluginsInstance,
-	)
-
-	defer func() {
-		_ = steamcmdInstance.Cancel()
-		steamcmdInstance.Close()
-
-		_ = serverInstance.Stop()
-		serverInstance.Close()
-
-		userLogWriter.Close()
-	}()
-
-	// this lock is used to prevent collision between the server and steamcmd instance
-	// Fox example the lock is used to prevent the server from being started while a steamcmd updated is getting started at the same time.
-	// This can occur if two http request are coming in at the same time and the internal status of the steamcmd and/or server instances is not yet updated
-	ServerSteamcmdLock := sync.Mutex{}
-
-	startApi(
-		cfg,
-		&ServerSteamcmdLock,
-		serverInstance,
-		steamcmdInstance,
-		startParametersJsonFileHandler,
-		statusInstance,
-		userLogWriter,
-		webSocketServerInstance,
-		pluginsInstance,
-		editorInstance,
-	)
+	registry.OnInstanceCreated(func(p event.PayloadWithData[*instances.Instance]) {
+		registerEvents(cfg, p.Data)
+	})
+
+	if err := registry.Load(); err != nil {
+		slog.Error("FATAL: failed to load instances", "error", err)
+		os.Exit(1)
+	}
+
+	defer registry.Close()
+
+	startApi(cfg, registry)
 }
 
 func configureLogger() {
@@ -129,19 +88,11 @@ func configureLogger() {
 	slog.SetDefault(logger)
 }
 
-func startApi(
-	config config.Config,
-	ServerSteamcmdLock *sync.Mutex,
-	serverInstance *server.Instance,
-	steamcmdInstance *steamcmd.Instance,
-	startParametersJsonFile *start_parameters_json.Instance,
-	status *status.Status,
-	userLogWriter *logwrt.LogWriter,
-	webSocketServer *WebSocketServer,
-	pluginsInstance *plugins.Instance,
-	editorInstance *editor.Instance,
-) {
+func startApi(config config.Config, registry *instances.Registry) {
 	app := fiber.New(fiber.Config{
+		// bodies larger than the body limit are streamed instead of rejected. bodyLimitMiddleware enforces the limit of each route
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 		ErrorHandler: func(c fiber.Ctx, err error) error {
 			requestId := requestid.FromContext(c)
 			if requestId == "" {
@@ -174,30 +125,31 @@ func startApi(
 	api.Use(requestid.New())
 	api.Use(logMiddleware)
 	api.Use(panicHandler)
+	api.Use(bodyLimitMiddleware)
 
 	v1 := api.Group("/v1", func(c fiber.Ctx) error {
 		c.Locals(constants.ConfigKey, config)
-		c.Locals(constants.ServerSteamcmdLockKey, ServerSteamcmdLock)
-		c.Locals(constants.ServerInstanceKey, serverInstance)
-		c.Locals(constants.SteamCmdInstanceKey, steamcmdInstance)
-		c.Locals(constants.StartParametersJsonFileKey, startParametersJsonFile)
-		c.Locals(constants.StatusKey, status)
-		c.Locals(constants.PluginsKey, pluginsInstance)
-		c.Locals(constants.UserLogWriterKey, userLogWriter)
-		c.Locals(constants.EditorKey, editorInstance)
+		c.Locals(constants.RegistryKey, registry)
+		setInstanceLocals(c, registry.Default())
 		return c.Next()
 	})
 
-	handlers.RegisterStatus(v1)
-	handlers.RegisterStartStop(v1)
-	handlers.RegisterCommand(v1)
-	handlers.RegisterUpdate(v1)
-	handlers.RegisterSettings(v1)
-	handlers.RegisterPlugins(v1)
-	handlers.RegisterLogs(v1)
-	handlers.RegisterFiles(v1)
+	handlers.RegisterInstances(v1)
+	registerInstanceRoutes(v1)
+
+	// the same routes as above but for the instance with the given id.
+	// The routes without the instance prefix are always using the default instance
+	instanceGroup := v1.Group("/instances/:id", func(c fiber.Ctx) error {
+		instance, err := registry.Get(c.Params("id"))
+		if err != nil {
+			return handlers.NewErrorWithInternal(c, fiber.StatusNotFound, "instance not found", err)
+		}
+
+		setInstanceLocals(c, instance)
+		return c.Next()
+	})
 
-	v1.Get("/ws", adaptor.HTTPHandler(websocket.Handler(webSocketServer.handleWs)))
+	registerInstanceRoutes(instanceGroup)
 
 	if config.EnableSwagger {
 		swagger := api.Group("swagger")
@@ -225,6 +177,50 @@ func startApi(
 	log.Fatal(app.Listen(":" + config.HttpPort))
 }
 
+func setInstanceLocals(c fiber.Ctx, instance *instances.Instance) {
+	c.Locals(constants.InstanceKey, instance)
+	c.Locals(constants.ServerSteamcmdLockKey, instance.ServerSteamcmdLock)
+	c.Locals(constants.ServerInstanceKey, instance.Server)
+	c.Locals(constants.SteamCmdInstanceKey, instance.Steamcmd)
+	c.Locals(constants.UpdateCheckerKey, instance.UpdateChecker)
+	c.Locals(constants.SnapshotsKey, instance.Snapshots)
+	c.Locals(constants.SupervisorKey, instance.Supervisor)
+	c.Locals(constants.StartParametersJsonFileKey, instance.StartParametersJson)
+	c.Locals(constants.ProfilesKey, instance.Profiles)
+	c.Locals(constants.StatusKey, instance.Status)
+	c.Locals(constants.MapsKey, instance.Maps)
+	c.Locals(constants.PluginsKey, instance.Plugins)
+	c.Locals(constants.UserLogWriterKey, instance.UserLogWriter)
+	c.Locals(constants.EditorKey, instance.Editor)
+	c.Locals(constants.SchedulerKey, instance.Scheduler)
+	c.Locals(constants.BackupsKey, instance.Backups)
+}
+
+func registerInstanceRoutes(router fiber.Router) {
+	handlers.RegisterStatus(router)
+	handlers.RegisterStartStop(router)
+	handlers.RegisterCommand(router)
+	handlers.RegisterMaps(router)
+	handlers.RegisterUpdate(router)
+	handlers.RegisterSnapshots(router)
+	handlers.RegisterSettings(router)
+	handlers.RegisterProfiles(router)
+	handlers.RegisterPlugins(router)
+	handlers.RegisterLogs(router)
+	handlers.RegisterFiles(router)
+	handlers.RegisterSchedules(router)
+	handlers.RegisterBackups(router)
+
+	router.Get("/ws", func(c fiber.Ctx) error {
+		instance, err := handlers.GetFromLocals[*instances.Instance](c, constants.InstanceKey)
+		if err != nil {
+			return handlers.NewInternalServerErrorWithInternal(c, err)
+		}
+
+		return adaptor.HTTPHandler(websocket.Handler(instance.WebSocketServer.HandleWs))(c)
+	})
+}
+
 func mapDir(router fiber.Router, path string, fs embed.FS, dir string) error {
 	router.Get("", static.New(fmt.Sprintf("%v/index.html", dir), static.Config{
 		FS:     fs,
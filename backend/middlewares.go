@@ -3,11 +3,14 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"github.com/Phi-S/cs-server-manager/constants"
+	"github.com/Phi-S/cs-server-manager/handlers"
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/requestid"
 )
@@ -57,6 +60,53 @@ func logMiddleware(c fiber.Ctx) error {
 	return err
 }
 
+// body limits of the routes that accept larger bodies than the default limit of 4 MB by the suffix of the path
+var bodyLimits = map[string]int{
+	// uploaded plugin archives, e.g. CounterStrikeSharp with runtime. The archive is streamed to a temp file
+	"/plugins/upload": 256 * 1024 * 1024,
+}
+
+// bodyLimitMiddleware rejects request bodies that are larger than the body limit of the route
+func bodyLimitMiddleware(c fiber.Ctx) error {
+	limit := fiber.DefaultBodyLimit
+	if c.Method() == fiber.MethodPost {
+		for suffix, routeLimit := range bodyLimits {
+			if strings.HasSuffix(c.Path(), suffix) {
+				limit = routeLimit
+			}
+		}
+	}
+
+	contentLength := c.Request().Header.ContentLength()
+	// bodies that are larger than the default limit or chunked are streamed. If the handler does not read the whole body,
+	// the rest of it would be read as the next request of the connection
+	if contentLength > fiber.DefaultBodyLimit || contentLength == -1 {
+		c.Response().Header.SetConnectionClose()
+	}
+
+	if contentLength > limit {
+		return handlers.NewErrorWithMessage(c, fiber.StatusRequestEntityTooLarge, fmt.Sprintf("request body can not be bigger than %v MB", limit/1024/1024))
+	}
+
+	// the size of chunked bodies is unknown until they are read
+	if bodyStream := c.Request().BodyStream(); contentLength == -1 && bodyStream != nil {
+		if limit != fiber.DefaultBodyLimit {
+			return handlers.NewErrorWithMessage(c, fiber.StatusLengthRequired, "content length is required")
+		}
+
+		body, err := io.ReadAll(io.LimitReader(bodyStream, int64(limit)+1))
+		if err != nil {
+			return handlers.NewErrorWithInternal(c, fiber.StatusBadRequest, "failed to read request body", err)
+		}
+		if len(body) > limit {
+			return handlers.NewErrorWithMessage(c, fiber.StatusRequestEntityTooLarge, fmt.Sprintf("request body can not be bigger than %v MB", limit/1024/1024))
+		}
+		c.Request().SetBody(body)
+	}
+
+	return c.Next()
+}
+
 func panicHandler(c fiber.Ctx) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
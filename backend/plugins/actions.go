@@ -0,0 +1,578 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Phi-S/cs-server-manager/keyvalues"
+)
+
+type ActionType string
+
+const (
+	// ActionTypeInsertLine inserts Line after the first line that matches Anchor. Reverted by removing the line again
+	ActionTypeInsertLine ActionType = "insert_line"
+	// ActionTypeRemoveLine removes Line. Reverted by inserting the removed line after Anchor again
+	ActionTypeRemoveLine ActionType = "remove_line"
+	// ActionTypeSetKeyValue sets the KeyValues key to Value. Reverted by restoring the previous value or removing the added key
+	ActionTypeSetKeyValue ActionType = "set_keyvalue"
+	// ActionTypeAddKeyValue adds the KeyValues key with Value to its section, e.g. a SearchPaths entry of the gameinfo.gi.
+	// Nothing is added if the section already contains the key with the value. Reverted by removing the entry
+	ActionTypeAddKeyValue ActionType = "add_keyvalue"
+	// ActionTypeCopyFile copies Source to File if File does not exist yet. Reverted by removing the copy if it was not modified
+	ActionTypeCopyFile ActionType = "copy_file"
+	// ActionTypeServerCommand executes Command once on the next server start. Reverted by executing RevertCommand on the next server start
+	ActionTypeServerCommand ActionType = "server_command"
+)
+
+// Action is executed after the plugin files are installed and reverted before they are uninstalled.
+// All paths are relative to the csgo dir
+type Action struct {
+	Type ActionType `json:"type" validate:"required,oneof=insert_line remove_line set_keyvalue add_keyvalue copy_file server_command"`
+	// the changed file. The destination of copy_file
+	File string `json:"file,omitempty" validate:"omitempty,lt=256"`
+	// lines are compared without leading, trailing and repeated whitespace
+	Anchor string `json:"anchor,omitempty" validate:"omitempty,lt=256"`
+	Line   string `json:"line,omitempty" validate:"omitempty,lt=256"`
+	// path of the key in the KeyValues file, e.g. ["GameInfo", "FileSystem", "SearchPaths", "Game"]
+	Key   []string `json:"key,omitempty" validate:"omitnil,dive,required,lt=64"`
+	Value string   `json:"value,omitempty" validate:"omitempty,lt=256"`
+	// file that is copied, e.g. a default config of the plugin archive
+	Source        string `json:"source,omitempty" validate:"omitempty,lt=256"`
+	Command       string `json:"command,omitempty" validate:"omitempty,lt=256"`
+	RevertCommand string `json:"revert_command,omitempty" validate:"omitempty,lt=256"`
+}
+
+// AppliedAction is an executed action together with everything that is required to revert it
+type AppliedAction struct {
+	Action
+	// false if the action had nothing to do, e.g. because the line already existed. Unchanged actions are not reverted
+	Changed bool `json:"changed"`
+	// the removed line of remove_line or the previous value of set_keyvalue. Nil if the key did not exist
+	Previous *string `json:"previous,omitempty"`
+	// sha256 hash of the copied file. A copy that was modified afterward is kept on revert
+	Sha256 string `json:"sha256,omitempty" validate:"omitempty,len=64,hexadecimal"`
+}
+
+// validateAction checks that all fields required by the action type are set and that all paths stay inside the csgo dir
+func validateAction(action Action) error {
+	required := map[ActionType][]string{
+		ActionTypeInsertLine:    {"file", "anchor", "line"},
+		ActionTypeRemoveLine:    {"file", "anchor", "line"},
+		ActionTypeSetKeyValue:   {"file", "key"},
+		ActionTypeAddKeyValue:   {"file", "key"},
+		ActionTypeCopyFile:      {"file", "source"},
+		ActionTypeServerCommand: {"command"},
+	}[action.Type]
+
+	fields := map[string]bool{
+		"file":    action.File != "",
+		"anchor":  strings.TrimSpace(action.Anchor) != "",
+		"line":    strings.TrimSpace(action.Line) != "",
+		"key":     len(action.Key) > 0,
+		"source":  action.Source != "",
+		"command": strings.TrimSpace(action.Command) != "",
+	}
+
+	for _, field := range required {
+		if !fields[field] {
+			return fmt.Errorf("%v action requires '%v'", action.Type, field)
+		}
+	}
+
+	if action.Type == ActionTypeAddKeyValue && len(action.Key) < 2 {
+		return fmt.Errorf("%v action requires the section and the name of the key", action.Type)
+	}
+
+	for _, path := range []string{action.File, action.Source} {
+		if path != "" && !filepath.IsLocal(strings.TrimPrefix(path, "/")) {
+			return fmt.Errorf("%v action path '%v' is outside of the csgo dir", action.Type, path)
+		}
+	}
+
+	return nil
+}
+
+func (a Action) equal(other Action) bool {
+	return a.Type == other.Type &&
+		a.File == other.File &&
+		a.Anchor == other.Anchor &&
+		a.Line == other.Line &&
+		slices.Equal(a.Key, other.Key) &&
+		a.Value == other.Value &&
+		a.Source == other.Source &&
+		a.Command == other.Command &&
+		a.RevertCommand == other.RevertCommand
+}
+
+// applyActions executes the actions in order. All changed files are journaled in the transaction
+func (i *Instance) applyActions(tx *transaction, actions []Action) ([]AppliedAction, error) {
+	applied := make([]AppliedAction, 0, len(actions))
+	for index, action := range actions {
+		appliedAction, err := i.applyAction(tx, action)
+		if err != nil {
+			return nil, fmt.Errorf("%v action %v: %w", action.Type, index+1, err)
+		}
+		applied = append(applied, appliedAction)
+	}
+
+	return applied, nil
+}
+
+// revertActions reverts the actions in reverse order. The transaction can be nil
+func (i *Instance) revertActions(tx *transaction, actions []AppliedAction) error {
+	for index := len(actions) - 1; index >= 0; index-- {
+		if err := i.revertAction(tx, actions[index]); err != nil {
+			return fmt.Errorf("revert %v action %v: %w", actions[index].Type, index+1, err)
+		}
+	}
+
+	return nil
+}
+
+// replaceActions reverts the applied actions that are not part of the new actions anymore and applies the new ones.
+// Actions that did not change are kept as they are
+func (i *Instance) replaceActions(tx *transaction, applied []AppliedAction, actions []Action) ([]AppliedAction, error) {
+	removed := make([]AppliedAction, 0)
+	for _, appliedAction := range applied {
+		if !slices.ContainsFunc(actions, appliedAction.Action.equal) {
+			removed = append(removed, appliedAction)
+		}
+	}
+
+	if err := i.revertActions(tx, removed); err != nil {
+		return nil, err
+	}
+
+	result := make([]AppliedAction, 0, len(actions))
+	for index, action := range actions {
+		keptIndex := slices.IndexFunc(applied, func(a AppliedAction) bool {
+			return a.Action.equal(action)
+		})
+		if keptIndex != -1 {
+			result = append(result, applied[keptIndex])
+			continue
+		}
+
+		appliedAction, err := i.applyAction(tx, action)
+		if err != nil {
+			return nil, fmt.Errorf("%v action %v: %w", action.Type, index+1, err)
+		}
+		result = append(result, appliedAction)
+	}
+
+	return result, nil
+}
+
+// appliedActionsOf returns the actions that are reverted if the plugin is uninstalled.
+// Plugins that were installed before the actions were tracked could only have the gameinfo.gi entry that was hardcoded for metamod,
+// so only the insert_line and add_keyvalue actions of the plugins list are reverted. Both do nothing if the entry does not exist
+func (i *Instance) appliedActionsOf(installedPlugin InstalledPlugin) []AppliedAction {
+	if installedPlugin.Actions != nil || installedPlugin.Uploaded {
+		return installedPlugin.Actions
+	}
+
+	result := make([]AppliedAction, 0)
+	for _, plugin := range i.GetAllAvailablePlugins() {
+		if plugin.Name != installedPlugin.Name {
+			continue
+		}
+
+		for _, action := range plugin.Actions {
+			if action.Type == ActionTypeInsertLine || action.Type == ActionTypeAddKeyValue {
+				result = append(result, AppliedAction{Action: action, Changed: true})
+			}
+		}
+	}
+
+	return result
+}
+
+func (i *Instance) applyAction(tx *transaction, action Action) (AppliedAction, error) {
+	applied := AppliedAction{Action: action}
+	path := i.actionPath(action.File)
+
+	switch action.Type {
+	case ActionTypeInsertLine:
+		err := editFile(tx, path, func(content string) (string, error) {
+			lines := strings.Split(content, "\n")
+			if indexOfLine(lines, action.Line) != -1 {
+				return content, nil
+			}
+
+			anchorIndex := indexOfLine(lines, action.Anchor)
+			if anchorIndex == -1 {
+				return "", fmt.Errorf("anchor '%v' not found in '%v'", action.Anchor, action.File)
+			}
+
+			applied.Changed = true
+			return strings.Join(slices.Insert(lines, anchorIndex+1, action.Line), "\n"), nil
+		})
+		return applied, err
+	case ActionTypeRemoveLine:
+		err := editFile(tx, path, func(content string) (string, error) {
+			lines := strings.Split(content, "\n")
+			index := indexOfLine(lines, action.Line)
+			if index == -1 {
+				return content, nil
+			}
+
+			previous := lines[index]
+			applied.Changed = true
+			applied.Previous = &previous
+			return strings.Join(slices.Delete(lines, index, index+1), "\n"), nil
+		})
+		return applied, err
+	case ActionTypeSetKeyValue:
+		err := editFile(tx, path, func(content string) (string, error) {
+			node, err := getKeyValuesNode(content, action.Key)
+			if err != nil {
+				return "", err
+			}
+
+			if node != nil {
+				if node.IsSection() {
+					return "", fmt.Errorf("key '%v' is a section", strings.Join(action.Key, "/"))
+				}
+				if node.Value == action.Value {
+					return content, nil
+				}
+				applied.Previous = &node.Value
+			}
+
+			applied.Changed = true
+			return keyvalues.SetValue(content, action.Value, action.Key...)
+		})
+		return applied, err
+	case ActionTypeAddKeyValue:
+		err := editFile(tx, path, func(content string) (string, error) {
+			exists, err := containsKeyValue(content, action.Key, action.Value)
+			if err != nil || exists {
+				return content, err
+			}
+
+			applied.Changed = true
+			return keyvalues.AddEntry(content, action.Value, action.Key...)
+		})
+		return applied, err
+	case ActionTypeCopyFile:
+		if _, err := os.Stat(path); err == nil {
+			return applied, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return AppliedAction{}, fmt.Errorf("os.Stat: %w", err)
+		}
+
+		if err := tx.mkdirAll(filepath.Dir(path)); err != nil {
+			return AppliedAction{}, err
+		}
+
+		if err := tx.record(path); err != nil {
+			return AppliedAction{}, err
+		}
+
+		if err := copyFile(i.actionPath(action.Source), path); err != nil {
+			return AppliedAction{}, fmt.Errorf("failed to copy '%v' to '%v': %w", action.Source, action.File, err)
+		}
+
+		hash, err := fileSha256(path)
+		if err != nil {
+			return AppliedAction{}, err
+		}
+
+		applied.Changed = true
+		applied.Sha256 = hash
+		return applied, nil
+	case ActionTypeServerCommand:
+		if err := i.queueCommand(tx, action.Command); err != nil {
+			return AppliedAction{}, err
+		}
+
+		applied.Changed = true
+		return applied, nil
+	default:
+		return AppliedAction{}, fmt.Errorf("unknown action type '%v'", action.Type)
+	}
+}
+
+// revertAction undoes the changes of the action. Changes that were already undone or modified by the user are kept and logged
+func (i *Instance) revertAction(tx *transaction, applied AppliedAction) error {
+	if !applied.Changed {
+		return nil
+	}
+
+	path := i.actionPath(applied.File)
+	keep := func(reason string) {
+		slog.Info("plugin action not reverted", "type", applied.Type, "file", applied.File, "reason", reason)
+	}
+
+	switch applied.Type {
+	case ActionTypeInsertLine:
+		return editFileIfExists(tx, path, func(content string) (string, error) {
+			lines := strings.Split(content, "\n")
+			index := indexOfLine(lines, applied.Line)
+			if index == -1 {
+				keep("line not found")
+				return content, nil
+			}
+
+			return strings.Join(slices.Delete(lines, index, index+1), "\n"), nil
+		})
+	case ActionTypeRemoveLine:
+		return editFileIfExists(tx, path, func(content string) (string, error) {
+			lines := strings.Split(content, "\n")
+			if indexOfLine(lines, applied.Line) != -1 {
+				keep("line already exists")
+				return content, nil
+			}
+
+			anchorIndex := indexOfLine(lines, applied.Anchor)
+			if anchorIndex == -1 {
+				keep("anchor not found")
+				return content, nil
+			}
+
+			line := applied.Line
+			if applied.Previous != nil {
+				line = *applied.Previous
+			}
+			return strings.Join(slices.Insert(lines, anchorIndex+1, line), "\n"), nil
+		})
+	case ActionTypeSetKeyValue:
+		return editFileIfExists(tx, path, func(content string) (string, error) {
+			node, err := getKeyValuesNode(content, applied.Key)
+			if err != nil {
+				return "", err
+			}
+
+			if node == nil || node.IsSection() || node.Value != applied.Value {
+				keep("value was changed")
+				return content, nil
+			}
+
+			if applied.Previous == nil {
+				return keyvalues.RemoveKey(content, applied.Key...)
+			}
+			return keyvalues.SetValue(content, *applied.Previous, applied.Key...)
+		})
+	case ActionTypeAddKeyValue:
+		return editFileIfExists(tx, path, func(content string) (string, error) {
+			newContent, err := keyvalues.RemoveEntry(content, applied.Value, applied.Key...)
+			if errors.Is(err, keyvalues.ErrNotFound) {
+				keep("entry not found")
+				return content, nil
+			}
+			return newContent, err
+		})
+	case ActionTypeCopyFile:
+		hash, err := fileSha256(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				keep("file does not exist")
+				return nil
+			}
+			return err
+		}
+
+		if hash != applied.Sha256 {
+			keep("file was modified")
+			return nil
+		}
+
+		return tx.remove(path)
+	case ActionTypeServerCommand:
+		// a command that was not executed yet is dropped instead
+		removed, err := i.dropCommand(tx, applied.Command)
+		if err != nil || removed || applied.RevertCommand == "" {
+			return err
+		}
+
+		return i.queueCommand(tx, applied.RevertCommand)
+	default:
+		return fmt.Errorf("unknown action type '%v'", applied.Type)
+	}
+}
+
+func (i *Instance) actionPath(file string) string {
+	return filepath.Join(i.csgoDir, strings.TrimPrefix(file, "/"))
+}
+
+// normalizeLine removes leading, trailing and repeated whitespace, so lines match independent of their indentation
+func normalizeLine(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
+
+func indexOfLine(lines []string, line string) int {
+	normalized := normalizeLine(line)
+	return slices.IndexFunc(lines, func(l string) bool {
+		return normalizeLine(l) == normalized
+	})
+}
+
+func getKeyValuesNode(content string, key []string) (*keyvalues.Node, error) {
+	nodes, err := keyvalues.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("keyvalues.Parse: %w", err)
+	}
+
+	return (&keyvalues.Node{Children: nodes}).Get(key...), nil
+}
+
+// containsKeyValue reports whether the section of the key contains the key with the value. The section has to exist
+func containsKeyValue(content string, key []string, value string) (bool, error) {
+	section, err := getKeyValuesNode(content, key[:len(key)-1])
+	if err != nil {
+		return false, err
+	}
+
+	if section == nil || !section.IsSection() {
+		return false, fmt.Errorf("section '%v' not found", strings.Join(key[:len(key)-1], "/"))
+	}
+
+	return slices.ContainsFunc(section.Children, func(child *keyvalues.Node) bool {
+		return strings.EqualFold(child.Key, key[len(key)-1]) && !child.IsSection() && child.Value == value
+	}), nil
+}
+
+// editFile replaces the content of the file with the result of edit. The file is only journaled and written if the content changed
+func editFile(tx *transaction, path string, edit func(content string) (string, error)) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("os.Stat: %w", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	newContent, err := edit(string(content))
+	if err != nil {
+		return err
+	}
+
+	if newContent == string(content) {
+		return nil
+	}
+
+	if err := tx.record(path); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, []byte(newContent), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
+
+// editFileIfExists is editFile for reverts. A file that was removed in the meantime has nothing to revert
+func editFileIfExists(tx *transaction, path string, edit func(content string) (string, error)) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		slog.Info("plugin action not reverted", "file", path, "reason", "file does not exist")
+		return nil
+	}
+
+	return editFile(tx, path, edit)
+}
+
+// TakePendingCommands returns the commands of server_command actions and removes them from the pending commands file.
+// Called once the server is started. Waits for a running install, so commands of a reverted install are never returned
+func (i *Instance) TakePendingCommands() ([]string, error) {
+	if i.pendingCommandsJsonPath == "" {
+		return nil, nil
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	commands, err := i.readPendingCommands()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(commands) == 0 {
+		return commands, nil
+	}
+
+	if err := i.writePendingCommands(nil, nil); err != nil {
+		return nil, err
+	}
+
+	return commands, nil
+}
+
+func (i *Instance) queueCommand(tx *transaction, command string) error {
+	if i.pendingCommandsJsonPath == "" {
+		return errors.New("server commands of plugin actions are not supported without pending commands file")
+	}
+
+	commands, err := i.readPendingCommands()
+	if err != nil {
+		return err
+	}
+
+	return i.writePendingCommands(tx, append(commands, command))
+}
+
+// dropCommand removes the command from the pending commands. Returns false if the command is not pending
+func (i *Instance) dropCommand(tx *transaction, command string) (bool, error) {
+	if i.pendingCommandsJsonPath == "" {
+		return false, nil
+	}
+
+	commands, err := i.readPendingCommands()
+	if err != nil {
+		return false, err
+	}
+
+	index := slices.Index(commands, command)
+	if index == -1 {
+		return false, nil
+	}
+
+	return true, i.writePendingCommands(tx, slices.Delete(commands, index, index+1))
+}
+
+func (i *Instance) readPendingCommands() ([]string, error) {
+	content, err := os.ReadFile(i.pendingCommandsJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	var commands []string
+	if err := json.Unmarshal(content, &commands); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal pending commands: %w", err)
+	}
+
+	return commands, nil
+}
+
+func (i *Instance) writePendingCommands(tx *transaction, commands []string) error {
+	if commands == nil {
+		commands = []string{}
+	}
+
+	content, err := json.MarshalIndent(commands, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
+	if err := tx.record(i.pendingCommandsJsonPath); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(i.pendingCommandsJsonPath, content, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package plugins_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/plugins"
+
+	"github.com/google/uuid"
+)
+
+var metamodAction = plugins.Action{
+	Type:  plugins.ActionTypeAddKeyValue,
+	File:  "gameinfo.gi",
+	Key:   []string{"GameInfo", "FileSystem", "SearchPaths", "Game"},
+	Value: "csgo/addons/metamod",
+}
+
+func expectPendingCommands(t *testing.T, pluginsInstance *plugins.Instance, commands ...string) {
+	actual, err := pluginsInstance.TakePendingCommands()
+	if err != nil {
+		t.Fatal("TakePendingCommands", err)
+	}
+
+	if !slices.Equal(actual, commands) {
+		t.Fatalf("expected pending commands %v but got %v", commands, actual)
+	}
+}
+
+func TestActions(t *testing.T) {
+	archive := createZip(t, map[string]string{
+		"addons/plugin/plugin.dll":  "plugin",
+		"addons/plugin/default.cfg": "default",
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	version := func(name string) plugins.Version {
+		v := testVersion(name)
+		v.DownloadURL = server.URL + "/plugin.zip"
+		return v
+	}
+
+	plugin := testPlugin("plugin", version("1.0.0"), version("2.0.0"))
+	plugin.Actions = []plugins.Action{
+		metamodAction,
+		{Type: plugins.ActionTypeInsertLine, File: "cfg/server.cfg", Anchor: "hostname    test", Line: "exec plugin.cfg"},
+		{Type: plugins.ActionTypeRemoveLine, File: "cfg/server.cfg", Anchor: "hostname test", Line: "sv_cheats 0"},
+		{Type: plugins.ActionTypeSetKeyValue, File: "cfg/plugin.vdf", Key: []string{"Settings", "enabled"}, Value: "1"},
+		{Type: plugins.ActionTypeSetKeyValue, File: "cfg/plugin.vdf", Key: []string{"Settings", "mode"}, Value: "competitive"},
+		{Type: plugins.ActionTypeCopyFile, File: "cfg/plugin.cfg", Source: "addons/plugin/default.cfg"},
+		{Type: plugins.ActionTypeServerCommand, Command: "plugin_reload", RevertCommand: "plugin_unload"},
+	}
+
+	pluginsInstance, tempDirPath := createResolverTestInstance(t, []plugins.Plugin{plugin}, "")
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+	gameinfoPath := filepath.Join(csgoDir, "gameinfo.gi")
+	serverCfgPath := filepath.Join(csgoDir, "cfg", "server.cfg")
+	vdfPath := filepath.Join(csgoDir, "cfg", "plugin.vdf")
+	copyPath := filepath.Join(csgoDir, "cfg", "plugin.cfg")
+
+	if err := createGameinfoFile(gameinfoPath); err != nil {
+		t.Fatal("createGameinfoFile", err)
+	}
+	gameinfoContent, err := os.ReadFile(gameinfoPath)
+	if err != nil {
+		t.Fatal("os.ReadFile", err)
+	}
+
+	const serverCfgContent = "hostname test\n  sv_cheats    0\nmp_warmup_end\n"
+	const vdfContent = "\"Settings\"\n{\n\t\"enabled\"\t\"0\" // disabled by default\n}\n"
+	if err := os.MkdirAll(filepath.Dir(serverCfgPath), os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+	if err := os.WriteFile(serverCfgPath, []byte(serverCfgContent), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+	if err := os.WriteFile(vdfPath, []byte(vdfContent), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	if err := pluginsInstance.InstallPluginByName(context.Background(), "plugin", "1.0.0"); err != nil {
+		t.Fatal("InstallPluginByName", err)
+	}
+
+	expectFileContent(t, gameinfoPath, strings.Replace(string(gameinfoContent), "\t\t\tGame\tcsgo\n", "\t\t\tGame\tcsgo/addons/metamod\n\t\t\tGame\tcsgo\n", 1))
+	expectFileContent(t, serverCfgPath, "hostname test\nexec plugin.cfg\nmp_warmup_end\n")
+	expectFileContent(t, vdfPath, "\"Settings\"\n{\n\t\"enabled\"\t\"1\" // disabled by default\n\t\"mode\"\t\"competitive\"\n}\n")
+	expectFileContent(t, copyPath, "default")
+	expectPendingCommands(t, pluginsInstance, "plugin_reload")
+	expectPendingCommands(t, pluginsInstance)
+
+	// unchanged actions are not executed again
+	if _, err := pluginsInstance.Upgrade(context.Background(), "plugin", "2.0.0"); err != nil {
+		t.Fatal("Upgrade", err)
+	}
+	expectPendingCommands(t, pluginsInstance)
+
+	if err := pluginsInstance.Uninstall("plugin"); err != nil {
+		t.Fatal("Uninstall", err)
+	}
+
+	// the removed line keeps its original whitespace. The entry of the gameinfo.gi is removed again
+	expectFileContent(t, gameinfoPath, string(gameinfoContent))
+	expectFileContent(t, serverCfgPath, serverCfgContent)
+	expectFileContent(t, vdfPath, vdfContent)
+	expectFileNotExists(t, copyPath)
+	expectPendingCommands(t, pluginsInstance, "plugin_unload")
+
+	// changes that existed before the installation and modifications of the user are kept
+	withMetamodLine := strings.Replace(string(gameinfoContent), "override\n", "override\n    Game    csgo/addons/metamod\n", 1)
+	if err := os.WriteFile(gameinfoPath, []byte(withMetamodLine), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	if err := pluginsInstance.InstallPluginByName(context.Background(), "plugin", "1.0.0"); err != nil {
+		t.Fatal("InstallPluginByName", err)
+	}
+	expectFileContent(t, gameinfoPath, withMetamodLine)
+
+	if err := os.WriteFile(copyPath, []byte("user"), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+	if err := os.WriteFile(vdfPath, []byte(strings.Replace(vdfContent, "\"0\"", "\"2\"", 1)), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	if err := pluginsInstance.Uninstall("plugin"); err != nil {
+		t.Fatal("Uninstall", err)
+	}
+
+	expectFileContent(t, gameinfoPath, withMetamodLine)
+	expectFileContent(t, copyPath, "user")
+	expectFileContent(t, vdfPath, strings.Replace(vdfContent, "\"0\"", "\"2\"", 1))
+	// the command was never executed, so the revert command is not required
+	expectPendingCommands(t, pluginsInstance)
+}
+
+func TestActions_LegacyInstalledPlugin(t *testing.T) {
+	metamod := testPlugin("metamod_source", testVersion("1.0.0"))
+	metamod.Actions = []plugins.Action{metamodAction}
+
+	// installed before actions were tracked
+	installedPluginJson := `[{"name": "metamod_source", "version": "1.0.0", "installed_at_utc": "2024-10-01T10:00:00Z", "files": [], "dependencies": [], "explicit": true}]`
+	pluginsInstance, tempDirPath := createResolverTestInstance(t, []plugins.Plugin{metamod}, installedPluginJson)
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	gameinfoPath := filepath.Join(tempDirPath, "game", "csgo", "gameinfo.gi")
+	if err := createGameinfoFile(gameinfoPath); err != nil {
+		t.Fatal("createGameinfoFile", err)
+	}
+	gameinfoContent, err := os.ReadFile(gameinfoPath)
+	if err != nil {
+		t.Fatal("os.ReadFile", err)
+	}
+
+	withMetamodLine := strings.Replace(string(gameinfoContent), "override\n", "override\n\t\t\tGame csgo/addons/metamod\n", 1)
+	if err := os.WriteFile(gameinfoPath, []byte(withMetamodLine), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	if err := pluginsInstance.Uninstall("metamod_source"); err != nil {
+		t.Fatal("Uninstall", err)
+	}
+
+	expectFileContent(t, gameinfoPath, string(gameinfoContent))
+}
+
+func TestNew_InvalidAction(t *testing.T) {
+	testData := []struct {
+		name   string
+		action plugins.Action
+	}{
+		{name: "unknown type", action: plugins.Action{Type: "run_script", Command: "rm -rf /"}},
+		{name: "missing anchor", action: plugins.Action{Type: plugins.ActionTypeInsertLine, File: "gameinfo.gi", Line: "line"}},
+		{name: "missing key", action: plugins.Action{Type: plugins.ActionTypeSetKeyValue, File: "gameinfo.gi", Value: "value"}},
+		{name: "missing command", action: plugins.Action{Type: plugins.ActionTypeServerCommand, RevertCommand: "command"}},
+		{name: "file outside of csgo dir", action: plugins.Action{Type: plugins.ActionTypeCopyFile, File: "../server.cfg", Source: "cfg/server.cfg"}},
+		{name: "source outside of csgo dir", action: plugins.Action{Type: plugins.ActionTypeCopyFile, File: "cfg/server.cfg", Source: "/../../etc/passwd"}},
+	}
+
+	for _, td := range testData {
+		tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+		if err := os.MkdirAll(tempDirPath, os.ModePerm); err != nil {
+			t.Fatal("os.MkdirAll", err)
+		}
+
+		plugin := testPlugin("plugin", testVersion("1.0.0"))
+		plugin.Actions = []plugins.Action{td.action}
+		content, err := json.Marshal([]plugins.Plugin{plugin})
+		if err != nil {
+			t.Fatal("json.Marshal", err)
+		}
+
+		pluginsJsonPath := filepath.Join(tempDirPath, "plugins.json")
+		if err := os.WriteFile(pluginsJsonPath, content, os.ModePerm); err != nil {
+			t.Fatal("os.WriteFile", err)
+		}
+
+		_, err = plugins.New(plugins.Config{
+			CsgoDir:                 filepath.Join(tempDirPath, "game", "csgo"),
+			PluginsJsonFilePath:     pluginsJsonPath,
+			InstalledPluginJsonPath: filepath.Join(tempDirPath, "installed-plugin.json"),
+		})
+		if err == nil {
+			t.Fatalf("%v: expected error", td.name)
+		}
+
+		if !t.Failed() {
+			_ = os.RemoveAll(tempDirPath)
+		}
+	}
+}
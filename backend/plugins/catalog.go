@@ -0,0 +1,433 @@
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+)
+
+const (
+	catalogFetchTimeout = 30 * time.Second
+	// maximum size of a catalog download
+	catalogMaxSize = 10 << 20
+)
+
+type CatalogSourceState string
+
+const (
+	// CatalogSourceStateFetched the catalog was downloaded and cached
+	CatalogSourceStateFetched CatalogSourceState = "fetched"
+	// CatalogSourceStateNotModified the server reported that the cached catalog is still up to date
+	CatalogSourceStateNotModified CatalogSourceState = "not_modified"
+	// CatalogSourceStateCached the cached catalog is used without asking the server, or because the refresh failed
+	CatalogSourceStateCached CatalogSourceState = "cached"
+	// CatalogSourceStateFile the catalog was read from a local file
+	CatalogSourceStateFile CatalogSourceState = "file"
+	// CatalogSourceStateFailed the catalog is not available and not part of the plugins list
+	CatalogSourceStateFailed CatalogSourceState = "failed"
+)
+
+type CatalogSourceStatus struct {
+	Source string             `json:"source"`
+	State  CatalogSourceState `json:"state"`
+	// number of plugins in the catalog of the source
+	Plugins int `json:"plugins"`
+	// time of the last successful request to the source. Nil for local files and sources that were never fetched
+	FetchedAtUtc *time.Time `json:"fetched_at_utc"`
+	Error        string     `json:"error,omitempty"`
+}
+
+type CatalogStatus struct {
+	Sources []CatalogSourceStatus `json:"sources"`
+	// number of plugins after all catalogs and the override file are merged
+	Plugins int `json:"plugins"`
+}
+
+// catalogCacheMetadata is stored next to the cached catalog and used for conditional requests
+type catalogCacheMetadata struct {
+	Source       string    `json:"source"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"last_modified"`
+	FetchedAtUtc time.Time `json:"fetched_at_utc"`
+}
+
+// catalog merges the plugins of all sources in order. Plugins of later sources extend or replace the plugins of earlier sources.
+// The override file is merged last. Without sources the default plugins are used
+type catalog struct {
+	sources          []string
+	cacheDir         string
+	overrideFilePath string
+	client           *http.Client
+}
+
+func newCatalog(sources []string, cacheDir string, overrideFilePath string) (catalog, error) {
+	for _, source := range sources {
+		if isRemoteCatalogSource(source) {
+			if err := gvalidator.Instance().Var(source, "http_url,lt=512"); err != nil {
+				return catalog{}, fmt.Errorf("catalog source '%v' is not a valid url %w", source, err)
+			}
+			continue
+		}
+
+		if err := gvalidator.Instance().Var(localCatalogSourcePath(source), "required,filepath"); err != nil {
+			return catalog{}, fmt.Errorf("catalog source '%v' is not a valid file path %w", source, err)
+		}
+	}
+
+	if len(sources) > 0 {
+		if err := gvalidator.Instance().Var(cacheDir, "required,dirpath|filepath"); err != nil {
+			return catalog{}, fmt.Errorf("catalog cache dir '%v' is not valid %w", cacheDir, err)
+		}
+	}
+
+	return catalog{
+		sources:          sources,
+		cacheDir:         cacheDir,
+		overrideFilePath: overrideFilePath,
+		client:           &http.Client{Timeout: catalogFetchTimeout},
+	}, nil
+}
+
+func isRemoteCatalogSource(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+func localCatalogSourcePath(source string) string {
+	return strings.TrimPrefix(source, "file://")
+}
+
+// load builds the plugins list from the local files and the cached catalogs without any request
+func (c catalog) load() ([]Plugin, CatalogStatus, error) {
+	return c.build(func(source string) ([]Plugin, CatalogSourceStatus) {
+		return c.loadCached(source)
+	})
+}
+
+// refresh builds the plugins list and fetches all remote catalogs.
+// If a request fails, the cached catalog of the source is used
+func (c catalog) refresh(ctx context.Context) ([]Plugin, CatalogStatus, error) {
+	return c.build(func(source string) ([]Plugin, CatalogSourceStatus) {
+		return c.fetch(ctx, source)
+	})
+}
+
+func (c catalog) build(getRemote func(source string) ([]Plugin, CatalogSourceStatus)) ([]Plugin, CatalogStatus, error) {
+	status := CatalogStatus{Sources: make([]CatalogSourceStatus, 0, len(c.sources))}
+
+	var plugins []Plugin
+	if len(c.sources) == 0 {
+		plugins = getDefaultPlugins()
+	}
+
+	for _, source := range c.sources {
+		var sourcePlugins []Plugin
+		var sourceStatus CatalogSourceStatus
+		if isRemoteCatalogSource(source) {
+			sourcePlugins, sourceStatus = getRemote(source)
+		} else {
+			sourcePlugins, sourceStatus = readLocalCatalog(source)
+		}
+
+		if sourceStatus.State == CatalogSourceStateFailed {
+			slog.Warn("plugin catalog source is not available", "source", source, "error", sourceStatus.Error)
+		}
+
+		status.Sources = append(status.Sources, sourceStatus)
+		plugins = mergePlugins(plugins, sourcePlugins)
+	}
+
+	if _, err := os.Stat(c.overrideFilePath); err == nil {
+		content, err := os.ReadFile(c.overrideFilePath)
+		if err != nil {
+			return nil, CatalogStatus{}, fmt.Errorf("failed to read '%v' | %w", c.overrideFilePath, err)
+		}
+
+		overridePlugins, err := parseCatalog(content)
+		if err != nil {
+			return nil, CatalogStatus{}, fmt.Errorf("'%v' is not valid | %w", c.overrideFilePath, err)
+		}
+
+		plugins = mergePlugins(plugins, overridePlugins)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, CatalogStatus{}, fmt.Errorf("failed to read plugins.json at '%v' %w", c.overrideFilePath, err)
+	}
+
+	plugins, err := normalizePlugins(plugins)
+	if err != nil {
+		return nil, CatalogStatus{}, fmt.Errorf("plugins list is not valid: %w", err)
+	}
+
+	status.Plugins = len(plugins)
+	return plugins, status, nil
+}
+
+// parseCatalog parses and validates the content of a plugins.json file
+func parseCatalog(content []byte) ([]Plugin, error) {
+	var plugins []Plugin
+	if err := json.Unmarshal(content, &plugins); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if plugins == nil {
+		return nil, errors.New("plugins list is nil")
+	}
+
+	if err := gvalidator.Instance().Var(plugins, "dive"); err != nil {
+		return nil, fmt.Errorf("validation: %w", err)
+	}
+
+	if _, err := normalizePlugins(plugins); err != nil {
+		return nil, err
+	}
+
+	return plugins, nil
+}
+
+// mergePlugins adds the plugins of overrides to plugins. If a plugin already exists, its description, url and install dir are replaced.
+// Its actions are only replaced if the override declares actions.
+// Versions with the same name are replaced and new versions are added
+func mergePlugins(plugins []Plugin, overrides []Plugin) []Plugin {
+	result := slices.Clone(plugins)
+	for _, override := range overrides {
+		index := slices.IndexFunc(result, func(p Plugin) bool {
+			return p.Name == override.Name
+		})
+		if index == -1 {
+			result = append(result, override)
+			continue
+		}
+
+		versions := slices.Clone(result[index].Versions)
+		for _, version := range override.Versions {
+			versionIndex := slices.IndexFunc(versions, func(v Version) bool {
+				return v.Name == version.Name
+			})
+			if versionIndex == -1 {
+				versions = append(versions, version)
+			} else {
+				versions[versionIndex] = version
+			}
+		}
+
+		override.Versions = versions
+		// a catalog that only pins versions keeps the actions of the plugin
+		if override.Actions == nil {
+			override.Actions = result[index].Actions
+		}
+		result[index] = override
+	}
+
+	return result
+}
+
+func readLocalCatalog(source string) ([]Plugin, CatalogSourceStatus) {
+	status := CatalogSourceStatus{Source: source, State: CatalogSourceStateFile}
+
+	content, err := os.ReadFile(localCatalogSourcePath(source))
+	if err != nil {
+		return nil, failedCatalogSourceStatus(status, fmt.Errorf("os.ReadFile: %w", err))
+	}
+
+	plugins, err := parseCatalog(content)
+	if err != nil {
+		return nil, failedCatalogSourceStatus(status, err)
+	}
+
+	status.Plugins = len(plugins)
+	return plugins, status
+}
+
+func failedCatalogSourceStatus(status CatalogSourceStatus, err error) CatalogSourceStatus {
+	status.State = CatalogSourceStateFailed
+	status.Error = err.Error()
+	status.Plugins = 0
+	return status
+}
+
+func (c catalog) cachePaths(source string) (string, string) {
+	hash := sha256.Sum256([]byte(source))
+	name := hex.EncodeToString(hash[:8])
+	return filepath.Join(c.cacheDir, name+".json"), filepath.Join(c.cacheDir, name+".meta.json")
+}
+
+func (c catalog) readCache(source string) ([]Plugin, catalogCacheMetadata, error) {
+	cachePath, metadataPath := c.cachePaths(source)
+
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, catalogCacheMetadata{}, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	plugins, err := parseCatalog(content)
+	if err != nil {
+		return nil, catalogCacheMetadata{}, fmt.Errorf("cached catalog is not valid: %w", err)
+	}
+
+	var metadata catalogCacheMetadata
+	metadataContent, err := os.ReadFile(metadataPath)
+	if err == nil {
+		err = json.Unmarshal(metadataContent, &metadata)
+	}
+	// without valid metadata the next refresh downloads the catalog again
+	if err != nil || metadata.Source != source {
+		metadata = catalogCacheMetadata{Source: source}
+	}
+
+	return plugins, metadata, nil
+}
+
+func (c catalog) writeCache(content []byte, metadata catalogCacheMetadata) error {
+	if err := os.MkdirAll(c.cacheDir, os.ModePerm); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	cachePath, _ := c.cachePaths(metadata.Source)
+
+	// the catalog is written to a temporary file first, so a cached catalog is never incomplete
+	if err := os.WriteFile(cachePath+".tmp", content, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	if err := os.Rename(cachePath+".tmp", cachePath); err != nil {
+		_ = os.Remove(cachePath + ".tmp")
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	return c.writeCacheMetadata(metadata)
+}
+
+func (c catalog) loadCached(source string) ([]Plugin, CatalogSourceStatus) {
+	status := CatalogSourceStatus{Source: source, State: CatalogSourceStateCached}
+
+	plugins, metadata, err := c.readCache(source)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = errors.New("catalog was not fetched yet")
+		}
+		return nil, failedCatalogSourceStatus(status, err)
+	}
+
+	if !metadata.FetchedAtUtc.IsZero() {
+		status.FetchedAtUtc = &metadata.FetchedAtUtc
+	}
+	status.Plugins = len(plugins)
+	return plugins, status
+}
+
+// fetch sends a conditional request with the ETag and Last-Modified header of the cached catalog.
+// An invalid catalog is never cached
+func (c catalog) fetch(ctx context.Context, source string) ([]Plugin, CatalogSourceStatus) {
+	cachedPlugins, metadata, cacheErr := c.readCache(source)
+	if cacheErr != nil {
+		metadata = catalogCacheMetadata{Source: source}
+	}
+
+	fallback := func(err error) ([]Plugin, CatalogSourceStatus) {
+		if cacheErr != nil {
+			return nil, failedCatalogSourceStatus(CatalogSourceStatus{Source: source}, err)
+		}
+
+		plugins, status := c.loadCached(source)
+		status.Error = err.Error()
+		return plugins, status
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return fallback(fmt.Errorf("http.NewRequestWithContext: %w", err))
+	}
+
+	if cacheErr == nil {
+		if metadata.ETag != "" {
+			request.Header.Set("If-None-Match", metadata.ETag)
+		}
+		if metadata.LastModified != "" {
+			request.Header.Set("If-Modified-Since", metadata.LastModified)
+		}
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return fallback(fmt.Errorf("request failed: %w", err))
+	}
+	defer response.Body.Close()
+
+	fetchedAt := time.Now().UTC()
+
+	if response.StatusCode == http.StatusNotModified && cacheErr == nil {
+		metadata.FetchedAtUtc = fetchedAt
+		if err := c.writeCacheMetadata(metadata); err != nil {
+			slog.Warn("failed to update plugin catalog cache metadata", "source", source, "error", err)
+		}
+
+		return cachedPlugins, CatalogSourceStatus{
+			Source:       source,
+			State:        CatalogSourceStateNotModified,
+			Plugins:      len(cachedPlugins),
+			FetchedAtUtc: &fetchedAt,
+		}
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fallback(fmt.Errorf("unexpected status code %v", response.StatusCode))
+	}
+
+	content, err := io.ReadAll(io.LimitReader(response.Body, catalogMaxSize+1))
+	if err != nil {
+		return fallback(fmt.Errorf("failed to read response: %w", err))
+	}
+
+	if len(content) > catalogMaxSize {
+		return fallback(fmt.Errorf("catalog is larger than %v bytes", catalogMaxSize))
+	}
+
+	plugins, err := parseCatalog(content)
+	if err != nil {
+		return fallback(fmt.Errorf("catalog is not valid: %w", err))
+	}
+
+	metadata = catalogCacheMetadata{
+		Source:       source,
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+		FetchedAtUtc: fetchedAt,
+	}
+	if err := c.writeCache(content, metadata); err != nil {
+		slog.Warn("failed to cache plugin catalog", "source", source, "error", err)
+	}
+
+	return plugins, CatalogSourceStatus{
+		Source:       source,
+		State:        CatalogSourceStateFetched,
+		Plugins:      len(plugins),
+		FetchedAtUtc: &fetchedAt,
+	}
+}
+
+func (c catalog) writeCacheMetadata(metadata catalogCacheMetadata) error {
+	_, metadataPath := c.cachePaths(metadata.Source)
+
+	content, err := json.MarshalIndent(metadata, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
+	if err := os.WriteFile(metadataPath, content, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,326 @@
+package plugins_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/plugins"
+
+	"github.com/google/uuid"
+)
+
+type testCatalogServer struct {
+	lock     sync.Mutex
+	content  []byte
+	modTime  time.Time
+	etag     bool
+	status   int
+	requests []*http.Request
+}
+
+func (s *testCatalogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests = append(s.requests, r)
+
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+
+	if s.etag {
+		w.Header().Set("ETag", fmt.Sprintf("\"%x\"", len(s.content)))
+	}
+
+	// handles If-None-Match and If-Modified-Since
+	http.ServeContent(w, r, "plugins.json", s.modTime, bytes.NewReader(s.content))
+}
+
+func (s *testCatalogServer) set(t *testing.T, catalog []plugins.Plugin, status int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	content, err := json.Marshal(catalog)
+	if err != nil {
+		t.Fatal("json.Marshal", err)
+	}
+
+	s.content = content
+	s.modTime = s.modTime.Add(time.Hour)
+	s.status = status
+}
+
+func (s *testCatalogServer) lastRequest() *http.Request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+func (s *testCatalogServer) requestCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.requests)
+}
+
+func newCatalogTestInstance(t *testing.T, tempDirPath string, sources ...string) *plugins.Instance {
+	csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+	if err := os.MkdirAll(csgoDir, os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+
+	pluginsInstance, err := plugins.New(plugins.Config{
+		CsgoDir:                 csgoDir,
+		PluginsJsonFilePath:     filepath.Join(tempDirPath, "plugins.json"),
+		InstalledPluginJsonPath: filepath.Join(tempDirPath, "installed-plugin.json"),
+		CatalogSources:          sources,
+		CatalogCacheDir:         filepath.Join(tempDirPath, "plugin-catalog"),
+	})
+	if err != nil {
+		t.Fatal("plugins.New", err)
+	}
+
+	return pluginsInstance
+}
+
+func catalogVersions(pluginsInstance *plugins.Instance) map[string][]string {
+	result := make(map[string][]string)
+	for _, plugin := range pluginsInstance.GetAllAvailablePlugins() {
+		for _, version := range plugin.Versions {
+			result[plugin.Name] = append(result[plugin.Name], version.Name)
+		}
+	}
+	return result
+}
+
+func expectSourceState(t *testing.T, status plugins.CatalogStatus, state plugins.CatalogSourceState, withError bool) {
+	if len(status.Sources) != 1 {
+		t.Fatalf("expected one source but got %+v", status.Sources)
+	}
+
+	source := status.Sources[0]
+	if source.State != state || (source.Error != "") != withError {
+		t.Fatalf("expected state '%v' with error %v but got %+v", state, withError, source)
+	}
+}
+
+func TestRefreshCatalog_RemoteSource(t *testing.T) {
+	for _, etag := range []bool{true, false} {
+		tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+
+		catalogServer := &testCatalogServer{etag: etag, modTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		catalogServer.set(t, []plugins.Plugin{testPlugin("remote", testVersion("1.0.0"))}, 0)
+		server := httptest.NewServer(catalogServer)
+		source := server.URL + "/plugins.json"
+
+		// nothing is fetched on startup
+		pluginsInstance := newCatalogTestInstance(t, tempDirPath, source)
+		expectSourceState(t, pluginsInstance.GetCatalogStatus(), plugins.CatalogSourceStateFailed, true)
+		if len(pluginsInstance.GetAllAvailablePlugins()) != 0 || catalogServer.requestCount() != 0 {
+			t.Fatal("expected an empty plugins list without request")
+		}
+
+		status, err := pluginsInstance.RefreshCatalog(context.Background())
+		if err != nil {
+			t.Fatal("RefreshCatalog", err)
+		}
+		expectSourceState(t, status, plugins.CatalogSourceStateFetched, false)
+		if !slices.Equal(catalogVersions(pluginsInstance)["remote"], []string{"1.0.0"}) {
+			t.Fatalf("remote plugin not in plugins list %v", catalogVersions(pluginsInstance))
+		}
+
+		// conditional request
+		status, err = pluginsInstance.RefreshCatalog(context.Background())
+		if err != nil {
+			t.Fatal("RefreshCatalog", err)
+		}
+		expectSourceState(t, status, plugins.CatalogSourceStateNotModified, false)
+
+		request := catalogServer.lastRequest()
+		if etag && request.Header.Get("If-None-Match") == "" {
+			t.Fatal("If-None-Match header not set")
+		}
+		if request.Header.Get("If-Modified-Since") == "" {
+			t.Fatal("If-Modified-Since header not set")
+		}
+
+		// the cache is loaded on startup
+		pluginsInstance = newCatalogTestInstance(t, tempDirPath, source)
+		expectSourceState(t, pluginsInstance.GetCatalogStatus(), plugins.CatalogSourceStateCached, false)
+		if !slices.Equal(catalogVersions(pluginsInstance)["remote"], []string{"1.0.0"}) {
+			t.Fatalf("cached plugin not in plugins list %v", catalogVersions(pluginsInstance))
+		}
+
+		// the cache is used if the source is not available or not valid
+		catalogServer.set(t, nil, http.StatusInternalServerError)
+		status, err = pluginsInstance.RefreshCatalog(context.Background())
+		if err != nil {
+			t.Fatal("RefreshCatalog", err)
+		}
+		expectSourceState(t, status, plugins.CatalogSourceStateCached, true)
+
+		catalogServer.set(t, []plugins.Plugin{testPlugin("remote", plugins.Version{Name: "1.0.1"})}, 0)
+		status, err = pluginsInstance.RefreshCatalog(context.Background())
+		if err != nil {
+			t.Fatal("RefreshCatalog", err)
+		}
+		expectSourceState(t, status, plugins.CatalogSourceStateCached, true)
+		if !slices.Equal(catalogVersions(pluginsInstance)["remote"], []string{"1.0.0"}) {
+			t.Fatalf("invalid catalog was used %v", catalogVersions(pluginsInstance))
+		}
+
+		// modified catalog
+		catalogServer.set(t, []plugins.Plugin{testPlugin("remote", testVersion("1.0.0"), testVersion("1.1.0"))}, 0)
+		status, err = pluginsInstance.RefreshCatalog(context.Background())
+		if err != nil {
+			t.Fatal("RefreshCatalog", err)
+		}
+		expectSourceState(t, status, plugins.CatalogSourceStateFetched, false)
+		if !slices.Equal(catalogVersions(pluginsInstance)["remote"], []string{"1.0.0", "1.1.0"}) {
+			t.Fatalf("modified catalog was not used %v", catalogVersions(pluginsInstance))
+		}
+
+		server.Close()
+		if !t.Failed() {
+			_ = os.RemoveAll(tempDirPath)
+		}
+	}
+}
+
+func TestRefreshCatalog_Override(t *testing.T) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	if err := os.MkdirAll(tempDirPath, os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+
+	writeCatalog := func(name string, catalog []plugins.Plugin) string {
+		content, err := json.Marshal(catalog)
+		if err != nil {
+			t.Fatal("json.Marshal", err)
+		}
+
+		path := filepath.Join(tempDirPath, name)
+		if err := os.WriteFile(path, content, os.ModePerm); err != nil {
+			t.Fatal("os.WriteFile", err)
+		}
+		return path
+	}
+
+	pluginWithActions := testPlugin("a", testVersion("1.0.0"))
+	pluginWithActions.Actions = []plugins.Action{metamodAction}
+	firstSource := writeCatalog("first.json", []plugins.Plugin{
+		pluginWithActions,
+		testPlugin("b", testVersion("1.0.0")),
+	})
+	secondSource := writeCatalog("second.json", []plugins.Plugin{
+		testPlugin("b", testVersion("2.0.0")),
+	})
+	writeCatalog("plugins.json", []plugins.Plugin{
+		testPlugin("a", testVersion("1.0.0", plugins.Requirement{Name: "b", Version: "^2.0.0"}), testVersion("1.1.0")),
+		testPlugin("c", testVersion("1.0.0")),
+	})
+
+	pluginsInstance := newCatalogTestInstance(t, tempDirPath, firstSource, "file://"+secondSource)
+
+	expected := map[string][]string{
+		"a": {"1.0.0", "1.1.0"},
+		"b": {"1.0.0", "2.0.0"},
+		"c": {"1.0.0"},
+	}
+	actual := catalogVersions(pluginsInstance)
+	for name, versions := range expected {
+		if !slices.Equal(actual[name], versions) {
+			t.Fatalf("expected %v but got %v", expected, actual)
+		}
+	}
+
+	// the override file does not declare actions for "a"
+	for _, plugin := range pluginsInstance.GetAllAvailablePlugins() {
+		if plugin.Name == "a" && len(plugin.Actions) != 1 {
+			t.Fatalf("actions of the catalog not kept %+v", plugin.Actions)
+		}
+	}
+
+	plan, err := pluginsInstance.Plan("a", "1.0.0")
+	if err != nil {
+		t.Fatal("Plan", err)
+	}
+	if plan.Steps[0].Name != "b" || plan.Steps[0].Version != "2.0.0" {
+		t.Fatalf("requirement of the override file not used %+v", plan.Steps)
+	}
+
+	// local files are read again on refresh
+	writeCatalog("plugins.json", []plugins.Plugin{testPlugin("d", testVersion("1.0.0"))})
+	status, err := pluginsInstance.RefreshCatalog(context.Background())
+	if err != nil {
+		t.Fatal("RefreshCatalog", err)
+	}
+
+	actual = catalogVersions(pluginsInstance)
+	if status.Plugins != 3 || len(actual["c"]) != 0 || len(actual["d"]) != 1 {
+		t.Fatalf("override file not read again %v", actual)
+	}
+
+	// an invalid override file keeps the current plugins list
+	if err := os.WriteFile(filepath.Join(tempDirPath, "plugins.json"), []byte("[{}]"), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+	if _, err := pluginsInstance.RefreshCatalog(context.Background()); err == nil {
+		t.Fatal("expected error for invalid override file")
+	}
+	if len(catalogVersions(pluginsInstance)["d"]) != 1 {
+		t.Fatal("plugins list changed after failed refresh")
+	}
+}
+
+func TestRefreshCatalog_InstallDirOutsideOfCsgoDir(t *testing.T) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	pluginsInstance := newCatalogTestInstance(t, tempDirPath)
+
+	pluginWithDependency := testPlugin("b", testVersion("1.0.0"))
+	pluginWithDependency.Versions[0].Dependencies = []plugins.PluginDependency{{
+		Name:        "dependency",
+		InstallDir:  "/addons/../../../",
+		Version:     "1.0.0",
+		DownloadURL: "http://test.test/dependency.zip",
+	}}
+
+	pluginOutside := testPlugin("a", testVersion("1.0.0"))
+	pluginOutside.InstallDir = "../../x"
+
+	for _, catalog := range [][]plugins.Plugin{{pluginOutside}, {pluginWithDependency}} {
+		content, err := json.Marshal(catalog)
+		if err != nil {
+			t.Fatal("json.Marshal", err)
+		}
+		if err := os.WriteFile(filepath.Join(tempDirPath, "plugins.json"), content, os.ModePerm); err != nil {
+			t.Fatal("os.WriteFile", err)
+		}
+
+		if _, err := pluginsInstance.RefreshCatalog(context.Background()); err == nil {
+			t.Fatalf("expected error for install dir outside of the csgo dir %+v", catalog)
+		}
+		actual := catalogVersions(pluginsInstance)
+		if len(actual["a"]) != 0 || len(actual["b"]) != 0 || len(actual["dependency"]) != 0 {
+			t.Fatalf("plugins list changed after failed refresh %v", actual)
+		}
+	}
+}
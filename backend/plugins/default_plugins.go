@@ -1,7 +1,34 @@
 package plugins
 
 func getDefaultPlugins() []Plugin {
-	return []Plugin{getCounterStrikeSharp(), getCs2PracticeMode()}
+	return []Plugin{getMetamodSource(), getCounterStrikeSharp(), getCs2PracticeMode()}
+}
+
+func getMetamodSource() Plugin {
+	return Plugin{
+		Name:        "metamod_source",
+		Description: "Metamod:Source is a C++ plugin environment for Source engine games",
+		URL:         "https://www.sourcemm.net",
+		InstallDir:  "/",
+		Actions: []Action{
+			{
+				Type:  ActionTypeAddKeyValue,
+				File:  "gameinfo.gi",
+				Key:   []string{"GameInfo", "FileSystem", "SearchPaths", "Game"},
+				Value: "csgo/addons/metamod",
+			},
+		},
+		Versions: []Version{
+			{
+				Name:        "2.0.0-git1313",
+				DownloadURL: "https://mms.alliedmods.net/mmsdrop/2.0/mmsource-2.0.0-git1313-linux.tar.gz",
+			},
+			{
+				Name:        "2.0.0-git1314",
+				DownloadURL: "https://mms.alliedmods.net/mmsdrop/2.0/mmsource-2.0.0-git1314-linux.tar.gz",
+			},
+		},
+	}
 }
 
 func getCounterStrikeSharp() Plugin {
@@ -14,27 +41,15 @@ func getCounterStrikeSharp() Plugin {
 			{
 				Name:        "v264",
 				DownloadURL: "https://github.com/roflmuffin/CounterStrikeSharp/releases/download/v264/counterstrikesharp-with-runtime-build-264-linux-8f59fd5.zip",
-				Dependencies: []PluginDependency{
-					{
-						Name:         "metamod_source",
-						InstallDir:   "/",
-						Version:      "2.0.0-git1313",
-						DownloadURL:  "https://mms.alliedmods.net/mmsdrop/2.0/mmsource-2.0.0-git1313-linux.tar.gz",
-						Dependencies: nil,
-					},
+				Requires: []Requirement{
+					{Name: "metamod_source", Version: "2.0.0-git1313"},
 				},
 			},
 			{
 				Name:        "v272",
 				DownloadURL: "https://github.com/roflmuffin/CounterStrikeSharp/releases/download/v272/counterstrikesharp-with-runtime-build-272-linux-e36d2e0.zip",
-				Dependencies: []PluginDependency{
-					{
-						Name:         "metamod_source",
-						InstallDir:   "/",
-						Version:      "2.0.0-git1314",
-						DownloadURL:  "https://mms.alliedmods.net/mmsdrop/2.0/mmsource-2.0.0-git1314-linux.tar.gz",
-						Dependencies: nil,
-					},
+				Requires: []Requirement{
+					{Name: "metamod_source", Version: "2.0.0-git1314"},
 				},
 			},
 		},
@@ -51,43 +66,15 @@ func getCs2PracticeMode() Plugin {
 			{
 				Name:        "0.0.16",
 				DownloadURL: "https://github.com/Phi-S/cs2-practice-mode/releases/download/0.0.16/cs2-practice-mode-0.0.16.tar.gz",
-				Dependencies: []PluginDependency{
-					{
-						Name:        "CounterStrikeSharp",
-						InstallDir:  "/",
-						Version:     "v264",
-						DownloadURL: "https://github.com/roflmuffin/CounterStrikeSharp/releases/download/v264/counterstrikesharp-with-runtime-build-264-linux-8f59fd5.zip",
-						Dependencies: []PluginDependency{
-							{
-								Name:         "metamod_source",
-								InstallDir:   "/",
-								Version:      "2.0.0-git1313",
-								DownloadURL:  "https://mms.alliedmods.net/mmsdrop/2.0/mmsource-2.0.0-git1313-linux.tar.gz",
-								Dependencies: nil,
-							},
-						},
-					},
+				Requires: []Requirement{
+					{Name: "CounterStrikeSharp", Version: "v264"},
 				},
 			},
 			{
 				Name:        "0.0.17",
 				DownloadURL: "https://github.com/Phi-S/cs2-practice-mode/releases/download/0.0.17/cs2-practice-mode-0.0.17.tar.gz",
-				Dependencies: []PluginDependency{
-					{
-						Name:        "CounterStrikeSharp",
-						Version:     "v272",
-						InstallDir:  "/",
-						DownloadURL: "https://github.com/roflmuffin/CounterStrikeSharp/releases/download/v272/counterstrikesharp-with-runtime-build-272-linux-e36d2e0.zip",
-						Dependencies: []PluginDependency{
-							{
-								Name:         "metamod_source",
-								InstallDir:   "/",
-								Version:      "2.0.0-git1314",
-								DownloadURL:  "https://mms.alliedmods.net/mmsdrop/2.0/mmsource-2.0.0-git1314-linux.tar.gz",
-								Dependencies: nil,
-							},
-						},
-					},
+				Requires: []Requirement{
+					{Name: "CounterStrikeSharp", Version: "v272"},
 				},
 			},
 		},
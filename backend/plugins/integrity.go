@@ -0,0 +1,218 @@
+package plugins
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+var ErrIntegrity = errors.New("integrity check failed")
+
+const (
+	// minisign signature algorithm of the file content
+	minisignAlgorithmLegacy = "Ed"
+	// minisign signature algorithm of the blake2b-512 hash of the file content
+	minisignAlgorithmPrehashed = "ED"
+
+	minisignKeyIdLength     = 8
+	minisignPublicKeyLength = 2 + minisignKeyIdLength + ed25519.PublicKeySize
+	minisignSignatureLength = 2 + minisignKeyIdLength + ed25519.SignatureSize
+
+	untrustedCommentPrefix = "untrusted comment:"
+	trustedCommentPrefix   = "trusted comment: "
+)
+
+// signaturePublicKey is a raw ed25519 public key or a minisign public key
+type signaturePublicKey struct {
+	// only set for minisign public keys
+	keyId []byte
+	key   ed25519.PublicKey
+}
+
+// parseSignaturePublicKey accepts the base64 encoded minisign public key, the content of a minisign .pub file
+// or a base64 encoded raw ed25519 public key. Returns nil if the key is empty
+func parseSignaturePublicKey(s string) (*signaturePublicKey, error) {
+	lines := nonEmptyLines(s)
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(lines[len(lines)-1])
+	if err != nil {
+		return nil, fmt.Errorf("public key is not base64 encoded: %w", err)
+	}
+
+	switch {
+	case len(decoded) == ed25519.PublicKeySize:
+		return &signaturePublicKey{key: decoded}, nil
+	case len(decoded) == minisignPublicKeyLength && string(decoded[:2]) == minisignAlgorithmLegacy:
+		return &signaturePublicKey{
+			keyId: decoded[2 : 2+minisignKeyIdLength],
+			key:   decoded[2+minisignKeyIdLength:],
+		}, nil
+	default:
+		return nil, fmt.Errorf("public key with %v bytes is neither an ed25519 nor a minisign public key", len(decoded))
+	}
+}
+
+// ValidateSignaturePublicKey returns an error if the key is not empty and not accepted as signature public key
+func ValidateSignaturePublicKey(key string) error {
+	_, err := parseSignaturePublicKey(key)
+	return err
+}
+
+func nonEmptyLines(s string) []string {
+	result := make([]string, 0)
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// verifyDownload checks the sha256 hash and the signature of the downloaded file and returns its sha256 hash.
+// It fails closed: if a public key is configured, every download has to be signed, and a signed download can not be installed without public key
+func verifyDownload(path string, expectedSha256 string, signature string, publicKey *signaturePublicKey) (string, error) {
+	hash, err := fileSha256(path)
+	if err != nil {
+		return "", err
+	}
+
+	if expectedSha256 != "" && !strings.EqualFold(hash, expectedSha256) {
+		return "", fmt.Errorf("%w: expected sha256 '%v' but the download has '%v'", ErrIntegrity, expectedSha256, hash)
+	}
+
+	if publicKey == nil {
+		if signature != "" {
+			return "", fmt.Errorf("%w: the download is signed but no public key is configured", ErrIntegrity)
+		}
+		return hash, nil
+	}
+
+	if signature == "" {
+		return "", fmt.Errorf("%w: a public key is configured but the download is not signed", ErrIntegrity)
+	}
+
+	if err := verifySignature(path, signature, publicKey); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrIntegrity, err)
+	}
+
+	return hash, nil
+}
+
+func fileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("os.Open: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("hash file: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifySignature accepts the content of a minisign .minisig file or a base64 encoded raw ed25519 signature of the file content
+func verifySignature(path string, signature string, publicKey *signaturePublicKey) error {
+	lines := nonEmptyLines(signature)
+	if len(lines) == 1 {
+		decoded, err := base64.StdEncoding.DecodeString(lines[0])
+		if err != nil {
+			return fmt.Errorf("signature is not base64 encoded: %w", err)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("os.ReadFile: %w", err)
+		}
+
+		if !ed25519.Verify(publicKey.key, content, decoded) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	return verifyMinisign(path, lines, publicKey)
+}
+
+func verifyMinisign(path string, lines []string, publicKey *signaturePublicKey) error {
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], untrustedCommentPrefix) || !strings.HasPrefix(lines[2], trustedCommentPrefix) {
+		return errors.New("signature is not a valid minisign signature")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(decoded) != minisignSignatureLength {
+		return errors.New("minisign signature is not valid")
+	}
+
+	algorithm := string(decoded[:2])
+	keyId := decoded[2 : 2+minisignKeyIdLength]
+	fileSignature := decoded[2+minisignKeyIdLength:]
+
+	if publicKey.keyId != nil && !bytes.Equal(keyId, publicKey.keyId) {
+		return fmt.Errorf("signed with key '%X' but the public key is '%X'", keyId, publicKey.keyId)
+	}
+
+	var message []byte
+	switch algorithm {
+	case minisignAlgorithmLegacy:
+		if message, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("os.ReadFile: %w", err)
+		}
+	case minisignAlgorithmPrehashed:
+		if message, err = fileBlake2b512(path); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm '%v'", algorithm)
+	}
+
+	if !ed25519.Verify(publicKey.key, message, fileSignature) {
+		return errors.New("invalid signature")
+	}
+
+	// the global signature covers the file signature and the trusted comment
+	globalSignature, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil {
+		return errors.New("minisign global signature is not base64 encoded")
+	}
+
+	trustedComment := strings.TrimPrefix(lines[2], trustedCommentPrefix)
+	if !ed25519.Verify(publicKey.key, append(bytes.Clone(fileSignature), trustedComment...), globalSignature) {
+		return errors.New("invalid global signature of the trusted comment")
+	}
+
+	return nil
+}
+
+func fileBlake2b512(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer f.Close()
+
+	hash, err := blake2b.New512(nil)
+	if err != nil {
+		return nil, fmt.Errorf("blake2b.New512: %w", err)
+	}
+
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, fmt.Errorf("hash file: %w", err)
+	}
+
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,214 @@
+package plugins_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/plugins"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/blake2b"
+)
+
+type testSigningKey struct {
+	keyId      []byte
+	publicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+}
+
+func newTestSigningKey(t *testing.T) testSigningKey {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("ed25519.GenerateKey", err)
+	}
+
+	keyId := make([]byte, 8)
+	if _, err := rand.Read(keyId); err != nil {
+		t.Fatal("rand.Read", err)
+	}
+
+	return testSigningKey{keyId: keyId, publicKey: publicKey, privateKey: privateKey}
+}
+
+func (k testSigningKey) minisignPublicKey() string {
+	return base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), k.keyId...), k.publicKey...))
+}
+
+func (k testSigningKey) rawPublicKey() string {
+	return base64.StdEncoding.EncodeToString(k.publicKey)
+}
+
+// minisign creates the content of a .minisig file. The prehashed algorithm "ED" signs the blake2b-512 hash of the content
+func (k testSigningKey) minisign(content []byte, prehashed bool, trustedComment string) string {
+	algorithm := "Ed"
+	message := content
+	if prehashed {
+		algorithm = "ED"
+		hash := blake2b.Sum512(content)
+		message = hash[:]
+	}
+
+	signature := ed25519.Sign(k.privateKey, message)
+	globalSignature := ed25519.Sign(k.privateKey, append(append([]byte{}, signature...), trustedComment...))
+
+	return fmt.Sprintf("untrusted comment: signature from minisign secret key\n%v\ntrusted comment: %v\n%v\n",
+		base64.StdEncoding.EncodeToString(append(append([]byte(algorithm), k.keyId...), signature...)),
+		trustedComment,
+		base64.StdEncoding.EncodeToString(globalSignature),
+	)
+}
+
+func installVerifiedTestPlugin(t *testing.T, archive []byte, publicKey string, version plugins.Version) (*plugins.Instance, string, error) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+	if err := os.MkdirAll(csgoDir, os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	version.Name = "1.0.0"
+	version.DownloadURL = server.URL + "/plugin.zip"
+	catalog := []plugins.Plugin{testPlugin("plugin", version)}
+	catalogContent, err := json.Marshal(catalog)
+	if err != nil {
+		t.Fatal("json.Marshal", err)
+	}
+
+	pluginsJsonPath := filepath.Join(tempDirPath, "plugins.json")
+	if err := os.WriteFile(pluginsJsonPath, catalogContent, os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	pluginsInstance, err := plugins.New(plugins.Config{
+		CsgoDir:                 csgoDir,
+		PluginsJsonFilePath:     pluginsJsonPath,
+		InstalledPluginJsonPath: filepath.Join(tempDirPath, "installed-plugin.json"),
+		SignaturePublicKey:      publicKey,
+	})
+	if err != nil {
+		t.Fatal("plugins.New", err)
+	}
+
+	return pluginsInstance, tempDirPath, pluginsInstance.InstallPluginByName(context.Background(), "plugin", "1.0.0")
+}
+
+func TestInstall_Verification(t *testing.T) {
+	archive := createZip(t, map[string]string{"addons/plugin/plugin.dll": "plugin"})
+	hash := sha256.Sum256(archive)
+	archiveSha256 := hex.EncodeToString(hash[:])
+
+	key := newTestSigningKey(t)
+	otherKey := newTestSigningKey(t)
+	trustedComment := "timestamp:1700000000\tfile:plugin.zip\thashed"
+	tamperedSignature := key.minisign(archive, true, trustedComment)
+	tamperedSignature = tamperedSignature[:len(tamperedSignature)-len(trustedComment)] + "x"
+
+	testData := []struct {
+		name      string
+		publicKey string
+		version   plugins.Version
+		valid     bool
+	}{
+		{name: "no verification", valid: true},
+		{name: "sha256", version: plugins.Version{Sha256: archiveSha256}, valid: true},
+		{name: "sha256 mismatch", version: plugins.Version{Sha256: hex.EncodeToString(make([]byte, 32))}},
+		{name: "minisign prehashed", publicKey: key.minisignPublicKey(), version: plugins.Version{Signature: key.minisign(archive, true, trustedComment)}, valid: true},
+		{name: "minisign .pub file", publicKey: "untrusted comment: minisign public key 0123456789ABCDEF\n" + key.minisignPublicKey() + "\n", version: plugins.Version{Signature: key.minisign(archive, true, trustedComment)}, valid: true},
+		{name: "minisign legacy", publicKey: key.minisignPublicKey(), version: plugins.Version{Signature: key.minisign(archive, false, trustedComment)}, valid: true},
+		{name: "minisign wrong key", publicKey: key.minisignPublicKey(), version: plugins.Version{Signature: otherKey.minisign(archive, true, trustedComment)}},
+		{name: "minisign tampered trusted comment", publicKey: key.minisignPublicKey(), version: plugins.Version{Signature: tamperedSignature}},
+		{name: "ed25519", publicKey: key.rawPublicKey(), version: plugins.Version{Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key.privateKey, archive))}, valid: true},
+		{name: "ed25519 wrong key", publicKey: otherKey.rawPublicKey(), version: plugins.Version{Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key.privateKey, archive))}},
+		{name: "not signed", publicKey: key.minisignPublicKey(), version: plugins.Version{Sha256: archiveSha256}},
+		{name: "no public key", version: plugins.Version{Signature: key.minisign(archive, true, trustedComment)}},
+	}
+
+	for _, td := range testData {
+		pluginsInstance, tempDirPath, err := installVerifiedTestPlugin(t, archive, td.publicKey, td.version)
+
+		installedPlugins, getErr := pluginsInstance.GetInstalledPlugins()
+		if getErr != nil {
+			t.Fatal(td.name, "GetInstalledPlugins", getErr)
+		}
+
+		_, statErr := os.Stat(filepath.Join(tempDirPath, "game", "csgo", "addons", "plugin", "plugin.dll"))
+
+		if td.valid {
+			if err != nil {
+				t.Fatal(td.name, "InstallPluginByName", err)
+			}
+
+			if len(installedPlugins) != 1 || installedPlugins[0].Sha256 != archiveSha256 {
+				t.Fatalf("%v: sha256 not recorded %+v", td.name, installedPlugins)
+			}
+
+			if statErr != nil {
+				t.Fatal(td.name, "plugin file not installed", statErr)
+			}
+		} else {
+			if !errors.Is(err, plugins.ErrIntegrity) {
+				t.Fatal(td.name, "expected ErrIntegrity but got", err)
+			}
+
+			// fail closed. nothing is extracted or tracked
+			if len(installedPlugins) != 0 {
+				t.Fatalf("%v: unexpected installed plugins %+v", td.name, installedPlugins)
+			}
+
+			if !errors.Is(statErr, os.ErrNotExist) {
+				t.Fatal(td.name, "plugin file extracted", statErr)
+			}
+
+			// the rejected download is removed
+			if _, err := os.Stat(filepath.Join(tempDirPath, "game", "csgo", "temp_plugin_transaction")); !errors.Is(err, os.ErrNotExist) {
+				t.Fatal(td.name, "download not removed", err)
+			}
+		}
+
+		if !t.Failed() {
+			_ = os.RemoveAll(tempDirPath)
+		}
+	}
+}
+
+func TestValidateSignaturePublicKey(t *testing.T) {
+	key := newTestSigningKey(t)
+
+	valid := []string{
+		"",
+		key.rawPublicKey(),
+		key.minisignPublicKey(),
+		"untrusted comment: minisign public key 0123456789ABCDEF\n" + key.minisignPublicKey() + "\n",
+	}
+	for _, publicKey := range valid {
+		if err := plugins.ValidateSignaturePublicKey(publicKey); err != nil {
+			t.Fatalf("public key '%v' not valid: %v", publicKey, err)
+		}
+	}
+
+	invalid := []string{
+		"not base64",
+		base64.StdEncoding.EncodeToString([]byte("too short")),
+	}
+	for _, publicKey := range invalid {
+		if err := plugins.ValidateSignaturePublicKey(publicKey); err == nil {
+			t.Fatalf("public key '%v' is valid", publicKey)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package plugins
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -24,28 +28,78 @@ type Plugin struct {
 	URL         string    `json:"url" validate:"required,url,lt=256"`
 	InstallDir  string    `json:"install_dir" validate:"required,dirpath,lt=256"`
 	Versions    []Version `json:"versions" validate:"required,dive"`
+	// executed after the plugin is installed and reverted before it is uninstalled
+	Actions []Action `json:"actions,omitempty" validate:"omitnil,dive"`
 }
 
 type Version struct {
-	Name         string             `json:"name" validate:"required,lt=32"`
-	DownloadURL  string             `json:"download_url" validate:"required,url,lt=256"`
-	Dependencies []PluginDependency `json:"dependencies" validate:"omitnil,dive"`
+	Name        string `json:"name" validate:"required,lt=32"`
+	DownloadURL string `json:"download_url" validate:"required,url,lt=256"`
+	// hex encoded sha256 hash of the download. The installation fails if the hash does not match
+	Sha256 string `json:"sha256,omitempty" validate:"omitempty,len=64,hexadecimal"`
+	// minisign signature (content of the .minisig file) or base64 encoded ed25519 signature of the download.
+	// Verified with the configured public key
+	Signature string `json:"signature,omitempty" validate:"omitempty,lt=1024"`
+	// other plugins of the plugins list that are installed before this version
+	Requires []Requirement `json:"requires" validate:"omitnil,dive"`
+	// Deprecated: dependencies with their own download url and an exact version.
+	// They are converted to plugins and requirements when the plugins list is loaded. Use Requires instead
+	Dependencies []PluginDependency `json:"dependencies,omitempty" validate:"omitnil,dive"`
+}
+
+// Requirement references another plugin of the plugins list by name
+type Requirement struct {
+	Name string `json:"name" validate:"required,lt=32"`
+	// semver-style version constraint, e.g. "^1.2.0", ">=v264 <v300" or an exact version name. See VersionConstraint
+	Version string `json:"version" validate:"required,lt=64"`
 }
 
 type PluginDependency struct {
 	Name         string             `json:"name" validate:"required,lt=32"`
 	InstallDir   string             `json:"install_dir" validate:"required,dirpath,lt=256"`
-	Version      string             `json:"version" validate:"required,lt32"`
+	Version      string             `json:"version" validate:"required,lt=32"`
 	DownloadURL  string             `json:"download_url" validate:"required,url,lt=256"`
+	Sha256       string             `json:"sha256,omitempty" validate:"omitempty,len=64,hexadecimal"`
+	Signature    string             `json:"signature,omitempty" validate:"omitempty,lt=1024"`
 	Dependencies []PluginDependency `json:"dependencies" validate:"omitnil,dive"`
 }
 
+var (
+	ErrPluginNotFound         = errors.New("plugin not found")
+	ErrPluginNotInstalled     = errors.New("plugin is not installed")
+	ErrPluginAlreadyInstalled = errors.New("plugin is already installed")
+	ErrPluginRequired         = errors.New("plugin is required by other installed plugins")
+	ErrVersionConflict        = errors.New("another version of the plugin is installed")
+)
+
+// InstalledPlugin is one entry of the installed plugins list.
+// Dependencies are referenced by name, so a dependency that is shared by multiple plugins is only installed once
 type InstalledPlugin struct {
-	Name           string            `json:"name" validate:"required,lt=32"`
-	Version        string            `json:"version" validate:"required,lt=32"`
-	InstalledAtUtc time.Time         `json:"installed_at_utc" validate:"required,lt=32"`
-	Files          []string          `json:"files" validate:"required"`
-	Dependencies   []InstalledPlugin `json:"dependencies" validate:"dive"`
+	Name           string    `json:"name" validate:"required,lt=32"`
+	Version        string    `json:"version" validate:"required,lt=32"`
+	InstalledAtUtc time.Time `json:"installed_at_utc" validate:"required,lt=32"`
+	Files          []string  `json:"files" validate:"required"`
+	// sha256 hashes of the files at install time. Used to detect files that were modified by the user
+	FileHashes map[string]string `json:"file_hashes,omitempty"`
+	// sha256 hash of the download the plugin was installed from
+	Sha256 string `json:"sha256,omitempty" validate:"omitempty,len=64,hexadecimal"`
+	// names of the installed plugins this plugin depends on
+	Dependencies []string `json:"dependencies" validate:"dive,required,lt=32"`
+	// actions of the plugins list that were executed after the installation. Nil if the plugin was installed before actions were tracked
+	Actions []AppliedAction `json:"actions" validate:"omitnil,dive"`
+	// false if the plugin was only installed as dependency. It is uninstalled together with the last plugin that requires it
+	Explicit bool `json:"explicit"`
+	// true if the plugin was installed from an uploaded archive instead of the plugins list
+	Uploaded bool `json:"uploaded,omitempty"`
+}
+
+// legacyInstalledPlugin is the format of the installed plugin json file before multiple plugins could be installed
+type legacyInstalledPlugin struct {
+	Name           string                  `json:"name"`
+	Version        string                  `json:"version"`
+	InstalledAtUtc time.Time               `json:"installed_at_utc"`
+	Files          []string                `json:"files"`
+	Dependencies   []legacyInstalledPlugin `json:"dependencies"`
 }
 
 type PluginEventsPayload struct {
@@ -53,6 +107,28 @@ type PluginEventsPayload struct {
 	Version string
 }
 
+type PluginDownloadProgressPayload struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	download.Progress
+}
+
+type Config struct {
+	CsgoDir string
+	// local override file. Its plugins are merged into the plugins of the catalog sources
+	PluginsJsonFilePath     string
+	InstalledPluginJsonPath string
+	// if not empty, every plugin download has to be signed with the matching private key
+	SignaturePublicKey string
+	// http(s) urls or local file paths of plugins lists. If empty, the default plugins are used
+	CatalogSources []string
+	// directory in which the plugins lists of the http(s) sources are cached
+	CatalogCacheDir string
+	// file in which the commands of server_command actions are kept until the next server start.
+	// If empty, plugins with server_command actions can not be installed
+	PendingCommandsJsonPath string
+}
+
 type Instance struct {
 	running                     atomic.Bool
 	lock                        sync.Mutex
@@ -60,8 +136,14 @@ type Instance struct {
 
 	csgoDir                     string
 	installedPluginJsonFilePath string
+	pendingCommandsJsonPath     string
 
-	plugins []Plugin
+	catalog       catalog
+	catalogLock   sync.RWMutex
+	plugins       []Plugin
+	catalogStatus CatalogStatus
+	// nil if no public key is configured
+	signaturePublicKey *signaturePublicKey
 
 	onPluginInstallingEvent         event.InstanceWithData[PluginEventsPayload]
 	onPluginInstalledEvent          event.InstanceWithData[PluginEventsPayload]
@@ -69,9 +151,18 @@ type Instance struct {
 	onPluginUninstallingEvent       event.InstanceWithData[PluginEventsPayload]
 	onPluginUninstalledEvent        event.InstanceWithData[PluginEventsPayload]
 	onPluginUninstallFailedEvent    event.InstanceWithData[PluginEventsPayload]
+	onPluginUpgradingEvent          event.InstanceWithData[PluginUpgradeEventsPayload]
+	onPluginUpgradedEvent           event.InstanceWithData[PluginUpgradeEventsPayload]
+	onPluginUpgradeFailedEvent      event.InstanceWithData[PluginUpgradeEventsPayload]
+	onPluginDownloadProgressEvent   event.InstanceWithData[PluginDownloadProgressPayload]
 }
 
-func New(csgoDir string, pluginsJsonFilePath string, installedPluginJsonPath string) (*Instance, error) {
+// New loads the plugins list from the local files and the cached catalogs. Call RefreshCatalog to fetch the http(s) sources
+func New(config Config) (*Instance, error) {
+	csgoDir := config.CsgoDir
+	pluginsJsonFilePath := config.PluginsJsonFilePath
+	installedPluginJsonPath := config.InstalledPluginJsonPath
+
 	if err := gvalidator.Instance().Var(csgoDir, "required,dirpath"); err != nil {
 		return nil, fmt.Errorf("csgoDir '%v' is not valid %w", csgoDir, err)
 	}
@@ -84,36 +175,34 @@ func New(csgoDir string, pluginsJsonFilePath string, installedPluginJsonPath str
 		return nil, fmt.Errorf("installedPluginJsonPath '%v' is not valid %w", installedPluginJsonPath, err)
 	}
 
-	var plugins []Plugin
-	if _, err := os.Stat(pluginsJsonFilePath); err != nil {
-		if !errors.Is(err, os.ErrNotExist) {
-			return nil, fmt.Errorf("failed to read plugins.json at '%v' %w", pluginsJsonFilePath, err)
-		}
-
-		plugins = getDefaultPlugins()
-	} else {
-		pluginsJsonContent, err := os.ReadFile(pluginsJsonFilePath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read '%v' | %w", pluginsJsonFilePath, err)
-		}
-
-		if err := json.Unmarshal(pluginsJsonContent, &plugins); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal '%v' | %w", pluginsJsonFilePath, err)
-		}
+	pluginsCatalog, err := newCatalog(config.CatalogSources, config.CatalogCacheDir, pluginsJsonFilePath)
+	if err != nil {
+		return nil, err
+	}
 
-		if plugins == nil {
-			return nil, fmt.Errorf("plugins is nil. failed to get plugins from '%v'", pluginsJsonFilePath)
-		}
+	plugins, catalogStatus, err := pluginsCatalog.load()
+	if err != nil {
+		return nil, err
+	}
 
-		if err := gvalidator.Instance().Var(plugins, "dive"); err != nil {
-			return nil, fmt.Errorf("validation failed for '%v' | %w", pluginsJsonFilePath, err)
-		}
+	publicKey, err := parseSignaturePublicKey(config.SignaturePublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("signature public key is not valid: %w", err)
 	}
 
 	instance := &Instance{
 		csgoDir:                     csgoDir,
 		installedPluginJsonFilePath: installedPluginJsonPath,
+		pendingCommandsJsonPath:     config.PendingCommandsJsonPath,
+		catalog:                     pluginsCatalog,
 		plugins:                     plugins,
+		catalogStatus:               catalogStatus,
+		signaturePublicKey:          publicKey,
+	}
+
+	// an install that was interrupted by a crash is reverted before the installed plugins are read
+	if err := instance.recoverTransaction(); err != nil {
+		slog.Error("failed to recover interrupted plugin transaction. It is tried again before the next install", "error", err)
 	}
 
 	if _, err := os.Stat(installedPluginJsonPath); err != nil {
@@ -124,24 +213,168 @@ func New(csgoDir string, pluginsJsonFilePath string, installedPluginJsonPath str
 		} else {
 			return nil, fmt.Errorf("failed to get '%v' fileinfo: %w", installedPluginJsonPath, err)
 		}
+	} else {
+		// rewrites the file in the current format if it was written by an older version
+		installedPlugins, err := instance.readInstalledPluginsJsonFile()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%v': %w", installedPluginJsonPath, err)
+		}
+
+		if err := instance.writeInstalledPluginsJsonFile(installedPlugins); err != nil {
+			return nil, fmt.Errorf("failed to write '%v': %w", installedPluginJsonPath, err)
+		}
 	}
 
 	return instance, nil
 }
 
 func (i *Instance) GetAllAvailablePlugins() []Plugin {
+	i.catalogLock.RLock()
+	defer i.catalogLock.RUnlock()
 	return i.plugins
 }
 
-func (i *Instance) GetInstalledPlugin() (*InstalledPlugin, error) {
-	installedPlugin, err := i.readInstalledPluginsJsonFile()
+// GetCatalogStatus returns the state of the catalog sources of the last load or refresh
+func (i *Instance) GetCatalogStatus() CatalogStatus {
+	i.catalogLock.RLock()
+	defer i.catalogLock.RUnlock()
+	return i.catalogStatus
+}
+
+// RefreshCatalog fetches the http(s) catalog sources and reads the local files again.
+// Sources that are not available are reported in the status. If the merged plugins list is not valid, the current list is kept
+func (i *Instance) RefreshCatalog(ctx context.Context) (CatalogStatus, error) {
+	plugins, status, err := i.catalog.refresh(ctx)
+	if err != nil {
+		return CatalogStatus{}, err
+	}
+
+	i.catalogLock.Lock()
+	defer i.catalogLock.Unlock()
+	i.plugins = plugins
+	i.catalogStatus = status
+	return status, nil
+}
+
+// normalizePlugins converts the deprecated inline dependencies to plugins and requirements and validates all version constraints
+func normalizePlugins(plugins []Plugin) ([]Plugin, error) {
+	result := slices.Clone(plugins)
+	for pluginIndex := range result {
+		result[pluginIndex].Versions = slices.Clone(result[pluginIndex].Versions)
+	}
+
+	for pluginIndex := 0; pluginIndex < len(result); pluginIndex++ {
+		for versionIndex := range result[pluginIndex].Versions {
+			version := &result[pluginIndex].Versions[versionIndex]
+			version.Requires = append(slices.Clone(version.Requires), addInlineDependencies(&result, version.Dependencies)...)
+			version.Dependencies = nil
+		}
+	}
+
+	for _, plugin := range result {
+		// inline dependencies are plugins now, so their install dirs are checked as well
+		if !isLocalInstallDir(plugin.InstallDir) {
+			return nil, fmt.Errorf("install dir '%v' of '%v' is outside of the csgo dir", plugin.InstallDir, plugin.Name)
+		}
+
+		for index, action := range plugin.Actions {
+			if err := validateAction(action); err != nil {
+				return nil, fmt.Errorf("action %v of '%v': %w", index+1, plugin.Name, err)
+			}
+		}
+
+		for _, version := range plugin.Versions {
+			for _, requirement := range version.Requires {
+				if _, err := ParseVersionConstraint(requirement.Version); err != nil {
+					return nil, fmt.Errorf("requirement '%v' of '%v' version '%v': %w", requirement.Name, plugin.Name, version.Name, err)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// isLocalInstallDir reports whether the install dir is inside the csgo dir. "/" is the csgo dir.
+// The dir is not cleaned first, because cleaning "/../bin" results in "/bin"
+func isLocalInstallDir(installDir string) bool {
+	relative := strings.TrimLeft(installDir, "/")
+	return relative == "" || filepath.IsLocal(relative)
+}
+
+// isInsideDir reports whether the path is the dir or inside of it
+func isInsideDir(dir string, path string) bool {
+	dir = filepath.Clean(dir)
+	path = filepath.Clean(path)
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// addInlineDependencies adds the dependencies and their versions to the plugins if they don't exist yet and returns the matching requirements
+func addInlineDependencies(plugins *[]Plugin, dependencies []PluginDependency) []Requirement {
+	requirements := make([]Requirement, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		requirements = append(requirements, Requirement{Name: dependency.Name, Version: dependency.Version})
+
+		version := Version{
+			Name:        dependency.Version,
+			DownloadURL: dependency.DownloadURL,
+			Sha256:      dependency.Sha256,
+			Signature:   dependency.Signature,
+			Requires:    addInlineDependencies(plugins, dependency.Dependencies),
+		}
+
+		index := slices.IndexFunc(*plugins, func(p Plugin) bool {
+			return p.Name == dependency.Name
+		})
+		if index == -1 {
+			*plugins = append(*plugins, Plugin{
+				Name:       dependency.Name,
+				InstallDir: dependency.InstallDir,
+				Versions:   []Version{version},
+			})
+			continue
+		}
+
+		versionExists := slices.ContainsFunc((*plugins)[index].Versions, func(v Version) bool {
+			return v.Name == dependency.Version
+		})
+		if !versionExists {
+			(*plugins)[index].Versions = append((*plugins)[index].Versions, version)
+		}
+	}
+
+	return requirements
+}
+
+// GetInstalledPlugins returns all installed plugins including the plugins that were only installed as dependency.
+// Dependencies are listed before the plugins that require them
+func (i *Instance) GetInstalledPlugins() ([]InstalledPlugin, error) {
+	installedPlugins, err := i.readInstalledPluginsJsonFile()
 	if err != nil {
 		return nil, fmt.Errorf("readInstalledPluginsJsonFile: %w", err)
 	}
-	return installedPlugin, nil
+	return installedPlugins, nil
+}
+
+// RequiredBy returns the names of the installed plugins that depend on the given plugin
+func RequiredBy(installedPlugins []InstalledPlugin, pluginName string) []string {
+	result := make([]string, 0)
+	for _, installedPlugin := range installedPlugins {
+		if slices.Contains(installedPlugin.Dependencies, pluginName) {
+			result = append(result, installedPlugin.Name)
+		}
+	}
+	return result
+}
+
+func indexOfInstalledPlugin(installedPlugins []InstalledPlugin, pluginName string) int {
+	return slices.IndexFunc(installedPlugins, func(p InstalledPlugin) bool {
+		return p.Name == pluginName
+	})
 }
 
-func (i *Instance) InstallPluginByName(pluginName string, versionName string) error {
+// InstallPluginByName installs the version of the plugin and its dependencies. The downloads are stopped if the context is cancelled
+func (i *Instance) InstallPluginByName(ctx context.Context, pluginName string, versionName string) error {
 	if i.running.Load() {
 		return fmt.Errorf("another plugin is currently being installed/uninstalled")
 	}
@@ -152,88 +385,94 @@ func (i *Instance) InstallPluginByName(pluginName string, versionName string) er
 	i.lock.Lock()
 	defer i.lock.Unlock()
 
-	plugin, version, err := i.getPluginAndVersionByName(pluginName, versionName)
+	installedPlugins, err := i.GetInstalledPlugins()
 	if err != nil {
-		return fmt.Errorf("plugin not found in plugins list: %w", err)
+		return fmt.Errorf("failed to get installed plugins: %w", err)
 	}
 
-	installedPlugins, err := i.GetInstalledPlugin()
+	// the plugins list can be refreshed during the installation
+	availablePlugins := i.GetAllAvailablePlugins()
+	plan, err := newResolver(availablePlugins, installedPlugins).plan(pluginName, versionName)
 	if err != nil {
-		return fmt.Errorf("failed to get installed plugin: %w", err)
+		return fmt.Errorf("failed to resolve dependencies: %w", err)
 	}
 
-	if installedPlugins != nil {
-		return fmt.Errorf("another plugin is already installed '%v'", installedPlugins)
+	if plan.Steps[len(plan.Steps)-1].Action == PlanActionKeep {
+		return fmt.Errorf("%w: '%v' version '%v'", ErrPluginAlreadyInstalled, pluginName, versionName)
 	}
 
 	eventPayload := PluginEventsPayload{Name: pluginName, Version: versionName}
 	i.onPluginInstallingEvent.Trigger(eventPayload)
 
-	installedDependencies, err := i.InstallPluginDependency(version.Dependencies)
+	tx, err := i.beginTransaction(fmt.Sprintf("install %v %v", pluginName, versionName))
 	if err != nil {
 		i.onPluginInstallationFailedEvent.Trigger(eventPayload)
-		return fmt.Errorf("failed to install dependencies: %w", err)
+		return err
 	}
 
-	files, err := i.downloadAndInstall(plugin.Name, plugin.InstallDir, version.DownloadURL)
-	if err != nil {
+	// every change of the installation is reverted, including the dependencies that were installed before the failed step
+	fail := func(err error) error {
+		if rollbackErr := tx.rollback(); rollbackErr != nil {
+			slog.Error("failed to revert installation", "plugin", pluginName, "error", rollbackErr)
+			err = errors.Join(err, fmt.Errorf("rollback: %w", rollbackErr))
+		}
 		i.onPluginInstallationFailedEvent.Trigger(eventPayload)
-		return fmt.Errorf("failed to download plugin: %w", err)
+		return err
 	}
 
-	installedPlugin := InstalledPlugin{
-		Name:           plugin.Name,
-		Version:        version.Name,
-		InstalledAtUtc: time.Now().UTC(),
-		Dependencies:   installedDependencies,
-		Files:          files,
-	}
+	for _, step := range plan.Steps {
+		switch step.Action {
+		case PlanActionMarkExplicit:
+			installedPlugins[indexOfInstalledPlugin(installedPlugins, step.Name)].Explicit = true
+		case PlanActionInstall:
+			plugin, version, err := getPluginAndVersionByName(availablePlugins, step.Name, step.Version)
+			if err != nil {
+				return fail(fmt.Errorf("plugin not found in plugins list: %w", err))
+			}
+
+			archive, err := i.installVersion(ctx, tx, plugin, version)
+			if err != nil {
+				return fail(fmt.Errorf("failed to install '%v' version '%v': %w", step.Name, step.Version, err))
+			}
 
-	if err := i.writeInstalledPluginsJsonFile(&installedPlugin); err != nil {
-		if uninstallErr := i.uninstallInternal(installedPlugin); uninstallErr != nil {
-			slog.Error("failed to uninstall plugin ", "plugin", pluginName, "error", err)
+			installedPlugins = append(installedPlugins, InstalledPlugin{
+				Name:           plugin.Name,
+				Version:        version.Name,
+				InstalledAtUtc: time.Now().UTC(),
+				Files:          archive.files,
+				FileHashes:     archive.fileHashes,
+				Sha256:         archive.sha256,
+				Actions:        archive.actions,
+				Dependencies:   step.Dependencies,
+				Explicit:       step.Name == pluginName,
+			})
 		}
-		return fmt.Errorf("installedPluginJfile.Update: %w", err)
 	}
 
-	i.onPluginInstalledEvent.Trigger(eventPayload)
-	return nil
-}
-
-func (i *Instance) InstallPluginDependency(dependencies []PluginDependency) ([]InstalledPlugin, error) {
-	if len(dependencies) == 0 {
-		return nil, nil
+	if err := i.writeInstalledPluginsJsonFileInTransaction(tx, installedPlugins); err != nil {
+		return fail(err)
 	}
 
-	result := make([]InstalledPlugin, 0)
-
-	for _, dependency := range dependencies {
-		installedDependencies, err := i.InstallPluginDependency(dependency.Dependencies)
-		if err != nil {
-			return nil, fmt.Errorf("InstallPluginDependencies: for: (%v | version: %v) | dependencies: %v | %w",
-				dependency.Name, dependency.Version, dependency.Dependencies, err)
-		}
-
-		files, err := i.downloadAndInstall(dependency.Name, dependency.InstallDir, dependency.DownloadURL)
-		if err != nil {
-			return nil, fmt.Errorf("downloadAndInstall for dependency %v | %w", dependency, err)
-		}
+	if err := tx.commit(); err != nil {
+		return fail(fmt.Errorf("commit: %w", err))
+	}
 
-		installedDependency := InstalledPlugin{
-			Name:           dependency.Name,
-			Version:        dependency.Version,
-			InstalledAtUtc: time.Now().UTC(),
-			Dependencies:   installedDependencies,
-			Files:          files,
-		}
+	i.onPluginInstalledEvent.Trigger(eventPayload)
+	return nil
+}
 
-		result = append(result, installedDependency)
+// Plan returns the plugins that are installed or changed if the given plugin version is installed
+func (i *Instance) Plan(pluginName string, versionName string) (Plan, error) {
+	installedPlugins, err := i.GetInstalledPlugins()
+	if err != nil {
+		return Plan{}, fmt.Errorf("failed to get installed plugins: %w", err)
 	}
 
-	return result, nil
+	return newResolver(i.GetAllAvailablePlugins(), installedPlugins).plan(pluginName, versionName)
 }
 
-func (i *Instance) Uninstall() error {
+// Uninstall removes the plugin and all of its dependencies that are neither required by another plugin nor installed explicitly
+func (i *Instance) Uninstall(pluginName string) error {
 	if i.running.Load() {
 		return fmt.Errorf("another plugin is currently getting installed/uninstalled")
 	}
@@ -244,48 +483,93 @@ func (i *Instance) Uninstall() error {
 	i.lock.Lock()
 	defer i.lock.Unlock()
 
-	installedPlugin, err := i.GetInstalledPlugin()
+	installedPlugins, err := i.GetInstalledPlugins()
 	if err != nil {
-		return fmt.Errorf("GetInstalledPlugin: %w", err)
+		return fmt.Errorf("GetInstalledPlugins: %w", err)
+	}
+
+	index := indexOfInstalledPlugin(installedPlugins, pluginName)
+	if index == -1 {
+		return fmt.Errorf("%w: '%v'", ErrPluginNotInstalled, pluginName)
 	}
-	eventPayload := PluginEventsPayload{Name: installedPlugin.Name, Version: installedPlugin.Version}
+
+	if requiredBy := RequiredBy(installedPlugins, pluginName); len(requiredBy) > 0 {
+		return fmt.Errorf("%w: '%v' is required by %v", ErrPluginRequired, pluginName, strings.Join(requiredBy, ", "))
+	}
+
+	eventPayload := PluginEventsPayload{Name: installedPlugins[index].Name, Version: installedPlugins[index].Version}
 
 	i.onPluginUninstallingEvent.Trigger(eventPayload)
-	if err := i.uninstallInternal(*installedPlugin); err != nil {
+	installedPlugins, uninstallErr := i.uninstallInternal(installedPlugins, pluginName)
+
+	// the plugins removed before a failure must not be tracked anymore
+	if err := i.writeInstalledPluginsJsonFile(installedPlugins); err != nil {
 		i.onPluginUninstallFailedEvent.Trigger(eventPayload)
-		return fmt.Errorf("uninstallInternal: %w", err)
+		return fmt.Errorf("writeInstalledPluginsJsonFile: %w", err)
 	}
 
-	if err := i.writeInstalledPluginsJsonFile(nil); err != nil {
-		return fmt.Errorf("installedPluginJfile.Update: %w", err)
+	if uninstallErr != nil {
+		i.onPluginUninstallFailedEvent.Trigger(eventPayload)
+		return fmt.Errorf("uninstallInternal: %w", uninstallErr)
 	}
 
 	i.onPluginUninstalledEvent.Trigger(eventPayload)
 	return nil
 }
 
-func (i *Instance) uninstallInternal(plugin InstalledPlugin) error {
-	for _, dependency := range plugin.Dependencies {
-		if err := i.uninstallInternal(dependency); err != nil {
-			return fmt.Errorf("failed to uninstall dependencies '%v' of plugin '%v' %w", dependency.Name, plugin.Name, err)
-		}
+// uninstallInternal removes the files of the plugin and afterward all of its dependencies that are not needed anymore.
+// Files that are also tracked by another installed plugin are kept. Returns the updated installed plugins
+func (i *Instance) uninstallInternal(installedPlugins []InstalledPlugin, pluginName string) ([]InstalledPlugin, error) {
+	index := indexOfInstalledPlugin(installedPlugins, pluginName)
+	if index == -1 {
+		return installedPlugins, nil
+	}
+	plugin := installedPlugins[index]
+	remainingPlugins := slices.Delete(slices.Clone(installedPlugins), index, index+1)
+
+	// the actions are reverted while the plugin files still exist
+	if err := i.revertActions(nil, i.appliedActionsOf(plugin)); err != nil {
+		return installedPlugins, fmt.Errorf("failed to revert actions of '%v': %w", plugin.Name, err)
 	}
 
 	for _, file := range plugin.Files {
-		if err := os.Remove(filepath.Join(i.csgoDir, file)); err != nil {
-			return fmt.Errorf("failed to remove file '%v' %w", file, err)
+		if isTrackedByAny(remainingPlugins, file) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(i.csgoDir, file)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return installedPlugins, fmt.Errorf("failed to remove file '%v' %w", file, err)
 		}
 	}
 
-	// additional plugin actions
-	executeCustomUninstallAction(i.csgoDir, plugin.Name)
+	for _, dependency := range plugin.Dependencies {
+		dependencyIndex := indexOfInstalledPlugin(remainingPlugins, dependency)
+		if dependencyIndex == -1 || remainingPlugins[dependencyIndex].Explicit || len(RequiredBy(remainingPlugins, dependency)) > 0 {
+			continue
+		}
 
-	return nil
+		var err error
+		remainingPlugins, err = i.uninstallInternal(remainingPlugins, dependency)
+		if err != nil {
+			return remainingPlugins, fmt.Errorf("failed to uninstall dependency '%v' of plugin '%v' %w", dependency, plugin.Name, err)
+		}
+	}
+
+	return remainingPlugins, nil
 }
 
-func (i *Instance) getPluginAndVersionByName(pluginName string, versionName string) (Plugin, Version, error) {
+func isTrackedByAny(installedPlugins []InstalledPlugin, file string) bool {
+	for _, installedPlugin := range installedPlugins {
+		if slices.Contains(installedPlugin.Files, file) {
+			return true
+		}
+	}
+	return false
+}
+
+func getPluginAndVersionByName(plugins []Plugin, pluginName string, versionName string) (Plugin, Version, error) {
 	pluginFound := false
-	for _, plugin := range i.plugins {
+	for _, plugin := range plugins {
 		if plugin.Name != pluginName {
 			continue
 		}
@@ -299,64 +583,223 @@ func (i *Instance) getPluginAndVersionByName(pluginName string, versionName stri
 	}
 
 	if pluginFound {
-		return Plugin{}, Version{}, fmt.Errorf("plugin found %v but version %v dose not exists in plugin", pluginName, versionName)
+		return Plugin{}, Version{}, fmt.Errorf("%w: plugin found %v but version %v dose not exists in plugin", ErrPluginNotFound, pluginName, versionName)
+	}
+
+	return Plugin{}, Version{}, fmt.Errorf("%w: no plugin with name %v found", ErrPluginNotFound, pluginName)
+}
+
+// installedArchive contains the files of an extracted archive relative to the csgo dir
+type installedArchive struct {
+	files []string
+	// sha256 hashes of the files right after the extraction
+	fileHashes map[string]string
+	// sha256 hash of the archive
+	sha256 string
+	// actions of the plugin that were executed after the files were moved into place
+	actions []AppliedAction
+}
+
+// stagedArchive is a verified and extracted archive that is not moved into the csgo dir yet
+type stagedArchive struct {
+	sha256 string
+	// staged file path by target path
+	files map[string]string
+	// sha256 hashes of the staged files by target path
+	fileHashes map[string]string
+}
+
+// stageDownload downloads and verifies the archive of the version and extracts it to the staging dir.
+// Nothing is extracted if the hash or the signature of the download does not match
+func (i *Instance) stageDownload(ctx context.Context, plugin Plugin, version Version, stagingDir string) (stagedArchive, error) {
+	downloadDir := filepath.Join(stagingDir, "download")
+	if err := os.MkdirAll(downloadDir, os.ModePerm); err != nil {
+		return stagedArchive{}, fmt.Errorf("os.MkdirAll staging dir: %w", err)
 	}
 
-	return Plugin{}, Version{}, errors.New("No plugin with name " + pluginName + " found")
+	options := download.DefaultOptions()
+	options.OnProgress = func(progress download.Progress) {
+		i.onPluginDownloadProgressEvent.Trigger(PluginDownloadProgressPayload{Name: plugin.Name, Version: version.Name, Progress: progress})
+	}
+
+	downloadedFilePath, err := download.Download(ctx, version.DownloadURL, downloadDir, options)
+	if err != nil {
+		return stagedArchive{}, fmt.Errorf("download.Download: %w", err)
+	}
+
+	sha256, err := verifyDownload(downloadedFilePath, version.Sha256, version.Signature, i.signaturePublicKey)
+	if err != nil {
+		return stagedArchive{}, fmt.Errorf("verifyDownload: %w", err)
+	}
+
+	return i.stageArchive(downloadedFilePath, sha256, plugin.InstallDir, stagingDir)
 }
 
-func (i *Instance) downloadAndInstall(pluginName string, pluginInstallDir, downloadUrl string) ([]string, error) {
-	tempDirPath := filepath.Join(i.csgoDir, fmt.Sprintf("temp_%v_%v", pluginName, time.Now().UTC()))
-	if err := os.Mkdir(tempDirPath, os.ModePerm); err != nil {
-		return nil, fmt.Errorf("os.Mkdir temp dir: %w", err)
+// stageArchive extracts the archive to the staging dir and maps the extracted files to their target paths in the csgo dir
+func (i *Instance) stageArchive(archivePath string, sha256 string, pluginInstallDir string, stagingDir string) (stagedArchive, error) {
+	filesDir := filepath.Join(stagingDir, "files")
+	extractDir := filepath.Join(filesDir, pluginInstallDir)
+	if !isInsideDir(filesDir, extractDir) {
+		return stagedArchive{}, fmt.Errorf("install dir '%v' is outside of the csgo dir", pluginInstallDir)
+	}
+
+	stagedFiles, err := unzip.Extract(archivePath, extractDir)
+	if err != nil {
+		return stagedArchive{}, fmt.Errorf("unzip.Extract: %w", err)
 	}
 
-	downloadedFilePath, err := download.Download(downloadUrl, tempDirPath)
+	fileHashes, err := hashFiles(stagedFiles)
 	if err != nil {
-		return nil, fmt.Errorf("download.Download: %w", err)
+		return stagedArchive{}, err
+	}
+
+	staged := stagedArchive{
+		sha256:     sha256,
+		files:      make(map[string]string, len(stagedFiles)),
+		fileHashes: make(map[string]string, len(stagedFiles)),
+	}
+	for _, stagedFile := range stagedFiles {
+		relativePath, err := filepath.Rel(filesDir, stagedFile)
+		if err != nil {
+			return stagedArchive{}, fmt.Errorf("filepath.Rel: %w", err)
+		}
+
+		target := filepath.Join(i.csgoDir, relativePath)
+		if !isInsideDir(i.csgoDir, target) {
+			return stagedArchive{}, fmt.Errorf("file '%v' is outside of the csgo dir", relativePath)
+		}
+		staged.files[target] = stagedFile
+		staged.fileHashes[target] = fileHashes[stagedFile]
 	}
 
-	destPath := filepath.Join(i.csgoDir, pluginInstallDir)
+	return staged, nil
+}
 
-	unzippedFiles, err := unzipDownload(downloadedFilePath, destPath)
+// installVersion downloads the version and installs it. All changes are journaled in the transaction
+func (i *Instance) installVersion(ctx context.Context, tx *transaction, plugin Plugin, version Version) (installedArchive, error) {
+	staged, err := i.stageDownload(ctx, plugin, version, tx.newStagingDir())
 	if err != nil {
-		return nil, fmt.Errorf("unzipDownload: %w", err)
+		return installedArchive{}, err
 	}
 
-	if err := os.RemoveAll(tempDirPath); err != nil {
-		return nil, fmt.Errorf("os.RemoveAll temp dir: %w", err)
+	return i.installStaged(tx, plugin.Name, plugin.Actions, staged)
+}
+
+// installStaged moves the staged files into place and executes the actions of the plugin
+func (i *Instance) installStaged(tx *transaction, pluginName string, actions []Action, staged stagedArchive) (installedArchive, error) {
+	if err := tx.placeFiles(staged.files); err != nil {
+		return installedArchive{}, err
 	}
 
-	// additional plugin actions
-	executeCustomInstallAction(i.csgoDir, pluginName)
+	appliedActions, err := i.applyActions(tx, actions)
+	if err != nil {
+		return installedArchive{}, fmt.Errorf("actions of '%v': %w", pluginName, err)
+	}
 
-	for index := 0; index < len(unzippedFiles); index++ {
-		unzippedFiles[index] = strings.Replace(unzippedFiles[index], i.csgoDir, "", 1)
+	// the hashes were taken before the actions, so changes of the actions count as user modifications
+	archive := installedArchive{
+		files:      make([]string, 0, len(staged.files)),
+		fileHashes: make(map[string]string, len(staged.files)),
+		sha256:     staged.sha256,
+		actions:    appliedActions,
+	}
+	for _, target := range slices.Sorted(maps.Keys(staged.files)) {
+		file := strings.Replace(target, i.csgoDir, "", 1)
+		archive.files = append(archive.files, file)
+		archive.fileHashes[file] = staged.fileHashes[target]
 	}
 
-	return unzippedFiles, nil
+	return archive, nil
 }
 
-func unzipDownload(sourcePath, destDir string) ([]string, error) {
-	var unzippedFiles []string
-	var err error
-	if strings.HasSuffix(sourcePath, ".zip") {
-		unzippedFiles, err = unzip.Zip(sourcePath, destDir)
+// hashFiles returns the sha256 hashes of the files by path
+func hashFiles(paths []string) (map[string]string, error) {
+	result := make(map[string]string, len(paths))
+	for _, path := range paths {
+		hash, err := fileSha256(path)
 		if err != nil {
-			return nil, fmt.Errorf("unzip zip: %w", err)
+			return nil, fmt.Errorf("hash '%v': %w", path, err)
 		}
-	} else if strings.HasSuffix(sourcePath, ".tar.gz") {
-		if unzippedFiles, err = unzip.TarGz(sourcePath, destDir); err != nil {
-			return nil, fmt.Errorf("unzip tar.gz: %w", err)
+		result[path] = hash
+	}
+	return result, nil
+}
+
+// ValidateInstalledPluginJson checks the content of an installed plugin json file, e.g. before a backup is restored
+func ValidateInstalledPluginJson(content []byte) error {
+	_, err := parseInstalledPluginsJson(content)
+	return err
+}
+
+// parseInstalledPluginsJson also accepts the single plugin format of older versions and converts it to the list format
+func parseInstalledPluginsJson(content []byte) ([]InstalledPlugin, error) {
+	trimmedContent := bytes.TrimSpace(content)
+	if string(trimmedContent) == "{}" {
+		return []InstalledPlugin{}, nil
+	}
+
+	var installedPlugins []InstalledPlugin
+	if bytes.HasPrefix(trimmedContent, []byte("{")) {
+		var legacyPlugin legacyInstalledPlugin
+		if err := json.Unmarshal(content, &legacyPlugin); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
 		}
-	} else {
-		return nil, fmt.Errorf("downloaded filetype not supported '%v'", sourcePath)
+		installedPlugins = migrateLegacyInstalledPlugin(legacyPlugin, true, make([]InstalledPlugin, 0))
+	} else if err := json.Unmarshal(content, &installedPlugins); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if installedPlugins == nil {
+		installedPlugins = []InstalledPlugin{}
+	}
+
+	if err := validateInstalledPlugins(installedPlugins); err != nil {
+		return nil, err
+	}
+
+	return installedPlugins, nil
+}
+
+func migrateLegacyInstalledPlugin(plugin legacyInstalledPlugin, explicit bool, installedPlugins []InstalledPlugin) []InstalledPlugin {
+	dependencies := make([]string, 0, len(plugin.Dependencies))
+	for _, dependency := range plugin.Dependencies {
+		dependencies = append(dependencies, dependency.Name)
+		if indexOfInstalledPlugin(installedPlugins, dependency.Name) == -1 {
+			installedPlugins = migrateLegacyInstalledPlugin(dependency, false, installedPlugins)
+		}
+	}
+
+	return append(installedPlugins, InstalledPlugin{
+		Name:           plugin.Name,
+		Version:        plugin.Version,
+		InstalledAtUtc: plugin.InstalledAtUtc,
+		Files:          plugin.Files,
+		Dependencies:   dependencies,
+		Explicit:       explicit,
+	})
+}
+
+func validateInstalledPlugins(installedPlugins []InstalledPlugin) error {
+	if err := gvalidator.Instance().Var(installedPlugins, "dive"); err != nil {
+		return fmt.Errorf("validate: %w", err)
 	}
 
-	return unzippedFiles, err
+	for index, installedPlugin := range installedPlugins {
+		if indexOfInstalledPlugin(installedPlugins, installedPlugin.Name) != index {
+			return fmt.Errorf("plugin '%v' is installed more than once", installedPlugin.Name)
+		}
+
+		for _, dependency := range installedPlugin.Dependencies {
+			if indexOfInstalledPlugin(installedPlugins, dependency) == -1 {
+				return fmt.Errorf("dependency '%v' of plugin '%v' is not installed", dependency, installedPlugin.Name)
+			}
+		}
+	}
+
+	return nil
 }
 
-func (i *Instance) readInstalledPluginsJsonFile() (*InstalledPlugin, error) {
+func (i *Instance) readInstalledPluginsJsonFile() ([]InstalledPlugin, error) {
 	i.installedPluginJsonFileLock.Lock()
 	defer i.installedPluginJsonFileLock.Unlock()
 
@@ -365,27 +808,39 @@ func (i *Instance) readInstalledPluginsJsonFile() (*InstalledPlugin, error) {
 		return nil, fmt.Errorf("os.ReadFile: %w", err)
 	}
 
-	contentJson := string(content)
-	if contentJson == "{}" {
-		return nil, nil
-	}
+	return parseInstalledPluginsJson(content)
+}
 
-	var installedPlugin InstalledPlugin
-	if err := json.Unmarshal(content, &installedPlugin); err != nil {
-		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+// writeInstalledPluginsJsonFileInTransaction journals the installed plugin json file before it is written, so it is restored on rollback
+func (i *Instance) writeInstalledPluginsJsonFileInTransaction(tx *transaction, installedPlugins []InstalledPlugin) error {
+	if err := tx.record(i.installedPluginJsonFilePath); err != nil {
+		return fmt.Errorf("record installed plugin json file: %w", err)
 	}
 
-	if err := gvalidator.Instance().Struct(installedPlugin); err != nil {
-		return nil, fmt.Errorf("validate: %w", err)
+	if err := i.writeInstalledPluginsJsonFile(installedPlugins); err != nil {
+		return fmt.Errorf("writeInstalledPluginsJsonFile: %w", err)
 	}
 
-	return &installedPlugin, nil
+	return nil
 }
 
-func (i *Instance) writeInstalledPluginsJsonFile(plugin *InstalledPlugin) error {
+func (i *Instance) writeInstalledPluginsJsonFile(installedPlugins []InstalledPlugin) error {
 	i.installedPluginJsonFileLock.Lock()
 	defer i.installedPluginJsonFileLock.Unlock()
 
+	if installedPlugins == nil {
+		installedPlugins = []InstalledPlugin{}
+	}
+
+	if err := validateInstalledPlugins(installedPlugins); err != nil {
+		return fmt.Errorf("validation: %w", err)
+	}
+
+	jsonContent, err := json.MarshalIndent(installedPlugins, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
 	if _, err := os.Stat(i.installedPluginJsonFilePath); err == nil {
 		if err := os.Remove(i.installedPluginJsonFilePath); err != nil {
 			return fmt.Errorf("remove old installedPluginJsonFile: %w", err)
@@ -394,23 +849,8 @@ func (i *Instance) writeInstalledPluginsJsonFile(plugin *InstalledPlugin) error
 		return fmt.Errorf("os.Stats: %w", err)
 	}
 
-	if plugin == nil {
-		if err := os.WriteFile(i.installedPluginJsonFilePath, []byte("{}"), os.ModePerm); err != nil {
-			return fmt.Errorf("os.WriteFile empty: %w", err)
-		}
-	} else {
-		if err := gvalidator.Instance().Struct(*plugin); err != nil {
-			return fmt.Errorf("validation: %w", err)
-		}
-
-		jsonContent, err := json.MarshalIndent(*plugin, "", "    ")
-		if err != nil {
-			return fmt.Errorf("json.MarshalIndent: %w", err)
-		}
-
-		if err := os.WriteFile(i.installedPluginJsonFilePath, jsonContent, os.ModePerm); err != nil {
-			return fmt.Errorf("os.WriteFile: %w", err)
-		}
+	if err := os.WriteFile(i.installedPluginJsonFilePath, jsonContent, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
 	}
 
 	return nil
@@ -439,3 +879,19 @@ func (i *Instance) OnPluginUninstalledEvent(handler func(data event.PayloadWithD
 func (i *Instance) OnPluginUninstallFailedEvent(handler func(data event.PayloadWithData[PluginEventsPayload])) {
 	i.onPluginUninstallFailedEvent.Register(handler)
 }
+
+func (i *Instance) OnPluginUpgradingEvent(handler func(data event.PayloadWithData[PluginUpgradeEventsPayload])) {
+	i.onPluginUpgradingEvent.Register(handler)
+}
+
+func (i *Instance) OnPluginUpgradedEvent(handler func(data event.PayloadWithData[PluginUpgradeEventsPayload])) {
+	i.onPluginUpgradedEvent.Register(handler)
+}
+
+func (i *Instance) OnPluginUpgradeFailedEvent(handler func(data event.PayloadWithData[PluginUpgradeEventsPayload])) {
+	i.onPluginUpgradeFailedEvent.Register(handler)
+}
+
+func (i *Instance) OnPluginDownloadProgressEvent(handler func(data event.PayloadWithData[PluginDownloadProgressPayload])) {
+	i.onPluginDownloadProgressEvent.Register(handler)
+}
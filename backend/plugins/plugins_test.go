@@ -1,11 +1,19 @@
 package plugins_test
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/Phi-S/cs-server-manager/plugins"
@@ -53,7 +61,11 @@ func TestNew_CustomPlugins(t *testing.T) {
 		t.Fatal("os.WriteFile pluginsJsonContent ", err)
 	}
 
-	pluginsInstance, err := plugins.New(csGamePath, pluginsJsonPath, installedPluginsJsonPath)
+	pluginsInstance, err := plugins.New(plugins.Config{
+		CsgoDir:                 csGamePath,
+		PluginsJsonFilePath:     pluginsJsonPath,
+		InstalledPluginJsonPath: installedPluginsJsonPath,
+	})
 	if err != nil {
 		t.Fatal("plugins.New temp dir", err)
 	}
@@ -420,54 +432,33 @@ func TestInstall_CounterStrikeSharp(t *testing.T) {
 
 	pluginsJsonPath := filepath.Join(tempDirPath, "plugins.json")
 	installedPluginsJsonPath := filepath.Join(tempDirPath, "installed-plugin.json")
-	pluginsInstance, err := plugins.New(csgoDir, pluginsJsonPath, installedPluginsJsonPath)
+	pluginsInstance, err := plugins.New(plugins.Config{
+		CsgoDir:                 csgoDir,
+		PluginsJsonFilePath:     pluginsJsonPath,
+		InstalledPluginJsonPath: installedPluginsJsonPath,
+	})
 	if err != nil {
 		t.Fatal("plugins.New temp dir", err)
 	}
 
-	if err := pluginsInstance.InstallPluginByName("CounterStrikeSharp", "v264"); err != nil {
+	if err := pluginsInstance.InstallPluginByName(context.Background(), "CounterStrikeSharp", "v264"); err != nil {
 		t.Fatal("InstallPluginByName", err)
 	}
 
-	installedPluginsJsonContent, err := os.ReadFile(installedPluginsJsonPath)
+	installedPlugins, err := pluginsInstance.GetInstalledPlugins()
 	if err != nil {
-		t.Fatal("os.ReadFile(installedPluginsJsonPath) ", err)
+		t.Fatal("GetInstalledPlugins", err)
 	}
-	installedPluginsJson := string(installedPluginsJsonContent)
 
-	if strings.Contains(installedPluginsJson, `"name": "metamod_source",`) == false {
-		t.Fatal("installedPluginsJson dose not contain metamod entry")
-	}
-
-	if strings.Contains(installedPluginsJson, `"version": "2.0.0-git1313",`) == false {
-		t.Fatal("installedPluginsJson dose not contain metamod entry version")
-	}
-
-	if strings.Contains(installedPluginsJson, `"dependencies": null`) == false {
-		t.Fatal("installedPluginsJson dose not contain metamod entry dependencies")
-	}
-
-	if strings.Contains(installedPluginsJson, `"name": "CounterStrikeSharp",`) == false {
-		t.Fatal("installedPluginsJson dose not contain CounterStrikeSharp entry")
-	}
-
-	if strings.Contains(installedPluginsJson, `"version": "v264",`) == false {
-		t.Fatal("installedPluginsJson dose not contain CounterStrikeSharp entry version")
-	}
-
-	if strings.Contains(installedPluginsJson, `"dependencies": [
-        {
-            "name": "metamod_source",
-            "version": "2.0.0-git1313",`) == false {
-		t.Fatal("installedPluginsJson dose not contain CounterStrikeSharp entry dependencies")
-	}
+	expectInstalledPlugin(t, installedPlugins, "metamod_source", "2.0.0-git1313", false)
+	expectInstalledPlugin(t, installedPlugins, "CounterStrikeSharp", "v264", true, "metamod_source")
 
 	newGameinfoContent, err := os.ReadFile(gameinfoPath)
 	if err != nil {
 		t.Fatal("failed to validate new gameinfo.gi", err)
 	}
 
-	if strings.Contains(string(newGameinfoContent), "Game csgo/addons/metamod") == false {
+	if strings.Contains(string(newGameinfoContent), "\t\t\tGame\tcsgo/addons/metamod\n\t\t\tGame\tcsgo\n") == false {
 		t.Fatal("new gameinfo.gi is missing metamod_install line")
 	}
 
@@ -518,90 +509,322 @@ func TestInstall_Cs2PracticeMode(t *testing.T) {
 
 	pluginsJsonPath := filepath.Join(tempDirPath, "plugins.json")
 	installedPluginsJsonPath := filepath.Join(tempDirPath, "installed-plugin.json")
-	pluginsInstance, err := plugins.New(csgoDir, pluginsJsonPath, installedPluginsJsonPath)
+	pluginsInstance, err := plugins.New(plugins.Config{
+		CsgoDir:                 csgoDir,
+		PluginsJsonFilePath:     pluginsJsonPath,
+		InstalledPluginJsonPath: installedPluginsJsonPath,
+	})
 	if err != nil {
 		t.Fatal("plugins.New temp dir", err)
 	}
 
-	if err := pluginsInstance.InstallPluginByName("Cs2PracticeMode", "0.0.16"); err != nil {
+	if err := pluginsInstance.InstallPluginByName(context.Background(), "Cs2PracticeMode", "0.0.16"); err != nil {
 		t.Fatal("InstallPluginByName", err)
 	}
 
-	installedPluginsJsonContent, err := os.ReadFile(installedPluginsJsonPath)
+	installedPlugins, err := pluginsInstance.GetInstalledPlugins()
 	if err != nil {
-		t.Fatal("os.ReadFile(installedPluginsJsonPath) ", err)
+		t.Fatal("GetInstalledPlugins", err)
 	}
-	installedPluginsJson := string(installedPluginsJsonContent)
 
-	if strings.Contains(installedPluginsJson, `"name": "metamod_source",`) == false {
-		t.Fatal("installedPluginsJson dose not contain metamod entry")
+	expectInstalledPlugin(t, installedPlugins, "metamod_source", "2.0.0-git1313", false)
+	expectInstalledPlugin(t, installedPlugins, "CounterStrikeSharp", "v264", false, "metamod_source")
+	expectInstalledPlugin(t, installedPlugins, "Cs2PracticeMode", "0.0.16", true, "CounterStrikeSharp")
+
+	newGameinfoContent, err := os.ReadFile(gameinfoPath)
+	if err != nil {
+		t.Fatal("failed to validate new gameinfo.gi", err)
 	}
 
-	if strings.Contains(installedPluginsJson, `"version": "2.0.0-git1313",`) == false {
-		t.Fatal("installedPluginsJson dose not contain metamod entry version")
+	if strings.Contains(string(newGameinfoContent), "\t\t\tGame\tcsgo/addons/metamod\n\t\t\tGame\tcsgo\n") == false {
+		t.Fatal("new gameinfo.gi is missing metamod_install line")
 	}
 
-	if strings.Contains(installedPluginsJson, `"dependencies": null`) == false {
-		t.Fatal("installedPluginsJson dose not contain metamod entry dependencies")
+	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "metamod.vdf")); err != nil {
+		t.Fatal("metamod.vdf file not found", err)
 	}
 
-	if strings.Contains(installedPluginsJson, `"name": "CounterStrikeSharp",`) == false {
-		t.Fatal("installedPluginsJson dose not contain CounterStrikeSharp entry")
+	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "metamod", "bin", "linux64", "metamod.2.blade.so")); err != nil {
+		t.Fatal("metamod.2.blade.so file not found ", err)
 	}
 
-	if strings.Contains(installedPluginsJson, `"version": "v264",`) == false {
-		t.Fatal("installedPluginsJson dose not contain CounterStrikeSharp entry version")
+	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "metamod", "counterstrikesharp.vdf")); err != nil {
+		t.Fatal("counterstrikesharp.vdf file not found ", err)
 	}
 
-	if strings.Contains(installedPluginsJson, `"dependencies": [
-                {
-                    "name": "metamod_source",
-                    "version": "2.0.0-git1313",`) == false {
-		t.Fatal("installedPluginsJson dose not contain CounterStrikeSharp entry dependencies")
+	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "counterstrikesharp", "bin", "linuxsteamrt64", "counterstrikesharp.so")); err != nil {
+		t.Fatal("counterstrikesharp.so file not found ", err)
 	}
 
-	if strings.Contains(installedPluginsJson, `"name": "Cs2PracticeMode",`) == false {
-		t.Fatal("installedPluginsJson dose not contain Cs2PracticeMode entry")
+	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "counterstrikesharp", "plugins", "Cs2PracticeMode", "Cs2PracticeMode.dll")); err != nil {
+		t.Fatal("Cs2PracticeMode.dll file not found ", err)
 	}
 
-	if strings.Contains(installedPluginsJson, `"version": "0.0.16",`) == false {
-		t.Fatal("installedPluginsJson dose not contain Cs2PracticeMode entry version")
+	if !t.Failed() {
+		defer func() {
+			if err := os.RemoveAll(tempDirPath); err != nil {
+				t.Log("success but failed to cleanup test dir: ", tempDirPath)
+			}
+		}()
 	}
+}
 
-	if strings.Contains(installedPluginsJson, `"dependencies": [
-        {
-            "name": "CounterStrikeSharp",
-            "version": "v264",`) == false {
-		t.Fatal("installedPluginsJson dose not contain Cs2PracticeMode entry dependencies")
+func expectInstalledPlugin(t *testing.T, installedPlugins []plugins.InstalledPlugin, name string, version string, explicit bool, dependencies ...string) {
+	index := slices.IndexFunc(installedPlugins, func(p plugins.InstalledPlugin) bool {
+		return p.Name == name
+	})
+	if index == -1 {
+		t.Fatalf("plugin '%v' is not installed. installed plugins: %+v", name, installedPlugins)
 	}
 
-	newGameinfoContent, err := os.ReadFile(gameinfoPath)
+	installedPlugin := installedPlugins[index]
+	if installedPlugin.Version != version || installedPlugin.Explicit != explicit || !slices.Equal(installedPlugin.Dependencies, dependencies) {
+		t.Fatalf("unexpected installed plugin %+v", installedPlugin)
+	}
+}
+
+func createZip(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	for name, content := range files {
+		fileWriter, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatal("zipWriter.Create", err)
+		}
+
+		if _, err := fileWriter.Write([]byte(content)); err != nil {
+			t.Fatal("fileWriter.Write", err)
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal("zipWriter.Close", err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestInstall_SharedDependency(t *testing.T) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+	if err := os.MkdirAll(csgoDir, os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+
+	archives := map[string][]byte{
+		"/shared.zip": createZip(t, map[string]string{"addons/shared/shared.so": "shared"}),
+		"/a.zip":      createZip(t, map[string]string{"a/a.dll": "a"}),
+		"/b.zip":      createZip(t, map[string]string{"b/b.dll": "b"}),
+	}
+
+	var downloadsLock sync.Mutex
+	downloads := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		archive, ok := archives[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		downloadsLock.Lock()
+		downloads[r.URL.Path]++
+		downloadsLock.Unlock()
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	sharedDependency := plugins.PluginDependency{
+		Name:        "shared",
+		InstallDir:  "/",
+		Version:     "1.0",
+		DownloadURL: server.URL + "/shared.zip",
+	}
+
+	pluginsJson := []plugins.Plugin{
+		{
+			Name:       "plugin-a",
+			URL:        server.URL,
+			InstallDir: "/addons/plugins/",
+			Versions: []plugins.Version{
+				{Name: "1.0", DownloadURL: server.URL + "/a.zip", Dependencies: []plugins.PluginDependency{sharedDependency}},
+			},
+		},
+		{
+			Name:       "plugin-b",
+			URL:        server.URL,
+			InstallDir: "/addons/plugins/",
+			Versions: []plugins.Version{
+				{Name: "1.0", DownloadURL: server.URL + "/b.zip", Dependencies: []plugins.PluginDependency{sharedDependency}},
+			},
+		},
+	}
+	pluginsJsonContent, err := json.Marshal(pluginsJson)
 	if err != nil {
-		t.Fatal("failed to validate new gameinfo.gi", err)
+		t.Fatal("json.Marshal(pluginsJson)", err)
 	}
 
-	if strings.Contains(string(newGameinfoContent), "Game csgo/addons/metamod") == false {
-		t.Fatal("new gameinfo.gi is missing metamod_install line")
+	pluginsJsonPath := filepath.Join(tempDirPath, "plugins.json")
+	if err := os.WriteFile(pluginsJsonPath, pluginsJsonContent, os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile pluginsJsonContent", err)
 	}
 
-	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "metamod.vdf")); err != nil {
-		t.Fatal("metamod.vdf file not found", err)
+	pluginsInstance, err := plugins.New(plugins.Config{
+		CsgoDir:                 csgoDir,
+		PluginsJsonFilePath:     pluginsJsonPath,
+		InstalledPluginJsonPath: filepath.Join(tempDirPath, "installed-plugin.json"),
+	})
+	if err != nil {
+		t.Fatal("plugins.New", err)
 	}
 
-	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "metamod", "bin", "linux64", "metamod.2.blade.so")); err != nil {
-		t.Fatal("metamod.2.blade.so file not found ", err)
+	if err := pluginsInstance.InstallPluginByName(context.Background(), "plugin-a", "1.0"); err != nil {
+		t.Fatal("InstallPluginByName plugin-a", err)
 	}
 
-	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "metamod", "counterstrikesharp.vdf")); err != nil {
-		t.Fatal("counterstrikesharp.vdf file not found ", err)
+	if err := pluginsInstance.InstallPluginByName(context.Background(), "plugin-b", "1.0"); err != nil {
+		t.Fatal("InstallPluginByName plugin-b", err)
 	}
 
-	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "counterstrikesharp", "bin", "linuxsteamrt64", "counterstrikesharp.so")); err != nil {
-		t.Fatal("counterstrikesharp.so file not found ", err)
+	if err := pluginsInstance.InstallPluginByName(context.Background(), "plugin-b", "1.0"); !errors.Is(err, plugins.ErrPluginAlreadyInstalled) {
+		t.Fatal("expected ErrPluginAlreadyInstalled but got", err)
 	}
 
-	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "counterstrikesharp", "plugins", "Cs2PracticeMode", "Cs2PracticeMode.dll")); err != nil {
-		t.Fatal("Cs2PracticeMode.dll file not found ", err)
+	if downloads["/shared.zip"] != 1 {
+		t.Fatalf("shared dependency downloaded %v times", downloads["/shared.zip"])
+	}
+
+	installedPlugins, err := pluginsInstance.GetInstalledPlugins()
+	if err != nil {
+		t.Fatal("GetInstalledPlugins", err)
+	}
+
+	if len(installedPlugins) != 3 {
+		t.Fatalf("expected 3 installed plugins but got %+v", installedPlugins)
+	}
+	expectInstalledPlugin(t, installedPlugins, "shared", "1.0", false)
+	expectInstalledPlugin(t, installedPlugins, "plugin-a", "1.0", true, "shared")
+	expectInstalledPlugin(t, installedPlugins, "plugin-b", "1.0", true, "shared")
+
+	if requiredBy := plugins.RequiredBy(installedPlugins, "shared"); !slices.Equal(requiredBy, []string{"plugin-a", "plugin-b"}) {
+		t.Fatal("unexpected required by", requiredBy)
+	}
+
+	if err := pluginsInstance.Uninstall("shared"); !errors.Is(err, plugins.ErrPluginRequired) {
+		t.Fatal("expected ErrPluginRequired but got", err)
+	}
+
+	if err := pluginsInstance.Uninstall("plugin-a"); err != nil {
+		t.Fatal("Uninstall plugin-a", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "plugins", "a", "a.dll")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("file of plugin-a still exists", err)
+	}
+
+	// still required by plugin-b
+	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "shared", "shared.so")); err != nil {
+		t.Fatal("file of the shared dependency removed", err)
+	}
+
+	if err := pluginsInstance.Uninstall("plugin-b"); err != nil {
+		t.Fatal("Uninstall plugin-b", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(csgoDir, "addons", "shared", "shared.so")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("file of the shared dependency still exists", err)
+	}
+
+	installedPlugins, err = pluginsInstance.GetInstalledPlugins()
+	if err != nil {
+		t.Fatal("GetInstalledPlugins", err)
+	}
+
+	if len(installedPlugins) != 0 {
+		t.Fatalf("expected no installed plugins but got %+v", installedPlugins)
+	}
+
+	if err := pluginsInstance.Uninstall("plugin-b"); !errors.Is(err, plugins.ErrPluginNotInstalled) {
+		t.Fatal("expected ErrPluginNotInstalled but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			if err := os.RemoveAll(tempDirPath); err != nil {
+				t.Log("success but failed to cleanup test dir: ", tempDirPath)
+			}
+		}()
+	}
+}
+
+func TestNew_MigrateLegacyInstalledPlugin(t *testing.T) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+	if err := os.MkdirAll(csgoDir, os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+
+	legacyInstalledPlugin := `{
+    "name": "Cs2PracticeMode",
+    "version": "0.0.16",
+    "installed_at_utc": "2024-10-01T10:00:00Z",
+    "files": ["/addons/counterstrikesharp/plugins/Cs2PracticeMode/Cs2PracticeMode.dll"],
+    "dependencies": [
+        {
+            "name": "CounterStrikeSharp",
+            "version": "v264",
+            "installed_at_utc": "2024-10-01T10:00:00Z",
+            "files": ["/addons/counterstrikesharp/api/CounterStrikeSharp.API.dll"],
+            "dependencies": [
+                {
+                    "name": "metamod_source",
+                    "version": "2.0.0-git1313",
+                    "installed_at_utc": "2024-10-01T10:00:00Z",
+                    "files": ["/addons/metamod.vdf"],
+                    "dependencies": null
+                }
+            ]
+        }
+    ]
+}`
+
+	if err := plugins.ValidateInstalledPluginJson([]byte(legacyInstalledPlugin)); err != nil {
+		t.Fatal("ValidateInstalledPluginJson", err)
+	}
+
+	installedPluginJsonPath := filepath.Join(tempDirPath, "installed-plugin.json")
+	if err := os.WriteFile(installedPluginJsonPath, []byte(legacyInstalledPlugin), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	pluginsInstance, err := plugins.New(plugins.Config{
+		CsgoDir:                 csgoDir,
+		PluginsJsonFilePath:     filepath.Join(tempDirPath, "plugins.json"),
+		InstalledPluginJsonPath: installedPluginJsonPath,
+	})
+	if err != nil {
+		t.Fatal("plugins.New", err)
+	}
+
+	installedPlugins, err := pluginsInstance.GetInstalledPlugins()
+	if err != nil {
+		t.Fatal("GetInstalledPlugins", err)
+	}
+
+	if len(installedPlugins) != 3 {
+		t.Fatalf("expected 3 installed plugins but got %+v", installedPlugins)
+	}
+	expectInstalledPlugin(t, installedPlugins, "metamod_source", "2.0.0-git1313", false)
+	expectInstalledPlugin(t, installedPlugins, "CounterStrikeSharp", "v264", false, "metamod_source")
+	expectInstalledPlugin(t, installedPlugins, "Cs2PracticeMode", "0.0.16", true, "CounterStrikeSharp")
+
+	// the file is rewritten in the list format
+	content, err := os.ReadFile(installedPluginJsonPath)
+	if err != nil {
+		t.Fatal("os.ReadFile", err)
+	}
+
+	if !strings.HasPrefix(string(content), "[") {
+		t.Fatal("installed plugin json file not migrated", string(content))
 	}
 
 	if !t.Failed() {
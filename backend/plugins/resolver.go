@@ -0,0 +1,321 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrDependencyConflict = errors.New("dependency conflict")
+	ErrDependencyCycle    = errors.New("dependency cycle")
+)
+
+type PlanAction string
+
+const (
+	// PlanActionInstall downloads and installs the plugin
+	PlanActionInstall PlanAction = "install"
+	// PlanActionKeep uses the already installed version of the plugin
+	PlanActionKeep PlanAction = "keep"
+	// PlanActionMarkExplicit marks a plugin that was only installed as dependency as explicitly installed
+	PlanActionMarkExplicit PlanAction = "mark_explicit"
+)
+
+type PlanStep struct {
+	Name    string     `json:"name"`
+	Version string     `json:"version"`
+	Action  PlanAction `json:"action"`
+	// names of the direct dependencies of the plugin
+	Dependencies []string `json:"dependencies"`
+	// names of the plugins of the plan that depend on the plugin
+	RequiredBy []string `json:"required_by"`
+}
+
+// Plan contains the requested plugin and all of its dependencies in install order, dependencies first
+type Plan struct {
+	Name    string     `json:"name"`
+	Version string     `json:"version"`
+	Steps   []PlanStep `json:"steps"`
+}
+
+// resolver selects a version for every dependency that is not installed yet.
+// The newest version matching all constraints is preferred. If the selection leads to a conflict, older versions are tried
+type resolver struct {
+	catalog   map[string]Plugin
+	installed map[string]InstalledPlugin
+}
+
+type resolvedConstraint struct {
+	constraint VersionConstraint
+	requiredBy string
+}
+
+type pendingRequirement struct {
+	name       string
+	constraint VersionConstraint
+	requiredBy string
+	// names of the plugins from the requested plugin to the plugin that has the requirement
+	path []string
+}
+
+type resolution struct {
+	selected    map[string]Version
+	constraints map[string][]resolvedConstraint
+}
+
+func (r resolution) clone() resolution {
+	return resolution{
+		selected:    maps.Clone(r.selected),
+		constraints: maps.Clone(r.constraints),
+	}
+}
+
+func newResolver(plugins []Plugin, installedPlugins []InstalledPlugin) resolver {
+	r := resolver{
+		catalog:   make(map[string]Plugin, len(plugins)),
+		installed: make(map[string]InstalledPlugin, len(installedPlugins)),
+	}
+
+	for _, plugin := range plugins {
+		r.catalog[plugin.Name] = plugin
+	}
+
+	for _, installedPlugin := range installedPlugins {
+		r.installed[installedPlugin.Name] = installedPlugin
+	}
+
+	return r
+}
+
+func (r resolver) plan(pluginName string, versionName string) (Plan, error) {
+	plugin, ok := r.catalog[pluginName]
+	if !ok {
+		return Plan{}, fmt.Errorf("%w: no plugin with name %v found", ErrPluginNotFound, pluginName)
+	}
+
+	index := slices.IndexFunc(plugin.Versions, func(v Version) bool {
+		return v.Name == versionName
+	})
+	if index == -1 {
+		return Plan{}, fmt.Errorf("%w: plugin found %v but version %v dose not exists in plugin", ErrPluginNotFound, pluginName, versionName)
+	}
+	version := plugin.Versions[index]
+
+	plan := Plan{Name: pluginName, Version: versionName, Steps: make([]PlanStep, 0)}
+
+	if installedPlugin, ok := r.installed[pluginName]; ok {
+		if installedPlugin.Version != versionName {
+			return Plan{}, fmt.Errorf("%w: '%v' version '%v' is installed", ErrVersionConflict, installedPlugin.Name, installedPlugin.Version)
+		}
+
+		action := PlanActionKeep
+		if !installedPlugin.Explicit {
+			action = PlanActionMarkExplicit
+		}
+
+		plan.Steps = append(r.installedSteps(installedPlugin.Dependencies, make(map[string]bool)), PlanStep{
+			Name:         installedPlugin.Name,
+			Version:      installedPlugin.Version,
+			Action:       action,
+			Dependencies: installedPlugin.Dependencies,
+		})
+		setRequiredBy(plan.Steps)
+		return plan, nil
+	}
+
+	state := resolution{
+		selected:    map[string]Version{pluginName: version},
+		constraints: make(map[string][]resolvedConstraint),
+	}
+
+	requirements, err := pendingRequirements(pluginName, version, nil)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	state, err = r.resolve(state, requirements)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan.Steps = r.steps(state, pluginName, make(map[string]bool))
+	setRequiredBy(plan.Steps)
+	return plan, nil
+}
+
+func pendingRequirements(pluginName string, version Version, path []string) ([]pendingRequirement, error) {
+	result := make([]pendingRequirement, 0, len(version.Requires))
+	for _, requirement := range version.Requires {
+		constraint, err := ParseVersionConstraint(requirement.Version)
+		if err != nil {
+			return nil, fmt.Errorf("requirement '%v' of '%v' version '%v': %w", requirement.Name, pluginName, version.Name, err)
+		}
+
+		result = append(result, pendingRequirement{
+			name:       requirement.Name,
+			constraint: constraint,
+			requiredBy: fmt.Sprintf("%v %v", pluginName, version.Name),
+			path:       append(slices.Clone(path), pluginName),
+		})
+	}
+
+	return result, nil
+}
+
+func (r resolver) resolve(state resolution, queue []pendingRequirement) (resolution, error) {
+	if len(queue) == 0 {
+		return state, nil
+	}
+
+	pending, queue := queue[0], queue[1:]
+	name := pending.name
+
+	if slices.Contains(pending.path, name) {
+		return state, fmt.Errorf("%w: %v", ErrDependencyCycle, strings.Join(append(slices.Clone(pending.path), name), " -> "))
+	}
+
+	state = state.clone()
+	state.constraints[name] = append(slices.Clone(state.constraints[name]), resolvedConstraint{
+		constraint: pending.constraint,
+		requiredBy: pending.requiredBy,
+	})
+	constraints := state.constraints[name]
+
+	if installedPlugin, ok := r.installed[name]; ok {
+		if !matchesAll(constraints, installedPlugin.Version) {
+			return state, conflictError(name, constraints, fmt.Sprintf("version '%v' is installed", installedPlugin.Version))
+		}
+		return r.resolve(state, queue)
+	}
+
+	if selected, ok := state.selected[name]; ok {
+		if !matchesAll(constraints, selected.Name) {
+			return state, conflictError(name, constraints, fmt.Sprintf("version '%v' is selected", selected.Name))
+		}
+		return r.resolve(state, queue)
+	}
+
+	plugin, ok := r.catalog[name]
+	if !ok {
+		return state, fmt.Errorf("%w: '%v' required by %v is not in the plugins list", ErrDependencyConflict, name, pending.requiredBy)
+	}
+
+	candidates := make([]Version, 0, len(plugin.Versions))
+	for _, version := range plugin.Versions {
+		if matchesAll(constraints, version.Name) {
+			candidates = append(candidates, version)
+		}
+	}
+
+	if len(candidates) == 0 {
+		available := make([]string, 0, len(plugin.Versions))
+		for _, version := range plugin.Versions {
+			available = append(available, version.Name)
+		}
+		return state, conflictError(name, constraints, fmt.Sprintf("no available version matches (%v)", strings.Join(available, ", ")))
+	}
+
+	// newest version first
+	slices.SortStableFunc(candidates, func(a, b Version) int {
+		return compareVersionNames(b.Name, a.Name)
+	})
+
+	var firstErr error
+	for _, candidate := range candidates {
+		requirements, err := pendingRequirements(name, candidate, pending.path)
+		if err != nil {
+			return state, err
+		}
+
+		next := state.clone()
+		next.selected[name] = candidate
+
+		result, err := r.resolve(next, append(slices.Clone(queue), requirements...))
+		if err == nil {
+			return result, nil
+		}
+
+		if errors.Is(err, ErrDependencyCycle) {
+			return state, err
+		}
+
+		// the error of the newest version is the most relevant one
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return state, firstErr
+}
+
+func matchesAll(constraints []resolvedConstraint, versionName string) bool {
+	for _, c := range constraints {
+		if !c.constraint.Matches(versionName) {
+			return false
+		}
+	}
+	return true
+}
+
+func conflictError(name string, constraints []resolvedConstraint, reason string) error {
+	requirements := make([]string, 0, len(constraints))
+	for _, c := range constraints {
+		requirements = append(requirements, fmt.Sprintf("%v requires '%v'", c.requiredBy, c.constraint))
+	}
+
+	return fmt.Errorf("%w: %v: %v but %v", ErrDependencyConflict, name, strings.Join(requirements, ", "), reason)
+}
+
+// steps returns the steps of the plugin and all of its dependencies, dependencies first
+func (r resolver) steps(state resolution, name string, visited map[string]bool) []PlanStep {
+	if visited[name] {
+		return nil
+	}
+	visited[name] = true
+
+	if installedPlugin, ok := r.installed[name]; ok {
+		return append(r.installedSteps(installedPlugin.Dependencies, visited), PlanStep{
+			Name:         installedPlugin.Name,
+			Version:      installedPlugin.Version,
+			Action:       PlanActionKeep,
+			Dependencies: installedPlugin.Dependencies,
+		})
+	}
+
+	version := state.selected[name]
+	dependencies := make([]string, 0, len(version.Requires))
+	result := make([]PlanStep, 0)
+	for _, requirement := range version.Requires {
+		dependencies = append(dependencies, requirement.Name)
+		result = append(result, r.steps(state, requirement.Name, visited)...)
+	}
+
+	return append(result, PlanStep{
+		Name:         name,
+		Version:      version.Name,
+		Action:       PlanActionInstall,
+		Dependencies: dependencies,
+	})
+}
+
+func (r resolver) installedSteps(names []string, visited map[string]bool) []PlanStep {
+	result := make([]PlanStep, 0)
+	for _, name := range names {
+		result = append(result, r.steps(resolution{}, name, visited)...)
+	}
+	return result
+}
+
+func setRequiredBy(steps []PlanStep) {
+	for index := range steps {
+		steps[index].RequiredBy = make([]string, 0)
+		for _, step := range steps {
+			if slices.Contains(step.Dependencies, steps[index].Name) {
+				steps[index].RequiredBy = append(steps[index].RequiredBy, step.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package plugins_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/plugins"
+
+	"github.com/google/uuid"
+)
+
+func testVersion(name string, requires ...plugins.Requirement) plugins.Version {
+	return plugins.Version{
+		Name:        name,
+		DownloadURL: "http://test.test/" + name + ".zip",
+		Requires:    requires,
+	}
+}
+
+func testPlugin(name string, versions ...plugins.Version) plugins.Plugin {
+	return plugins.Plugin{
+		Name:       name,
+		URL:        "http://test.test",
+		InstallDir: "/",
+		Versions:   versions,
+	}
+}
+
+func createResolverTestInstance(t *testing.T, catalog []plugins.Plugin, installedPluginJson string) (*plugins.Instance, string) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+	if err := os.MkdirAll(csgoDir, os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+
+	catalogContent, err := json.Marshal(catalog)
+	if err != nil {
+		t.Fatal("json.Marshal", err)
+	}
+
+	pluginsJsonPath := filepath.Join(tempDirPath, "plugins.json")
+	if err := os.WriteFile(pluginsJsonPath, catalogContent, os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	installedPluginJsonPath := filepath.Join(tempDirPath, "installed-plugin.json")
+	if installedPluginJson != "" {
+		if err := os.WriteFile(installedPluginJsonPath, []byte(installedPluginJson), os.ModePerm); err != nil {
+			t.Fatal("os.WriteFile", err)
+		}
+	}
+
+	pluginsInstance, err := plugins.New(plugins.Config{
+		CsgoDir:                 csgoDir,
+		PluginsJsonFilePath:     pluginsJsonPath,
+		InstalledPluginJsonPath: installedPluginJsonPath,
+		PendingCommandsJsonPath: filepath.Join(tempDirPath, "plugin-commands.json"),
+	})
+	if err != nil {
+		t.Fatal("plugins.New", err)
+	}
+
+	return pluginsInstance, tempDirPath
+}
+
+func planSummary(plan plugins.Plan) string {
+	steps := make([]string, 0, len(plan.Steps))
+	for _, step := range plan.Steps {
+		steps = append(steps, fmt.Sprintf("%v %v %v", step.Action, step.Name, step.Version))
+	}
+	return strings.Join(steps, ", ")
+}
+
+func TestInstance_Plan(t *testing.T) {
+	catalog := []plugins.Plugin{
+		testPlugin("metamod", testVersion("2.0.0-git1313"), testVersion("2.0.0-git1314"), testVersion("2.0.0-git1315")),
+		testPlugin("css",
+			testVersion("v264", plugins.Requirement{Name: "metamod", Version: ">=2.0.0-git1313"}),
+			testVersion("v272", plugins.Requirement{Name: "metamod", Version: ">=2.0.0-git1314 <2.0.0-git1315"}),
+		),
+		testPlugin("practice", testVersion("1.0.0", plugins.Requirement{Name: "css", Version: ">=v264"})),
+		// requires an older css, so the newest css must not be selected
+		testPlugin("retakes", testVersion("1.0.0",
+			plugins.Requirement{Name: "css", Version: "*"},
+			plugins.Requirement{Name: "metamod", Version: "2.0.0-git1313"},
+		)),
+	}
+
+	pluginsInstance, tempDirPath := createResolverTestInstance(t, catalog, "")
+
+	plan, err := pluginsInstance.Plan("practice", "1.0.0")
+	if err != nil {
+		t.Fatal("Plan", err)
+	}
+
+	if summary := planSummary(plan); summary != "install metamod 2.0.0-git1314, install css v272, install practice 1.0.0" {
+		t.Fatal("unexpected plan:", summary)
+	}
+
+	if plan.Steps[0].RequiredBy[0] != "css" || plan.Steps[1].Dependencies[0] != "metamod" {
+		t.Fatalf("unexpected plan steps %+v", plan.Steps)
+	}
+
+	plan, err = pluginsInstance.Plan("retakes", "1.0.0")
+	if err != nil {
+		t.Fatal("Plan", err)
+	}
+
+	if summary := planSummary(plan); summary != "install metamod 2.0.0-git1313, install css v264, install retakes 1.0.0" {
+		t.Fatal("unexpected plan after backtracking:", summary)
+	}
+
+	if _, err := pluginsInstance.Plan("practice", "2.0.0"); !errors.Is(err, plugins.ErrPluginNotFound) {
+		t.Fatal("expected ErrPluginNotFound but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Plan_Installed(t *testing.T) {
+	catalog := []plugins.Plugin{
+		testPlugin("metamod", testVersion("2.0.0-git1313"), testVersion("2.0.0-git1314")),
+		testPlugin("css", testVersion("v264", plugins.Requirement{Name: "metamod", Version: "^2.0.0-git1313"})),
+		testPlugin("practice", testVersion("1.0.0", plugins.Requirement{Name: "css", Version: "v264"})),
+		testPlugin("retakes", testVersion("1.0.0", plugins.Requirement{Name: "metamod", Version: "2.0.0-git1314"})),
+	}
+
+	installedPluginJson := `[
+    {"name": "metamod", "version": "2.0.0-git1313", "installed_at_utc": "2024-10-01T10:00:00Z", "files": [], "dependencies": [], "explicit": false},
+    {"name": "css", "version": "v264", "installed_at_utc": "2024-10-01T10:00:00Z", "files": [], "dependencies": ["metamod"], "explicit": true}
+]`
+
+	pluginsInstance, tempDirPath := createResolverTestInstance(t, catalog, installedPluginJson)
+
+	plan, err := pluginsInstance.Plan("practice", "1.0.0")
+	if err != nil {
+		t.Fatal("Plan", err)
+	}
+
+	if summary := planSummary(plan); summary != "keep metamod 2.0.0-git1313, keep css v264, install practice 1.0.0" {
+		t.Fatal("unexpected plan:", summary)
+	}
+
+	plan, err = pluginsInstance.Plan("metamod", "2.0.0-git1313")
+	if err != nil {
+		t.Fatal("Plan", err)
+	}
+
+	if summary := planSummary(plan); summary != "mark_explicit metamod 2.0.0-git1313" {
+		t.Fatal("unexpected plan:", summary)
+	}
+
+	_, err = pluginsInstance.Plan("retakes", "1.0.0")
+	if !errors.Is(err, plugins.ErrDependencyConflict) {
+		t.Fatal("expected ErrDependencyConflict but got", err)
+	}
+
+	if !strings.Contains(err.Error(), "metamod: retakes 1.0.0 requires '2.0.0-git1314' but version '2.0.0-git1313' is installed") {
+		t.Fatal("unexpected error message:", err)
+	}
+
+	if _, err := pluginsInstance.Plan("metamod", "2.0.0-git1314"); !errors.Is(err, plugins.ErrVersionConflict) {
+		t.Fatal("expected ErrVersionConflict but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Plan_ConflictAndCycle(t *testing.T) {
+	catalog := []plugins.Plugin{
+		testPlugin("metamod", testVersion("1.0.0"), testVersion("2.0.0")),
+		testPlugin("a", testVersion("1.0.0", plugins.Requirement{Name: "metamod", Version: "^1.0.0"})),
+		testPlugin("b", testVersion("1.0.0", plugins.Requirement{Name: "metamod", Version: "^2.0.0"})),
+		testPlugin("conflict", testVersion("1.0.0",
+			plugins.Requirement{Name: "a", Version: "*"},
+			plugins.Requirement{Name: "b", Version: "*"},
+		)),
+		testPlugin("missing", testVersion("1.0.0", plugins.Requirement{Name: "unknown", Version: "*"})),
+		testPlugin("cycle-a", testVersion("1.0.0", plugins.Requirement{Name: "cycle-b", Version: "*"})),
+		testPlugin("cycle-b", testVersion("1.0.0", plugins.Requirement{Name: "cycle-a", Version: "*"})),
+	}
+
+	pluginsInstance, tempDirPath := createResolverTestInstance(t, catalog, "")
+
+	_, err := pluginsInstance.Plan("conflict", "1.0.0")
+	if !errors.Is(err, plugins.ErrDependencyConflict) {
+		t.Fatal("expected ErrDependencyConflict but got", err)
+	}
+
+	if !strings.Contains(err.Error(), "metamod: a 1.0.0 requires '^1.0.0', b 1.0.0 requires '^2.0.0' but version '1.0.0' is selected") {
+		t.Fatal("unexpected error message:", err)
+	}
+
+	if _, err := pluginsInstance.Plan("missing", "1.0.0"); !errors.Is(err, plugins.ErrDependencyConflict) {
+		t.Fatal("expected ErrDependencyConflict for a missing dependency but got", err)
+	}
+
+	_, err = pluginsInstance.Plan("cycle-a", "1.0.0")
+	if !errors.Is(err, plugins.ErrDependencyCycle) {
+		t.Fatal("expected ErrDependencyCycle but got", err)
+	}
+
+	if !strings.Contains(err.Error(), "cycle-a -> cycle-b -> cycle-a") {
+		t.Fatal("unexpected error message:", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
@@ -0,0 +1,339 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// the transaction dir is inside the csgo dir, so staged files can be moved into place
+const transactionDirName = "temp_plugin_transaction"
+
+type transactionState string
+
+const (
+	transactionStatePending   transactionState = "pending"
+	transactionStateCommitted transactionState = "committed"
+)
+
+type journalEntry struct {
+	Path string `json:"path"`
+	// copy of the previous content in the backup dir. Empty if the file did not exist
+	Backup string `json:"backup,omitempty"`
+	// true if the directory was created by the transaction. It is only removed on rollback if it is empty
+	Dir bool `json:"dir,omitempty"`
+}
+
+// journal is written before every change, so an interrupted transaction can be reverted on the next start
+type journal struct {
+	Operation    string           `json:"operation"`
+	State        transactionState `json:"state"`
+	StartedAtUtc time.Time        `json:"started_at_utc"`
+	Entries      []journalEntry   `json:"entries"`
+}
+
+// transaction journals every file that is written, replaced or removed during an install, so all changes can be reverted
+type transaction struct {
+	dir          string
+	journal      journal
+	recorded     map[string]bool
+	stagingCount int
+}
+
+// beginTransaction starts a new transaction. A transaction that was interrupted before is recovered first
+func (i *Instance) beginTransaction(operation string) (*transaction, error) {
+	if err := i.recoverTransaction(); err != nil {
+		return nil, fmt.Errorf("failed to recover interrupted transaction: %w", err)
+	}
+
+	tx := &transaction{
+		dir: filepath.Join(i.csgoDir, transactionDirName),
+		journal: journal{
+			Operation:    operation,
+			State:        transactionStatePending,
+			StartedAtUtc: time.Now().UTC(),
+			Entries:      []journalEntry{},
+		},
+		recorded: make(map[string]bool),
+	}
+
+	if err := os.MkdirAll(filepath.Join(tx.dir, "backup"), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll transaction dir: %w", err)
+	}
+
+	if err := tx.writeJournal(); err != nil {
+		_ = os.RemoveAll(tx.dir)
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// recoverTransaction finishes a committed transaction or reverts a pending one. Called on startup and before each transaction
+func (i *Instance) recoverTransaction() error {
+	dir := filepath.Join(i.csgoDir, transactionDirName)
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("os.Stat: %w", err)
+	}
+
+	tx := &transaction{dir: dir}
+	content, err := os.ReadFile(tx.journalPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.ReadFile journal: %w", err)
+	}
+
+	// without journal nothing was changed yet
+	if err == nil {
+		if err := json.Unmarshal(content, &tx.journal); err != nil {
+			return fmt.Errorf("json.Unmarshal journal: %w", err)
+		}
+	}
+
+	if tx.journal.State == transactionStatePending && len(tx.journal.Entries) > 0 {
+		slog.Warn("reverting interrupted plugin transaction", "operation", tx.journal.Operation, "started_at_utc", tx.journal.StartedAtUtc)
+		return tx.rollback()
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("os.RemoveAll transaction dir: %w", err)
+	}
+	return nil
+}
+
+func (tx *transaction) journalPath() string {
+	return filepath.Join(tx.dir, "journal.json")
+}
+
+// writeJournal replaces the journal file atomically
+func (tx *transaction) writeJournal() error {
+	content, err := json.Marshal(tx.journal)
+	if err != nil {
+		return fmt.Errorf("json.Marshal journal: %w", err)
+	}
+
+	tmpPath := tx.journalPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, content, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile journal: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, tx.journalPath()); err != nil {
+		return fmt.Errorf("os.Rename journal: %w", err)
+	}
+
+	return nil
+}
+
+// newStagingDir returns a new empty directory for downloads and extracted archives. It is removed together with the transaction
+func (tx *transaction) newStagingDir() string {
+	tx.stagingCount++
+	return filepath.Join(tx.dir, "staging", strconv.Itoa(tx.stagingCount))
+}
+
+// record journals the files before they are changed. The current content of existing files is copied to the backup dir.
+// Without transaction, e.g. during uninstall, nothing is journaled
+func (tx *transaction) record(paths ...string) error {
+	if tx == nil {
+		return nil
+	}
+
+	for _, path := range paths {
+		if tx.recorded[path] {
+			continue
+		}
+
+		entry := journalEntry{Path: path}
+		info, err := os.Lstat(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("os.Lstat: %w", err)
+		}
+
+		if err == nil {
+			if !info.Mode().IsRegular() {
+				return fmt.Errorf("'%v' is not a regular file", path)
+			}
+
+			entry.Backup = filepath.Join(tx.dir, "backup", strconv.Itoa(len(tx.journal.Entries)))
+			if err := copyFile(path, entry.Backup); err != nil {
+				return fmt.Errorf("failed to back up '%v': %w", path, err)
+			}
+		}
+
+		tx.journal.Entries = append(tx.journal.Entries, entry)
+		tx.recorded[path] = true
+	}
+
+	return tx.writeJournal()
+}
+
+// mkdirAll creates the directories and journals every directory that did not exist
+func (tx *transaction) mkdirAll(dirs ...string) error {
+	created := make([]string, 0)
+	for _, dir := range dirs {
+		for current := filepath.Clean(dir); ; current = filepath.Dir(current) {
+			if _, err := os.Lstat(current); err == nil || current == filepath.Dir(current) {
+				break
+			}
+
+			if !slices.Contains(created, current) {
+				created = append(created, current)
+			}
+		}
+	}
+
+	if len(created) == 0 {
+		return nil
+	}
+
+	// parents first, so the rollback removes children first
+	slices.Sort(created)
+	for _, dir := range created {
+		tx.journal.Entries = append(tx.journal.Entries, journalEntry{Path: dir, Dir: true})
+	}
+
+	if err := tx.writeJournal(); err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("os.MkdirAll: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// placeFiles moves the staged files to their target paths. The files map contains the staged path by target path
+func (tx *transaction) placeFiles(files map[string]string) error {
+	targets := slices.Sorted(maps.Keys(files))
+
+	dirs := make([]string, 0)
+	for _, target := range targets {
+		if dir := filepath.Dir(target); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if err := tx.mkdirAll(dirs...); err != nil {
+		return err
+	}
+
+	if err := tx.record(targets...); err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		if err := os.Rename(files[target], target); err != nil {
+			return fmt.Errorf("failed to move '%v' into place: %w", target, err)
+		}
+	}
+
+	return nil
+}
+
+// remove journals and removes the files
+func (tx *transaction) remove(paths ...string) error {
+	if err := tx.record(paths...); err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("os.Remove: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// commit marks the transaction as committed and removes the backups. After this, the changes can not be reverted anymore
+func (tx *transaction) commit() error {
+	tx.journal.State = transactionStateCommitted
+	if err := tx.writeJournal(); err != nil {
+		return err
+	}
+
+	// a leftover committed transaction is removed on the next start
+	if err := os.RemoveAll(tx.dir); err != nil {
+		slog.Warn("failed to remove committed plugin transaction", "path", tx.dir, "error", err)
+	}
+
+	return nil
+}
+
+// rollback reverts all journaled changes in reverse order. If a change can not be reverted, the transaction dir is kept,
+// so the rollback is tried again on the next start
+func (tx *transaction) rollback() error {
+	var errs []error
+	for index := len(tx.journal.Entries) - 1; index >= 0; index-- {
+		entry := tx.journal.Entries[index]
+		switch {
+		case entry.Dir:
+			// directories that contain files the transaction did not create are kept
+			_ = os.Remove(entry.Path)
+		case entry.Backup != "":
+			if err := copyFile(entry.Backup, entry.Path); err != nil {
+				errs = append(errs, fmt.Errorf("failed to restore '%v': %w", entry.Path, err))
+			}
+		default:
+			if err := os.Remove(entry.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("failed to remove '%v': %w", entry.Path, err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if err := os.RemoveAll(tx.dir); err != nil {
+		return fmt.Errorf("os.RemoveAll transaction dir: %w", err)
+	}
+
+	return nil
+}
+
+// copyFile copies the content and the permissions of the file
+func copyFile(sourcePath string, destPath string) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("source.Stat: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+
+	if _, err := io.Copy(dest, source); err != nil {
+		_ = dest.Close()
+		return fmt.Errorf("io.Copy: %w", err)
+	}
+
+	if err := dest.Close(); err != nil {
+		return fmt.Errorf("dest.Close: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package plugins_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/plugins"
+
+	"github.com/google/uuid"
+)
+
+func expectDirEntries(t *testing.T, dir string, names ...string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal("os.ReadDir", err)
+	}
+
+	actual := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actual = append(actual, entry.Name())
+	}
+
+	if strings.Join(actual, ",") != strings.Join(names, ",") {
+		t.Fatalf("expected %v to contain %v but got %v", dir, names, actual)
+	}
+}
+
+func TestInstall_Rollback(t *testing.T) {
+	archives := map[string][]byte{
+		"/metamod.zip": createZip(t, map[string]string{
+			"addons/metamod/metamod.so": "metamod",
+			"cfg/server.cfg":            "metamod",
+		}),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		archive, ok := archives[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	metamodVersion := testVersion("1.0.0")
+	metamodVersion.DownloadURL = server.URL + "/metamod.zip"
+	missingVersion := testVersion("1.0.0", plugins.Requirement{Name: "metamod_source", Version: "*"})
+	missingVersion.DownloadURL = server.URL + "/missing.zip"
+
+	metamod := testPlugin("metamod_source", metamodVersion)
+	metamod.Actions = []plugins.Action{metamodAction}
+
+	catalog := []plugins.Plugin{metamod, testPlugin("plugin", missingVersion)}
+
+	testData := []struct {
+		name string
+		// gameinfo.gi without SearchPaths
+		invalidGameinfo bool
+		install         string
+	}{
+		{name: "failed download after dependency with custom action", install: "plugin"},
+		{name: "failed action", invalidGameinfo: true, install: "metamod_source"},
+	}
+
+	for _, td := range testData {
+		pluginsInstance, tempDirPath := createResolverTestInstance(t, catalog, "")
+		csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+
+		gameinfoPath := filepath.Join(csgoDir, "gameinfo.gi")
+		if td.invalidGameinfo {
+			if err := os.WriteFile(gameinfoPath, []byte("\"GameInfo\"\n{\n}\n"), os.ModePerm); err != nil {
+				t.Fatal("os.WriteFile", err)
+			}
+		} else if err := createGameinfoFile(gameinfoPath); err != nil {
+			t.Fatal("createGameinfoFile", err)
+		}
+
+		serverCfgPath := filepath.Join(csgoDir, "cfg", "server.cfg")
+		if err := os.MkdirAll(filepath.Dir(serverCfgPath), os.ModePerm); err != nil {
+			t.Fatal("os.MkdirAll", err)
+		}
+		if err := os.WriteFile(serverCfgPath, []byte("user"), os.ModePerm); err != nil {
+			t.Fatal("os.WriteFile", err)
+		}
+
+		gameinfoContent, err := os.ReadFile(gameinfoPath)
+		if err != nil {
+			t.Fatal("os.ReadFile", err)
+		}
+
+		if err := pluginsInstance.InstallPluginByName(context.Background(), td.install, "1.0.0"); err == nil {
+			t.Fatalf("%v: expected error", td.name)
+		}
+
+		// the created directories and the transaction dir are removed, the overwritten files are restored
+		expectDirEntries(t, csgoDir, "cfg", "gameinfo.gi")
+		expectFileContent(t, serverCfgPath, "user")
+		expectFileContent(t, gameinfoPath, string(gameinfoContent))
+
+		installedPlugins, err := pluginsInstance.GetInstalledPlugins()
+		if err != nil {
+			t.Fatal("GetInstalledPlugins", err)
+		}
+		if len(installedPlugins) != 0 {
+			t.Fatalf("%v: expected no installed plugins but got %+v", td.name, installedPlugins)
+		}
+
+		if !t.Failed() {
+			_ = os.RemoveAll(tempDirPath)
+		}
+	}
+}
+
+func TestNew_RecoverTransaction(t *testing.T) {
+	for _, state := range []string{"pending", "committed"} {
+		tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+		csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+		transactionDir := filepath.Join(csgoDir, "temp_plugin_transaction")
+		installedPluginJsonPath := filepath.Join(tempDirPath, "installed-plugin.json")
+
+		// state of an install that was interrupted after the files were moved into place
+		files := map[string]string{
+			filepath.Join(csgoDir, "addons", "plugin", "plugin.dll"): "plugin",
+			filepath.Join(csgoDir, "cfg", "server.cfg"):              "plugin",
+			filepath.Join(transactionDir, "backup", "2"):             "user",
+			filepath.Join(transactionDir, "backup", "3"):             "[]",
+			installedPluginJsonPath:                                  `[{"name": "plugin", "version": "1.0.0", "installed_at_utc": "2024-10-01T10:00:00Z", "files": ["/addons/plugin/plugin.dll"], "dependencies": [], "explicit": true}]`,
+		}
+		for path, content := range files {
+			if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+				t.Fatal("os.MkdirAll", err)
+			}
+			if err := os.WriteFile(path, []byte(content), os.ModePerm); err != nil {
+				t.Fatal("os.WriteFile", err)
+			}
+		}
+
+		journal, err := json.Marshal(map[string]any{
+			"operation":      "install plugin 1.0.0",
+			"state":          state,
+			"started_at_utc": "2024-10-01T10:00:00Z",
+			"entries": []map[string]any{
+				{"path": filepath.Join(csgoDir, "addons"), "dir": true},
+				{"path": filepath.Join(csgoDir, "addons", "plugin"), "dir": true},
+				{"path": filepath.Join(csgoDir, "cfg", "server.cfg"), "backup": filepath.Join(transactionDir, "backup", "2")},
+				{"path": installedPluginJsonPath, "backup": filepath.Join(transactionDir, "backup", "3")},
+				{"path": filepath.Join(csgoDir, "addons", "plugin", "plugin.dll")},
+			},
+		})
+		if err != nil {
+			t.Fatal("json.Marshal", err)
+		}
+		if err := os.WriteFile(filepath.Join(transactionDir, "journal.json"), journal, os.ModePerm); err != nil {
+			t.Fatal("os.WriteFile", err)
+		}
+
+		pluginsInstance, err := plugins.New(plugins.Config{
+			CsgoDir:                 csgoDir,
+			PluginsJsonFilePath:     filepath.Join(tempDirPath, "plugins.json"),
+			InstalledPluginJsonPath: installedPluginJsonPath,
+		})
+		if err != nil {
+			t.Fatal("plugins.New", err)
+		}
+
+		installedPlugins, err := pluginsInstance.GetInstalledPlugins()
+		if err != nil {
+			t.Fatal("GetInstalledPlugins", err)
+		}
+
+		if state == "pending" {
+			expectDirEntries(t, csgoDir, "cfg")
+			expectFileContent(t, filepath.Join(csgoDir, "cfg", "server.cfg"), "user")
+			if len(installedPlugins) != 0 {
+				t.Fatalf("expected the interrupted install to be reverted %+v", installedPlugins)
+			}
+		} else {
+			expectDirEntries(t, csgoDir, "addons", "cfg")
+			expectFileContent(t, filepath.Join(csgoDir, "cfg", "server.cfg"), "plugin")
+			expectInstalledPlugin(t, installedPlugins, "plugin", "1.0.0", true)
+		}
+
+		if _, err := os.Stat(transactionDir); !errors.Is(err, os.ErrNotExist) {
+			t.Fatal("transaction dir not removed", err)
+		}
+
+		if !t.Failed() {
+			_ = os.RemoveAll(tempDirPath)
+		}
+	}
+}
+
+func TestInstall_Cancelled(t *testing.T) {
+	requested := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		close(requested)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	catalog := []plugins.Plugin{testPlugin("plugin", plugins.Version{Name: "1.0.0", DownloadURL: server.URL + "/plugin.zip"})}
+	pluginsInstance, tempDirPath := createResolverTestInstance(t, catalog, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-requested
+		cancel()
+	}()
+
+	if err := pluginsInstance.InstallPluginByName(ctx, "plugin", "1.0.0"); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled but got", err)
+	}
+
+	installedPlugins, err := pluginsInstance.GetInstalledPlugins()
+	if err != nil {
+		t.Fatal("GetInstalledPlugins", err)
+	}
+	if len(installedPlugins) != 0 {
+		t.Fatalf("unexpected installed plugins %+v", installedPlugins)
+	}
+
+	// the partial download is removed together with the transaction
+	expectDirEntries(t, filepath.Join(tempDirPath, "game", "csgo"))
+
+	if !t.Failed() {
+		_ = os.RemoveAll(tempDirPath)
+	}
+}
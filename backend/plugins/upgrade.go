@@ -0,0 +1,328 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// file extensions of config files that are kept on upgrade if they were modified after the installation
+var configFileExtensions = []string{".json", ".jsonc", ".cfg", ".conf", ".toml", ".ini", ".yml", ".yaml", ".txt", ".xml", ".vdf"}
+
+type PluginUpgradeEventsPayload struct {
+	Name        string `json:"name"`
+	FromVersion string `json:"from_version"`
+	ToVersion   string `json:"to_version"`
+	// only set for failed upgrades
+	Error string `json:"error,omitempty"`
+}
+
+type UpgradeResult struct {
+	Name        string `json:"name"`
+	FromVersion string `json:"from_version"`
+	ToVersion   string `json:"to_version"`
+	// config files that were modified after the installation and therefore not replaced or removed
+	PreservedFiles []string `json:"preserved_files"`
+	// dependencies of the new version that were not installed yet
+	InstalledDependencies []string `json:"installed_dependencies"`
+	// dependencies of the old version that are not required anymore
+	UninstalledDependencies []string `json:"uninstalled_dependencies"`
+}
+
+// Upgrade switches an installed plugin to another version of the plugins list. Downgrades work the same way.
+// The new version is downloaded and extracted to a staging dir first, so a failed download or an invalid archive does not touch the installed files.
+// Config files that were modified after the installation are kept. If any step fails, all changes are reverted.
+// The downloads are stopped if the context is cancelled
+func (i *Instance) Upgrade(ctx context.Context, pluginName string, versionName string) (UpgradeResult, error) {
+	if i.running.Load() {
+		return UpgradeResult{}, fmt.Errorf("another plugin is currently being installed/uninstalled")
+	}
+
+	i.running.Store(true)
+	defer i.running.Store(false)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	installedPlugins, err := i.GetInstalledPlugins()
+	if err != nil {
+		return UpgradeResult{}, fmt.Errorf("failed to get installed plugins: %w", err)
+	}
+
+	index := indexOfInstalledPlugin(installedPlugins, pluginName)
+	if index == -1 {
+		return UpgradeResult{}, fmt.Errorf("%w: '%v'", ErrPluginNotInstalled, pluginName)
+	}
+	current := installedPlugins[index]
+
+	if current.Version == versionName {
+		return UpgradeResult{}, fmt.Errorf("%w: '%v' version '%v'", ErrPluginAlreadyInstalled, pluginName, versionName)
+	}
+
+	// the plugins list can be refreshed during the upgrade
+	availablePlugins := i.GetAllAvailablePlugins()
+	plugin, version, err := getPluginAndVersionByName(availablePlugins, pluginName, versionName)
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+
+	if err := checkDependents(availablePlugins, installedPlugins, pluginName, versionName); err != nil {
+		return UpgradeResult{}, err
+	}
+
+	// the requirements of the new version are resolved as if the plugin was not installed
+	otherPlugins := slices.Delete(slices.Clone(installedPlugins), index, index+1)
+	plan, err := newResolver(availablePlugins, otherPlugins).plan(pluginName, versionName)
+	if err != nil {
+		return UpgradeResult{}, fmt.Errorf("failed to resolve dependencies: %w", err)
+	}
+
+	result := UpgradeResult{
+		Name:                    pluginName,
+		FromVersion:             current.Version,
+		ToVersion:               versionName,
+		PreservedFiles:          []string{},
+		InstalledDependencies:   []string{},
+		UninstalledDependencies: []string{},
+	}
+
+	eventPayload := PluginUpgradeEventsPayload{Name: pluginName, FromVersion: current.Version, ToVersion: versionName}
+	i.onPluginUpgradingEvent.Trigger(eventPayload)
+
+	tx, err := i.beginTransaction(fmt.Sprintf("upgrade %v %v to %v", pluginName, current.Version, versionName))
+	if err != nil {
+		eventPayload.Error = err.Error()
+		i.onPluginUpgradeFailedEvent.Trigger(eventPayload)
+		return UpgradeResult{}, err
+	}
+
+	// the old version and its files are restored, and the new dependencies are removed again
+	fail := func(err error) (UpgradeResult, error) {
+		if rollbackErr := tx.rollback(); rollbackErr != nil {
+			slog.Error("failed to revert upgrade", "plugin", pluginName, "error", rollbackErr)
+			err = errors.Join(err, fmt.Errorf("rollback: %w", rollbackErr))
+		}
+		eventPayload.Error = err.Error()
+		i.onPluginUpgradeFailedEvent.Trigger(eventPayload)
+		return UpgradeResult{}, err
+	}
+
+	// the new version is verified and extracted before anything in the csgo dir is changed
+	staged, err := i.stageDownload(ctx, plugin, version, tx.newStagingDir())
+	if err != nil {
+		return fail(fmt.Errorf("failed to stage '%v' version '%v': %w", pluginName, versionName, err))
+	}
+
+	newDependencies := make([]InstalledPlugin, 0)
+	for _, step := range plan.Steps[:len(plan.Steps)-1] {
+		if step.Action != PlanActionInstall {
+			continue
+		}
+
+		dependencyPlugin, dependencyVersion, err := getPluginAndVersionByName(availablePlugins, step.Name, step.Version)
+		if err != nil {
+			return fail(fmt.Errorf("plugin not found in plugins list: %w", err))
+		}
+
+		archive, err := i.installVersion(ctx, tx, dependencyPlugin, dependencyVersion)
+		if err != nil {
+			return fail(fmt.Errorf("failed to install '%v' version '%v': %w", step.Name, step.Version, err))
+		}
+
+		newDependencies = append(newDependencies, InstalledPlugin{
+			Name:           dependencyPlugin.Name,
+			Version:        dependencyVersion.Name,
+			InstalledAtUtc: time.Now().UTC(),
+			Files:          archive.files,
+			FileHashes:     archive.fileHashes,
+			Sha256:         archive.sha256,
+			Actions:        archive.actions,
+			Dependencies:   step.Dependencies,
+			Explicit:       false,
+		})
+		result.InstalledDependencies = append(result.InstalledDependencies, dependencyPlugin.Name)
+	}
+
+	upgraded, err := i.swapFiles(tx, current, append(slices.Clone(otherPlugins), newDependencies...), staged)
+	if err != nil {
+		return fail(fmt.Errorf("failed to replace the files of '%v': %w", pluginName, err))
+	}
+	result.PreservedFiles = upgraded.preservedFiles
+
+	// actions that are the same for both versions are not executed again
+	actions, err := i.replaceActions(tx, i.appliedActionsOf(current), plugin.Actions)
+	if err != nil {
+		return fail(fmt.Errorf("actions of '%v': %w", pluginName, err))
+	}
+
+	upgradedPlugin := current
+	upgradedPlugin.Version = versionName
+	upgradedPlugin.InstalledAtUtc = time.Now().UTC()
+	upgradedPlugin.Files = upgraded.files
+	upgradedPlugin.FileHashes = upgraded.fileHashes
+	upgradedPlugin.Sha256 = staged.sha256
+	upgradedPlugin.Actions = actions
+	upgradedPlugin.Dependencies = plan.Steps[len(plan.Steps)-1].Dependencies
+
+	// new dependencies are inserted before the plugin, so dependencies are still listed before the plugins that require them
+	updatedPlugins := slices.Clone(installedPlugins)
+	updatedPlugins[index] = upgradedPlugin
+	updatedPlugins = slices.Insert(updatedPlugins, index, newDependencies...)
+
+	if err := i.writeInstalledPluginsJsonFileInTransaction(tx, updatedPlugins); err != nil {
+		return fail(err)
+	}
+
+	if err := tx.commit(); err != nil {
+		return fail(fmt.Errorf("commit: %w", err))
+	}
+
+	// the old version is only removed from disk after the new version is tracked
+	for _, dependency := range current.Dependencies {
+		dependencyIndex := indexOfInstalledPlugin(updatedPlugins, dependency)
+		if dependencyIndex == -1 || updatedPlugins[dependencyIndex].Explicit || len(RequiredBy(updatedPlugins, dependency)) > 0 {
+			continue
+		}
+
+		remainingPlugins, err := i.uninstallInternal(updatedPlugins, dependency)
+		if err != nil {
+			slog.Error("failed to uninstall dependency that is not required anymore", "plugin", pluginName, "dependency", dependency, "error", err)
+		}
+		for _, installedPlugin := range updatedPlugins {
+			if indexOfInstalledPlugin(remainingPlugins, installedPlugin.Name) == -1 {
+				result.UninstalledDependencies = append(result.UninstalledDependencies, installedPlugin.Name)
+			}
+		}
+		updatedPlugins = remainingPlugins
+	}
+
+	if len(result.UninstalledDependencies) > 0 {
+		if err := i.writeInstalledPluginsJsonFile(updatedPlugins); err != nil {
+			slog.Error("failed to write installed plugins after uninstalling dependencies", "plugin", pluginName, "error", err)
+		}
+	}
+
+	i.onPluginUpgradedEvent.Trigger(eventPayload)
+	return result, nil
+}
+
+// checkDependents returns an error if an installed plugin requires a version of the plugin that does not match the new version
+func checkDependents(availablePlugins []Plugin, installedPlugins []InstalledPlugin, pluginName string, versionName string) error {
+	for _, dependentName := range RequiredBy(installedPlugins, pluginName) {
+		dependent := installedPlugins[indexOfInstalledPlugin(installedPlugins, dependentName)]
+
+		// the requirements of uploaded plugins or versions that were removed from the plugins list are unknown
+		_, dependentVersion, err := getPluginAndVersionByName(availablePlugins, dependent.Name, dependent.Version)
+		if err != nil {
+			continue
+		}
+
+		for _, requirement := range dependentVersion.Requires {
+			if requirement.Name != pluginName {
+				continue
+			}
+
+			constraint, err := ParseVersionConstraint(requirement.Version)
+			if err != nil {
+				return err
+			}
+
+			if !constraint.Matches(versionName) {
+				return fmt.Errorf("%w: %v %v requires %v '%v'", ErrDependencyConflict, dependent.Name, dependent.Version, pluginName, requirement.Version)
+			}
+		}
+	}
+
+	return nil
+}
+
+type swappedFiles struct {
+	files          []string
+	fileHashes     map[string]string
+	preservedFiles []string
+}
+
+// swapFiles removes the old files of the plugin and moves the staged files into place. Both are journaled in the transaction.
+// Modified config files are kept. Files that are also tracked by other plugins are only replaced if the new version contains them
+func (i *Instance) swapFiles(tx *transaction, current InstalledPlugin, otherPlugins []InstalledPlugin, staged stagedArchive) (swappedFiles, error) {
+	result := swappedFiles{
+		files:          make([]string, 0, len(staged.files)),
+		fileHashes:     make(map[string]string, len(staged.files)),
+		preservedFiles: make([]string, 0),
+	}
+
+	oldFiles := make(map[string]string, len(current.Files))
+	for _, file := range current.Files {
+		oldFiles[filepath.Join(i.csgoDir, file)] = file
+	}
+
+	filesToRemove := make([]string, 0)
+	for _, target := range slices.Sorted(maps.Keys(oldFiles)) {
+		file := oldFiles[target]
+		if i.isModifiedConfigFile(current, file) {
+			result.preservedFiles = append(result.preservedFiles, file)
+			continue
+		}
+
+		// files of the new version are replaced instead
+		if _, ok := staged.files[target]; ok || isTrackedByAny(otherPlugins, file) {
+			continue
+		}
+
+		filesToRemove = append(filesToRemove, target)
+	}
+
+	if err := tx.remove(filesToRemove...); err != nil {
+		return swappedFiles{}, err
+	}
+
+	filesToPlace := make(map[string]string, len(staged.files))
+	for _, target := range slices.Sorted(maps.Keys(staged.files)) {
+		file := strings.Replace(target, i.csgoDir, "", 1)
+		result.files = append(result.files, file)
+		result.fileHashes[file] = staged.fileHashes[target]
+
+		// the modified file stays in place. The hash of the new version is recorded, so it is still detected as modified
+		if oldFile, ok := oldFiles[target]; ok && slices.Contains(result.preservedFiles, oldFile) {
+			continue
+		}
+
+		filesToPlace[target] = staged.files[target]
+	}
+
+	if err := tx.placeFiles(filesToPlace); err != nil {
+		return swappedFiles{}, err
+	}
+
+	return result, nil
+}
+
+// isModifiedConfigFile returns true if the file is a config file and its content differs from the content at install time.
+// Files without a recorded hash are never treated as modified
+func (i *Instance) isModifiedConfigFile(installedPlugin InstalledPlugin, file string) bool {
+	if !isConfigFile(file) {
+		return false
+	}
+
+	installHash, ok := installedPlugin.FileHashes[file]
+	if !ok {
+		return false
+	}
+
+	currentHash, err := fileSha256(filepath.Join(i.csgoDir, file))
+	if err != nil {
+		return false
+	}
+
+	return currentHash != installHash
+}
+
+func isConfigFile(file string) bool {
+	return slices.Contains(configFileExtensions, strings.ToLower(filepath.Ext(file)))
+}
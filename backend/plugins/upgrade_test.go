@@ -0,0 +1,222 @@
+package plugins_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/plugins"
+)
+
+func expectFileContent(t *testing.T, path string, content string) {
+	actual, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("os.ReadFile", err)
+	}
+
+	if string(actual) != content {
+		t.Fatalf("expected '%v' to contain '%v' but got '%v'", path, content, string(actual))
+	}
+}
+
+func expectFileNotExists(t *testing.T, path string) {
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected '%v' to not exist but got %v", path, err)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	archives := map[string][]byte{
+		"/lib.zip": createZip(t, map[string]string{"addons/lib/lib.dll": "lib"}),
+		"/plugin-1.zip": createZip(t, map[string]string{
+			"addons/plugin/plugin.dll":    "1",
+			"addons/plugin/old.dll":       "old",
+			"addons/configs/plugin.json":  "default 1",
+			"addons/configs/core.cfg":     "default 1",
+			"addons/configs/removed.cfg":  "default 1",
+			"addons/configs/modified.cfg": "default 1",
+		}),
+		"/plugin-2.zip": createZip(t, map[string]string{
+			"addons/plugin/plugin.dll":   "2",
+			"addons/plugin/new.dll":      "new",
+			"addons/configs/plugin.json": "default 2",
+			"addons/configs/core.cfg":    "default 2",
+		}),
+		"/plugin-3.zip": createZip(t, map[string]string{"addons/plugin/plugin.dll": "3"}),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		archive, ok := archives[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	version := func(name string, path string, requires ...plugins.Requirement) plugins.Version {
+		v := testVersion(name, requires...)
+		v.DownloadURL = server.URL + path
+		return v
+	}
+
+	// the hash of version 3.0.0 does not match the archive
+	brokenVersion := version("3.0.0", "/plugin-3.zip")
+	brokenVersion.Sha256 = strings.Repeat("0", 64)
+
+	catalog := []plugins.Plugin{
+		testPlugin("lib", version("1.0.0", "/lib.zip")),
+		testPlugin("plugin",
+			version("1.0.0", "/plugin-1.zip"),
+			version("2.0.0", "/plugin-2.zip", plugins.Requirement{Name: "lib", Version: "*"}),
+			brokenVersion,
+		),
+		testPlugin("dependent", version("1.0.0", "/lib.zip", plugins.Requirement{Name: "plugin", Version: "^1.0.0"})),
+	}
+
+	pluginsInstance, tempDirPath := createResolverTestInstance(t, catalog, "")
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+	pluginDir := filepath.Join(csgoDir, "addons", "plugin")
+	configsDir := filepath.Join(csgoDir, "addons", "configs")
+
+	if err := pluginsInstance.InstallPluginByName(context.Background(), "plugin", "1.0.0"); err != nil {
+		t.Fatal("InstallPluginByName", err)
+	}
+
+	// user modifications
+	if err := os.WriteFile(filepath.Join(configsDir, "plugin.json"), []byte("user"), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+	if err := os.WriteFile(filepath.Join(configsDir, "modified.cfg"), []byte("user"), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	if _, err := pluginsInstance.Upgrade(context.Background(), "plugin", "1.0.0"); !errors.Is(err, plugins.ErrPluginAlreadyInstalled) {
+		t.Fatal("expected ErrPluginAlreadyInstalled but got", err)
+	}
+
+	if _, err := pluginsInstance.Upgrade(context.Background(), "lib", "1.0.0"); !errors.Is(err, plugins.ErrPluginNotInstalled) {
+		t.Fatal("expected ErrPluginNotInstalled but got", err)
+	}
+
+	if _, err := pluginsInstance.Upgrade(context.Background(), "plugin", "4.0.0"); !errors.Is(err, plugins.ErrPluginNotFound) {
+		t.Fatal("expected ErrPluginNotFound but got", err)
+	}
+
+	result, err := pluginsInstance.Upgrade(context.Background(), "plugin", "2.0.0")
+	if err != nil {
+		t.Fatal("Upgrade", err)
+	}
+
+	if result.FromVersion != "1.0.0" || result.ToVersion != "2.0.0" || !slices.Equal(result.InstalledDependencies, []string{"lib"}) {
+		t.Fatalf("unexpected upgrade result %+v", result)
+	}
+
+	if len(result.PreservedFiles) != 2 {
+		t.Fatalf("expected the two modified config files to be preserved %+v", result.PreservedFiles)
+	}
+
+	expectFileContent(t, filepath.Join(pluginDir, "plugin.dll"), "2")
+	expectFileContent(t, filepath.Join(pluginDir, "new.dll"), "new")
+	expectFileContent(t, filepath.Join(configsDir, "plugin.json"), "user")
+	expectFileContent(t, filepath.Join(configsDir, "core.cfg"), "default 2")
+	expectFileContent(t, filepath.Join(configsDir, "modified.cfg"), "user")
+	expectFileContent(t, filepath.Join(csgoDir, "addons", "lib", "lib.dll"), "lib")
+	expectFileNotExists(t, filepath.Join(pluginDir, "old.dll"))
+	expectFileNotExists(t, filepath.Join(configsDir, "removed.cfg"))
+
+	installedPlugins, err := pluginsInstance.GetInstalledPlugins()
+	if err != nil {
+		t.Fatal("GetInstalledPlugins", err)
+	}
+	expectInstalledPlugin(t, installedPlugins, "lib", "1.0.0", false)
+	expectInstalledPlugin(t, installedPlugins, "plugin", "2.0.0", true, "lib")
+	if installedPlugins[0].Name != "lib" {
+		t.Fatalf("dependency is not listed before the plugin %+v", installedPlugins)
+	}
+	for _, installedPlugin := range installedPlugins {
+		if installedPlugin.Name == "plugin" && (len(installedPlugin.Files) != 4 || slices.ContainsFunc(installedPlugin.Files, func(file string) bool {
+			return strings.HasSuffix(file, "modified.cfg")
+		})) {
+			t.Fatalf("unexpected tracked files %v", installedPlugin.Files)
+		}
+	}
+
+	// the installed version stays untouched if the new version can not be verified
+	if _, err := pluginsInstance.Upgrade(context.Background(), "plugin", "3.0.0"); !errors.Is(err, plugins.ErrIntegrity) {
+		t.Fatal("expected ErrIntegrity but got", err)
+	}
+	expectFileContent(t, filepath.Join(pluginDir, "plugin.dll"), "2")
+	expectFileContent(t, filepath.Join(pluginDir, "new.dll"), "new")
+
+	installedPlugins, err = pluginsInstance.GetInstalledPlugins()
+	if err != nil {
+		t.Fatal("GetInstalledPlugins", err)
+	}
+	expectInstalledPlugin(t, installedPlugins, "plugin", "2.0.0", true, "lib")
+
+	// a missing download does not touch the installed version either
+	delete(archives, "/plugin-1.zip")
+	if _, err := pluginsInstance.Upgrade(context.Background(), "plugin", "1.0.0"); err == nil {
+		t.Fatal("expected error for missing download")
+	}
+	expectFileContent(t, filepath.Join(pluginDir, "plugin.dll"), "2")
+	archives["/plugin-1.zip"] = createZip(t, map[string]string{
+		"addons/plugin/plugin.dll":   "1",
+		"addons/configs/plugin.json": "default 1",
+	})
+
+	// downgrade
+	result, err = pluginsInstance.Upgrade(context.Background(), "plugin", "1.0.0")
+	if err != nil {
+		t.Fatal("Upgrade downgrade", err)
+	}
+
+	if !slices.Equal(result.UninstalledDependencies, []string{"lib"}) || !slices.Equal(result.PreservedFiles, []string{"/addons/configs/plugin.json"}) {
+		t.Fatalf("unexpected downgrade result %+v", result)
+	}
+
+	expectFileContent(t, filepath.Join(pluginDir, "plugin.dll"), "1")
+	expectFileContent(t, filepath.Join(configsDir, "plugin.json"), "user")
+	expectFileNotExists(t, filepath.Join(pluginDir, "new.dll"))
+	expectFileNotExists(t, filepath.Join(configsDir, "core.cfg"))
+	expectFileNotExists(t, filepath.Join(csgoDir, "addons", "lib", "lib.dll"))
+
+	installedPlugins, err = pluginsInstance.GetInstalledPlugins()
+	if err != nil {
+		t.Fatal("GetInstalledPlugins", err)
+	}
+	if len(installedPlugins) != 1 {
+		t.Fatalf("expected only the plugin to be installed %+v", installedPlugins)
+	}
+	expectInstalledPlugin(t, installedPlugins, "plugin", "1.0.0", true)
+
+	// installed plugins that require the current version prevent the upgrade
+	if err := pluginsInstance.InstallPluginByName(context.Background(), "dependent", "1.0.0"); err != nil {
+		t.Fatal("InstallPluginByName dependent", err)
+	}
+	if _, err := pluginsInstance.Upgrade(context.Background(), "plugin", "2.0.0"); !errors.Is(err, plugins.ErrDependencyConflict) {
+		t.Fatal("expected ErrDependencyConflict but got", err)
+	}
+
+	entries, err := os.ReadDir(csgoDir)
+	if err != nil {
+		t.Fatal("os.ReadDir", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "addons" {
+			t.Fatal("staging dir not removed", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/download/unzip"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+)
+
+var ErrInvalidUpload = errors.New("uploaded plugin is not valid")
+
+// Upload is a plugin archive that is not part of the plugins list, e.g. a private plugin
+type Upload struct {
+	Name    string `validate:"required,lt=32"`
+	Version string `validate:"required,lt=32"`
+	// directory the archive is extracted to. "/" is the csgo dir
+	InstallDir string `validate:"required,dirpath,lt=256"`
+	// names of the installed plugins the uploaded plugin depends on. They can not be uninstalled while the uploaded plugin is installed
+	Dependencies []string `validate:"dive,required,lt=32"`
+}
+
+// InstallUpload installs the uploaded archive like a plugin of the plugins list, so it can be uninstalled the same way.
+// The file name has to end with .zip or .tar.gz, but the archive type is detected by the content of the archive
+func (i *Instance) InstallUpload(upload Upload, archive io.Reader, fileName string) error {
+	if i.running.Load() {
+		return fmt.Errorf("another plugin is currently being installed/uninstalled")
+	}
+
+	i.running.Store(true)
+	defer i.running.Store(false)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if upload.Dependencies == nil {
+		upload.Dependencies = []string{}
+	}
+
+	if err := gvalidator.Instance().Struct(upload); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidUpload, err)
+	}
+
+	archiveName := ""
+	for _, extension := range []string{".zip", ".tar.gz"} {
+		if strings.HasSuffix(strings.ToLower(fileName), extension) {
+			archiveName = "upload" + extension
+		}
+	}
+	if archiveName == "" {
+		return fmt.Errorf("%w: file '%v' is neither a .zip nor a .tar.gz archive", ErrInvalidUpload, fileName)
+	}
+
+	if !isInsideDir(i.csgoDir, filepath.Join(i.csgoDir, upload.InstallDir)) {
+		return fmt.Errorf("%w: install dir '%v' is outside of the csgo dir", ErrInvalidUpload, upload.InstallDir)
+	}
+
+	installedPlugins, err := i.GetInstalledPlugins()
+	if err != nil {
+		return fmt.Errorf("failed to get installed plugins: %w", err)
+	}
+
+	if index := indexOfInstalledPlugin(installedPlugins, upload.Name); index != -1 {
+		if installedPlugins[index].Version == upload.Version {
+			return fmt.Errorf("%w: '%v' version '%v'", ErrPluginAlreadyInstalled, upload.Name, upload.Version)
+		}
+		return fmt.Errorf("%w: '%v' version '%v' is installed", ErrVersionConflict, upload.Name, installedPlugins[index].Version)
+	}
+
+	for _, dependency := range upload.Dependencies {
+		if indexOfInstalledPlugin(installedPlugins, dependency) == -1 {
+			return fmt.Errorf("%w: dependency '%v' is not installed", ErrInvalidUpload, dependency)
+		}
+	}
+
+	eventPayload := PluginEventsPayload{Name: upload.Name, Version: upload.Version}
+	i.onPluginInstallingEvent.Trigger(eventPayload)
+
+	tx, err := i.beginTransaction(fmt.Sprintf("upload %v %v", upload.Name, upload.Version))
+	if err != nil {
+		i.onPluginInstallationFailedEvent.Trigger(eventPayload)
+		return err
+	}
+
+	fail := func(err error) error {
+		if rollbackErr := tx.rollback(); rollbackErr != nil {
+			slog.Error("failed to revert installation of uploaded plugin", "plugin", upload.Name, "error", rollbackErr)
+			err = errors.Join(err, fmt.Errorf("rollback: %w", rollbackErr))
+		}
+		i.onPluginInstallationFailedEvent.Trigger(eventPayload)
+		return err
+	}
+
+	installed, err := i.installUploadedArchive(tx, upload, archive, archiveName)
+	if err != nil {
+		return fail(err)
+	}
+
+	installedPlugins = append(installedPlugins, InstalledPlugin{
+		Name:           upload.Name,
+		Version:        upload.Version,
+		InstalledAtUtc: time.Now().UTC(),
+		Files:          installed.files,
+		FileHashes:     installed.fileHashes,
+		Sha256:         installed.sha256,
+		Actions:        installed.actions,
+		Dependencies:   upload.Dependencies,
+		Explicit:       true,
+		Uploaded:       true,
+	})
+
+	if err := i.writeInstalledPluginsJsonFileInTransaction(tx, installedPlugins); err != nil {
+		return fail(err)
+	}
+
+	if err := tx.commit(); err != nil {
+		return fail(fmt.Errorf("commit: %w", err))
+	}
+
+	i.onPluginInstalledEvent.Trigger(eventPayload)
+	return nil
+}
+
+// installUploadedArchive writes the archive to a staging dir of the transaction and installs it
+func (i *Instance) installUploadedArchive(tx *transaction, upload Upload, archive io.Reader, archiveName string) (installedArchive, error) {
+	stagingDir := tx.newStagingDir()
+	if err := os.MkdirAll(stagingDir, os.ModePerm); err != nil {
+		return installedArchive{}, fmt.Errorf("os.MkdirAll staging dir: %w", err)
+	}
+
+	archivePath := filepath.Join(stagingDir, archiveName)
+	if err := writeUploadedArchive(archive, archivePath); err != nil {
+		return installedArchive{}, err
+	}
+
+	sha256, err := fileSha256(archivePath)
+	if err != nil {
+		return installedArchive{}, err
+	}
+
+	staged, err := i.stageArchive(archivePath, sha256, upload.InstallDir, stagingDir)
+	if err != nil {
+		// only problems of the archive are caused by the upload. Errors while writing the files are server errors
+		if isInvalidArchive(err) {
+			return installedArchive{}, fmt.Errorf("%w: %w", ErrInvalidUpload, err)
+		}
+		return installedArchive{}, err
+	}
+
+	// uploaded plugins are not part of the plugins list and have no actions
+	return i.installStaged(tx, upload.Name, nil, staged)
+}
+
+func writeUploadedArchive(archive io.Reader, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("os.Create: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, archive); err != nil {
+		return fmt.Errorf("write uploaded archive: %w", err)
+	}
+
+	return nil
+}
+
+func isInvalidArchive(err error) bool {
+	return errors.Is(err, unzip.ErrInvalidArchive) ||
+		errors.Is(err, unzip.ErrUnknownFormat) ||
+		errors.Is(err, unzip.ErrUnsafeArchive) ||
+		errors.Is(err, unzip.ErrLimitExceeded)
+}
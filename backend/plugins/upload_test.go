@@ -0,0 +1,162 @@
+package plugins_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/plugins"
+
+	"github.com/google/uuid"
+)
+
+func createTarGz(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal("tarWriter.WriteHeader", err)
+		}
+
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatal("tarWriter.Write", err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal("tarWriter.Close", err)
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal("gzipWriter.Close", err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestInstallUpload(t *testing.T) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+	if err := os.MkdirAll(csgoDir, os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+
+	pluginsInstance, err := plugins.New(plugins.Config{
+		CsgoDir:                 csgoDir,
+		PluginsJsonFilePath:     filepath.Join(tempDirPath, "plugins.json"),
+		InstalledPluginJsonPath: filepath.Join(tempDirPath, "installed-plugin.json"),
+	})
+	if err != nil {
+		t.Fatal("plugins.New", err)
+	}
+
+	installDir := "/addons/counterstrikesharp/plugins/"
+	library := plugins.Upload{Name: "library", Version: "1.0.0", InstallDir: installDir}
+	libraryArchive := createTarGz(t, map[string]string{"library/library.dll": "library"})
+	if err := pluginsInstance.InstallUpload(library, bytes.NewReader(libraryArchive), "library.tar.gz"); err != nil {
+		t.Fatal("InstallUpload library", err)
+	}
+
+	private := plugins.Upload{Name: "private", Version: "0.1.0", InstallDir: installDir, Dependencies: []string{"library"}}
+	privateArchive := createZip(t, map[string]string{"private/private.dll": "private", "private/private.json": "{}"})
+	if err := pluginsInstance.InstallUpload(private, bytes.NewReader(privateArchive), "private.ZIP"); err != nil {
+		t.Fatal("InstallUpload private", err)
+	}
+
+	installedPlugins, err := pluginsInstance.GetInstalledPlugins()
+	if err != nil {
+		t.Fatal("GetInstalledPlugins", err)
+	}
+	expectInstalledPlugin(t, installedPlugins, "library", "1.0.0", true)
+	expectInstalledPlugin(t, installedPlugins, "private", "0.1.0", true, "library")
+	for _, installedPlugin := range installedPlugins {
+		if !installedPlugin.Uploaded || installedPlugin.Sha256 == "" {
+			t.Fatalf("uploaded plugin not tracked as upload %+v", installedPlugin)
+		}
+	}
+
+	privateFile := filepath.Join(csgoDir, "addons", "counterstrikesharp", "plugins", "private", "private.dll")
+	if _, err := os.Stat(privateFile); err != nil {
+		t.Fatal("uploaded file not installed", err)
+	}
+
+	testData := []struct {
+		name     string
+		upload   plugins.Upload
+		fileName string
+		err      error
+	}{
+		{name: "same version", upload: private, fileName: "private.zip", err: plugins.ErrPluginAlreadyInstalled},
+		{name: "other version", upload: plugins.Upload{Name: "private", Version: "0.2.0", InstallDir: installDir}, fileName: "private.zip", err: plugins.ErrVersionConflict},
+		{name: "unsupported archive", upload: plugins.Upload{Name: "other", Version: "1.0.0", InstallDir: installDir}, fileName: "other.rar", err: plugins.ErrInvalidUpload},
+		{name: "missing dependency", upload: plugins.Upload{Name: "other", Version: "1.0.0", InstallDir: installDir, Dependencies: []string{"missing"}}, fileName: "other.zip", err: plugins.ErrInvalidUpload},
+		{name: "outside of csgo dir", upload: plugins.Upload{Name: "other", Version: "1.0.0", InstallDir: "/../../"}, fileName: "other.zip", err: plugins.ErrInvalidUpload},
+		{name: "missing name", upload: plugins.Upload{Version: "1.0.0", InstallDir: installDir}, fileName: "other.zip", err: plugins.ErrInvalidUpload},
+	}
+
+	for _, td := range testData {
+		err := pluginsInstance.InstallUpload(td.upload, bytes.NewReader(privateArchive), td.fileName)
+		if !errors.Is(err, td.err) {
+			t.Fatalf("%v: expected %v but got %v", td.name, td.err, err)
+		}
+	}
+
+	other := plugins.Upload{Name: "other", Version: "1.0.0", InstallDir: installDir}
+	if err := pluginsInstance.InstallUpload(other, bytes.NewReader(privateArchive[:len(privateArchive)/2]), "other.zip"); !errors.Is(err, plugins.ErrInvalidUpload) {
+		t.Fatal("truncated archive: expected ErrInvalidUpload but got", err)
+	}
+
+	// the install dir is blocked by a file. This is not a problem of the upload
+	if err := os.WriteFile(filepath.Join(csgoDir, "blocked"), []byte("file"), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+	blocked := plugins.Upload{Name: "other", Version: "1.0.0", InstallDir: "/blocked/"}
+	if err := pluginsInstance.InstallUpload(blocked, bytes.NewReader(privateArchive), "other.zip"); err == nil || errors.Is(err, plugins.ErrInvalidUpload) {
+		t.Fatal("blocked install dir: expected an error that is not ErrInvalidUpload but got", err)
+	}
+	if err := os.Remove(filepath.Join(csgoDir, "blocked")); err != nil {
+		t.Fatal("os.Remove", err)
+	}
+
+	if err := pluginsInstance.Uninstall("library"); !errors.Is(err, plugins.ErrPluginRequired) {
+		t.Fatal("expected ErrPluginRequired but got", err)
+	}
+
+	if err := pluginsInstance.Uninstall("private"); err != nil {
+		t.Fatal("Uninstall private", err)
+	}
+
+	if _, err := os.Stat(privateFile); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("uploaded file not removed", err)
+	}
+
+	// explicitly installed dependencies are kept
+	installedPlugins, err = pluginsInstance.GetInstalledPlugins()
+	if err != nil {
+		t.Fatal("GetInstalledPlugins", err)
+	}
+	if len(installedPlugins) != 1 || installedPlugins[0].Name != "library" {
+		t.Fatalf("expected only library to be installed %+v", installedPlugins)
+	}
+
+	entries, err := os.ReadDir(csgoDir)
+	if err != nil {
+		t.Fatal("os.ReadDir", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "addons" {
+			t.Fatal("temp dir not removed", entry.Name())
+		}
+	}
+}
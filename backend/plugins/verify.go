@@ -0,0 +1,330 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type FileDriftState string
+
+const (
+	FileDriftStateMissing  FileDriftState = "missing"
+	FileDriftStateModified FileDriftState = "modified"
+)
+
+type FileDrift struct {
+	Path  string         `json:"path"`
+	State FileDriftState `json:"state"`
+	// modified config files are changes of the user. They are reported but never replaced by a repair
+	ConfigFile bool `json:"config_file"`
+	Repaired   bool `json:"repaired"`
+}
+
+// ActionDrift is an action of an installed plugin whose change is not in place anymore, e.g. the metamod entry of the gameinfo.gi after a server update
+type ActionDrift struct {
+	Action   Action `json:"action"`
+	Repaired bool   `json:"repaired"`
+	// index of the action in the applied actions of the plugin
+	index int
+}
+
+type PluginVerifyResult struct {
+	Name    string        `json:"name"`
+	Version string        `json:"version"`
+	Files   []FileDrift   `json:"files"`
+	Actions []ActionDrift `json:"actions"`
+	// set if the repair of the plugin failed. All changes of the failed repair are reverted
+	Error string `json:"error,omitempty"`
+}
+
+type VerifyResult struct {
+	// installed plugins with missing or modified files or actions that are not in place anymore
+	Plugins []PluginVerifyResult `json:"plugins"`
+	// files in the directories of the installed plugins that are not tracked by any plugin. They are never removed
+	UnexpectedFiles []string  `json:"unexpected_files"`
+	VerifiedAtUtc   time.Time `json:"verified_at_utc"`
+}
+
+// Verify compares the tracked files of all installed plugins with their hashes at install time and checks that the changes of their actions are still in place.
+// With repair, missing files and modified files that are not config files are restored from the download of the installed version,
+// and the actions that are not in place anymore are executed again. Every plugin is repaired in its own transaction.
+// The downloads of the repair are stopped if the context is cancelled
+func (i *Instance) Verify(ctx context.Context, repair bool) (VerifyResult, error) {
+	if i.running.Load() {
+		return VerifyResult{}, fmt.Errorf("another plugin is currently being installed/uninstalled")
+	}
+
+	i.running.Store(true)
+	defer i.running.Store(false)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	installedPlugins, err := i.GetInstalledPlugins()
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("failed to get installed plugins: %w", err)
+	}
+
+	result := VerifyResult{
+		Plugins:       make([]PluginVerifyResult, 0),
+		VerifiedAtUtc: time.Now().UTC(),
+	}
+
+	for index, installedPlugin := range installedPlugins {
+		pluginResult, err := i.verifyPlugin(installedPlugin)
+		if err != nil {
+			return VerifyResult{}, fmt.Errorf("failed to verify '%v': %w", installedPlugin.Name, err)
+		}
+
+		if len(pluginResult.Files) == 0 && len(pluginResult.Actions) == 0 {
+			continue
+		}
+
+		if repair {
+			installedPlugins, err = i.repairPlugin(ctx, installedPlugins, index, &pluginResult)
+			if err != nil {
+				slog.Error("failed to repair plugin", "plugin", installedPlugin.Name, "error", err)
+				pluginResult.Error = err.Error()
+			}
+		}
+
+		result.Plugins = append(result.Plugins, pluginResult)
+	}
+
+	result.UnexpectedFiles, err = i.unexpectedFiles(installedPlugins)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+
+	return result, nil
+}
+
+func (i *Instance) verifyPlugin(installedPlugin InstalledPlugin) (PluginVerifyResult, error) {
+	result := PluginVerifyResult{
+		Name:    installedPlugin.Name,
+		Version: installedPlugin.Version,
+		Files:   make([]FileDrift, 0),
+		Actions: make([]ActionDrift, 0),
+	}
+
+	for _, file := range installedPlugin.Files {
+		hash, err := fileSha256(filepath.Join(i.csgoDir, file))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				result.Files = append(result.Files, FileDrift{Path: file, State: FileDriftStateMissing, ConfigFile: isConfigFile(file)})
+				continue
+			}
+			return PluginVerifyResult{}, err
+		}
+
+		// plugins installed by older versions have no hashes
+		if installHash, ok := installedPlugin.FileHashes[file]; ok && installHash != hash {
+			result.Files = append(result.Files, FileDrift{Path: file, State: FileDriftStateModified, ConfigFile: isConfigFile(file)})
+		}
+	}
+
+	for index, applied := range i.appliedActionsOf(installedPlugin) {
+		inPlace, err := i.isActionInPlace(applied.Action)
+		if err != nil {
+			slog.Warn("failed to check plugin action", "plugin", installedPlugin.Name, "type", applied.Type, "file", applied.File, "error", err)
+		}
+
+		if !inPlace {
+			result.Actions = append(result.Actions, ActionDrift{Action: applied.Action, index: index})
+		}
+	}
+
+	return result, nil
+}
+
+// isActionInPlace reports whether the change of the action still exists. Server commands are only executed once and are always in place
+func (i *Instance) isActionInPlace(action Action) (bool, error) {
+	path := i.actionPath(action.File)
+
+	var content string
+	switch action.Type {
+	case ActionTypeInsertLine, ActionTypeRemoveLine, ActionTypeSetKeyValue, ActionTypeAddKeyValue:
+		fileContent, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) && action.Type == ActionTypeRemoveLine {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("os.ReadFile: %w", err)
+		}
+		content = string(fileContent)
+	}
+
+	switch action.Type {
+	case ActionTypeInsertLine:
+		return indexOfLine(strings.Split(content, "\n"), action.Line) != -1, nil
+	case ActionTypeRemoveLine:
+		return indexOfLine(strings.Split(content, "\n"), action.Line) == -1, nil
+	case ActionTypeSetKeyValue:
+		node, err := getKeyValuesNode(content, action.Key)
+		if err != nil {
+			return false, err
+		}
+		return node != nil && !node.IsSection() && node.Value == action.Value, nil
+	case ActionTypeAddKeyValue:
+		return containsKeyValue(content, action.Key, action.Value)
+	case ActionTypeCopyFile:
+		if _, err := os.Stat(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return false, nil
+			}
+			return false, fmt.Errorf("os.Stat: %w", err)
+		}
+		return true, nil
+	default:
+		return true, nil
+	}
+}
+
+// repairPlugin restores the files and executes the actions of the verify result again. Returns the updated installed plugins
+func (i *Instance) repairPlugin(ctx context.Context, installedPlugins []InstalledPlugin, index int, result *PluginVerifyResult) ([]InstalledPlugin, error) {
+	installedPlugin := installedPlugins[index]
+
+	filesToRestore := make([]string, 0)
+	for _, drift := range result.Files {
+		if drift.State == FileDriftStateMissing || !drift.ConfigFile {
+			filesToRestore = append(filesToRestore, drift.Path)
+		}
+	}
+
+	if len(filesToRestore) == 0 && len(result.Actions) == 0 {
+		return installedPlugins, nil
+	}
+
+	tx, err := i.beginTransaction(fmt.Sprintf("repair %v %v", installedPlugin.Name, installedPlugin.Version))
+	if err != nil {
+		return installedPlugins, err
+	}
+
+	fail := func(err error) ([]InstalledPlugin, error) {
+		if rollbackErr := tx.rollback(); rollbackErr != nil {
+			slog.Error("failed to revert repair", "plugin", installedPlugin.Name, "error", rollbackErr)
+			err = errors.Join(err, fmt.Errorf("rollback: %w", rollbackErr))
+		}
+		return installedPlugins, err
+	}
+
+	if len(filesToRestore) > 0 {
+		if err := i.restoreFiles(ctx, tx, installedPlugin, filesToRestore); err != nil {
+			return fail(err)
+		}
+	}
+
+	// the applied actions are copied, so legacy plugins without tracked actions get them tracked
+	actions := slices.Clone(i.appliedActionsOf(installedPlugin))
+	for _, drift := range result.Actions {
+		applied, err := i.applyAction(tx, drift.Action)
+		if err != nil {
+			return fail(fmt.Errorf("%v action %v: %w", drift.Action.Type, drift.index+1, err))
+		}
+		actions[drift.index] = applied
+	}
+
+	updatedPlugins := slices.Clone(installedPlugins)
+	updatedPlugins[index].Actions = actions
+	if err := i.writeInstalledPluginsJsonFileInTransaction(tx, updatedPlugins); err != nil {
+		return fail(err)
+	}
+
+	if err := tx.commit(); err != nil {
+		return fail(fmt.Errorf("commit: %w", err))
+	}
+
+	for fileIndex := range result.Files {
+		result.Files[fileIndex].Repaired = slices.Contains(filesToRestore, result.Files[fileIndex].Path)
+	}
+	for actionIndex := range result.Actions {
+		result.Actions[actionIndex].Repaired = true
+	}
+
+	return updatedPlugins, nil
+}
+
+// restoreFiles downloads the installed version again and moves the given files of it into place.
+// The download has to match the hash of the download the plugin was installed from
+func (i *Instance) restoreFiles(ctx context.Context, tx *transaction, installedPlugin InstalledPlugin, files []string) error {
+	if installedPlugin.Uploaded {
+		return errors.New("files of uploaded plugins can not be restored. Upload the plugin again")
+	}
+
+	plugin, version, err := getPluginAndVersionByName(i.GetAllAvailablePlugins(), installedPlugin.Name, installedPlugin.Version)
+	if err != nil {
+		return fmt.Errorf("installed version is not part of the plugins list: %w", err)
+	}
+
+	staged, err := i.stageDownload(ctx, plugin, version, tx.newStagingDir())
+	if err != nil {
+		return fmt.Errorf("failed to stage '%v' version '%v': %w", plugin.Name, version.Name, err)
+	}
+
+	if installedPlugin.Sha256 != "" && staged.sha256 != installedPlugin.Sha256 {
+		return fmt.Errorf("%w: download does not match the download the plugin was installed from", ErrIntegrity)
+	}
+
+	filesToPlace := make(map[string]string, len(files))
+	for _, file := range files {
+		target := filepath.Join(i.csgoDir, file)
+		stagedFile, ok := staged.files[target]
+		if !ok {
+			return fmt.Errorf("'%v' is not part of the download", file)
+		}
+		filesToPlace[target] = stagedFile
+	}
+
+	return tx.placeFiles(filesToPlace)
+}
+
+// unexpectedFiles returns the files in the directories of the tracked files that are neither tracked by a plugin nor copied by an action.
+// The csgo dir itself and subdirectories are not checked
+func (i *Instance) unexpectedFiles(installedPlugins []InstalledPlugin) ([]string, error) {
+	known := make(map[string]bool)
+	dirs := make([]string, 0)
+	for _, installedPlugin := range installedPlugins {
+		for _, file := range installedPlugin.Files {
+			path := filepath.Join(i.csgoDir, file)
+			known[path] = true
+
+			if dir := filepath.Dir(path); dir != filepath.Clean(i.csgoDir) && !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+
+		for _, applied := range installedPlugin.Actions {
+			if applied.Type == ActionTypeCopyFile {
+				known[i.actionPath(applied.File)] = true
+			}
+		}
+	}
+
+	result := make([]string, 0)
+	slices.Sort(dirs)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("os.ReadDir: %w", err)
+		}
+
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if entry.Type().IsRegular() && !known[path] {
+				result = append(result, strings.Replace(path, i.csgoDir, "", 1))
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,174 @@
+package plugins_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/plugins"
+)
+
+func TestVerify(t *testing.T) {
+	archive := createZip(t, map[string]string{
+		"addons/plugin/plugin.dll": "plugin",
+		"addons/plugin/core.dll":   "core",
+		"addons/plugin/plugin.cfg": "default",
+	})
+	changedArchive := createZip(t, map[string]string{
+		"addons/plugin/plugin.dll": "changed",
+		"addons/plugin/core.dll":   "changed",
+		"addons/plugin/plugin.cfg": "changed",
+	})
+
+	var serveChangedArchive atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if serveChangedArchive.Load() {
+			_, _ = w.Write(changedArchive)
+			return
+		}
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	version := testVersion("1.0.0")
+	version.DownloadURL = server.URL + "/plugin.zip"
+	plugin := testPlugin("plugin", version)
+	plugin.Actions = []plugins.Action{metamodAction}
+
+	pluginsInstance, tempDirPath := createResolverTestInstance(t, []plugins.Plugin{plugin}, "")
+	if !t.Failed() {
+		defer os.RemoveAll(tempDirPath)
+	}
+
+	csgoDir := filepath.Join(tempDirPath, "game", "csgo")
+	pluginDir := filepath.Join(csgoDir, "addons", "plugin")
+	gameinfoPath := filepath.Join(csgoDir, "gameinfo.gi")
+	if err := createGameinfoFile(gameinfoPath); err != nil {
+		t.Fatal("createGameinfoFile", err)
+	}
+	gameinfoContent, err := os.ReadFile(gameinfoPath)
+	if err != nil {
+		t.Fatal("os.ReadFile", err)
+	}
+
+	if err := pluginsInstance.InstallPluginByName(context.Background(), "plugin", "1.0.0"); err != nil {
+		t.Fatal("InstallPluginByName", err)
+	}
+	withMetamodEntry, err := os.ReadFile(gameinfoPath)
+	if err != nil {
+		t.Fatal("os.ReadFile", err)
+	}
+
+	result, err := pluginsInstance.Verify(context.Background(), false)
+	if err != nil {
+		t.Fatal("Verify", err)
+	}
+	if len(result.Plugins) != 0 || len(result.UnexpectedFiles) != 0 {
+		t.Fatalf("expected no drift but got %+v", result)
+	}
+
+	// drift caused by the user and a server update
+	if err := os.Remove(filepath.Join(pluginDir, "plugin.dll")); err != nil {
+		t.Fatal("os.Remove", err)
+	}
+	if err := os.WriteFile(filepath.Join(pluginDir, "core.dll"), []byte("broken"), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+	if err := os.WriteFile(filepath.Join(pluginDir, "plugin.cfg"), []byte("user"), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+	if err := os.WriteFile(filepath.Join(pluginDir, "extra.dll"), []byte("extra"), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+	if err := os.WriteFile(gameinfoPath, gameinfoContent, os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	expectDrift := func(result plugins.VerifyResult, repaired bool, errorContains string) {
+		t.Helper()
+
+		if len(result.Plugins) != 1 {
+			t.Fatalf("expected drift of one plugin but got %+v", result.Plugins)
+		}
+
+		pluginResult := result.Plugins[0]
+		if pluginResult.Name != "plugin" || pluginResult.Version != "1.0.0" {
+			t.Fatalf("expected drift of 'plugin' version '1.0.0' but got '%v' version '%v'", pluginResult.Name, pluginResult.Version)
+		}
+
+		if errorContains == "" && pluginResult.Error != "" || !strings.Contains(pluginResult.Error, errorContains) {
+			t.Fatalf("expected error containing '%v' but got '%v'", errorContains, pluginResult.Error)
+		}
+
+		expectedFiles := []plugins.FileDrift{
+			{Path: "/addons/plugin/core.dll", State: plugins.FileDriftStateModified, Repaired: repaired},
+			{Path: "/addons/plugin/plugin.cfg", State: plugins.FileDriftStateModified, ConfigFile: true},
+			{Path: "/addons/plugin/plugin.dll", State: plugins.FileDriftStateMissing, Repaired: repaired},
+		}
+		files := slices.Clone(pluginResult.Files)
+		slices.SortFunc(files, func(a, b plugins.FileDrift) int { return strings.Compare(a.Path, b.Path) })
+		if !slices.Equal(files, expectedFiles) {
+			t.Fatalf("expected files %+v but got %+v", expectedFiles, files)
+		}
+
+		if len(pluginResult.Actions) != 1 || !slices.Equal(pluginResult.Actions[0].Action.Key, metamodAction.Key) || pluginResult.Actions[0].Repaired != repaired {
+			t.Fatalf("expected the metamod action with repaired %v but got %+v", repaired, pluginResult.Actions)
+		}
+
+		if !slices.Equal(result.UnexpectedFiles, []string{"/addons/plugin/extra.dll"}) {
+			t.Fatalf("expected unexpected file '/addons/plugin/extra.dll' but got %v", result.UnexpectedFiles)
+		}
+	}
+
+	result, err = pluginsInstance.Verify(context.Background(), false)
+	if err != nil {
+		t.Fatal("Verify", err)
+	}
+	expectDrift(result, false, "")
+	expectFileNotExists(t, filepath.Join(pluginDir, "plugin.dll"))
+
+	// the download changed since the installation. Nothing is repaired
+	serveChangedArchive.Store(true)
+	result, err = pluginsInstance.Verify(context.Background(), true)
+	if err != nil {
+		t.Fatal("Verify", err)
+	}
+	expectDrift(result, false, plugins.ErrIntegrity.Error())
+	expectFileNotExists(t, filepath.Join(pluginDir, "plugin.dll"))
+	expectFileContent(t, filepath.Join(pluginDir, "core.dll"), "broken")
+	expectFileContent(t, gameinfoPath, string(gameinfoContent))
+
+	serveChangedArchive.Store(false)
+	result, err = pluginsInstance.Verify(context.Background(), true)
+	if err != nil {
+		t.Fatal("Verify", err)
+	}
+	expectDrift(result, true, "")
+
+	// modified config files and unexpected files are kept
+	expectFileContent(t, filepath.Join(pluginDir, "plugin.dll"), "plugin")
+	expectFileContent(t, filepath.Join(pluginDir, "core.dll"), "core")
+	expectFileContent(t, filepath.Join(pluginDir, "plugin.cfg"), "user")
+	expectFileContent(t, filepath.Join(pluginDir, "extra.dll"), "extra")
+	expectFileContent(t, gameinfoPath, string(withMetamodEntry))
+
+	result, err = pluginsInstance.Verify(context.Background(), false)
+	if err != nil {
+		t.Fatal("Verify", err)
+	}
+	if len(result.Plugins) != 1 || len(result.Plugins[0].Files) != 1 || len(result.Plugins[0].Actions) != 0 {
+		t.Fatalf("expected only the modified config file but got %+v", result.Plugins)
+	}
+
+	// the repaired action is reverted on uninstall
+	if err := pluginsInstance.Uninstall("plugin"); err != nil {
+		t.Fatal("Uninstall", err)
+	}
+	expectFileContent(t, gameinfoPath, string(gameinfoContent))
+}
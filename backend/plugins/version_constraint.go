@@ -0,0 +1,323 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidVersionConstraint = errors.New("invalid version constraint")
+
+// semVersion is a semver-style version. The "v" prefix and missing minor and patch numbers are allowed, e.g. "v264" is 264.0.0
+type semVersion struct {
+	major      uint64
+	minor      uint64
+	patch      uint64
+	prerelease []string
+}
+
+// parseSemVersion also returns the number of given version numbers, so "1.2" and "1.2.0" can be handled differently in constraints.
+// Wildcards ("x", "X", "*") end the version, e.g. "1.2.x" has two numbers
+func parseSemVersion(s string) (semVersion, int, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+
+	// build metadata has no precedence
+	s, _, _ = strings.Cut(s, "+")
+	s, prerelease, hasPrerelease := strings.Cut(s, "-")
+
+	var version semVersion
+	if hasPrerelease {
+		if prerelease == "" {
+			return semVersion{}, 0, errors.New("empty prerelease")
+		}
+		version.prerelease = strings.Split(prerelease, ".")
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return semVersion{}, 0, fmt.Errorf("'%v' has more than 3 version numbers", s)
+	}
+
+	numbers := make([]uint64, 0, 3)
+	for _, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+
+		number, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return semVersion{}, 0, fmt.Errorf("'%v' is not a number", part)
+		}
+		numbers = append(numbers, number)
+	}
+
+	if len(numbers) == 0 && s != "x" && s != "X" && s != "*" {
+		return semVersion{}, 0, fmt.Errorf("'%v' has no version number", s)
+	}
+
+	if len(numbers) < 3 && hasPrerelease {
+		return semVersion{}, 0, errors.New("a prerelease requires major, minor and patch version")
+	}
+
+	for i, number := range numbers {
+		switch i {
+		case 0:
+			version.major = number
+		case 1:
+			version.minor = number
+		case 2:
+			version.patch = number
+		}
+	}
+
+	return version, len(numbers), nil
+}
+
+// compare returns -1, 0 or 1 following the semver precedence rules
+func (v semVersion) compare(other semVersion) int {
+	for _, pair := range [][2]uint64{{v.major, other.major}, {v.minor, other.minor}, {v.patch, other.patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// a version without prerelease has a higher precedence
+	if len(v.prerelease) == 0 || len(other.prerelease) == 0 {
+		if len(v.prerelease) == len(other.prerelease) {
+			return 0
+		}
+		if len(v.prerelease) == 0 {
+			return 1
+		}
+		return -1
+	}
+
+	for i := 0; i < len(v.prerelease) && i < len(other.prerelease); i++ {
+		if result := comparePrereleaseIdentifier(v.prerelease[i], other.prerelease[i]); result != 0 {
+			return result
+		}
+	}
+
+	return compareInt(len(v.prerelease), len(other.prerelease))
+}
+
+// comparePrereleaseIdentifier compares numeric identifiers numerically and all other identifiers lexically.
+// Numeric identifiers have a lower precedence than alphanumeric identifiers
+func comparePrereleaseIdentifier(a string, b string) int {
+	aNumber, aErr := strconv.ParseUint(a, 10, 64)
+	bNumber, bErr := strconv.ParseUint(b, 10, 64)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		if aNumber == bNumber {
+			return 0
+		}
+		if aNumber < bNumber {
+			return -1
+		}
+		return 1
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareInt(a int, b int) int {
+	if a == b {
+		return 0
+	}
+	if a < b {
+		return -1
+	}
+	return 1
+}
+
+type versionComparator struct {
+	operator string
+	version  semVersion
+}
+
+func (c versionComparator) check(v semVersion) bool {
+	result := v.compare(c.version)
+	switch c.operator {
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case "!=":
+		return result != 0
+	default:
+		return result == 0
+	}
+}
+
+// VersionConstraint is a semver-style version constraint.
+// Supported are exact versions "1.2.3" or "=1.2.3", comparisons ">=1.2.0", "<2", "!=1.2.5", caret "^1.2.3" (>=1.2.3 <2.0.0),
+// tilde "~1.2.3" (>=1.2.3 <1.3.0), wildcards "1.2.x" and "*". Comparators separated by spaces or commas must all match,
+// alternatives are separated by "||". Prereleases are compared like any other version.
+// Version names that are not semver-style, e.g. "latest", only match a constraint with exactly the same text
+type VersionConstraint struct {
+	text string
+	// one of the groups must match. All comparators of a group must match
+	groups [][]versionComparator
+}
+
+func ParseVersionConstraint(s string) (VersionConstraint, error) {
+	text := strings.TrimSpace(s)
+	constraint := VersionConstraint{text: text}
+
+	if text == "" {
+		return VersionConstraint{}, fmt.Errorf("%w: constraint is empty", ErrInvalidVersionConstraint)
+	}
+
+	for _, group := range strings.Split(text, "||") {
+		fields := strings.FieldsFunc(group, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+		if len(fields) == 0 {
+			return VersionConstraint{}, fmt.Errorf("%w: '%v' contains an empty alternative", ErrInvalidVersionConstraint, text)
+		}
+
+		comparators := make([]versionComparator, 0, len(fields))
+		for _, field := range fields {
+			parsed, err := parseVersionComparator(field)
+			if err != nil {
+				// a single version name that is not semver-style, e.g. "latest", is only matched by name
+				if len(fields) == 1 && !strings.Contains(text, "||") && !strings.ContainsAny(text[:1], "<>=!^~") {
+					return VersionConstraint{text: text}, nil
+				}
+				return VersionConstraint{}, fmt.Errorf("%w: '%v': %w", ErrInvalidVersionConstraint, text, err)
+			}
+			comparators = append(comparators, parsed...)
+		}
+
+		constraint.groups = append(constraint.groups, comparators)
+	}
+
+	return constraint, nil
+}
+
+func parseVersionComparator(s string) ([]versionComparator, error) {
+	operator := ""
+	for _, o := range []string{">=", "<=", "!=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(s, o) {
+			operator = o
+			break
+		}
+	}
+
+	version, numbers, err := parseSemVersion(strings.TrimPrefix(s, operator))
+	if err != nil {
+		return nil, err
+	}
+
+	// a wildcard without operator or with "=" is a range, e.g. "1.2.x" is ">=1.2.0 <1.3.0"
+	if (operator == "" || operator == "=") && numbers < 3 {
+		return wildcardRange(version, numbers), nil
+	}
+
+	if numbers < 3 && (operator == ">" || operator == "<=" || operator == "!=") {
+		return nil, fmt.Errorf("'%v' requires major, minor and patch version", operator)
+	}
+
+	switch operator {
+	case "", "=":
+		return []versionComparator{{operator: "=", version: version}}, nil
+	case "^":
+		upper := semVersion{major: version.major + 1}
+		if version.major == 0 && numbers > 1 {
+			upper = semVersion{minor: version.minor + 1}
+			if version.minor == 0 && numbers > 2 {
+				upper = semVersion{patch: version.patch + 1}
+			}
+		}
+		return []versionComparator{{operator: ">=", version: version}, {operator: "<", version: upper}}, nil
+	case "~":
+		upper := semVersion{major: version.major, minor: version.minor + 1}
+		if numbers == 1 {
+			upper = semVersion{major: version.major + 1}
+		}
+		return []versionComparator{{operator: ">=", version: version}, {operator: "<", version: upper}}, nil
+	default:
+		return []versionComparator{{operator: operator, version: version}}, nil
+	}
+}
+
+func wildcardRange(version semVersion, numbers int) []versionComparator {
+	switch numbers {
+	case 0:
+		return []versionComparator{{operator: ">=", version: semVersion{}}}
+	case 1:
+		return []versionComparator{
+			{operator: ">=", version: version},
+			{operator: "<", version: semVersion{major: version.major + 1}},
+		}
+	default:
+		return []versionComparator{
+			{operator: ">=", version: version},
+			{operator: "<", version: semVersion{major: version.major, minor: version.minor + 1}},
+		}
+	}
+}
+
+// Matches returns true if the version name satisfies the constraint
+func (c VersionConstraint) Matches(versionName string) bool {
+	if c.text == versionName {
+		return true
+	}
+
+	version, numbers, err := parseSemVersion(versionName)
+	if err != nil || numbers == 0 {
+		return false
+	}
+
+	for _, group := range c.groups {
+		matches := true
+		for _, comparator := range group {
+			if !comparator.check(version) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c VersionConstraint) String() string {
+	return c.text
+}
+
+// compareVersionNames orders semver-style version names by precedence. Other version names are lower than every semver-style version
+func compareVersionNames(a string, b string) int {
+	aVersion, aNumbers, aErr := parseSemVersion(a)
+	bVersion, bNumbers, bErr := parseSemVersion(b)
+	aValid := aErr == nil && aNumbers > 0
+	bValid := bErr == nil && bNumbers > 0
+
+	switch {
+	case aValid && bValid:
+		return aVersion.compare(bVersion)
+	case aValid:
+		return 1
+	case bValid:
+		return -1
+	default:
+		return strings.Compare(a, b)
+	}
+}
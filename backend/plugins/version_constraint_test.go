@@ -0,0 +1,57 @@
+package plugins_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/plugins"
+)
+
+func TestVersionConstraint_Matches(t *testing.T) {
+	testData := map[string]map[string]bool{
+		"1.2.3":            {"1.2.3": true, "v1.2.3": true, "1.2.4": false, "1.2.3-beta": false},
+		"=v264":            {"v264": true, "264.0.1": true, "v265": false},
+		">=1.2.0":          {"1.2.0": true, "2.0.0": true, "1.1.9": false},
+		">1.2.0 <2":        {"1.2.0": false, "1.9.9": true, "2.0.0": false},
+		"^1.2.3":           {"1.2.3": true, "1.9.0": true, "2.0.0": false, "1.2.2": false},
+		"^0.2.3":           {"0.2.9": true, "0.3.0": false},
+		"~1.2.3":           {"1.2.9": true, "1.3.0": false},
+		"1.2.x":            {"1.2.0": true, "1.2.99": true, "1.3.0": false},
+		"*":                {"0.0.1": true, "v272": true, "latest": false},
+		"<1.0.0 || >=2.0":  {"0.9.0": true, "1.5.0": false, "2.1.0": true},
+		">=1.0.0, !=1.0.5": {"1.0.4": true, "1.0.5": false},
+		"2.0.0-git1313":    {"2.0.0-git1313": true, "2.0.0-git1314": false},
+		">=2.0.0-git1313":  {"2.0.0-git1314": true, "2.0.0-git1312": false, "2.0.0": true},
+		"latest":           {"latest": true, "1.0.0": false},
+	}
+
+	for text, versions := range testData {
+		constraint, err := plugins.ParseVersionConstraint(text)
+		if err != nil {
+			t.Fatal(text, err)
+		}
+
+		for version, expected := range versions {
+			if constraint.Matches(version) != expected {
+				t.Fatalf("expected %q matches %q to be %v", text, version, expected)
+			}
+		}
+	}
+}
+
+func TestParseVersionConstraint_Invalid(t *testing.T) {
+	testData := []string{
+		"",
+		">=",
+		">=abc",
+		"^1.2.3.4",
+		"1.2.3 || ",
+		">1.2",
+	}
+
+	for _, td := range testData {
+		if _, err := plugins.ParseVersionConstraint(td); !errors.Is(err, plugins.ErrInvalidVersionConstraint) {
+			t.Fatalf("expected ErrInvalidVersionConstraint for %q but got %v", td, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package rcon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrAuthFailed = errors.New("rcon authentication failed")
+
+// Client is a Source RCON client.
+// Commands are executed sequentially, so a single client can be shared
+type Client struct {
+	conn    net.Conn
+	timeout time.Duration
+
+	lock   sync.Mutex
+	nextId int32
+}
+
+// Dial connects to the RCON server and authenticates with the given password
+func Dial(address string, password string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("net.DialTimeout: %w", err)
+	}
+
+	c := &Client{
+		conn:    conn,
+		timeout: timeout,
+		nextId:  1,
+	}
+
+	if err := c.auth(password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Client) newId() int32 {
+	id := c.nextId
+	c.nextId++
+	if c.nextId <= 0 {
+		c.nextId = 1
+	}
+	return id
+}
+
+func (c *Client) auth(password string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return fmt.Errorf("conn.SetDeadline: %w", err)
+	}
+
+	id := c.newId()
+	if err := WritePacket(c.conn, Packet{Id: id, Type: TypeAuth, Body: password}); err != nil {
+		return err
+	}
+
+	for {
+		p, err := ReadPacket(c.conn)
+		if err != nil {
+			return fmt.Errorf("read auth response: %w", err)
+		}
+
+		// the server sends an empty response value packet before the auth response
+		if p.Type != TypeAuthResponse {
+			continue
+		}
+
+		if p.Id == -1 {
+			return ErrAuthFailed
+		}
+
+		if p.Id != id {
+			return fmt.Errorf("unexpected auth response id %v. expected %v", p.Id, id)
+		}
+
+		return nil
+	}
+}
+
+// Execute sends the command and returns the response.
+// To detect the end of multi-packet responses, an empty response value packet is sent after the command.
+// The server mirrors this packet after the full command response was sent
+func (c *Client) Execute(command string) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return "", fmt.Errorf("conn.SetDeadline: %w", err)
+	}
+
+	commandId := c.newId()
+	if err := WritePacket(c.conn, Packet{Id: commandId, Type: TypeExecCommand, Body: command}); err != nil {
+		return "", err
+	}
+
+	terminatorId := c.newId()
+	if err := WritePacket(c.conn, Packet{Id: terminatorId, Type: TypeResponseValue}); err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	for {
+		p, err := ReadPacket(c.conn)
+		if err != nil {
+			return "", fmt.Errorf("read command response: %w", err)
+		}
+
+		switch p.Id {
+		case commandId:
+			output.WriteString(p.Body)
+		case terminatorId:
+			return output.String(), nil
+		}
+		// packets with other ids are leftovers from previous commands and can be ignored
+	}
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,85 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Packet types as defined by the Source RCON protocol.
+// https://developer.valvesoftware.com/wiki/Source_RCON_Protocol
+const (
+	TypeResponseValue int32 = 0
+	TypeExecCommand   int32 = 2
+	TypeAuthResponse  int32 = 2
+	TypeAuth          int32 = 3
+)
+
+const (
+	// id and type field + body null terminator + empty string null terminator
+	packetHeaderSize  = 8
+	packetPaddingSize = 2
+	minPacketSize     = packetHeaderSize + packetPaddingSize
+
+	// MaxBodySize is the maximum body size of a single packet. Longer responses are split into multiple packets
+	MaxBodySize   = 4096
+	maxPacketSize = minPacketSize + MaxBodySize
+)
+
+var ErrPacketSize = errors.New("invalid packet size")
+
+type Packet struct {
+	Id   int32
+	Type int32
+	Body string
+}
+
+func WritePacket(w io.Writer, p Packet) error {
+	if len(p.Body) > MaxBodySize {
+		return fmt.Errorf("%w: body size %v exceeds the maximum of %v bytes", ErrPacketSize, len(p.Body), MaxBodySize)
+	}
+
+	size := int32(minPacketSize + len(p.Body))
+
+	buf := bytes.NewBuffer(make([]byte, 0, size+4))
+	_ = binary.Write(buf, binary.LittleEndian, size)
+	_ = binary.Write(buf, binary.LittleEndian, p.Id)
+	_ = binary.Write(buf, binary.LittleEndian, p.Type)
+	buf.WriteString(p.Body)
+	buf.Write([]byte{0, 0})
+
+	// written at once, so packets of concurrent writers can not get mixed up
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write packet: %w", err)
+	}
+
+	return nil
+}
+
+func ReadPacket(r io.Reader) (Packet, error) {
+	var size int32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return Packet{}, fmt.Errorf("read packet size: %w", err)
+	}
+
+	if size < minPacketSize || size > maxPacketSize {
+		return Packet{}, fmt.Errorf("%w: %v", ErrPacketSize, size)
+	}
+
+	content := make([]byte, size)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return Packet{}, fmt.Errorf("read packet content: %w", err)
+	}
+
+	if content[size-1] != 0 || content[size-2] != 0 {
+		return Packet{}, errors.New("packet is not null terminated")
+	}
+
+	return Packet{
+		Id:   int32(binary.LittleEndian.Uint32(content[0:4])),
+		Type: int32(binary.LittleEndian.Uint32(content[4:8])),
+		Body: string(content[packetHeaderSize : size-packetPaddingSize]),
+	}, nil
+}
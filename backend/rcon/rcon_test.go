@@ -0,0 +1,217 @@
+package rcon_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/rcon"
+)
+
+const testPassword = "secret"
+
+func startTestServer(t *testing.T, handler rcon.Handler) *rcon.Server {
+	s, err := rcon.NewServer(testPassword, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+
+	return s
+}
+
+func TestPacket_RoundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	packet := rcon.Packet{Id: 42, Type: rcon.TypeExecCommand, Body: "status"}
+
+	if err := rcon.WritePacket(buf, packet); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Len() != 4+10+len(packet.Body) {
+		t.Fatalf("unexpected packet length %v", buf.Len())
+	}
+
+	result, err := rcon.ReadPacket(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != packet {
+		t.Fatalf("expected %+v but got %+v", packet, result)
+	}
+}
+
+func TestPacket_InvalidSize(t *testing.T) {
+	if err := rcon.WritePacket(new(bytes.Buffer), rcon.Packet{Body: strings.Repeat("a", rcon.MaxBodySize+1)}); !errors.Is(err, rcon.ErrPacketSize) {
+		t.Fatal("expected ErrPacketSize for too long body but got", err)
+	}
+
+	testData := [][]byte{
+		{0x09, 0x00, 0x00, 0x00},
+		{0xff, 0xff, 0x00, 0x00},
+		{0xff, 0xff, 0xff, 0xff},
+	}
+
+	for _, td := range testData {
+		if _, err := rcon.ReadPacket(bytes.NewReader(td)); !errors.Is(err, rcon.ErrPacketSize) {
+			t.Fatalf("expected ErrPacketSize for size %v but got %v", td, err)
+		}
+	}
+}
+
+func TestClient_Execute(t *testing.T) {
+	s := startTestServer(t, func(command string) (string, error) {
+		return "executed: " + command, nil
+	})
+
+	client, err := rcon.Dial(s.Addr().String(), testPassword, time.Second*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for _, command := range []string{"status", "changelevel de_dust2", ""} {
+		output, err := client.Execute(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output != "executed: "+command {
+			t.Fatalf("unexpected output %q for command %q", output, command)
+		}
+	}
+}
+
+func TestClient_Execute_MultiPacketResponse(t *testing.T) {
+	longOutput := strings.Repeat("0123456789", rcon.MaxBodySize)
+	s := startTestServer(t, func(command string) (string, error) {
+		return longOutput, nil
+	})
+
+	client, err := rcon.Dial(s.Addr().String(), testPassword, time.Second*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	output, err := client.Execute("cvarlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if output != longOutput {
+		t.Fatalf("multi packet response not reassembled. expected %v bytes but got %v bytes", len(longOutput), len(output))
+	}
+}
+
+func TestClient_Execute_HandlerError(t *testing.T) {
+	s := startTestServer(t, func(command string) (string, error) {
+		return "", errors.New("server is not yet started")
+	})
+
+	client, err := rcon.Dial(s.Addr().String(), testPassword, time.Second*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	output, err := client.Execute("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "server is not yet started") {
+		t.Fatalf("handler error not returned to client. output: %q", output)
+	}
+}
+
+func TestDial_WrongPassword(t *testing.T) {
+	s := startTestServer(t, func(command string) (string, error) {
+		t.Error("handler should not be called without authentication")
+		return "", nil
+	})
+
+	if _, err := rcon.Dial(s.Addr().String(), "wrong", time.Second*5); !errors.Is(err, rcon.ErrAuthFailed) {
+		t.Fatal("expected ErrAuthFailed but got", err)
+	}
+}
+
+func TestServer_CommandWithoutAuthentication(t *testing.T) {
+	s := startTestServer(t, func(command string) (string, error) {
+		t.Error("handler should not be called without authentication")
+		return "", nil
+	})
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := rcon.WritePacket(conn, rcon.Packet{Id: 1, Type: rcon.TypeExecCommand, Body: "status"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	if _, err := rcon.ReadPacket(conn); err == nil {
+		t.Fatal("connection should be closed without response")
+	}
+}
+
+func TestServer_CloseWithConnectingClients(t *testing.T) {
+	for range 20 {
+		s := startTestServer(t, func(command string) (string, error) { return "", nil })
+		address := s.Addr().String()
+
+		stop := make(chan struct{})
+		dialed := make(chan struct{})
+		go func() {
+			defer close(dialed)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				if conn, err := net.Dial("tcp", address); err == nil {
+					defer conn.Close()
+				}
+			}
+		}()
+
+		time.Sleep(time.Millisecond * 5)
+
+		closed := make(chan struct{})
+		go func() {
+			_ = s.Close()
+			close(closed)
+		}()
+
+		select {
+		case <-closed:
+		case <-time.After(time.Second * 5):
+			t.Fatal("Close is waiting for a connection that was accepted while closing")
+		}
+
+		close(stop)
+		<-dialed
+	}
+}
+
+func TestNewServer_EmptyPassword(t *testing.T) {
+	if _, err := rcon.NewServer("", func(command string) (string, error) { return "", nil }); err == nil {
+		t.Fatal("error expected but nil returned")
+	}
+}
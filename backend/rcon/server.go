@@ -0,0 +1,215 @@
+package rcon
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// Handler executes the command received from a RCON client and returns the output
+type Handler func(command string) (string, error)
+
+// Server is a Source RCON compatible listener.
+// Every authenticated command is passed to the Handler
+type Server struct {
+	password string
+	handler  Handler
+
+	// connections without any packets within this duration are closed
+	idleTimeout time.Duration
+
+	lock     sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+func NewServer(password string, handler Handler) (*Server, error) {
+	if password == "" {
+		return nil, errors.New("rcon password can not be empty")
+	}
+
+	if handler == nil {
+		return nil, errors.New("rcon handler can not be nil")
+	}
+
+	return &Server{
+		password:    password,
+		handler:     handler,
+		idleTimeout: time.Minute * 10,
+		conns:       make(map[net.Conn]struct{}),
+	}, nil
+}
+
+// Listen starts accepting connections on the given address in the background
+func (s *Server) Listen(address string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener != nil {
+		return errors.New("rcon server is already listening")
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("net.Listen: %w", err)
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go s.accept(listener)
+
+	slog.Info("rcon server listening", "address", listener.Addr().String())
+	return nil
+}
+
+// Addr returns the address the server is listening on or nil if the server is not listening
+func (s *Server) Addr() net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close stops the listener and closes all open connections
+func (s *Server) Close() error {
+	s.lock.Lock()
+	if s.listener == nil {
+		s.lock.Unlock()
+		return nil
+	}
+
+	err := s.listener.Close()
+	s.listener = nil
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept(listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Warn("rcon server failed to accept connection", "error", err)
+			}
+			return
+		}
+
+		// Close might have cleared the connections after the connection was accepted.
+		// The connection would not be closed anymore and Close would wait until the idle timeout
+		s.lock.Lock()
+		if s.listener != listener {
+			s.lock.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.lock.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+
+			s.lock.Lock()
+			delete(s.conns, conn)
+			s.lock.Unlock()
+			_ = conn.Close()
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	remoteAddr := conn.RemoteAddr().String()
+	authenticated := false
+
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+			return
+		}
+
+		p, err := ReadPacket(conn)
+		if err != nil {
+			slog.Debug("rcon connection closed", "remote", remoteAddr, "reason", err)
+			return
+		}
+
+		switch {
+		case p.Type == TypeAuth:
+			authenticated = subtle.ConstantTimeCompare([]byte(p.Body), []byte(s.password)) == 1
+
+			responseId := p.Id
+			if !authenticated {
+				responseId = -1
+			}
+
+			if err := WritePacket(conn, Packet{Id: p.Id, Type: TypeResponseValue}); err != nil {
+				return
+			}
+
+			if err := WritePacket(conn, Packet{Id: responseId, Type: TypeAuthResponse}); err != nil {
+				return
+			}
+
+			if !authenticated {
+				slog.Warn("rcon authentication failed", "remote", remoteAddr)
+				return
+			}
+
+		case !authenticated:
+			slog.Warn("rcon packet received before authentication", "remote", remoteAddr)
+			return
+
+		case p.Type == TypeExecCommand:
+			if err := s.execute(conn, p); err != nil {
+				return
+			}
+
+		case p.Type == TypeResponseValue:
+			// mirrored so clients can detect the end of multi-packet responses
+			if err := WritePacket(conn, Packet{Id: p.Id, Type: TypeResponseValue}); err != nil {
+				return
+			}
+
+		default:
+			slog.Debug("rcon packet with unknown type received", "remote", remoteAddr, "type", p.Type)
+		}
+	}
+}
+
+func (s *Server) execute(conn net.Conn, p Packet) error {
+	output, err := s.handler(p.Body)
+	if err != nil {
+		output = fmt.Sprintf("failed to execute command: %v", err)
+	}
+
+	for {
+		body := output
+		if len(body) > MaxBodySize {
+			body = body[:MaxBodySize]
+		}
+		output = output[len(body):]
+
+		if err := WritePacket(conn, Packet{Id: p.Id, Type: TypeResponseValue, Body: body}); err != nil {
+			return err
+		}
+
+		if output == "" {
+			return nil
+		}
+	}
+}
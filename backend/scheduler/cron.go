@@ -0,0 +1,207 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCron = errors.New("invalid cron expression")
+
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var weekdayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames},
+	// 7 is allowed as alias for sunday
+	{name: "day of week", min: 0, max: 7, names: weekdayNames},
+}
+
+// Cron is a parsed cron expression in the standard five field format "minute hour day-of-month month day-of-week".
+// Every field supports "*", single values, ranges "1-5", lists "1,15" and steps "*/10" or "0-30/5"
+type Cron struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+
+	// like in the standard cron, a job runs if either the day of month or the day of week matches,
+	// if both fields are restricted
+	daysOfMonthRestricted bool
+	daysOfWeekRestricted  bool
+}
+
+func ParseCron(expr string) (*Cron, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("%w: '%v' has %v fields but %v are expected", ErrInvalidCron, expr, len(fields), len(cronFields))
+	}
+
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		v, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	// sunday can be 0 or 7
+	if values[4]&(1<<7) != 0 {
+		values[4] |= 1
+	}
+
+	return &Cron{
+		minutes:               values[0],
+		hours:                 values[1],
+		daysOfMonth:           values[2],
+		months:                values[3],
+		daysOfWeek:            values[4],
+		daysOfMonthRestricted: fields[2] != "*",
+		daysOfWeekRestricted:  fields[4] != "*",
+	}, nil
+}
+
+func parseCronField(field string, def cronField) (uint64, error) {
+	var result uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			parsedStep, err := strconv.Atoi(stepPart)
+			if err != nil || parsedStep < 1 {
+				return 0, fmt.Errorf("%w: invalid step '%v' in %v field", ErrInvalidCron, stepPart, def.name)
+			}
+			step = parsedStep
+		}
+
+		start, end := def.min, def.max
+		if rangePart != "*" {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			start, err = parseCronValue(startPart, def)
+			if err != nil {
+				return 0, err
+			}
+
+			end = start
+			if isRange {
+				end, err = parseCronValue(endPart, def)
+				if err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// "5/15" is the same as "5-max/15"
+				end = def.max
+			}
+
+			if start > end {
+				return 0, fmt.Errorf("%w: invalid range '%v' in %v field", ErrInvalidCron, rangePart, def.name)
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			result |= 1 << v
+		}
+	}
+
+	return result, nil
+}
+
+func parseCronValue(value string, def cronField) (int, error) {
+	if v, ok := def.names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(value)
+	if err != nil || v < def.min || v > def.max {
+		return 0, fmt.Errorf("%w: value '%v' in %v field has to be between %v and %v", ErrInvalidCron, value, def.name, def.min, def.max)
+	}
+
+	return v, nil
+}
+
+func (c *Cron) matchesDay(t time.Time) bool {
+	dayOfMonth := c.daysOfMonth&(1<<t.Day()) != 0
+	dayOfWeek := c.daysOfWeek&(1<<int(t.Weekday())) != 0
+
+	if c.daysOfMonthRestricted && c.daysOfWeekRestricted {
+		return dayOfMonth || dayOfWeek
+	}
+
+	return dayOfMonth && dayOfWeek
+}
+
+// Matches reports whether the cron expression matches the minute of t
+func (c *Cron) Matches(t time.Time) bool {
+	return c.minutes&(1<<t.Minute()) != 0 &&
+		c.hours&(1<<t.Hour()) != 0 &&
+		c.months&(1<<int(t.Month())) != 0 &&
+		c.matchesDay(t)
+}
+
+// Next returns the first minute after t that matches the cron expression.
+// Returns the zero time if no matching minute is found within the next five years, e.g. for "0 0 30 2 *"
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.months&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.hours&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if c.minutes&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,74 @@
+package scheduler_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/scheduler"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	testData := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"30-10 * * * *",
+		"1,,2 * * * *",
+		"@yearly",
+	}
+
+	for _, td := range testData {
+		if _, err := scheduler.ParseCron(td); !errors.Is(err, scheduler.ErrInvalidCron) {
+			t.Fatalf("expected ErrInvalidCron for %q but got %v", td, err)
+		}
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	// 2024-01-01 is a monday
+	from := time.Date(2024, 1, 1, 10, 17, 30, 0, time.UTC)
+
+	testData := map[string]time.Time{
+		"* * * * *":        time.Date(2024, 1, 1, 10, 18, 0, 0, time.UTC),
+		"*/15 * * * *":     time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC),
+		"0 4 * * *":        time.Date(2024, 1, 2, 4, 0, 0, 0, time.UTC),
+		"@daily":           time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		"30 6 * * sat,7":   time.Date(2024, 1, 6, 6, 30, 0, 0, time.UTC),
+		"0 0 1 feb *":      time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":       time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"0 12 15 * fri":    time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC),
+		"5/20 10-12 * * *": time.Date(2024, 1, 1, 10, 25, 0, 0, time.UTC),
+	}
+
+	for expr, expected := range testData {
+		cron, err := scheduler.ParseCron(expr)
+		if err != nil {
+			t.Fatal(expr, err)
+		}
+
+		next := cron.Next(from)
+		if !next.Equal(expected) {
+			t.Fatalf("unexpected next run for %q. expected: %v actual: %v", expr, expected, next)
+		}
+
+		if !cron.Matches(next) {
+			t.Fatalf("%q does not match its own next run %v", expr, next)
+		}
+	}
+
+	cron, err := scheduler.ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cron.Next(from).IsZero() {
+		t.Fatal("expected zero time for a cron expression that never matches")
+	}
+}
@@ -0,0 +1,467 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+
+	"github.com/google/uuid"
+)
+
+type JobType string
+
+const (
+	JobTypeRestart       JobType = "restart"
+	JobTypeUpdate        JobType = "update"
+	JobTypeCommand       JobType = "command"
+	JobTypeInstallPlugin JobType = "install_plugin"
+	JobTypeBackup        JobType = "backup"
+)
+
+var JobTypes = []JobType{JobTypeRestart, JobTypeUpdate, JobTypeCommand, JobTypeInstallPlugin, JobTypeBackup}
+
+// MaxRuns is the number of runs that are kept in the run history
+const MaxRuns = 100
+
+// output of a run is truncated to this length before it is saved in the run history
+const maxRunOutputLength = 4096
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrAlreadyRunning   = errors.New("schedule is already running")
+)
+
+type Job struct {
+	Type          JobType `json:"type" validate:"required"`
+	Command       string  `json:"command,omitempty" validate:"required_if=Type command,lt=128"`
+	PluginName    string  `json:"plugin_name,omitempty" validate:"required_if=Type install_plugin,lte=64"`
+	PluginVersion string  `json:"plugin_version,omitempty" validate:"required_if=Type install_plugin,lte=64"`
+}
+
+type Schedule struct {
+	Id      string `json:"id"`
+	Name    string `json:"name" validate:"required,notblank,lte=64"`
+	Cron    string `json:"cron" validate:"required,lte=128"`
+	Enabled bool   `json:"enabled"`
+	Job     Job    `json:"job"`
+}
+
+type Run struct {
+	ScheduleId    string    `json:"schedule_id"`
+	ScheduleName  string    `json:"schedule_name"`
+	JobType       JobType   `json:"job_type"`
+	StartedAtUtc  time.Time `json:"started_at_utc"`
+	FinishedAtUtc time.Time `json:"finished_at_utc"`
+	Success       bool      `json:"success"`
+	Output        string    `json:"output"`
+	Error         string    `json:"error"`
+}
+
+// Runner executes the job and returns the output that is saved in the run history
+type Runner func(job Job) (output string, err error)
+
+type schedulesFile struct {
+	Schedules []Schedule `json:"schedules"`
+}
+
+type runsFile struct {
+	Runs []Run `json:"runs"`
+}
+
+// Instance runs the jobs of all enabled schedules at the times defined by their cron expression.
+// Cron expressions are evaluated in the local time zone of the server manager
+type Instance struct {
+	schedulesJsonPath string
+	runsJsonPath      string
+	runners           map[JobType]Runner
+
+	lock sync.Mutex
+
+	runningLock sync.Mutex
+	running     map[string]bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	now func() time.Time
+
+	onRunStarted  event.InstanceWithData[Run]
+	onRunFinished event.InstanceWithData[Run]
+}
+
+func New(schedulesJsonPath string, runsJsonPath string, runners map[JobType]Runner) (*Instance, error) {
+	if err := gvalidator.Instance().Var(schedulesJsonPath, "required,filepath"); err != nil {
+		return nil, fmt.Errorf("schedulesJsonPath validation: %w", err)
+	}
+
+	if err := gvalidator.Instance().Var(runsJsonPath, "required,filepath"); err != nil {
+		return nil, fmt.Errorf("runsJsonPath validation: %w", err)
+	}
+
+	return &Instance{
+		schedulesJsonPath: schedulesJsonPath,
+		runsJsonPath:      runsJsonPath,
+		runners:           runners,
+		running:           make(map[string]bool),
+		stop:              make(chan struct{}),
+		now:               time.Now,
+	}, nil
+}
+
+func (s *Instance) OnRunStarted(handler func(p event.PayloadWithData[Run])) {
+	s.onRunStarted.Register(handler)
+}
+
+func (s *Instance) OnRunFinished(handler func(p event.PayloadWithData[Run])) {
+	s.onRunFinished.Register(handler)
+}
+
+// Start checks every minute for schedules that are due, until Close is called
+func (s *Instance) Start() {
+	go func() {
+		for {
+			now := s.now()
+			nextMinute := now.Truncate(time.Minute).Add(time.Minute)
+
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(nextMinute.Sub(now)):
+				s.RunDue(nextMinute)
+			}
+		}
+	}()
+}
+
+func (s *Instance) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *Instance) validate(schedule Schedule) error {
+	if err := gvalidator.Instance().Struct(schedule); err != nil {
+		return fmt.Errorf("schedule validation: %w", err)
+	}
+
+	if _, err := ParseCron(schedule.Cron); err != nil {
+		return err
+	}
+
+	if _, ok := s.runners[schedule.Job.Type]; !ok {
+		return fmt.Errorf("schedule validation: job type '%v' is not supported", schedule.Job.Type)
+	}
+
+	return nil
+}
+
+// ValidateSchedulesJson checks the content of a schedules json file, e.g. before a backup is restored
+func ValidateSchedulesJson(content []byte) error {
+	var file schedulesFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	for _, schedule := range file.Schedules {
+		if err := gvalidator.Instance().Struct(schedule); err != nil {
+			return fmt.Errorf("schedule validation: %w", err)
+		}
+
+		if _, err := ParseCron(schedule.Cron); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readJson[T any](path string, empty T) (T, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return empty, nil
+		}
+		return empty, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	var result T
+	if err := json.Unmarshal(content, &result); err != nil {
+		return empty, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	return result, nil
+}
+
+func writeJson(path string, v any) error {
+	jsonContent, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
+	if err := os.WriteFile(path, jsonContent, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Instance) readSchedules() (schedulesFile, error) {
+	return readJson(s.schedulesJsonPath, schedulesFile{Schedules: []Schedule{}})
+}
+
+func (f schedulesFile) index(id string) int {
+	return slices.IndexFunc(f.Schedules, func(schedule Schedule) bool {
+		return schedule.Id == id
+	})
+}
+
+func (s *Instance) All() ([]Schedule, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.readSchedules()
+	if err != nil {
+		return nil, err
+	}
+
+	return file.Schedules, nil
+}
+
+func (s *Instance) Get(id string) (Schedule, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.readSchedules()
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	i := file.index(id)
+	if i == -1 {
+		return Schedule{}, ErrScheduleNotFound
+	}
+
+	return file.Schedules[i], nil
+}
+
+// Create saves the schedule with a new id
+func (s *Instance) Create(schedule Schedule) (Schedule, error) {
+	schedule.Id = uuid.New().String()
+	if err := s.validate(schedule); err != nil {
+		return Schedule{}, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.readSchedules()
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	file.Schedules = append(file.Schedules, schedule)
+	if err := writeJson(s.schedulesJsonPath, file); err != nil {
+		return Schedule{}, err
+	}
+
+	return schedule, nil
+}
+
+func (s *Instance) Update(id string, schedule Schedule) (Schedule, error) {
+	schedule.Id = id
+	if err := s.validate(schedule); err != nil {
+		return Schedule{}, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.readSchedules()
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	i := file.index(id)
+	if i == -1 {
+		return Schedule{}, ErrScheduleNotFound
+	}
+
+	file.Schedules[i] = schedule
+	if err := writeJson(s.schedulesJsonPath, file); err != nil {
+		return Schedule{}, err
+	}
+
+	return schedule, nil
+}
+
+// Delete removes the schedule. The runs of the schedule are kept in the run history
+func (s *Instance) Delete(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.readSchedules()
+	if err != nil {
+		return err
+	}
+
+	i := file.index(id)
+	if i == -1 {
+		return ErrScheduleNotFound
+	}
+
+	file.Schedules = slices.Delete(file.Schedules, i, i+1)
+	return writeJson(s.schedulesJsonPath, file)
+}
+
+// Runs returns the run history, newest run first. If scheduleId is set, only the runs of this schedule are returned
+func (s *Instance) Runs(scheduleId string) ([]Run, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := readJson(s.runsJsonPath, runsFile{Runs: []Run{}})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Run, 0, len(file.Runs))
+	for i := len(file.Runs) - 1; i >= 0; i-- {
+		if scheduleId == "" || file.Runs[i].ScheduleId == scheduleId {
+			result = append(result, file.Runs[i])
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Instance) addRun(run Run) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := readJson(s.runsJsonPath, runsFile{Runs: []Run{}})
+	if err != nil {
+		return err
+	}
+
+	file.Runs = append(file.Runs, run)
+	if len(file.Runs) > MaxRuns {
+		file.Runs = file.Runs[len(file.Runs)-MaxRuns:]
+	}
+
+	return writeJson(s.runsJsonPath, file)
+}
+
+// NextRun returns the next time the schedule is due. Returns the zero time if the schedule is disabled
+func (s *Instance) NextRun(schedule Schedule) time.Time {
+	if !schedule.Enabled {
+		return time.Time{}
+	}
+
+	cron, err := ParseCron(schedule.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return cron.Next(s.now())
+}
+
+// RunDue starts all enabled schedules that are due at t
+func (s *Instance) RunDue(t time.Time) {
+	schedules, err := s.All()
+	if err != nil {
+		slog.Error("scheduler: read schedules", "error", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		if !schedule.Enabled {
+			continue
+		}
+
+		cron, err := ParseCron(schedule.Cron)
+		if err != nil {
+			slog.Error("scheduler: invalid cron expression", "schedule_id", schedule.Id, "error", err)
+			continue
+		}
+
+		if !cron.Matches(t) {
+			continue
+		}
+
+		if err := s.start(schedule); err != nil {
+			slog.Warn("scheduler: schedule not started", "schedule_id", schedule.Id, "error", err)
+		}
+	}
+}
+
+// RunNow starts the job of the schedule in the background, even if the schedule is disabled
+func (s *Instance) RunNow(id string) error {
+	schedule, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	return s.start(schedule)
+}
+
+func (s *Instance) start(schedule Schedule) error {
+	runner, ok := s.runners[schedule.Job.Type]
+	if !ok {
+		return fmt.Errorf("job type '%v' is not supported", schedule.Job.Type)
+	}
+
+	s.runningLock.Lock()
+	if s.running[schedule.Id] {
+		s.runningLock.Unlock()
+		return ErrAlreadyRunning
+	}
+	s.running[schedule.Id] = true
+	s.runningLock.Unlock()
+
+	go func() {
+		defer func() {
+			s.runningLock.Lock()
+			delete(s.running, schedule.Id)
+			s.runningLock.Unlock()
+		}()
+
+		s.execute(schedule, runner)
+	}()
+
+	return nil
+}
+
+func (s *Instance) execute(schedule Schedule, runner Runner) {
+	run := Run{
+		ScheduleId:   schedule.Id,
+		ScheduleName: schedule.Name,
+		JobType:      schedule.Job.Type,
+		StartedAtUtc: s.now().UTC(),
+	}
+	s.onRunStarted.Trigger(run)
+
+	output, err := runner(schedule.Job)
+	if len(output) > maxRunOutputLength {
+		output = output[:maxRunOutputLength]
+	}
+
+	run.FinishedAtUtc = s.now().UTC()
+	run.Success = err == nil
+	run.Output = output
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	if err := s.addRun(run); err != nil {
+		slog.Error("scheduler: save run", "schedule_id", schedule.Id, "error", err)
+	}
+
+	s.onRunFinished.Trigger(run)
+}
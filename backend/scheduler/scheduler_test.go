@@ -0,0 +1,165 @@
+package scheduler_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/scheduler"
+	"github.com/google/uuid"
+)
+
+func createTestScheduler(t *testing.T, runners map[scheduler.JobType]scheduler.Runner) (*scheduler.Instance, string) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_scheduler_test_%v", uuid.New()))
+	if err := os.Mkdir(tempDirPath, os.ModePerm); err != nil {
+		t.Fatal("os.Mkdir temp dir", err)
+	}
+
+	s, err := scheduler.New(filepath.Join(tempDirPath, "schedules.json"), filepath.Join(tempDirPath, "schedule-runs.json"), runners)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, tempDirPath
+}
+
+func waitForRun(t *testing.T, finished chan scheduler.Run) scheduler.Run {
+	select {
+	case run := <-finished:
+		return run
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout while waiting for run to finish")
+		return scheduler.Run{}
+	}
+}
+
+func TestInstance_RunDue(t *testing.T) {
+	commands := make(chan string, 10)
+	s, tempDirPath := createTestScheduler(t, map[scheduler.JobType]scheduler.Runner{
+		scheduler.JobTypeCommand: func(job scheduler.Job) (string, error) {
+			commands <- job.Command
+			return "executed " + job.Command, nil
+		},
+	})
+
+	finished := make(chan scheduler.Run, 10)
+	s.OnRunFinished(func(p event.PayloadWithData[scheduler.Run]) {
+		finished <- p.Data
+	})
+
+	schedule, err := s.Create(scheduler.Schedule{
+		Name:    "warmup",
+		Cron:    "*/10 * * * *",
+		Enabled: true,
+		Job:     scheduler.Job{Type: scheduler.JobTypeCommand, Command: "mp_warmup_start"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Create(scheduler.Schedule{
+		Name:    "disabled",
+		Cron:    "* * * * *",
+		Enabled: false,
+		Job:     scheduler.Job{Type: scheduler.JobTypeCommand, Command: "quit"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.RunDue(time.Date(2024, 1, 1, 10, 5, 0, 0, time.Local))
+	s.RunDue(time.Date(2024, 1, 1, 10, 10, 0, 0, time.Local))
+
+	run := waitForRun(t, finished)
+	if run.ScheduleId != schedule.Id || !run.Success || run.Output != "executed mp_warmup_start" {
+		t.Fatalf("unexpected run %+v", run)
+	}
+
+	if len(commands) != 1 {
+		t.Fatalf("expected exactly one command but got %v", len(commands))
+	}
+
+	runs, err := s.Runs(schedule.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runs) != 1 || runs[0].ScheduleId != schedule.Id {
+		t.Fatalf("run not saved in history. runs: %+v", runs)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_RunNow_Failed(t *testing.T) {
+	s, tempDirPath := createTestScheduler(t, map[scheduler.JobType]scheduler.Runner{
+		scheduler.JobTypeRestart: func(job scheduler.Job) (string, error) {
+			return "", errors.New("server is not installed")
+		},
+	})
+
+	finished := make(chan scheduler.Run, 10)
+	s.OnRunFinished(func(p event.PayloadWithData[scheduler.Run]) {
+		finished <- p.Data
+	})
+
+	schedule, err := s.Create(scheduler.Schedule{
+		Name: "nightly restart",
+		Cron: "0 4 * * *",
+		Job:  scheduler.Job{Type: scheduler.JobTypeRestart},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RunNow(schedule.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	run := waitForRun(t, finished)
+	if run.Success || run.Error != "server is not installed" {
+		t.Fatalf("unexpected run %+v", run)
+	}
+
+	if err := s.RunNow(uuid.New().String()); !errors.Is(err, scheduler.ErrScheduleNotFound) {
+		t.Fatal("expected ErrScheduleNotFound but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Create_Invalid(t *testing.T) {
+	s, tempDirPath := createTestScheduler(t, map[scheduler.JobType]scheduler.Runner{
+		scheduler.JobTypeCommand: func(job scheduler.Job) (string, error) { return "", nil },
+	})
+
+	testData := []scheduler.Schedule{
+		{Name: "", Cron: "* * * * *", Job: scheduler.Job{Type: scheduler.JobTypeCommand, Command: "status"}},
+		{Name: "invalid cron", Cron: "every day", Job: scheduler.Job{Type: scheduler.JobTypeCommand, Command: "status"}},
+		{Name: "missing command", Cron: "* * * * *", Job: scheduler.Job{Type: scheduler.JobTypeCommand}},
+		{Name: "no runner", Cron: "* * * * *", Job: scheduler.Job{Type: scheduler.JobTypeBackup}},
+	}
+
+	for _, td := range testData {
+		if _, err := s.Create(td); err == nil {
+			t.Fatalf("error expected for schedule %+v but nil returned", td)
+		}
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+)
+
+const (
+	FlagPrefix   = "-"
+	ConvarPrefix = "+"
+)
+
+var launchArgNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.]+$`)
+var negativeNumberRegex = regexp.MustCompile(`^-[0-9.]+$`)
+
+// Those launch arguments are set by the manager via the start parameters or the instance configuration
+// and can not be overwritten with additional launch arguments
+var reservedLaunchArgs = []string{
+	FlagPrefix + "dedicated",
+	FlagPrefix + "console",
+	FlagPrefix + "port",
+	FlagPrefix + "maxplayers",
+	FlagPrefix + "usercon",
+	ConvarPrefix + "hostname",
+	ConvarPrefix + "map",
+	ConvarPrefix + "sv_password",
+	ConvarPrefix + "sv_setsteamaccount",
+	ConvarPrefix + "rcon_password",
+	ConvarPrefix + "game_type",
+	ConvarPrefix + "game_mode",
+	ConvarPrefix + "host_workshop_map",
+	ConvarPrefix + "host_workshop_collection",
+	ConvarPrefix + "tv_enable",
+}
+
+// Those launch arguments would prevent the manager from controlling the server
+var deniedLaunchArgs = []string{
+	ConvarPrefix + "quit",
+	ConvarPrefix + "exit",
+	ConvarPrefix + "killserver",
+	ConvarPrefix + "rcon",
+}
+
+var ErrInvalidLaunchArg = errors.New("invalid launch argument")
+
+type LaunchArg struct {
+	// "-" for flags or "+" for convars and commands
+	Prefix string
+	Name   string
+	Value  string
+}
+
+func (a LaunchArg) key() string {
+	return a.Prefix + strings.ToLower(a.Name)
+}
+
+func (a LaunchArg) String() string {
+	if a.Value == "" {
+		return a.Prefix + a.Name
+	}
+	return a.Prefix + a.Name + " " + a.Value
+}
+
+// ParseLaunchArg parses launch arguments in the format "-flag", "-flag value" or "+convar value"
+func ParseLaunchArg(arg string) (LaunchArg, error) {
+	arg = strings.TrimSpace(arg)
+
+	var prefix string
+	switch {
+	case strings.HasPrefix(arg, FlagPrefix):
+		prefix = FlagPrefix
+	case strings.HasPrefix(arg, ConvarPrefix):
+		prefix = ConvarPrefix
+	default:
+		return LaunchArg{}, fmt.Errorf("%w: '%v' has to start with '%v' or '%v'", ErrInvalidLaunchArg, arg, FlagPrefix, ConvarPrefix)
+	}
+
+	name, value, _ := strings.Cut(strings.TrimPrefix(arg, prefix), " ")
+	launchArg := LaunchArg{
+		Prefix: prefix,
+		Name:   name,
+		Value:  strings.TrimSpace(value),
+	}
+
+	if err := launchArg.validate(); err != nil {
+		return LaunchArg{}, err
+	}
+
+	return launchArg, nil
+}
+
+func (a LaunchArg) validate() error {
+	if a.Prefix != FlagPrefix && a.Prefix != ConvarPrefix {
+		return fmt.Errorf("%w: unknown prefix '%v'", ErrInvalidLaunchArg, a.Prefix)
+	}
+
+	if !launchArgNameRegex.MatchString(a.Name) {
+		return fmt.Errorf("%w: name '%v' contains invalid characters", ErrInvalidLaunchArg, a.Name)
+	}
+
+	if slices.Contains(deniedLaunchArgs, a.key()) {
+		return fmt.Errorf("%w: '%v%v' is not allowed", ErrInvalidLaunchArg, a.Prefix, a.Name)
+	}
+
+	// The server joins all arguments to one command line and convars are executed as console commands.
+	// Quotes, line breaks and semicolons would allow to break out of the value
+	for _, r := range a.Value {
+		if r < 0x20 || r == 0x7f || r == '"' || r == ';' {
+			return fmt.Errorf("%w: value of '%v%v' contains invalid character %q", ErrInvalidLaunchArg, a.Prefix, a.Name, r)
+		}
+	}
+
+	// values like "+map" or "-port" would be interpreted as separate launch arguments.
+	// Negative numbers and a single "-" like in "EU - 128 tick" are allowed
+	for _, word := range strings.Fields(a.Value) {
+		if word == FlagPrefix || word == ConvarPrefix {
+			continue
+		}
+		if strings.HasPrefix(word, ConvarPrefix) || strings.HasPrefix(word, FlagPrefix) && !negativeNumberRegex.MatchString(word) {
+			return fmt.Errorf("%w: value of '%v%v' contains the launch argument '%v'", ErrInvalidLaunchArg, a.Prefix, a.Name, word)
+		}
+	}
+
+	return nil
+}
+
+// LaunchArgs builds the command line of the server.
+// Flags are always placed before convars, so convars like +map are executed after the server is configured
+type LaunchArgs struct {
+	flags   []LaunchArg
+	convars []LaunchArg
+}
+
+func NewLaunchArgs() *LaunchArgs {
+	return &LaunchArgs{}
+}
+
+func (l *LaunchArgs) add(arg LaunchArg) error {
+	if err := arg.validate(); err != nil {
+		return err
+	}
+
+	if l.contains(arg) {
+		return fmt.Errorf("%w: '%v%v' is already set", ErrInvalidLaunchArg, arg.Prefix, arg.Name)
+	}
+
+	if arg.Prefix == FlagPrefix {
+		l.flags = append(l.flags, arg)
+	} else {
+		l.convars = append(l.convars, arg)
+	}
+
+	return nil
+}
+
+func (l *LaunchArgs) contains(arg LaunchArg) bool {
+	return slices.ContainsFunc(slices.Concat(l.flags, l.convars), func(existing LaunchArg) bool {
+		return existing.key() == arg.key()
+	})
+}
+
+// AddFlag adds "-name value". The value is optional
+func (l *LaunchArgs) AddFlag(name string, value string) error {
+	return l.add(LaunchArg{Prefix: FlagPrefix, Name: name, Value: value})
+}
+
+// AddConvar adds "+name value"
+func (l *LaunchArgs) AddConvar(name string, value string) error {
+	return l.add(LaunchArg{Prefix: ConvarPrefix, Name: name, Value: value})
+}
+
+// AddAdditional parses and adds the user defined launch arguments.
+// Reserved launch arguments are rejected, because they are managed via the start parameters
+func (l *LaunchArgs) AddAdditional(additional []string) error {
+	for _, a := range additional {
+		arg, err := ParseLaunchArg(a)
+		if err != nil {
+			return err
+		}
+
+		if slices.Contains(reservedLaunchArgs, arg.key()) {
+			return fmt.Errorf("%w: '%v%v' is managed by the server manager and can not be set as additional launch argument", ErrInvalidLaunchArg, arg.Prefix, arg.Name)
+		}
+
+		if err := l.add(arg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Args returns the arguments as expected by exec.Command.
+// Name and value are passed as separate arguments, so values with spaces don't need any quoting
+func (l *LaunchArgs) Args() []string {
+	args := make([]string, 0, (len(l.flags)+len(l.convars))*2)
+	for _, arg := range slices.Concat(l.flags, l.convars) {
+		args = append(args, arg.Prefix+arg.Name)
+		if arg.Value != "" {
+			args = append(args, arg.Value)
+		}
+	}
+	return args
+}
+
+// LaunchArgs builds the launch arguments defined by the start parameters
+func (sp StartParameters) LaunchArgs() (*LaunchArgs, error) {
+	l := NewLaunchArgs()
+	if err := sp.addLaunchArgs(l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (sp StartParameters) addLaunchArgs(l *LaunchArgs) error {
+	if err := l.AddFlag("maxplayers", fmt.Sprint(sp.MaxPlayers)); err != nil {
+		return err
+	}
+
+	if err := l.AddConvar("hostname", strings.TrimSpace(sp.Hostname)); err != nil {
+		return err
+	}
+
+	if sp.GameMode != "" {
+		preset, ok := gvalidator.GameModePresets[sp.GameMode]
+		if !ok {
+			return fmt.Errorf("%w: unknown game mode '%v'", ErrInvalidLaunchArg, sp.GameMode)
+		}
+
+		if err := l.AddConvar("game_type", fmt.Sprint(preset.GameType)); err != nil {
+			return err
+		}
+
+		if err := l.AddConvar("game_mode", fmt.Sprint(preset.GameMode)); err != nil {
+			return err
+		}
+	}
+
+	if sp.WorkshopCollection != "" {
+		if err := l.AddConvar("host_workshop_collection", sp.WorkshopCollection); err != nil {
+			return err
+		}
+	}
+
+	// the workshop map replaces the start map. If only a collection is set, the server starts with the first map of the collection
+	if sp.WorkshopMap != "" {
+		if err := l.AddConvar("host_workshop_map", sp.WorkshopMap); err != nil {
+			return err
+		}
+	} else if sp.WorkshopCollection == "" {
+		if err := l.AddConvar("map", strings.TrimSpace(sp.StartMap)); err != nil {
+			return err
+		}
+	}
+
+	if sp.TvEnable {
+		if err := l.AddConvar("tv_enable", "1"); err != nil {
+			return err
+		}
+	}
+
+	if password := strings.TrimSpace(sp.Password); password != "" {
+		if err := l.AddConvar("sv_password", password); err != nil {
+			return err
+		}
+	}
+
+	if loginToken := strings.TrimSpace(sp.SteamLoginToken); loginToken != "" {
+		if err := l.AddConvar("sv_setsteamaccount", loginToken); err != nil {
+			return err
+		}
+	}
+
+	return l.AddAdditional(sp.Additional)
+}
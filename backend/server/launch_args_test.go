@@ -0,0 +1,180 @@
+package server_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/server"
+)
+
+func TestStartParameters_LaunchArgs(t *testing.T) {
+	sp := server.StartParameters{
+		Hostname:   "cs server with spaces",
+		Password:   "secret",
+		StartMap:   "de_dust2",
+		MaxPlayers: 10,
+		Additional: []string{"+sv_cheats 0", "-tickrate 128", "+mp_roundtime -1", "-insecure"},
+	}
+
+	launchArgs, err := sp.LaunchArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"-maxplayers", "10",
+		"-tickrate", "128",
+		"-insecure",
+		"+hostname", "cs server with spaces",
+		"+map", "de_dust2",
+		"+sv_password", "secret",
+		"+sv_cheats", "0",
+		"+mp_roundtime", "-1",
+	}
+
+	if !slices.Equal(launchArgs.Args(), expected) {
+		t.Fatalf("unexpected launch args.\nexpected: %q\nactual:   %q", expected, launchArgs.Args())
+	}
+}
+
+func TestStartParameters_LaunchArgs_InvalidAdditional(t *testing.T) {
+	testData := []string{
+		"tickrate 128",
+		"-",
+		"+hostname overwritten",
+		"-PORT 27016",
+		"+quit",
+		"+sv_cheats 1;rcon_password abc",
+		"+sv_cheats \"1\"",
+		"+sv_cheats 1\nquit",
+		"-tickrate 128 +map de_inferno",
+		"-tick-rate 128",
+		"+sv_cheats 0",
+		"+game_type 0",
+		"+tv_enable 1",
+	}
+
+	for _, td := range testData {
+		sp := *server.DefaultStartParameters()
+		sp.Additional = []string{"+sv_cheats 1", td}
+
+		if _, err := sp.LaunchArgs(); !errors.Is(err, server.ErrInvalidLaunchArg) {
+			t.Fatalf("expected ErrInvalidLaunchArg for %q but got %v", td, err)
+		}
+	}
+}
+
+func TestStartParameters_LaunchArgs_GameModeAndWorkshop(t *testing.T) {
+	sp := server.StartParameters{
+		Hostname:           "cs server",
+		StartMap:           "de_mirage",
+		MaxPlayers:         2,
+		GameMode:           "wingman",
+		WorkshopMap:        "3070284539",
+		WorkshopCollection: "3070291913",
+		TvEnable:           true,
+	}
+
+	launchArgs, err := sp.LaunchArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"-maxplayers", "2",
+		"+hostname", "cs server",
+		"+game_type", "0",
+		"+game_mode", "2",
+		"+host_workshop_collection", "3070291913",
+		"+host_workshop_map", "3070284539",
+		"+tv_enable", "1",
+	}
+
+	if !slices.Equal(launchArgs.Args(), expected) {
+		t.Fatalf("unexpected launch args.\nexpected: %q\nactual:   %q", expected, launchArgs.Args())
+	}
+
+	sp.GameMode = "retake"
+	if _, err := sp.LaunchArgs(); !errors.Is(err, server.ErrInvalidLaunchArg) {
+		t.Fatal("expected ErrInvalidLaunchArg for unknown game mode but got", err)
+	}
+}
+
+func TestGameModePresets(t *testing.T) {
+	for gameMode, preset := range gvalidator.GameModePresets {
+		if err := gvalidator.Instance().Var(gameMode, "game_mode"); err != nil {
+			t.Fatalf("game mode %q is not valid. %v", gameMode, err)
+		}
+
+		sp := *server.DefaultStartParameters()
+		sp.GameMode = gameMode
+		launchArgs, err := sp.LaunchArgs()
+		if err != nil {
+			t.Fatalf("game mode %q: %v", gameMode, err)
+		}
+
+		args := launchArgs.Args()
+		gameTypeIndex := slices.Index(args, "+game_type")
+		gameModeIndex := slices.Index(args, "+game_mode")
+		if gameTypeIndex == -1 || gameModeIndex == -1 ||
+			args[gameTypeIndex+1] != fmt.Sprint(preset.GameType) || args[gameModeIndex+1] != fmt.Sprint(preset.GameMode) {
+			t.Fatalf("game mode %q: unexpected launch args %q", gameMode, args)
+		}
+	}
+}
+
+func TestStartParameters_LaunchArgs_Hostname(t *testing.T) {
+	for _, hostname := range []string{"EU - 128 tick", "cs server #1"} {
+		sp := *server.DefaultStartParameters()
+		sp.Hostname = hostname
+
+		launchArgs, err := sp.LaunchArgs()
+		if err != nil {
+			t.Fatalf("%q: %v", hostname, err)
+		}
+
+		args := launchArgs.Args()
+		index := slices.Index(args, "+hostname")
+		if index == -1 || args[index+1] != hostname {
+			t.Fatalf("%q: hostname not passed as separate argument %v", hostname, args)
+		}
+	}
+}
+
+func TestStartParameters_LaunchArgs_InvalidHostname(t *testing.T) {
+	invalidHostnames := []string{
+		"server; quit",
+		`x";rcon_password pwned;quit;"`,
+		`My "pro" server`,
+		"foo +exec evil",
+		"server -port 1",
+		"server\nquit",
+	}
+
+	for _, hostname := range invalidHostnames {
+		sp := *server.DefaultStartParameters()
+		sp.Hostname = hostname
+
+		if _, err := sp.LaunchArgs(); !errors.Is(err, server.ErrInvalidLaunchArg) {
+			t.Fatalf("%q: expected ErrInvalidLaunchArg but got %v", hostname, err)
+		}
+	}
+}
+
+func TestParseLaunchArg(t *testing.T) {
+	launchArg, err := server.ParseLaunchArg("  +exec   server.cfg ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if launchArg.Prefix != server.ConvarPrefix || launchArg.Name != "exec" || launchArg.Value != "server.cfg" {
+		t.Fatalf("unexpected launch arg %+v", launchArg)
+	}
+
+	if launchArg.String() != "+exec server.cfg" {
+		t.Fatalf("unexpected string %q", launchArg.String())
+	}
+}
@@ -1,7 +1,6 @@
 package server
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"os/exec"
@@ -12,13 +11,14 @@ import (
 	"github.com/Phi-S/cs-server-manager/gvalidator"
 )
 
-var instanceCreated = false
-
 type Instance struct {
 	steamcmdDir string
 	serverDir   string
 	port        string
 
+	// if set, the server is started with rcon enabled and rcon is used as fallback if commands can not be sent via stdin
+	rconPassword string
+
 	running atomic.Bool
 	started atomic.Bool
 	stop    atomic.Bool
@@ -38,10 +38,6 @@ type Instance struct {
 }
 
 func NewInstance(serverDir, port, steamcmdDir string) (*Instance, error) {
-	if instanceCreated {
-		return nil, errors.New("another instance already exists. Only one instance should be used throughout the program")
-	}
-
 	if err := gvalidator.Instance().Var(serverDir, "required,dir"); err != nil {
 		return nil, fmt.Errorf("server dir %v is not a valid filepath %w", serverDir, err)
 	}
@@ -72,10 +68,14 @@ func NewInstance(serverDir, port, steamcmdDir string) (*Instance, error) {
 		i.stop.Store(false)
 	})
 
-	instanceCreated = true
 	return &i, nil
 }
 
+// SetRconPassword enables rcon for the next server start
+func (s *Instance) SetRconPassword(password string) {
+	s.rconPassword = password
+}
+
 func (s *Instance) IsRunning() bool {
 	return s.running.Load()
 }
@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/rcon"
 
 	"github.com/google/uuid"
 )
@@ -80,7 +82,12 @@ func (s *Instance) SendCommand(command string) (string, error) {
 	defer s.onOutput.Deregister(handlerUuid)
 
 	if err := s.writeCommand(finalCommand); err != nil {
-		return "", err
+		if s.rconPassword == "" {
+			return "", err
+		}
+
+		slog.Warn("failed to send command via stdin. Falling back to rcon", "error", err)
+		return s.sendCommandRcon(command)
 	}
 
 	timeout := time.Second * 10
@@ -98,3 +105,20 @@ func (s *Instance) SendCommand(command string) (string, error) {
 		time.Sleep(time.Millisecond * 50)
 	}
 }
+
+func (s *Instance) sendCommandRcon(command string) (string, error) {
+	client, err := rcon.Dial(net.JoinHostPort("127.0.0.1", s.port), s.rconPassword, time.Second*10)
+	if err != nil {
+		return "", fmt.Errorf("rcon.Dial: %w", err)
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	output, err := client.Execute(command)
+	if err != nil {
+		return "", fmt.Errorf("client.Execute: %w", err)
+	}
+
+	return output, nil
+}
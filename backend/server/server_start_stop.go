@@ -56,6 +56,38 @@ func (s *Instance) copySteamclient() error {
 	return nil
 }
 
+func (s *Instance) launchArgs(sp StartParameters) (*LaunchArgs, error) {
+	launchArgs := NewLaunchArgs()
+
+	if err := launchArgs.AddFlag("dedicated", ""); err != nil {
+		return nil, err
+	}
+
+	if err := launchArgs.AddFlag("console", ""); err != nil {
+		return nil, err
+	}
+
+	if err := launchArgs.AddFlag("port", s.port); err != nil {
+		return nil, err
+	}
+
+	if s.rconPassword != "" {
+		if err := launchArgs.AddFlag("usercon", ""); err != nil {
+			return nil, err
+		}
+
+		if err := launchArgs.AddConvar("rcon_password", s.rconPassword); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sp.addLaunchArgs(launchArgs); err != nil {
+		return nil, err
+	}
+
+	return launchArgs, nil
+}
+
 func (s *Instance) Start(sp StartParameters) error {
 	if s.IsRunning() {
 		return errors.New("server is running")
@@ -79,26 +111,15 @@ func (s *Instance) Start(sp StartParameters) error {
 		return err
 	}
 
-	//TODO: use sp.Additional
-
-	args := []string{"-dedicated", "-console"}
-	args = append(args, fmt.Sprintf("-port %s", s.port))
-	args = append(args, fmt.Sprintf("+hostname '%s'", sp.Hostname))
-	args = append(args, fmt.Sprintf("-maxplayers %d", sp.MaxPlayers))
-	args = append(args, fmt.Sprintf("+map %s", sp.StartMap))
-
-	password := strings.TrimSpace(sp.Password)
-	if len([]rune(strings.TrimSpace(sp.Password))) > 0 {
-		args = append(args, fmt.Sprintf("+sv_password %s", password))
-	}
-
-	loginToken := strings.TrimSpace(sp.SteamLoginToken)
-	if len([]rune(strings.TrimSpace(sp.SteamLoginToken))) > 0 {
-		args = append(args, fmt.Sprintf("++sv_setsteamaccount %s", loginToken))
+	launchArgs, err := s.launchArgs(sp)
+	if err != nil {
+		err = fmt.Errorf("failed to build launch arguments %w", err)
+		s.onCrashed.Trigger(err)
+		return err
 	}
 
 	cs2ShPath := filepath.Join(s.serverDir, "game", "bin", "linuxsteamrt64", "cs2")
-	cmd := exec.Command(cs2ShPath, args...)
+	cmd := exec.Command(cs2ShPath, launchArgs.Args()...)
 
 	slog.Debug("start command: " + strings.Join(cmd.Args, " "))
 
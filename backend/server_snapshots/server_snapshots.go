@@ -0,0 +1,404 @@
+package server_snapshots
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/backup"
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+
+	"github.com/google/uuid"
+)
+
+const fileExtension = ".tar.gz"
+
+var (
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	ErrNoSnapshot       = errors.New("no snapshot available")
+)
+
+// Source is a directory with the files and directories that are part of a snapshot.
+// On restore, every path is replaced with the snapshot content
+type Source struct {
+	// prefix of the archive entries
+	Name string
+	Dir  string
+	// files and directories relative to Dir
+	Paths []string
+	// the files are part of the snapshot but are not restored
+	RecordOnly bool
+}
+
+type Snapshot struct {
+	Id           string    `json:"id"`
+	CreatedAtUtc time.Time `json:"created_at_utc"`
+	BuildId      string    `json:"build_id"`
+	Branch       string    `json:"branch"`
+	Size         int64     `json:"size"`
+}
+
+// Instance creates snapshots of the mutable parts of the server dir, e.g. configs and plugins.
+// Only the newest snapshots are kept, depending on the retention
+type Instance struct {
+	snapshotDir string
+	retention   int
+	sources     []Source
+
+	lock sync.Mutex
+
+	onCreated  event.InstanceWithData[Snapshot]
+	onRestored event.InstanceWithData[Snapshot]
+}
+
+func New(snapshotDir string, retention int, sources []Source) (*Instance, error) {
+	if err := gvalidator.Instance().Var(snapshotDir, "required,filepath"); err != nil {
+		return nil, fmt.Errorf("snapshotDir validation: %w", err)
+	}
+
+	if retention < 0 {
+		return nil, fmt.Errorf("retention must not be negative but is %v", retention)
+	}
+
+	for _, source := range sources {
+		if err := gvalidator.Instance().Var(source.Name, "required,alphanum"); err != nil {
+			return nil, fmt.Errorf("source name validation: %w", err)
+		}
+
+		for _, p := range source.Paths {
+			if !filepath.IsLocal(p) {
+				return nil, fmt.Errorf("path '%v' of source '%v' is not inside the source dir", p, source.Name)
+			}
+		}
+	}
+
+	return &Instance{
+		snapshotDir: filepath.Clean(snapshotDir),
+		retention:   retention,
+		sources:     sources,
+	}, nil
+}
+
+func (i *Instance) OnCreated(handler func(p event.PayloadWithData[Snapshot])) {
+	i.onCreated.Register(handler)
+}
+
+func (i *Instance) OnRestored(handler func(p event.PayloadWithData[Snapshot])) {
+	i.onRestored.Register(handler)
+}
+
+// Enabled reports whether snapshots are kept. If the retention is 0, no snapshots are created
+func (i *Instance) Enabled() bool {
+	return i.retention > 0
+}
+
+func (i *Instance) indexPath() string {
+	return filepath.Join(i.snapshotDir, "snapshots.json")
+}
+
+func (i *Instance) archivePath(id string) string {
+	return filepath.Join(i.snapshotDir, id+fileExtension)
+}
+
+func (i *Instance) readIndex() ([]Snapshot, error) {
+	content, err := os.ReadFile(i.indexPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Snapshot{}, nil
+		}
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	var snapshots []Snapshot
+	if err := json.Unmarshal(content, &snapshots); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+func (i *Instance) writeIndex(snapshots []Snapshot) error {
+	content, err := json.MarshalIndent(snapshots, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
+	if err := os.WriteFile(i.indexPath(), content, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
+
+// List returns all snapshots, newest snapshot first
+func (i *Instance) List() ([]Snapshot, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.readIndex()
+}
+
+// Get returns the snapshot with the id or the newest snapshot if the id is empty
+func (i *Instance) Get(id string) (Snapshot, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.get(id)
+}
+
+func (i *Instance) get(id string) (Snapshot, error) {
+	snapshots, err := i.readIndex()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if id == "" {
+		if len(snapshots) == 0 {
+			return Snapshot{}, ErrNoSnapshot
+		}
+		return snapshots[0], nil
+	}
+
+	index := slices.IndexFunc(snapshots, func(s Snapshot) bool {
+		return s.Id == id
+	})
+	if index == -1 {
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+
+	return snapshots[index], nil
+}
+
+// Create writes a new snapshot and removes the oldest snapshots exceeding the retention
+func (i *Instance) Create(buildId string, branch string) (Snapshot, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if err := os.MkdirAll(i.snapshotDir, os.ModePerm); err != nil {
+		return Snapshot{}, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	createdAt := time.Now().UTC()
+	id := fmt.Sprintf("%v_%v", createdAt.Format("2006-01-02_15-04-05"), strings.Split(uuid.New().String(), "-")[0])
+	archivePath := i.archivePath(id)
+	tempPath := archivePath + ".tmp"
+
+	if err := i.writeArchive(tempPath); err != nil {
+		_ = os.Remove(tempPath)
+		return Snapshot{}, err
+	}
+
+	if err := os.Rename(tempPath, archivePath); err != nil {
+		_ = os.Remove(tempPath)
+		return Snapshot{}, fmt.Errorf("os.Rename: %w", err)
+	}
+
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("os.Stat: %w", err)
+	}
+
+	snapshot := Snapshot{
+		Id:           id,
+		CreatedAtUtc: createdAt,
+		BuildId:      buildId,
+		Branch:       branch,
+		Size:         info.Size(),
+	}
+
+	snapshots, err := i.readIndex()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshots = append([]Snapshot{snapshot}, snapshots...)
+	if err := i.prune(snapshots); err != nil {
+		return Snapshot{}, err
+	}
+
+	i.onCreated.Trigger(snapshot)
+	return snapshot, nil
+}
+
+func (i *Instance) writeArchive(archivePath string) error {
+	archiveWriter, err := backup.NewArchiveWriter(archivePath)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range i.sources {
+		for _, p := range source.Paths {
+			name := path.Join(source.Name, filepath.ToSlash(p))
+			if err := archiveWriter.AddPath(filepath.Join(source.Dir, p), name); err != nil {
+				_ = archiveWriter.Close()
+				return fmt.Errorf("add '%v': %w", name, err)
+			}
+		}
+	}
+
+	return archiveWriter.Close()
+}
+
+// prune removes the snapshots exceeding the retention and writes the index
+func (i *Instance) prune(snapshots []Snapshot) error {
+	if len(snapshots) > i.retention {
+		for _, snapshot := range snapshots[i.retention:] {
+			if err := os.Remove(i.archivePath(snapshot.Id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("remove snapshot '%v': %w", snapshot.Id, err)
+			}
+		}
+		snapshots = snapshots[:i.retention]
+	}
+
+	return i.writeIndex(snapshots)
+}
+
+// Delete removes the snapshot
+func (i *Instance) Delete(id string) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	snapshots, err := i.readIndex()
+	if err != nil {
+		return err
+	}
+
+	index := slices.IndexFunc(snapshots, func(s Snapshot) bool {
+		return s.Id == id
+	})
+	if index == -1 {
+		return ErrSnapshotNotFound
+	}
+
+	if err := os.Remove(i.archivePath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+
+	return i.writeIndex(slices.Delete(snapshots, index, index+1))
+}
+
+// Restore replaces all paths of the sources with the content of the snapshot.
+// The newest snapshot is restored if the id is empty.
+// The snapshot is extracted to a staging dir inside every source dir first, so nothing is changed if the snapshot can not be extracted.
+// If a path can not be replaced, all paths that were already replaced are restored
+func (i *Instance) Restore(id string) (Snapshot, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	snapshot, err := i.get(id)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	archivePath := i.archivePath(snapshot.Id)
+	if _, err := os.Stat(archivePath); err != nil {
+		return Snapshot{}, fmt.Errorf("snapshot archive: %w", err)
+	}
+
+	restoredSources := slices.DeleteFunc(slices.Clone(i.sources), func(source Source) bool {
+		return source.RecordOnly
+	})
+
+	// the staging dir is inside the source dir, so the paths can be moved instead of copied
+	stagingName := fmt.Sprintf(".snapshot_restore_%v", uuid.New())
+	defer func() {
+		for _, source := range restoredSources {
+			if err := os.RemoveAll(filepath.Join(source.Dir, stagingName)); err != nil {
+				slog.Warn("failed to remove snapshot staging dir", "dir", filepath.Join(source.Dir, stagingName), "error", err)
+			}
+		}
+	}()
+
+	err = backup.ExtractArchive(archivePath, func(name string) string {
+		for _, source := range restoredSources {
+			if relPath, ok := strings.CutPrefix(name, source.Name+"/"); ok {
+				return filepath.Join(source.Dir, stagingName, "snapshot", filepath.FromSlash(relPath))
+			}
+		}
+		return ""
+	})
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("extract snapshot: %w", err)
+	}
+
+	if err := replacePaths(restoredSources, stagingName); err != nil {
+		return Snapshot{}, err
+	}
+
+	i.onRestored.Trigger(snapshot)
+	return snapshot, nil
+}
+
+type replacedPath struct {
+	target   string
+	previous string
+}
+
+// replacePaths moves the current paths of the sources to the staging dir and the staged snapshot content into their place.
+// Paths that are not part of the snapshot don't exist after the restore, like at the time the snapshot was created
+func replacePaths(sources []Source, stagingName string) error {
+	replaced := make([]replacedPath, 0)
+	fail := func(err error) error {
+		if rollbackErr := revertReplacedPaths(replaced); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("revert: %w", rollbackErr))
+		}
+		return err
+	}
+
+	for _, source := range sources {
+		for _, p := range source.Paths {
+			target := filepath.Join(source.Dir, p)
+			staged := filepath.Join(source.Dir, stagingName, "snapshot", p)
+			previous := filepath.Join(source.Dir, stagingName, "previous", p)
+
+			if err := os.MkdirAll(filepath.Dir(previous), os.ModePerm); err != nil {
+				return fail(fmt.Errorf("os.MkdirAll: %w", err))
+			}
+
+			if err := os.Rename(target, previous); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fail(fmt.Errorf("move '%v' to staging dir: %w", p, err))
+			}
+			replaced = append(replaced, replacedPath{target: target, previous: previous})
+
+			if _, err := os.Lstat(staged); err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return fail(fmt.Errorf("os.Lstat: %w", err))
+			}
+
+			if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+				return fail(fmt.Errorf("os.MkdirAll: %w", err))
+			}
+
+			if err := os.Rename(staged, target); err != nil {
+				return fail(fmt.Errorf("move '%v' from staging dir: %w", p, err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// revertReplacedPaths moves the previous paths back in reverse order
+func revertReplacedPaths(replaced []replacedPath) error {
+	var result error
+	for _, r := range slices.Backward(replaced) {
+		if err := os.RemoveAll(r.target); err != nil {
+			result = errors.Join(result, fmt.Errorf("os.RemoveAll: %w", err))
+			continue
+		}
+
+		if err := os.Rename(r.previous, r.target); err != nil && !errors.Is(err, os.ErrNotExist) {
+			result = errors.Join(result, fmt.Errorf("os.Rename: %w", err))
+		}
+	}
+	return result
+}
@@ -0,0 +1,218 @@
+package server_snapshots_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/server_snapshots"
+	"github.com/google/uuid"
+)
+
+func writeTestFile(t *testing.T, path string, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), os.ModePerm); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("os.ReadFile", err)
+	}
+	return string(content)
+}
+
+func createTestSnapshots(t *testing.T, retention int) (*server_snapshots.Instance, string, string) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_server_snapshots_test_%v", uuid.New()))
+	serverDir := filepath.Join(tempDirPath, "server")
+
+	writeTestFile(t, filepath.Join(serverDir, "game", "csgo", "cfg", "server.cfg"), "hostname before")
+	writeTestFile(t, filepath.Join(serverDir, "game", "csgo", "addons", "metamod.vdf"), "metamod before")
+	writeTestFile(t, filepath.Join(serverDir, "game", "csgo", "gameinfo.gi"), "gameinfo before")
+	writeTestFile(t, filepath.Join(serverDir, "game", "csgo", "pak01_001.vpk"), "vpk")
+	writeTestFile(t, filepath.Join(serverDir, "steamapps", "appmanifest_730.acf"), "buildid 100")
+
+	snapshots, err := server_snapshots.New(filepath.Join(tempDirPath, "snapshots"), retention, []server_snapshots.Source{
+		{
+			Name:  "server",
+			Dir:   serverDir,
+			Paths: []string{"game/csgo/cfg", "game/csgo/addons", "game/csgo/gameinfo.gi"},
+		},
+		{
+			Name:       "manifest",
+			Dir:        serverDir,
+			Paths:      []string{"steamapps/appmanifest_730.acf"},
+			RecordOnly: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return snapshots, serverDir, tempDirPath
+}
+
+func TestInstance_Restore(t *testing.T) {
+	snapshots, serverDir, tempDirPath := createTestSnapshots(t, 3)
+
+	snapshot, err := snapshots.Create("100", "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the update changes the configs and breaks the plugins
+	writeTestFile(t, filepath.Join(serverDir, "game", "csgo", "cfg", "server.cfg"), "hostname after")
+	writeTestFile(t, filepath.Join(serverDir, "game", "csgo", "cfg", "new.cfg"), "new")
+	writeTestFile(t, filepath.Join(serverDir, "game", "csgo", "gameinfo.gi"), "gameinfo after")
+	writeTestFile(t, filepath.Join(serverDir, "game", "csgo", "pak01_001.vpk"), "vpk after")
+	writeTestFile(t, filepath.Join(serverDir, "steamapps", "appmanifest_730.acf"), "buildid 101")
+
+	restored, err := snapshots.Restore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.Id != snapshot.Id || restored.BuildId != "100" {
+		t.Fatalf("unexpected restored snapshot %+v", restored)
+	}
+
+	if content := readTestFile(t, filepath.Join(serverDir, "game", "csgo", "cfg", "server.cfg")); content != "hostname before" {
+		t.Fatal("server.cfg not restored. content:", content)
+	}
+
+	if content := readTestFile(t, filepath.Join(serverDir, "game", "csgo", "gameinfo.gi")); content != "gameinfo before" {
+		t.Fatal("gameinfo.gi not restored. content:", content)
+	}
+
+	if content := readTestFile(t, filepath.Join(serverDir, "game", "csgo", "addons", "metamod.vdf")); content != "metamod before" {
+		t.Fatal("addons not restored. content:", content)
+	}
+
+	if _, err := os.Stat(filepath.Join(serverDir, "game", "csgo", "cfg", "new.cfg")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("file created after the snapshot still exists")
+	}
+
+	// files that are not part of the snapshot are not touched
+	if content := readTestFile(t, filepath.Join(serverDir, "game", "csgo", "pak01_001.vpk")); content != "vpk after" {
+		t.Fatal("file outside of the snapshot changed. content:", content)
+	}
+
+	if content := readTestFile(t, filepath.Join(serverDir, "steamapps", "appmanifest_730.acf")); content != "buildid 101" {
+		t.Fatal("record only file restored. content:", content)
+	}
+
+	if _, err := snapshots.Restore(uuid.New().String()); !errors.Is(err, server_snapshots.ErrSnapshotNotFound) {
+		t.Fatal("expected ErrSnapshotNotFound but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Restore_CorruptSnapshot(t *testing.T) {
+	snapshots, serverDir, tempDirPath := createTestSnapshots(t, 3)
+
+	snapshot, err := snapshots.Create("100", "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, filepath.Join(serverDir, "game", "csgo", "cfg", "server.cfg"), "hostname after")
+
+	// the archive ends in the middle of the entries
+	archivePath := filepath.Join(tempDirPath, "snapshots", snapshot.Id+".tar.gz")
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		t.Fatal("os.Stat", err)
+	}
+	if err := os.Truncate(archivePath, info.Size()/2); err != nil {
+		t.Fatal("os.Truncate", err)
+	}
+
+	if _, err := snapshots.Restore(snapshot.Id); err == nil {
+		t.Fatal("expected error for corrupt snapshot")
+	}
+
+	// the current files are kept
+	if content := readTestFile(t, filepath.Join(serverDir, "game", "csgo", "cfg", "server.cfg")); content != "hostname after" {
+		t.Fatal("server.cfg changed by failed restore. content:", content)
+	}
+
+	if content := readTestFile(t, filepath.Join(serverDir, "game", "csgo", "addons", "metamod.vdf")); content != "metamod before" {
+		t.Fatal("addons changed by failed restore. content:", content)
+	}
+
+	if content := readTestFile(t, filepath.Join(serverDir, "game", "csgo", "gameinfo.gi")); content != "gameinfo before" {
+		t.Fatal("gameinfo.gi changed by failed restore. content:", content)
+	}
+
+	// no staging dir is left behind
+	entries, err := os.ReadDir(serverDir)
+	if err != nil {
+		t.Fatal("os.ReadDir", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected only the game and steamapps dir but got %v entries", len(entries))
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Create_Retention(t *testing.T) {
+	snapshots, _, tempDirPath := createTestSnapshots(t, 2)
+
+	var created []server_snapshots.Snapshot
+	for _, buildId := range []string{"1", "2", "3"} {
+		snapshot, err := snapshots.Create(buildId, "public")
+		if err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, snapshot)
+	}
+
+	list, err := snapshots.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 || list[0].BuildId != "3" || list[1].BuildId != "2" {
+		t.Fatalf("unexpected snapshots %+v", list)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDirPath, "snapshots", created[0].Id+".tar.gz")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("archive of the pruned snapshot still exists")
+	}
+
+	if err := snapshots.Delete(list[0].Id); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := snapshots.Get("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if latest.BuildId != "2" {
+		t.Fatalf("unexpected latest snapshot after delete %+v", latest)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
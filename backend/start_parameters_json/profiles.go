@@ -0,0 +1,291 @@
+package start_parameters_json
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+	"github.com/Phi-S/cs-server-manager/server"
+)
+
+var ErrProfileNotFound = errors.New("profile not found")
+
+var profileNameRegex = regexp.MustCompile(`^[a-zA-Z0-9 _-]{1,32}$`)
+
+type Profile struct {
+	Name            string                 `json:"name"`
+	StartParameters server.StartParameters `json:"start_parameters"`
+}
+
+type profilesFile struct {
+	DefaultProfile string    `json:"default_profile"`
+	ActiveProfile  string    `json:"active_profile"`
+	Profiles       []Profile `json:"profiles" validate:"dive"`
+}
+
+// Profiles stores named start parameters.
+// Activating a profile writes its start parameters to the start-parameters.json file,
+// so the active profile is used for the next server start
+type Profiles struct {
+	path            string
+	lock            sync.Mutex
+	startParameters *Instance
+}
+
+func NewProfiles(path string, startParameters *Instance) (*Profiles, error) {
+	if err := gvalidator.Instance().Var(path, "required,filepath"); err != nil {
+		return nil, fmt.Errorf("path validation: %w", err)
+	}
+
+	return &Profiles{
+		path:            path,
+		startParameters: startParameters,
+	}, nil
+}
+
+func ValidateProfileName(name string) error {
+	if !profileNameRegex.MatchString(name) {
+		return fmt.Errorf("profile name '%v' is not valid. Only letters, numbers, spaces, '-' and '_' are allowed (max 32 characters)", name)
+	}
+	return nil
+}
+
+// ValidateProfilesJson checks the content of a profiles json file, e.g. before a backup is restored
+func ValidateProfilesJson(content []byte) error {
+	var file profilesFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if err := gvalidator.Instance().Struct(file); err != nil {
+		return fmt.Errorf("profiles validation: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Profiles) read() (profilesFile, error) {
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return profilesFile{Profiles: []Profile{}}, nil
+		}
+		return profilesFile{}, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	var file profilesFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return profilesFile{}, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if err := gvalidator.Instance().Struct(file); err != nil {
+		return profilesFile{}, fmt.Errorf("profiles validation: %w", err)
+	}
+
+	return file, nil
+}
+
+func (p *Profiles) write(file profilesFile) error {
+	if err := gvalidator.Instance().Struct(file); err != nil {
+		return fmt.Errorf("profiles validation: %w", err)
+	}
+
+	jsonContent, err := json.MarshalIndent(file, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
+	if err := os.WriteFile(p.path, jsonContent, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
+
+func (f profilesFile) index(name string) int {
+	return slices.IndexFunc(f.Profiles, func(profile Profile) bool {
+		return profile.Name == name
+	})
+}
+
+// All returns all profiles and the names of the default and active profile
+func (p *Profiles) All() (profiles []Profile, defaultProfile string, activeProfile string, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return file.Profiles, file.DefaultProfile, file.ActiveProfile, nil
+}
+
+func (p *Profiles) Get(name string) (server.StartParameters, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return server.StartParameters{}, err
+	}
+
+	i := file.index(name)
+	if i == -1 {
+		return server.StartParameters{}, ErrProfileNotFound
+	}
+
+	return file.Profiles[i].StartParameters, nil
+}
+
+// Save creates or updates the profile.
+// If the profile is the active profile, the start-parameters.json file is updated as well
+func (p *Profiles) Save(name string, sp server.StartParameters) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+
+	if err := gvalidator.Instance().Struct(sp); err != nil {
+		return fmt.Errorf("startParameters validation: %w", err)
+	}
+
+	isActive, err := p.save(name, sp)
+	if err != nil {
+		return err
+	}
+
+	// written outside the profiles lock, because OnUpdated handlers are executed synchronously
+	if isActive {
+		if err := p.startParameters.Write(sp); err != nil {
+			return fmt.Errorf("write active profile to start parameters: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Profiles) save(name string, sp server.StartParameters) (isActive bool, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return false, err
+	}
+
+	if i := file.index(name); i == -1 {
+		file.Profiles = append(file.Profiles, Profile{Name: name, StartParameters: sp})
+	} else {
+		file.Profiles[i].StartParameters = sp
+	}
+
+	if err := p.write(file); err != nil {
+		return false, err
+	}
+
+	return file.ActiveProfile == name, nil
+}
+
+// Delete removes the profile. The start-parameters.json file is not changed, even if the profile is active
+func (p *Profiles) Delete(name string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return err
+	}
+
+	i := file.index(name)
+	if i == -1 {
+		return ErrProfileNotFound
+	}
+
+	file.Profiles = slices.Delete(file.Profiles, i, i+1)
+	if file.DefaultProfile == name {
+		file.DefaultProfile = ""
+	}
+	if file.ActiveProfile == name {
+		file.ActiveProfile = ""
+	}
+
+	return p.write(file)
+}
+
+// SetDefault marks the profile as default. The default profile is activated on startup. An empty name removes the default profile
+func (p *Profiles) SetDefault(name string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return err
+	}
+
+	if name != "" && file.index(name) == -1 {
+		return ErrProfileNotFound
+	}
+
+	file.DefaultProfile = name
+	return p.write(file)
+}
+
+// SetActive marks the profile as active without changing the start-parameters.json file.
+// Used if the server was started with the start parameters of the profile
+func (p *Profiles) SetActive(name string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	file, err := p.read()
+	if err != nil {
+		return err
+	}
+
+	if file.index(name) == -1 {
+		return ErrProfileNotFound
+	}
+
+	file.ActiveProfile = name
+	return p.write(file)
+}
+
+// Activate writes the start parameters of the profile to the start-parameters.json file and marks the profile as active
+func (p *Profiles) Activate(name string) (server.StartParameters, error) {
+	sp, err := p.Get(name)
+	if err != nil {
+		return server.StartParameters{}, err
+	}
+
+	if err := p.startParameters.Write(sp); err != nil {
+		return server.StartParameters{}, fmt.Errorf("write profile to start parameters: %w", err)
+	}
+
+	if err := p.SetActive(name); err != nil {
+		return server.StartParameters{}, err
+	}
+
+	return sp, nil
+}
+
+// ActivateDefault activates the default profile if one is set
+func (p *Profiles) ActivateDefault() error {
+	_, defaultProfile, _, err := p.All()
+	if err != nil {
+		return err
+	}
+
+	if defaultProfile == "" {
+		return nil
+	}
+
+	if _, err := p.Activate(defaultProfile); err != nil {
+		return fmt.Errorf("activate default profile '%v': %w", defaultProfile, err)
+	}
+
+	return nil
+}
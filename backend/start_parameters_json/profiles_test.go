@@ -0,0 +1,155 @@
+package start_parameters_json_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/server"
+	"github.com/Phi-S/cs-server-manager/start_parameters_json"
+	"github.com/google/uuid"
+)
+
+func createTestProfiles(t *testing.T) (*start_parameters_json.Instance, *start_parameters_json.Profiles, string) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_start_parameters_json_test_%v", uuid.New()))
+	if err := os.Mkdir(tempDirPath, os.ModePerm); err != nil {
+		t.Fatal("os.Mkdir temp dir", err)
+	}
+
+	startParametersJson, err := start_parameters_json.New(filepath.Join(tempDirPath, "start-parameters.json"), *server.DefaultStartParameters())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, err := start_parameters_json.NewProfiles(filepath.Join(tempDirPath, "profiles.json"), startParametersJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return startParametersJson, profiles, tempDirPath
+}
+
+func TestProfiles_SaveAndActivate(t *testing.T) {
+	startParametersJson, profiles, tempDirPath := createTestProfiles(t)
+
+	var updatedHostname atomic.Value
+	startParametersJson.OnUpdated(func(data event.PayloadWithData[server.StartParameters]) {
+		updatedHostname.Store(data.Data.Hostname)
+	})
+
+	practice := *server.DefaultStartParameters()
+	practice.Hostname = "practice"
+	if err := profiles.Save("practice", practice); err != nil {
+		t.Fatal(err)
+	}
+
+	if updatedHostname.Load() != nil {
+		t.Fatal("saving an inactive profile should not update the start parameters")
+	}
+
+	if _, err := profiles.Activate("practice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if updatedHostname.Load() != "practice" {
+		t.Fatal("OnUpdated not triggered after activating profile")
+	}
+
+	practice.Hostname = "practice edited"
+	if err := profiles.Save("practice", practice); err != nil {
+		t.Fatal(err)
+	}
+
+	if updatedHostname.Load() != "practice edited" {
+		t.Fatal("OnUpdated not triggered after editing the active profile")
+	}
+
+	sp, err := startParametersJson.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sp.Hostname != "practice edited" {
+		t.Fatalf("start parameters not updated. hostname: %v", sp.Hostname)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestProfiles_DefaultAndDelete(t *testing.T) {
+	startParametersJson, profiles, tempDirPath := createTestProfiles(t)
+
+	match := *server.DefaultStartParameters()
+	match.Hostname = "5v5 match"
+	if err := profiles.Save("5v5 match", match); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := profiles.SetDefault("unknown"); !errors.Is(err, start_parameters_json.ErrProfileNotFound) {
+		t.Fatal("expected ErrProfileNotFound but got", err)
+	}
+
+	if err := profiles.SetDefault("5v5 match"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := profiles.ActivateDefault(); err != nil {
+		t.Fatal(err)
+	}
+
+	sp, err := startParametersJson.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sp.Hostname != "5v5 match" {
+		t.Fatal("default profile not activated")
+	}
+
+	if err := profiles.Delete("5v5 match"); err != nil {
+		t.Fatal(err)
+	}
+
+	all, defaultProfile, activeProfile, err := profiles.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 0 || defaultProfile != "" || activeProfile != "" {
+		t.Fatalf("profile not removed. profiles: %v, default: %q, active: %q", all, defaultProfile, activeProfile)
+	}
+
+	if err := profiles.Delete("5v5 match"); !errors.Is(err, start_parameters_json.ErrProfileNotFound) {
+		t.Fatal("expected ErrProfileNotFound but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestProfiles_Save_InvalidName(t *testing.T) {
+	_, profiles, tempDirPath := createTestProfiles(t)
+
+	for _, name := range []string{"", "../escape", "name;quit", "a-very-long-profile-name-with-more-than-32-characters"} {
+		if err := profiles.Save(name, *server.DefaultStartParameters()); err == nil {
+			t.Fatalf("error expected for profile name %q but nil returned", name)
+		}
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
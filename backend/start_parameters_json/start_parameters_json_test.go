@@ -34,6 +34,10 @@ func TestNew_createNewFile(t *testing.T) {
     "start_map": "de_mirage",
     "max_players": 10,
     "steam_login_token": "",
+    "game_mode": "competitive",
+    "workshop_map": "",
+    "workshop_collection": "",
+    "tv_enable": false,
     "additional": []
 }` {
 		t.Fatal("content dose not match")
@@ -142,6 +146,9 @@ func TestInstance_Write(t *testing.T) {
 		StartMap:        "de_test123",
 		MaxPlayers:      14,
 		SteamLoginToken: "",
+		GameMode:        "wingman",
+		WorkshopMap:     "3070284539",
+		TvEnable:        true,
 		Additional:      make([]string, 0),
 	}); err != nil {
 		t.Fatal(err)
@@ -158,9 +165,38 @@ func TestInstance_Write(t *testing.T) {
     "start_map": "de_test123",
     "max_players": 14,
     "steam_login_token": "",
+    "game_mode": "wingman",
+    "workshop_map": "3070284539",
+    "workshop_collection": "",
+    "tv_enable": true,
     "additional": []
 }` {
 		t.Fatal("content dose not match")
 	}
 
 }
+
+func TestInstance_Write_invalidGameMode(t *testing.T) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_start_parameters_json_test_%v", uuid.New()))
+	if err := os.Mkdir(tempDirPath, os.ModePerm); err != nil {
+		t.Fatal("os.Mkdir temp dir", err)
+	}
+
+	jsonPath := filepath.Join(tempDirPath, "test.json")
+	instance, err := start_parameters_json.New(jsonPath, *server.DefaultStartParameters())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sp := *server.DefaultStartParameters()
+	sp.GameMode = "retake"
+	if err := instance.Write(sp); err == nil {
+		t.Fatal("error expected but nil returned")
+	}
+
+	sp = *server.DefaultStartParameters()
+	sp.WorkshopMap = "de_dust2"
+	if err := instance.Write(sp); err == nil {
+		t.Fatal("error expected but nil returned")
+	}
+}
@@ -5,11 +5,12 @@ import (
 	"sync"
 
 	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/steamcmd"
 
 	"github.com/google/uuid"
 )
 
-func NewStatus(isGameServerInstalled bool, hostname string, ip string, port string, password string, maxPlayerCount uint8, startMap string) *Status {
+func NewStatus(isGameServerInstalled bool, hostname string, ip string, port string, password string, maxPlayerCount uint8, startMap string, gameMode string, workshopMap string, workshopCollection string, tvEnable bool) *Status {
 	instance := Status{
 		internalStatus: &InternalStatus{
 			IsGameServerInstalled: isGameServerInstalled,
@@ -21,6 +22,10 @@ func NewStatus(isGameServerInstalled bool, hostname string, ip string, port stri
 			Ip:                    ip,
 			Port:                  port,
 			Password:              password,
+			GameMode:              gameMode,
+			WorkshopMap:           workshopMap,
+			WorkshopCollection:    workshopCollection,
+			TvEnable:              tvEnable,
 		},
 	}
 
@@ -34,9 +39,11 @@ const (
 	ServerStarting     State = "server-starting"
 	ServerStarted      State = "server-started"
 	ServerStopping     State = "server-stopping"
+	CrashLoop          State = "crash-loop"
 	SteamcmdUpdating   State = "steamcmd-updating"
 	PluginInstalling   State = "plugin-installing"
 	PluginUninstalling State = "plugin-uninstalling"
+	PluginUpgrading    State = "plugin-upgrading"
 )
 
 type InternalStatus struct {
@@ -49,6 +56,16 @@ type InternalStatus struct {
 	Ip                    string `json:"ip"`
 	Port                  string `json:"port"`
 	Password              string `json:"password"`
+	GameMode              string `json:"game_mode"`
+	WorkshopMap           string `json:"workshop_map"`
+	WorkshopCollection    string `json:"workshop_collection"`
+	TvEnable              bool   `json:"tv_enable"`
+	UpdateAvailable       bool   `json:"update_available"`
+	InstalledBuildId      string `json:"installed_build_id"`
+	InstalledBranch       string `json:"installed_branch"`
+	LatestBuildId         string `json:"latest_build_id"`
+	// only set while the state is SteamcmdUpdating and steamcmd reported progress
+	UpdateProgress *steamcmd.Progress `json:"update_progress"`
 }
 
 type Status struct {
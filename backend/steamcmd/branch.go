@@ -0,0 +1,80 @@
+package steamcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Phi-S/cs-server-manager/gvalidator"
+)
+
+type UpdateOptions struct {
+	// empty uses the last used branch
+	Branch         string `validate:"omitempty,steam_branch"`
+	BranchPassword string `validate:"omitempty,steam_branch_password"`
+	Validate       bool
+}
+
+// DefaultUpdateOptions updates the last used branch and validates all files
+func DefaultUpdateOptions() UpdateOptions {
+	return UpdateOptions{Validate: true}
+}
+
+// Branch is the last branch used for an update
+type Branch struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// LastBranch returns the branch of the last update or the public branch if no branch was used yet
+func (s *Instance) LastBranch() (Branch, error) {
+	content, err := os.ReadFile(s.branchJsonPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Branch{Name: DefaultBranch}, nil
+		}
+		return Branch{}, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	var branch Branch
+	if err := json.Unmarshal(content, &branch); err != nil {
+		return Branch{}, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if branch.Name == "" {
+		branch.Name = DefaultBranch
+	}
+
+	return branch, nil
+}
+
+func (s *Instance) saveBranch(branch Branch) error {
+	content, err := json.MarshalIndent(branch, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
+	if err := os.WriteFile(s.branchJsonPath, content, os.ModePerm); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
+
+// resolveBranch returns the branch for the update options. The password of the last used branch is only
+// reused if no branch is given
+func (s *Instance) resolveBranch(options UpdateOptions) (Branch, error) {
+	if err := gvalidator.Instance().Struct(options); err != nil {
+		return Branch{}, fmt.Errorf("update options validation: %w", err)
+	}
+
+	if options.Branch == "" {
+		return s.LastBranch()
+	}
+
+	return Branch{
+		Name:     options.Branch,
+		Password: options.BranchPassword,
+	}, nil
+}
@@ -0,0 +1,199 @@
+package steamcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/google/uuid"
+)
+
+func Test_updateArgs(t *testing.T) {
+	args := updateArgs("/server", Branch{Name: "beta", Password: "s3cr3t"}, false)
+	expected := []string{"+force_install_dir /server", "+login anonymous", "+app_update 730", "-beta", "beta", "-betapassword", "s3cr3t", "+quit"}
+	if !slices.Equal(args, expected) {
+		t.Fatalf("expected %v but got %v", expected, args)
+	}
+
+	args = updateArgs("/server", Branch{Name: DefaultBranch}, true)
+	expected = []string{"+force_install_dir /server", "+login anonymous", "+app_update 730", "-beta", "public", "validate", "+quit"}
+	if !slices.Equal(args, expected) {
+		t.Fatalf("expected %v but got %v", expected, args)
+	}
+}
+
+func TestInstance_resolveBranch(t *testing.T) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	if err := os.Mkdir(tempDirPath, os.ModePerm); err != nil {
+		t.Fatal("os.Mkdir temp dir", err)
+	}
+
+	s, err := NewInstance(tempDirPath, tempDirPath, filepath.Join(tempDirPath, "steamcmd-branch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	branch, err := s.resolveBranch(DefaultUpdateOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if branch.Name != DefaultBranch || branch.Password != "" {
+		t.Fatalf("expected public branch without saved branch but got %+v", branch)
+	}
+
+	if err := s.saveBranch(Branch{Name: "beta", Password: "s3cr3t"}); err != nil {
+		t.Fatal(err)
+	}
+
+	branch, err = s.resolveBranch(DefaultUpdateOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if branch.Name != "beta" || branch.Password != "s3cr3t" {
+		t.Fatalf("expected last used branch but got %+v", branch)
+	}
+
+	branch, err = s.resolveBranch(UpdateOptions{Branch: DefaultBranch})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if branch.Name != DefaultBranch || branch.Password != "" {
+		t.Fatalf("password of the last used branch must not be used for another branch. branch: %+v", branch)
+	}
+
+	if _, err := s.resolveBranch(UpdateOptions{Branch: "beta +quit"}); err == nil {
+		t.Fatal("expected validation error for invalid branch")
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+// createFakeSteamcmd creates an instance with a steamcmd.sh that executes the script
+func createFakeSteamcmd(t *testing.T, script string) (*Instance, string) {
+	tempDirPath := filepath.Join(os.TempDir(), fmt.Sprintf("temp_test_%v", uuid.New()))
+	steamcmdDir := filepath.Join(tempDirPath, "steamcmd")
+	if err := os.MkdirAll(filepath.Join(steamcmdDir, "linux32"), os.ModePerm); err != nil {
+		t.Fatal("os.MkdirAll", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(steamcmdDir, "steamcmd.sh"), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal("os.WriteFile", err)
+	}
+
+	s, err := NewInstance(steamcmdDir, tempDirPath, filepath.Join(tempDirPath, "steamcmd-branch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, tempDirPath
+}
+
+func TestInstance_Update_FailedUpdateDoesNotSaveBranch(t *testing.T) {
+	// steamcmd exits without installing the server, e.g. because the branch does not exist
+	s, tempDirPath := createFakeSteamcmd(t, "echo \"ERROR! Failed to install app '730' (Invalid platform)\"\nexit 8\n")
+	branchJsonPath := filepath.Join(tempDirPath, "steamcmd-branch.json")
+
+	if err := s.UpdateAndWait(false, UpdateOptions{Branch: "bta"}); err == nil {
+		t.Fatal("expected failed update")
+	}
+
+	if _, err := os.Stat(branchJsonPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected branch of the failed update not to be saved but got", err)
+	}
+
+	branch, err := s.LastBranch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch.Name != DefaultBranch {
+		t.Fatalf("expected public branch after failed update but got %+v", branch)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Update_BeforeUpdateFailed(t *testing.T) {
+	s, tempDirPath := createFakeSteamcmd(t, "touch \"$(dirname \"$0\")/executed\"\n")
+
+	var failed error
+	s.OnFailed(func(p event.PayloadWithData[error]) {
+		failed = p.Data
+	})
+
+	snapshotErr := errors.New("disk full")
+	s.SetBeforeUpdate(func() error {
+		return snapshotErr
+	})
+
+	if err := s.UpdateAndWait(false, DefaultUpdateOptions()); !errors.Is(err, snapshotErr) {
+		t.Fatal("expected error of before update but got", err)
+	}
+
+	if !errors.Is(failed, snapshotErr) {
+		t.Fatal("expected failed event with the error of before update but got", failed)
+	}
+
+	if s.IsRunning() {
+		t.Fatal("steamcmd still running after failed update")
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDirPath, "steamcmd", "executed")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected steamcmd not to be executed but got", err)
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
+
+func TestInstance_Update_AfterUpdate(t *testing.T) {
+	s, tempDirPath := createFakeSteamcmd(t, "echo \"Success! App '730' fully installed.\"\nsleep 0.5\n")
+
+	finished := false
+	s.OnFinished(func(p event.DefaultPayload) {
+		finished = true
+	})
+
+	called := false
+	s.SetAfterUpdate(func() {
+		called = true
+		// the server can not be started before the function returned
+		if !s.IsRunning() {
+			t.Error("steamcmd not running while after update is called")
+		}
+		if finished {
+			t.Error("update finished before after update is called")
+		}
+	})
+
+	if err := s.UpdateAndWait(false, DefaultUpdateOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !called {
+		t.Fatal("after update not called")
+	}
+
+	if !t.Failed() {
+		defer func() {
+			_ = os.RemoveAll(tempDirPath)
+		}()
+	}
+}
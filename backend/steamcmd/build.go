@@ -0,0 +1,136 @@
+package steamcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/keyvalues"
+)
+
+const (
+	appId         = "730"
+	DefaultBranch = "public"
+
+	appInfoTimeout = time.Minute * 2
+)
+
+var (
+	ErrNotInstalled         = errors.New("server is not installed")
+	ErrSteamcmdNotInstalled = errors.New("steamcmd is not installed")
+	ErrBranchNotFound       = errors.New("branch not found")
+)
+
+// Build is the build of the server installed by steamcmd
+type Build struct {
+	BuildId string
+	Branch  string
+}
+
+func appManifestPath(serverDir string) string {
+	return filepath.Join(serverDir, "steamapps", "appmanifest_"+appId+".acf")
+}
+
+// InstalledBuild reads the build id and branch from the appmanifest of the installed server.
+// Returns ErrNotInstalled if the appmanifest does not exist
+func (s *Instance) InstalledBuild() (Build, error) {
+	content, err := os.ReadFile(appManifestPath(s.serverDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Build{}, ErrNotInstalled
+		}
+		return Build{}, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	return parseAppManifest(string(content))
+}
+
+func parseAppManifest(content string) (Build, error) {
+	appState, err := keyvalues.ParseString(content)
+	if err != nil {
+		return Build{}, fmt.Errorf("parse appmanifest: %w", err)
+	}
+
+	buildId := appState.GetValue("buildid")
+	if buildId == "" {
+		return Build{}, errors.New("appmanifest does not contain a build id")
+	}
+
+	branch := appState.GetValue("UserConfig", "BetaKey")
+	if branch == "" {
+		branch = DefaultBranch
+	}
+
+	return Build{
+		BuildId: buildId,
+		Branch:  branch,
+	}, nil
+}
+
+// LatestBuildId asks steam for the latest build id of the given branch.
+// The check is not possible while steamcmd is updating the server and blocks new updates until it is finished
+func (s *Instance) LatestBuildId(branch string) (string, error) {
+	s.startStopLock.Lock()
+	defer s.startStopLock.Unlock()
+
+	if s.IsRunning() {
+		return "", errors.New("SteamCmdService is busy")
+	}
+
+	if !IsSteamCmdInstalled(s.steamCmdDir) {
+		return "", ErrSteamcmdNotInstalled
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), appInfoTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, filepath.Join(s.steamCmdDir, "steamcmd.sh"),
+		"+login anonymous",
+		"+app_info_update 1",
+		"+app_info_print "+appId,
+		"+quit",
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("app_info_print: timeout of %v reached", appInfoTimeout)
+		}
+		return "", fmt.Errorf("app_info_print: %w", err)
+	}
+
+	return parseAppInfo(string(output), branch)
+}
+
+// parseAppInfo extracts the build id of the branch from the app_info_print output.
+// The app info is printed as KeyValues between other steamcmd output
+func parseAppInfo(output string, branch string) (string, error) {
+	start := strings.Index(output, "\""+appId+"\"\n")
+	if start == -1 {
+		start = strings.Index(output, "\""+appId+"\"\r\n")
+	}
+	if start == -1 {
+		return "", errors.New("app info not found in steamcmd output")
+	}
+
+	appInfo, err := keyvalues.ParseString(output[start:])
+	if err != nil {
+		return "", fmt.Errorf("parse app info: %w", err)
+	}
+
+	branchNode := appInfo.Get("depots", "branches", branch)
+	if branchNode == nil {
+		return "", fmt.Errorf("%w: '%v'", ErrBranchNotFound, branch)
+	}
+
+	buildId := branchNode.GetValue("buildid")
+	if buildId == "" {
+		return "", fmt.Errorf("app info does not contain a build id for branch '%v'", branch)
+	}
+
+	return buildId, nil
+}
@@ -0,0 +1,129 @@
+package steamcmd
+
+import (
+	"errors"
+	"testing"
+)
+
+const testAppManifest = `"AppState"
+{
+	"appid"		"730"
+	"Universe"		"1"
+	"name"		"Counter-Strike 2"
+	"StateFlags"		"4"
+	"installdir"		"Counter-Strike Global Offensive"
+	"LastUpdated"		"1728990000"
+	"SizeOnDisk"		"36453471210"
+	"buildid"		"15831209"
+	"LastOwner"		"18446744073709551615"
+	"UpdateResult"		"0"
+	"InstalledDepots"
+	{
+		"2347771"
+		{
+			"manifest"		"2611468359281154412"
+			"size"		"36453471210"
+		}
+	}
+	"UserConfig"
+	{
+		"BetaKey"		"beta"
+	}
+	"MountedConfig"
+	{
+	}
+}
+`
+
+const testAppInfoOutput = `Redirecting stderr to '/home/steam/Steam/logs/stderr.txt'
+Loading Steam API...OK
+Connecting anonymously to Steam Public...OK
+Waiting for client config...OK
+Waiting for user info...OK
+AppID : 730, change number : 25493102/0, last change : Mon Oct 14 18:33:05 2024
+"730"
+{
+	"common"
+	{
+		"name"		"Counter-Strike 2"
+		"type"		"Game"
+	}
+	"depots"
+	{
+		"2347771"
+		{
+			"manifests"
+			{
+				"public"
+				{
+					"gid"		"2611468359281154412"
+				}
+			}
+		}
+		"branches"
+		{
+			"public"
+			{
+				"buildid"		"15831209"
+				"timeupdated"		"1728929585"
+			}
+			"beta"
+			{
+				"buildid"		"15850001"
+				"description"		"beta {with braces}"
+				"timeupdated"		"1729000000"
+			}
+		}
+	}
+}
+Unloading Steam API...OK
+`
+
+func Test_parseAppManifest(t *testing.T) {
+	build, err := parseAppManifest(testAppManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if build.BuildId != "15831209" || build.Branch != "beta" {
+		t.Fatalf("unexpected build %+v", build)
+	}
+}
+
+func Test_parseAppManifest_DefaultBranch(t *testing.T) {
+	build, err := parseAppManifest(`"AppState" { "appid" "730" "buildid" "123" }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if build.BuildId != "123" || build.Branch != DefaultBranch {
+		t.Fatalf("unexpected build %+v", build)
+	}
+}
+
+func Test_parseAppInfo(t *testing.T) {
+	testData := map[string]string{
+		"public": "15831209",
+		"beta":   "15850001",
+		"BETA":   "15850001",
+	}
+
+	for branch, expectedBuildId := range testData {
+		buildId, err := parseAppInfo(testAppInfoOutput, branch)
+		if err != nil {
+			t.Fatal(branch, err)
+		}
+
+		if buildId != expectedBuildId {
+			t.Fatalf("expected build id %v for branch %v but got %v", expectedBuildId, branch, buildId)
+		}
+	}
+
+	if _, err := parseAppInfo(testAppInfoOutput, "does_not_exist"); !errors.Is(err, ErrBranchNotFound) {
+		t.Fatal("expected ErrBranchNotFound but got", err)
+	}
+
+	if _, err := parseAppInfo("Loading Steam API...OK\nUnloading Steam API...OK\n", DefaultBranch); err == nil {
+		t.Fatal("expected error for output without app info")
+	}
+}
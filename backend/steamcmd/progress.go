@@ -0,0 +1,90 @@
+package steamcmd
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress of the current update phase as reported by steamcmd
+type Progress struct {
+	Phase      string  `json:"phase"`
+	Percent    float64 `json:"percent"`
+	BytesDone  uint64  `json:"bytes_done"`
+	BytesTotal uint64  `json:"bytes_total"`
+	// nil until enough progress of the current phase is known to estimate the remaining time
+	EtaSeconds *int64 `json:"eta_seconds"`
+}
+
+// e.g. "Update state (0x61) downloading, progress: 45.12 (13431412 / 29767234)"
+var progressRegex = regexp.MustCompile(`Update state \(0x[0-9a-fA-F]+\) ([^,]+), progress: (\d+(?:\.\d+)?) \((\d+) / (\d+)\)`)
+
+// parseProgress returns the progress of the steamcmd output line. Returns false if the line is not a progress line
+func parseProgress(line string) (Progress, bool) {
+	match := progressRegex.FindStringSubmatch(line)
+	if match == nil {
+		return Progress{}, false
+	}
+
+	percent, err := strconv.ParseFloat(match[2], 64)
+	if err != nil {
+		return Progress{}, false
+	}
+
+	bytesDone, err := strconv.ParseUint(match[3], 10, 64)
+	if err != nil {
+		return Progress{}, false
+	}
+
+	bytesTotal, err := strconv.ParseUint(match[4], 10, 64)
+	if err != nil {
+		return Progress{}, false
+	}
+
+	// "verifying install" -> "verifying_install"
+	phase := strings.Join(strings.Fields(strings.ToLower(match[1])), "_")
+
+	return Progress{
+		Phase:      phase,
+		Percent:    percent,
+		BytesDone:  bytesDone,
+		BytesTotal: bytesTotal,
+	}, true
+}
+
+// progressTracker estimates the remaining time of the current phase from the average rate since the phase started
+type progressTracker struct {
+	now func() time.Time
+
+	phase      string
+	startedAt  time.Time
+	startBytes uint64
+}
+
+func newProgressTracker(now func() time.Time) *progressTracker {
+	return &progressTracker{now: now}
+}
+
+func (t *progressTracker) track(p Progress) Progress {
+	now := t.now()
+
+	if p.Phase != t.phase || p.BytesDone < t.startBytes {
+		t.phase = p.Phase
+		t.startedAt = now
+		t.startBytes = p.BytesDone
+		return p
+	}
+
+	elapsed := now.Sub(t.startedAt)
+	done := p.BytesDone - t.startBytes
+	if elapsed < time.Second || done == 0 || p.BytesTotal < p.BytesDone {
+		return p
+	}
+
+	bytesPerSecond := float64(done) / elapsed.Seconds()
+	eta := int64(math.Ceil(float64(p.BytesTotal-p.BytesDone) / bytesPerSecond))
+	p.EtaSeconds = &eta
+	return p
+}
@@ -0,0 +1,69 @@
+package steamcmd
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseProgress(t *testing.T) {
+	testData := map[string]Progress{
+		"Update state (0x61) downloading, progress: 45.12 (13431412 / 29767234)": {
+			Phase: "downloading", Percent: 45.12, BytesDone: 13431412, BytesTotal: 29767234,
+		},
+		"Update state (0x5) verifying install, progress: 3.09 (1126891298 / 36453471210)": {
+			Phase: "verifying_install", Percent: 3.09, BytesDone: 1126891298, BytesTotal: 36453471210,
+		},
+		"Update state (0x101) committing, progress: 100.00 (29767234 / 29767234)": {
+			Phase: "committing", Percent: 100, BytesDone: 29767234, BytesTotal: 29767234,
+		},
+		"Update state (0x11) preallocating, progress: 0 (0 / 0)": {
+			Phase: "preallocating", Percent: 0, BytesDone: 0, BytesTotal: 0,
+		},
+	}
+
+	for line, expected := range testData {
+		progress, ok := parseProgress(line)
+		if !ok {
+			t.Fatalf("line %q not detected as progress", line)
+		}
+
+		if progress.Phase != expected.Phase || progress.Percent != expected.Percent ||
+			progress.BytesDone != expected.BytesDone || progress.BytesTotal != expected.BytesTotal {
+			t.Fatalf("line %q: expected %+v but got %+v", line, expected, progress)
+		}
+	}
+
+	for _, line := range []string{
+		"Success! App '730' fully installed.",
+		"Update state (0x61) downloading, progress: 45.12",
+		"Loading Steam API...OK",
+	} {
+		if _, ok := parseProgress(line); ok {
+			t.Fatalf("line %q detected as progress", line)
+		}
+	}
+}
+
+func Test_progressTracker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	tracker := newProgressTracker(func() time.Time { return now })
+
+	p := tracker.track(Progress{Phase: "downloading", BytesDone: 1000, BytesTotal: 11000})
+	if p.EtaSeconds != nil {
+		t.Fatal("no eta expected for the first progress of a phase")
+	}
+
+	// 1000 bytes per second and 8000 bytes remaining
+	now = now.Add(time.Second * 2)
+	p = tracker.track(Progress{Phase: "downloading", BytesDone: 3000, BytesTotal: 11000})
+	if p.EtaSeconds == nil || *p.EtaSeconds != 8 {
+		t.Fatalf("expected eta of 8 seconds but got %+v", p.EtaSeconds)
+	}
+
+	// a new phase resets the rate
+	now = now.Add(time.Second)
+	p = tracker.track(Progress{Phase: "verifying_update", BytesDone: 5000, BytesTotal: 11000})
+	if p.EtaSeconds != nil {
+		t.Fatal("no eta expected for the first progress of a new phase")
+	}
+}
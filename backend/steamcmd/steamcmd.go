@@ -2,28 +2,33 @@ package steamcmd
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/Phi-S/cs-server-manager/download"
 	"github.com/Phi-S/cs-server-manager/event"
 	"github.com/Phi-S/cs-server-manager/gvalidator"
 
 	"github.com/creack/pty"
 )
 
-var instanceCreated = false
+var ErrCancelled = errors.New("steamcmd update cancelled")
 
 type Instance struct {
-	steamCmdDir string
-	serverDir   string
+	steamCmdDir    string
+	serverDir      string
+	branchJsonPath string
 
 	running  atomic.Bool
 	canceled atomic.Bool
@@ -33,19 +38,26 @@ type Instance struct {
 	cmd *exec.Cmd
 
 	startStopLock sync.Mutex
+	// cancels the download of steamcmd. Nil while steamcmd is not downloaded
+	cancelDownload atomic.Pointer[context.CancelFunc]
+
+	waitersLock sync.Mutex
+	waiters     []chan error
+
+	// called before steamcmd changes any files. Set with SetBeforeUpdate
+	beforeUpdate func() error
+	// called after a successful update while steamcmd is still running. Set with SetAfterUpdate
+	afterUpdate func()
 
 	onOutput    event.InstanceWithData[string]
+	onProgress  event.InstanceWithData[Progress]
 	onStarted   event.Instance
 	onFinished  event.Instance
 	onCancelled event.Instance
 	onFailed    event.InstanceWithData[error]
 }
 
-func NewInstance(steamcmdDir, serverDir string) (*Instance, error) {
-	if instanceCreated {
-		return nil, errors.New("another instance already exists. Use only one instance throughout the program")
-	}
-
+func NewInstance(steamcmdDir, serverDir, branchJsonPath string) (*Instance, error) {
 	if err := gvalidator.Instance().Var(steamcmdDir, "required,dir"); err != nil {
 		return nil, fmt.Errorf("steamcmd dir %v is not a valid filepath %w", serverDir, err)
 	}
@@ -54,15 +66,21 @@ func NewInstance(steamcmdDir, serverDir string) (*Instance, error) {
 		return nil, fmt.Errorf("server dir %v is not a valid filepath %w", serverDir, err)
 	}
 
+	if err := gvalidator.Instance().Var(branchJsonPath, "required,filepath"); err != nil {
+		return nil, fmt.Errorf("branch json path %v is not a valid filepath %w", branchJsonPath, err)
+	}
+
 	i := Instance{
-		steamCmdDir: steamcmdDir,
-		serverDir:   serverDir,
+		steamCmdDir:    steamcmdDir,
+		serverDir:      serverDir,
+		branchJsonPath: branchJsonPath,
 	}
 
 	i.onFailed.Register(func(pwd event.PayloadWithData[error]) {
 		i.Close()
 		i.running.Store(false)
 		i.lastLine.Store("")
+		i.notifyWaiters(pwd.Data)
 	})
 
 	i.onCancelled.Register(func(dp event.DefaultPayload) {
@@ -70,15 +88,16 @@ func NewInstance(steamcmdDir, serverDir string) (*Instance, error) {
 		i.lastLine.Store("")
 		i.running.Store(false)
 		i.canceled.Store(false)
+		i.notifyWaiters(ErrCancelled)
 	})
 
 	i.onFinished.Register(func(dp event.DefaultPayload) {
 		i.Close()
 		i.lastLine.Store("")
 		i.running.Store(false)
+		i.notifyWaiters(nil)
 	})
 
-	instanceCreated = true
 	return &i, nil
 }
 
@@ -101,7 +120,8 @@ func (s *Instance) IsRunning() bool {
 	return s.running.Load()
 }
 
-func (s *Instance) Update(force bool) error {
+// Update installs or updates the server. After the update finished successfully, the used branch is saved and used by later updates without a branch
+func (s *Instance) Update(force bool, options UpdateOptions) error {
 	s.startStopLock.Lock()
 	defer s.startStopLock.Unlock()
 
@@ -109,21 +129,38 @@ func (s *Instance) Update(force bool) error {
 		return errors.New("SteamCmdService is busy")
 	}
 
+	branch, err := s.resolveBranch(options)
+	if err != nil {
+		return err
+	}
+
 	s.running.Store(true)
 	s.onStarted.Trigger()
 
+	if s.beforeUpdate != nil {
+		if err := s.beforeUpdate(); err != nil {
+			err = fmt.Errorf("before update: %w", err)
+			s.onFailed.Trigger(err)
+			return err
+		}
+	}
+
 	if force {
 		_ = os.RemoveAll(s.steamCmdDir)
 	}
 
 	if !IsSteamCmdInstalled(s.steamCmdDir) {
-		if err := downloadSteamCmd(s.steamCmdDir); err != nil {
+		if err := s.download(); err != nil {
+			if errors.Is(err, context.Canceled) {
+				s.onCancelled.Trigger()
+				return ErrCancelled
+			}
 			s.onFailed.Trigger(err)
 			return err
 		}
 	}
 
-	if err := s.update(); err != nil {
+	if err := s.update(branch, options.Validate); err != nil {
 		s.onFailed.Trigger(err)
 		return err
 	}
@@ -131,20 +168,90 @@ func (s *Instance) Update(force bool) error {
 	return nil
 }
 
-func (s *Instance) update() error {
+// SetBeforeUpdate sets the function that is called after an update started and before steamcmd changes any files.
+// If it returns an error, the update fails with the error. Has to be set before the first update is started
+func (s *Instance) SetBeforeUpdate(beforeUpdate func() error) {
+	s.beforeUpdate = beforeUpdate
+}
+
+// SetAfterUpdate sets the function that is called after an update finished successfully and before the update is reported as finished.
+// Steamcmd is running until it returns, so the server can not be started before it is done. Has to be set before the first update is started
+func (s *Instance) SetAfterUpdate(afterUpdate func()) {
+	s.afterUpdate = afterUpdate
+}
+
+// download downloads steamcmd. The download progress is reported like the progress of the update. Cancel stops the download
+func (s *Instance) download() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.cancelDownload.Store(&cancel)
+	defer s.cancelDownload.Store(nil)
+
+	return downloadSteamCmd(ctx, s.steamCmdDir, func(p download.Progress) {
+		s.onProgress.Trigger(Progress{
+			Phase:      "downloading_steamcmd",
+			Percent:    math.Round(p.Percent*100) / 100,
+			BytesDone:  uint64(p.BytesDone),
+			BytesTotal: uint64(p.BytesTotal),
+		})
+	})
+}
+
+// UpdateAndWait starts the update and blocks until it is finished, failed or cancelled
+func (s *Instance) UpdateAndWait(force bool, options UpdateOptions) error {
+	wait, err := s.StartUpdate(force, options)
+	if err != nil {
+		return err
+	}
+
+	return wait()
+}
+
+// StartUpdate starts the update like Update. The returned function blocks until the update is finished, failed or cancelled
+func (s *Instance) StartUpdate(force bool, options UpdateOptions) (func() error, error) {
+	result := make(chan error, 1)
+
+	s.waitersLock.Lock()
+	s.waiters = append(s.waiters, result)
+	s.waitersLock.Unlock()
+
+	if err := s.Update(force, options); err != nil {
+		s.removeWaiter(result)
+		return nil, err
+	}
+
+	return func() error {
+		return <-result
+	}, nil
+}
+
+func (s *Instance) notifyWaiters(err error) {
+	s.waitersLock.Lock()
+	defer s.waitersLock.Unlock()
+
+	for _, waiter := range s.waiters {
+		waiter <- err
+	}
+	s.waiters = nil
+}
+
+func (s *Instance) removeWaiter(waiter chan error) {
+	s.waitersLock.Lock()
+	defer s.waitersLock.Unlock()
+	s.waiters = slices.DeleteFunc(s.waiters, func(w chan error) bool {
+		return w == waiter
+	})
+}
+
+func (s *Instance) update(branch Branch, validate bool) error {
 	var steamCmdShFilePath = filepath.Join(s.steamCmdDir, "steamcmd.sh")
 
 	if err := os.MkdirAll(s.serverDir, 0755); err != nil {
 		return err
 	}
 
-	cmd := exec.Command(steamCmdShFilePath,
-		"+force_install_dir "+s.serverDir,
-		"+login anonymous",
-		"+app_update 730",
-		"validate",
-		"+quit",
-	)
+	cmd := exec.Command(steamCmdShFilePath, updateArgs(s.serverDir, branch, validate)...)
 	f, err := pty.Start(cmd)
 	if err != nil {
 		s.onFailed.Trigger(err)
@@ -154,13 +261,33 @@ func (s *Instance) update() error {
 	s.pty = f
 	s.cmd = cmd
 
-	go s.checkIfCmdIsRunning(cmd)
-	go s.readOutput(f)
+	go s.checkIfCmdIsRunning(cmd, branch)
+	go s.readOutput(f, newProgressTracker(time.Now))
 
 	return nil
 }
 
-func (s *Instance) checkIfCmdIsRunning(cmd *exec.Cmd) {
+// the branch is always passed, so switching back to the public branch opts out of a beta branch
+func updateArgs(serverDir string, branch Branch, validate bool) []string {
+	args := []string{
+		"+force_install_dir " + serverDir,
+		"+login anonymous",
+		"+app_update " + appId,
+		"-beta", branch.Name,
+	}
+
+	if branch.Password != "" {
+		args = append(args, "-betapassword", branch.Password)
+	}
+
+	if validate {
+		args = append(args, "validate")
+	}
+
+	return append(args, "+quit")
+}
+
+func (s *Instance) checkIfCmdIsRunning(cmd *exec.Cmd, branch Branch) {
 	err := cmd.Wait()
 
 	if s.canceled.Load() {
@@ -168,6 +295,13 @@ func (s *Instance) checkIfCmdIsRunning(cmd *exec.Cmd) {
 		s.onCancelled.Trigger()
 	} else if s.lastLine.Load() == "Success! App '730' fully installed." {
 		slog.Debug("steamcmd finished")
+		// a failed update, e.g. because of a typo in the branch name, does not change the branch of later updates
+		if err := s.saveBranch(branch); err != nil {
+			slog.Error("steamcmd finished: save branch", "branch", branch.Name, "error", err)
+		}
+		if s.afterUpdate != nil {
+			s.afterUpdate()
+		}
 		s.onFinished.Trigger()
 		return
 	} else if err != nil {
@@ -182,7 +316,7 @@ func (s *Instance) checkIfCmdIsRunning(cmd *exec.Cmd) {
 	slog.Debug("checkIfCmdIsRunning exited")
 }
 
-func (s *Instance) readOutput(f *os.File) {
+func (s *Instance) readOutput(f *os.File, tracker *progressTracker) {
 	scanner := bufio.NewScanner(f)
 
 	for scanner.Scan() {
@@ -194,6 +328,10 @@ func (s *Instance) readOutput(f *os.File) {
 
 		s.onOutput.Trigger(lastLine)
 		s.lastLine.Store(lastLine)
+
+		if progress, ok := parseProgress(lastLine); ok {
+			s.onProgress.Trigger(tracker.track(progress))
+		}
 	}
 
 	slog.Debug("read output exited")
@@ -204,6 +342,11 @@ func (s *Instance) Cancel() error {
 		return errors.New("steamcmd is not running")
 	}
 
+	// the download holds the start stop lock until it is finished
+	if cancelDownload := s.cancelDownload.Load(); cancelDownload != nil {
+		(*cancelDownload)()
+	}
+
 	s.startStopLock.Lock()
 	defer s.startStopLock.Unlock()
 	s.canceled.Store(true)
@@ -1,13 +1,12 @@
 package steamcmd
 
 import (
-	"errors"
-	"io"
-	"net/http"
+	"context"
 	"os"
 	"path/filepath"
 
-	download "github.com/Phi-S/cs-server-manager/download/unzip"
+	"github.com/Phi-S/cs-server-manager/download"
+	"github.com/Phi-S/cs-server-manager/download/unzip"
 )
 
 func IsSteamCmdInstalled(steamCmdPath string) bool {
@@ -26,31 +25,28 @@ func IsSteamCmdInstalled(steamCmdPath string) bool {
 	return true
 }
 
-func downloadSteamCmd(steamCmdPath string) error {
-	if err := os.MkdirAll(steamCmdPath, 0755); err != nil {
-		return err
-	}
+const steamCmdDownloadUrl = "https://steamcdn-a.akamaihd.net/client/installer/steamcmd_linux.tar.gz"
 
-	resp, err := http.Get("https://steamcdn-a.akamaihd.net/client/installer/steamcmd_linux.tar.gz")
-	if err != nil {
+// downloadSteamCmd downloads and extracts steamcmd. onProgress can be nil
+func downloadSteamCmd(ctx context.Context, steamCmdPath string, onProgress func(download.Progress)) error {
+	if err := os.MkdirAll(steamCmdPath, 0755); err != nil {
 		return err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return errors.New("failed with status code " + resp.Status)
-	}
+	options := download.DefaultOptions()
+	options.OnProgress = onProgress
 
-	var steamCmdTarGzFilePath = filepath.Join(steamCmdPath, "steamcmd_linux.tar.gz")
-	file, err := os.Create(steamCmdTarGzFilePath)
+	steamCmdTarGzFilePath, err := download.Download(ctx, steamCmdDownloadUrl, steamCmdPath, options)
 	if err != nil {
 		return err
 	}
 
-	if _, err = io.Copy(file, resp.Body); err != nil {
-		return err
-	}
+	// the archive is downloaded into the steamcmd dir and is not needed anymore after the extraction
+	defer func() {
+		_ = os.Remove(steamCmdTarGzFilePath)
+	}()
 
-	if _, err := download.TarGz(steamCmdTarGzFilePath, steamCmdPath); err != nil {
+	if _, err := unzip.Extract(steamCmdTarGzFilePath, steamCmdPath); err != nil {
 		return err
 	}
 
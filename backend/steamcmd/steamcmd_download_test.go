@@ -1,6 +1,7 @@
 package steamcmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -15,7 +16,7 @@ func Test_downloadSteamCmd(t *testing.T) {
 		t.Fatal("os.Mkdir temp dir", err)
 	}
 
-	if err := downloadSteamCmd(tempDirPath); err != nil {
+	if err := downloadSteamCmd(context.Background(), tempDirPath, nil); err != nil {
 		t.Fatal(err)
 	}
 
@@ -6,6 +6,10 @@ func (s *Instance) OnOutput(handler func(event.PayloadWithData[string])) {
 	s.onOutput.Register(handler)
 }
 
+func (s *Instance) OnProgress(handler func(event.PayloadWithData[Progress])) {
+	s.onProgress.Register(handler)
+}
+
 func (s *Instance) OnStarted(handler func(event.DefaultPayload)) {
 	s.onStarted.Register(handler)
 }
@@ -0,0 +1,251 @@
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/server"
+)
+
+type Server interface {
+	IsRunning() bool
+	Start(sp server.StartParameters) error
+	OnStarted(handler func(event.PayloadWithData[server.StartParameters]))
+}
+
+type Steamcmd interface {
+	IsRunning() bool
+}
+
+type Config struct {
+	Enabled        bool
+	MaxRestarts    int
+	Window         time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+type RestartAttempt struct {
+	Attempt int
+	Backoff time.Duration
+}
+
+type CrashLoop struct {
+	Restarts int
+	Window   time.Duration
+}
+
+// Instance restarts the server with the last used start parameters after it crashed.
+// If the server crashes more often than Config.MaxRestarts in Config.Window, no further restarts are attempted
+type Instance struct {
+	cfg Config
+
+	serverInstance     Server
+	steamcmdInstance   Steamcmd
+	serverSteamcmdLock *sync.Mutex
+
+	lock                sync.Mutex
+	lastStartParameters *server.StartParameters
+	restarts            []time.Time
+	restarting          atomic.Bool
+	cancel              chan struct{}
+
+	now func() time.Time
+
+	onRestarting        event.InstanceWithData[RestartAttempt]
+	onRestarted         event.InstanceWithData[RestartAttempt]
+	onRestartFailed     event.InstanceWithData[error]
+	onCrashLoopDetected event.InstanceWithData[CrashLoop]
+}
+
+func New(cfg Config, serverInstance Server, steamcmdInstance Steamcmd, serverSteamcmdLock *sync.Mutex) (*Instance, error) {
+	if cfg.Enabled {
+		if cfg.MaxRestarts < 1 {
+			return nil, fmt.Errorf("max restarts must be at least 1 but is %v", cfg.MaxRestarts)
+		}
+
+		if cfg.Window <= 0 || cfg.InitialBackoff <= 0 || cfg.MaxBackoff < cfg.InitialBackoff {
+			return nil, fmt.Errorf("invalid window or backoff configuration %+v", cfg)
+		}
+	}
+
+	i := &Instance{
+		cfg:                cfg,
+		serverInstance:     serverInstance,
+		steamcmdInstance:   steamcmdInstance,
+		serverSteamcmdLock: serverSteamcmdLock,
+		now:                time.Now,
+	}
+
+	serverInstance.OnStarted(func(p event.PayloadWithData[server.StartParameters]) {
+		i.lock.Lock()
+		defer i.lock.Unlock()
+		sp := p.Data
+		i.lastStartParameters = &sp
+	})
+
+	return i, nil
+}
+
+func (i *Instance) IsRestarting() bool {
+	return i.restarting.Load()
+}
+
+// HandleCrash starts the restart loop in the background.
+// Crashes that happen while a restart is already in progress are ignored, because they are handled by the restart loop itself
+func (i *Instance) HandleCrash(crashErr error) {
+	if !i.cfg.Enabled {
+		return
+	}
+
+	if !i.restarting.CompareAndSwap(false, true) {
+		return
+	}
+
+	i.lock.Lock()
+	if i.lastStartParameters == nil {
+		i.lock.Unlock()
+		i.restarting.Store(false)
+		slog.Debug("server crashed before it was started successfully. Not restarting", "error", crashErr)
+		return
+	}
+	startParameters := *i.lastStartParameters
+	cancel := make(chan struct{})
+	i.cancel = cancel
+	i.lock.Unlock()
+
+	if crashLoop, ok := i.recordRestart(); !ok {
+		i.restarting.Store(false)
+		i.onCrashLoopDetected.Trigger(crashLoop)
+		return
+	}
+
+	go i.restartLoop(startParameters, cancel)
+}
+
+// Cancel stops a pending restart. Should be called if the server is stopped manually
+func (i *Instance) Cancel() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.cancel != nil {
+		close(i.cancel)
+		i.cancel = nil
+	}
+}
+
+// Reset clears the restart history. Should be called if the server is started manually
+func (i *Instance) Reset() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.restarts = nil
+}
+
+func (i *Instance) restartLoop(startParameters server.StartParameters, cancel chan struct{}) {
+	defer i.restarting.Store(false)
+
+	for attempt := 1; ; attempt++ {
+		restartAttempt := RestartAttempt{
+			Attempt: attempt,
+			Backoff: i.backoff(),
+		}
+		i.onRestarting.Trigger(restartAttempt)
+
+		select {
+		case <-cancel:
+			slog.Debug("server restart cancelled")
+			return
+		case <-time.After(restartAttempt.Backoff):
+		}
+
+		err := i.start(startParameters)
+		if err == nil {
+			i.onRestarted.Trigger(restartAttempt)
+			return
+		}
+
+		if errors.Is(err, errRestartNotRequired) {
+			slog.Debug("server restart not required", "reason", err)
+			return
+		}
+
+		i.onRestartFailed.Trigger(err)
+
+		if crashLoop, ok := i.recordRestart(); !ok {
+			i.onCrashLoopDetected.Trigger(crashLoop)
+			return
+		}
+	}
+}
+
+var errRestartNotRequired = errors.New("server is already running or steamcmd is updating")
+
+func (i *Instance) start(startParameters server.StartParameters) error {
+	i.serverSteamcmdLock.Lock()
+	defer i.serverSteamcmdLock.Unlock()
+
+	if i.serverInstance.IsRunning() || i.steamcmdInstance.IsRunning() {
+		return errRestartNotRequired
+	}
+
+	return i.serverInstance.Start(startParameters)
+}
+
+// recordRestart adds a restart to the history. Returns false if the restart limit for the current window is reached
+func (i *Instance) recordRestart() (CrashLoop, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	now := i.now()
+	restartsInWindow := make([]time.Time, 0, len(i.restarts)+1)
+	for _, restart := range i.restarts {
+		if now.Sub(restart) < i.cfg.Window {
+			restartsInWindow = append(restartsInWindow, restart)
+		}
+	}
+
+	if len(restartsInWindow) >= i.cfg.MaxRestarts {
+		i.restarts = restartsInWindow
+		return CrashLoop{Restarts: len(restartsInWindow), Window: i.cfg.Window}, false
+	}
+
+	i.restarts = append(restartsInWindow, now)
+	return CrashLoop{}, true
+}
+
+// backoff doubles with every restart in the current window, starting with Config.InitialBackoff
+func (i *Instance) backoff() time.Duration {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	backoff := i.cfg.InitialBackoff
+	for n := 1; n < len(i.restarts); n++ {
+		backoff *= 2
+		if backoff >= i.cfg.MaxBackoff {
+			return i.cfg.MaxBackoff
+		}
+	}
+
+	return backoff
+}
+
+func (i *Instance) OnRestarting(handler func(event.PayloadWithData[RestartAttempt])) {
+	i.onRestarting.Register(handler)
+}
+
+func (i *Instance) OnRestarted(handler func(event.PayloadWithData[RestartAttempt])) {
+	i.onRestarted.Register(handler)
+}
+
+func (i *Instance) OnRestartFailed(handler func(event.PayloadWithData[error])) {
+	i.onRestartFailed.Register(handler)
+}
+
+func (i *Instance) OnCrashLoopDetected(handler func(event.PayloadWithData[CrashLoop])) {
+	i.onCrashLoopDetected.Register(handler)
+}
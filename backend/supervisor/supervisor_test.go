@@ -0,0 +1,232 @@
+package supervisor_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/server"
+	"github.com/Phi-S/cs-server-manager/supervisor"
+)
+
+type fakeServer struct {
+	running    atomic.Bool
+	starts     atomic.Int32
+	startErr   error
+	onStarted  event.InstanceWithData[server.StartParameters]
+	lastParams atomic.Value
+}
+
+func (f *fakeServer) IsRunning() bool {
+	return f.running.Load()
+}
+
+func (f *fakeServer) Start(sp server.StartParameters) error {
+	f.starts.Add(1)
+	if f.startErr != nil {
+		return f.startErr
+	}
+	f.lastParams.Store(sp)
+	f.running.Store(true)
+	return nil
+}
+
+func (f *fakeServer) OnStarted(handler func(event.PayloadWithData[server.StartParameters])) {
+	f.onStarted.Register(handler)
+}
+
+type fakeSteamcmd struct{}
+
+func (f *fakeSteamcmd) IsRunning() bool {
+	return false
+}
+
+func testConfig() supervisor.Config {
+	return supervisor.Config{
+		Enabled:        true,
+		MaxRestarts:    2,
+		Window:         time.Minute,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     40 * time.Millisecond,
+	}
+}
+
+func newTestSupervisor(t *testing.T, cfg supervisor.Config, srv *fakeServer) *supervisor.Instance {
+	s, err := supervisor.New(cfg, srv, &fakeSteamcmd{}, &sync.Mutex{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func waitUntil(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSupervisor_New_InvalidConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxBackoff = time.Millisecond
+	if _, err := supervisor.New(cfg, &fakeServer{}, &fakeSteamcmd{}, &sync.Mutex{}); err == nil {
+		t.Fatal("error expected but nil returned")
+	}
+
+	cfg.Enabled = false
+	if _, err := supervisor.New(cfg, &fakeServer{}, &fakeSteamcmd{}, &sync.Mutex{}); err != nil {
+		t.Fatal("config should not be validated if disabled", err)
+	}
+}
+
+func TestSupervisor_HandleCrash_RestartsWithLastStartParameters(t *testing.T) {
+	srv := &fakeServer{}
+	s := newTestSupervisor(t, testConfig(), srv)
+
+	var restarted atomic.Bool
+	s.OnRestarted(func(p event.PayloadWithData[supervisor.RestartAttempt]) {
+		restarted.Store(true)
+	})
+
+	sp := *server.DefaultStartParameters()
+	sp.Hostname = "supervisor test"
+	srv.onStarted.Trigger(sp)
+
+	s.HandleCrash(errors.New("crashed"))
+	waitUntil(t, restarted.Load)
+
+	if srv.starts.Load() != 1 {
+		t.Fatalf("expected 1 start but got %v", srv.starts.Load())
+	}
+
+	if srv.lastParams.Load().(server.StartParameters).Hostname != sp.Hostname {
+		t.Fatal("server not restarted with the last start parameters")
+	}
+}
+
+func TestSupervisor_HandleCrash_NotStartedBefore(t *testing.T) {
+	srv := &fakeServer{}
+	s := newTestSupervisor(t, testConfig(), srv)
+
+	s.HandleCrash(errors.New("crashed"))
+	time.Sleep(50 * time.Millisecond)
+
+	if srv.starts.Load() != 0 || s.IsRestarting() {
+		t.Fatal("server should not be restarted if it was never started")
+	}
+}
+
+func TestSupervisor_HandleCrash_CrashLoopDetected(t *testing.T) {
+	srv := &fakeServer{}
+	s := newTestSupervisor(t, testConfig(), srv)
+
+	var crashLoop atomic.Bool
+	s.OnCrashLoopDetected(func(p event.PayloadWithData[supervisor.CrashLoop]) {
+		crashLoop.Store(true)
+	})
+
+	var backoffs []time.Duration
+	var backoffsLock sync.Mutex
+	s.OnRestarting(func(p event.PayloadWithData[supervisor.RestartAttempt]) {
+		backoffsLock.Lock()
+		defer backoffsLock.Unlock()
+		backoffs = append(backoffs, p.Data.Backoff)
+	})
+
+	srv.onStarted.Trigger(*server.DefaultStartParameters())
+
+	for range 2 {
+		s.HandleCrash(errors.New("crashed"))
+		waitUntil(t, func() bool { return !s.IsRestarting() })
+		srv.running.Store(false)
+	}
+
+	if crashLoop.Load() {
+		t.Fatal("crash loop detected too early")
+	}
+
+	s.HandleCrash(errors.New("crashed"))
+	waitUntil(t, crashLoop.Load)
+
+	if srv.starts.Load() != 2 {
+		t.Fatalf("expected 2 starts but got %v", srv.starts.Load())
+	}
+
+	backoffsLock.Lock()
+	defer backoffsLock.Unlock()
+	if len(backoffs) != 2 || backoffs[1] != 2*backoffs[0] {
+		t.Fatalf("backoff should double with every restart. backoffs: %v", backoffs)
+	}
+}
+
+func TestSupervisor_Reset(t *testing.T) {
+	srv := &fakeServer{}
+	s := newTestSupervisor(t, testConfig(), srv)
+
+	var crashLoop atomic.Bool
+	s.OnCrashLoopDetected(func(p event.PayloadWithData[supervisor.CrashLoop]) {
+		crashLoop.Store(true)
+	})
+
+	srv.onStarted.Trigger(*server.DefaultStartParameters())
+
+	for range 4 {
+		s.HandleCrash(errors.New("crashed"))
+		waitUntil(t, func() bool { return !s.IsRestarting() })
+		srv.running.Store(false)
+		s.Reset()
+	}
+
+	if crashLoop.Load() {
+		t.Fatal("crash loop should not be detected after reset")
+	}
+}
+
+func TestSupervisor_Cancel(t *testing.T) {
+	srv := &fakeServer{}
+	cfg := testConfig()
+	cfg.InitialBackoff = 200 * time.Millisecond
+	cfg.MaxBackoff = 200 * time.Millisecond
+	s := newTestSupervisor(t, cfg, srv)
+
+	srv.onStarted.Trigger(*server.DefaultStartParameters())
+
+	s.HandleCrash(errors.New("crashed"))
+	s.Cancel()
+	waitUntil(t, func() bool { return !s.IsRestarting() })
+	time.Sleep(300 * time.Millisecond)
+
+	if srv.starts.Load() != 0 {
+		t.Fatal("server restarted after cancel")
+	}
+}
+
+func TestSupervisor_RestartFailed(t *testing.T) {
+	srv := &fakeServer{startErr: errors.New("start failed")}
+	s := newTestSupervisor(t, testConfig(), srv)
+
+	var failed atomic.Int32
+	s.OnRestartFailed(func(p event.PayloadWithData[error]) {
+		failed.Add(1)
+	})
+
+	var crashLoop atomic.Bool
+	s.OnCrashLoopDetected(func(p event.PayloadWithData[supervisor.CrashLoop]) {
+		crashLoop.Store(true)
+	})
+
+	srv.onStarted.Trigger(*server.DefaultStartParameters())
+
+	s.HandleCrash(errors.New("crashed"))
+	waitUntil(t, crashLoop.Load)
+
+	if failed.Load() != 2 {
+		t.Fatalf("expected 2 failed restarts but got %v", failed.Load())
+	}
+}
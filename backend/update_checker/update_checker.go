@@ -0,0 +1,186 @@
+package update_checker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/steamcmd"
+)
+
+type Steamcmd interface {
+	InstalledBuild() (steamcmd.Build, error)
+	LatestBuildId(branch string) (string, error)
+}
+
+type Config struct {
+	Enabled  bool
+	Interval time.Duration
+}
+
+type Result struct {
+	InstalledBuildId string
+	LatestBuildId    string
+	Branch           string
+	UpdateAvailable  bool
+	CheckedAtUtc     time.Time
+}
+
+// Instance compares the build id of the installed server with the latest build id on steam.
+// If the server is not installed, no update is available
+type Instance struct {
+	cfg      Config
+	steamcmd Steamcmd
+
+	lock       sync.Mutex
+	lastResult Result
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	onChecked         event.InstanceWithData[Result]
+	onCheckFailed     event.InstanceWithData[error]
+	onUpdateAvailable event.InstanceWithData[Result]
+}
+
+func New(cfg Config, steamcmdInstance Steamcmd) (*Instance, error) {
+	if cfg.Enabled && cfg.Interval <= 0 {
+		return nil, fmt.Errorf("interval must be a positive duration but is %v", cfg.Interval)
+	}
+
+	return &Instance{
+		cfg:      cfg,
+		steamcmd: steamcmdInstance,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+func (i *Instance) OnChecked(handler func(p event.PayloadWithData[Result])) {
+	i.onChecked.Register(handler)
+}
+
+func (i *Instance) OnCheckFailed(handler func(p event.PayloadWithData[error])) {
+	i.onCheckFailed.Register(handler)
+}
+
+// OnUpdateAvailable is triggered after every check that found a newer build
+func (i *Instance) OnUpdateAvailable(handler func(p event.PayloadWithData[Result])) {
+	i.onUpdateAvailable.Register(handler)
+}
+
+// Start reads the installed build. If the update check is enabled,
+// it checks for updates right away and then in the configured interval, until Close is called
+func (i *Instance) Start() {
+	if _, err := i.RefreshInstalled(); err != nil {
+		i.onCheckFailed.Trigger(err)
+	}
+
+	if !i.cfg.Enabled {
+		return
+	}
+
+	go func() {
+		for {
+			// errors are reported via the OnCheckFailed event
+			_, _ = i.Check()
+
+			select {
+			case <-i.stop:
+				return
+			case <-time.After(i.cfg.Interval):
+			}
+		}
+	}()
+}
+
+func (i *Instance) Close() {
+	i.stopOnce.Do(func() {
+		close(i.stop)
+	})
+}
+
+// LastResult returns the result of the last successful check
+func (i *Instance) LastResult() Result {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.lastResult
+}
+
+// Check reads the installed build and asks steam for the latest build of the installed branch
+func (i *Instance) Check() (Result, error) {
+	installed, err := i.installedBuild()
+	if err != nil {
+		i.onCheckFailed.Trigger(err)
+		return Result{}, err
+	}
+
+	latestBuildId, err := i.latestBuildId(installed.Branch)
+	if err != nil {
+		err = fmt.Errorf("get latest build id: %w", err)
+		i.onCheckFailed.Trigger(err)
+		return Result{}, err
+	}
+
+	result := i.setResult(installed, latestBuildId)
+	if result.UpdateAvailable {
+		i.onUpdateAvailable.Trigger(result)
+	}
+
+	return result, nil
+}
+
+// RefreshInstalled reads the installed build again and compares it with the latest build id of the last check.
+// Used after an update, so the result is up to date without asking steam again. OnUpdateAvailable is not triggered
+func (i *Instance) RefreshInstalled() (Result, error) {
+	installed, err := i.installedBuild()
+	if err != nil {
+		return Result{}, err
+	}
+
+	latestBuildId := i.LastResult().LatestBuildId
+	if installed.Branch != i.LastResult().Branch {
+		latestBuildId = ""
+	}
+
+	return i.setResult(installed, latestBuildId), nil
+}
+
+// every check runs steamcmd. The checks of multiple instances are serialized, so not every instance starts steamcmd at the same time
+var latestBuildIdLock sync.Mutex
+
+func (i *Instance) latestBuildId(branch string) (string, error) {
+	latestBuildIdLock.Lock()
+	defer latestBuildIdLock.Unlock()
+	return i.steamcmd.LatestBuildId(branch)
+}
+
+func (i *Instance) installedBuild() (steamcmd.Build, error) {
+	installed, err := i.steamcmd.InstalledBuild()
+	if err != nil {
+		if errors.Is(err, steamcmd.ErrNotInstalled) {
+			return steamcmd.Build{Branch: steamcmd.DefaultBranch}, nil
+		}
+		return steamcmd.Build{}, fmt.Errorf("get installed build: %w", err)
+	}
+
+	return installed, nil
+}
+
+func (i *Instance) setResult(installed steamcmd.Build, latestBuildId string) Result {
+	result := Result{
+		InstalledBuildId: installed.BuildId,
+		LatestBuildId:    latestBuildId,
+		Branch:           installed.Branch,
+		UpdateAvailable:  installed.BuildId != "" && latestBuildId != "" && installed.BuildId != latestBuildId,
+		CheckedAtUtc:     time.Now().UTC(),
+	}
+
+	i.lock.Lock()
+	i.lastResult = result
+	i.lock.Unlock()
+
+	i.onChecked.Trigger(result)
+	return result
+}
@@ -0,0 +1,119 @@
+package update_checker_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Phi-S/cs-server-manager/event"
+	"github.com/Phi-S/cs-server-manager/steamcmd"
+	"github.com/Phi-S/cs-server-manager/update_checker"
+)
+
+type fakeSteamcmd struct {
+	installed    steamcmd.Build
+	installedErr error
+	latest       map[string]string
+}
+
+func (f *fakeSteamcmd) InstalledBuild() (steamcmd.Build, error) {
+	return f.installed, f.installedErr
+}
+
+func (f *fakeSteamcmd) LatestBuildId(branch string) (string, error) {
+	buildId, ok := f.latest[branch]
+	if !ok {
+		return "", steamcmd.ErrBranchNotFound
+	}
+	return buildId, nil
+}
+
+func TestInstance_Check_UpdateAvailable(t *testing.T) {
+	fake := &fakeSteamcmd{
+		installed: steamcmd.Build{BuildId: "100", Branch: steamcmd.DefaultBranch},
+		latest:    map[string]string{steamcmd.DefaultBranch: "101"},
+	}
+
+	checker, err := update_checker.New(update_checker.Config{}, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updateAvailableTriggered := false
+	checker.OnUpdateAvailable(func(p event.PayloadWithData[update_checker.Result]) {
+		updateAvailableTriggered = true
+	})
+
+	result, err := checker.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.UpdateAvailable || result.InstalledBuildId != "100" || result.LatestBuildId != "101" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	if !updateAvailableTriggered {
+		t.Fatal("OnUpdateAvailable not triggered")
+	}
+
+	// the update installed the latest build
+	fake.installed.BuildId = "101"
+	result, err = checker.RefreshInstalled()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.UpdateAvailable || checker.LastResult().UpdateAvailable {
+		t.Fatalf("no update expected after refresh. result: %+v", result)
+	}
+}
+
+func TestInstance_Check_NotInstalled(t *testing.T) {
+	fake := &fakeSteamcmd{
+		installedErr: steamcmd.ErrNotInstalled,
+		latest:       map[string]string{steamcmd.DefaultBranch: "101"},
+	}
+
+	checker, err := update_checker.New(update_checker.Config{}, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := checker.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.UpdateAvailable || result.LatestBuildId != "101" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestInstance_Check_Failed(t *testing.T) {
+	fake := &fakeSteamcmd{
+		installed: steamcmd.Build{BuildId: "100", Branch: "beta"},
+		latest:    map[string]string{steamcmd.DefaultBranch: "101"},
+	}
+
+	checker, err := update_checker.New(update_checker.Config{}, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var checkFailedErr error
+	checker.OnCheckFailed(func(p event.PayloadWithData[error]) {
+		checkFailedErr = p.Data
+	})
+
+	if _, err := checker.Check(); !errors.Is(err, steamcmd.ErrBranchNotFound) {
+		t.Fatal("expected ErrBranchNotFound but got", err)
+	}
+
+	if !errors.Is(checkFailedErr, steamcmd.ErrBranchNotFound) {
+		t.Fatal("OnCheckFailed not triggered with the error. error:", checkFailedErr)
+	}
+
+	if _, err := update_checker.New(update_checker.Config{Enabled: true}, fake); err == nil {
+		t.Fatal("expected error for enabled checker without interval")
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package websocket_server
 
 import (
 	"encoding/json"
@@ -23,7 +23,7 @@ type IncomingWebSocketMessage struct {
 	message          string
 }
 
-type WebSocketServer struct {
+type Instance struct {
 	connectionLock sync.Mutex
 	connections    map[*websocket.Conn]*websocket.Conn
 
@@ -31,13 +31,13 @@ type WebSocketServer struct {
 	OnNewClientConnectedEvent event.InstanceWithData[*websocket.Conn]
 }
 
-func NewWebSocketServer() *WebSocketServer {
-	return &WebSocketServer{
+func New() *Instance {
+	return &Instance{
 		connections: make(map[*websocket.Conn]*websocket.Conn),
 	}
 }
 
-func (s *WebSocketServer) handleWs(con *websocket.Conn) {
+func (s *Instance) HandleWs(con *websocket.Conn) {
 	slog.Debug("web socket client connected", "address", con.RemoteAddr())
 
 	s.connectionLock.Lock()
@@ -55,7 +55,7 @@ func (s *WebSocketServer) handleWs(con *websocket.Conn) {
 	}
 }
 
-func (s *WebSocketServer) read(con *websocket.Conn) error {
+func (s *Instance) read(con *websocket.Conn) error {
 	const errorThreshold = 5
 
 	buf := make([]byte, 1024)
@@ -91,7 +91,7 @@ func (s *WebSocketServer) read(con *websocket.Conn) error {
 	}
 }
 
-func (s *WebSocketServer) broadcast(msg []byte) error {
+func (s *Instance) broadcast(msg []byte) error {
 	s.connectionLock.Lock()
 	defer s.connectionLock.Unlock()
 
@@ -118,7 +118,7 @@ func (s *WebSocketServer) broadcast(msg []byte) error {
 	return nil
 }
 
-func (s *WebSocketServer) Broadcast(messageType string, jsonMessage any) error {
+func (s *Instance) Broadcast(messageType string, jsonMessage any) error {
 	message := OutgoingWebsocketMessage{
 		Type:    messageType,
 		Message: jsonMessage,
@@ -130,6 +130,6 @@ func (s *WebSocketServer) Broadcast(messageType string, jsonMessage any) error {
 	return s.broadcast(messageBytes)
 }
 
-func (s *WebSocketServer) BroadcastLogMessage(logEntry logwrt.LogEntry) error {
+func (s *Instance) BroadcastLogMessage(logEntry logwrt.LogEntry) error {
 	return s.Broadcast("log", logEntry)
 }
@@ -1,10 +1,19 @@
-import { DeleteWithoutResponse, Get, PostJsonWithoutResponse } from "./api";
+import {
+  DeleteWithoutResponse,
+  Get,
+  PostJson,
+  PostJsonWithoutResponse,
+  Send,
+  SendWithoutResponse,
+} from "./api";
 
 export interface PluginResp {
   name: string;
   description: string;
   url: string;
   versions: Version[];
+  required_by: string[];
+  uploaded: boolean;
 }
 
 export interface Version {
@@ -15,14 +24,72 @@ export interface Version {
 
 export interface Dependency {
   name: string;
+  // version constraint
   version: string;
-  dependencies: Dependency[];
+}
+
+export interface PlanStep {
+  name: string;
+  version: string;
+  action: "install" | "keep" | "mark_explicit";
+  dependencies: string[];
+  required_by: string[];
+}
+
+export interface Plan {
+  name: string;
+  version: string;
+  steps: PlanStep[];
+}
+
+export interface UpgradeResult {
+  name: string;
+  from_version: string;
+  to_version: string;
+  preserved_files: string[];
+  installed_dependencies: string[];
+  uninstalled_dependencies: string[];
+}
+
+export interface FileDrift {
+  path: string;
+  state: "missing" | "modified";
+  config_file: boolean;
+  repaired: boolean;
+}
+
+export interface ActionDrift {
+  action: { type: string; file?: string };
+  repaired: boolean;
+}
+
+export interface PluginVerifyResult {
+  name: string;
+  version: string;
+  files: FileDrift[];
+  actions: ActionDrift[];
+  error?: string;
+}
+
+export interface VerifyResult {
+  plugins: PluginVerifyResult[];
+  unexpected_files: string[];
+  verified_at_utc: string;
 }
 
 export async function getPlugins(): Promise<PluginResp[]> {
   return await Get<PluginResp[]>("/plugins");
 }
 
+export async function getInstallPlan(
+  name: string,
+  version: string,
+): Promise<Plan> {
+  return await Get<Plan>(
+    `/plugins/${encodeURIComponent(name)}/${encodeURIComponent(version)}/plan`,
+  );
+}
+
 export async function installPlugin(name: string, version: string) {
   return await PostJsonWithoutResponse("/plugins", {
     name: name,
@@ -30,6 +97,39 @@ export async function installPlugin(name: string, version: string) {
   });
 }
 
-export async function uninstallPlugin() {
-  return await DeleteWithoutResponse(`/plugins`);
+export async function upgradePlugin(
+  name: string,
+  version: string,
+): Promise<UpgradeResult> {
+  return await Send<UpgradeResult>(`/plugins/${encodeURIComponent(name)}`, {
+    method: "PUT",
+    body: JSON.stringify({ version: version }),
+  });
+}
+
+export async function uploadPlugin(
+  file: File,
+  name: string,
+  version: string,
+  installDir: string,
+  dependencies: string[],
+) {
+  const formData = new FormData();
+  formData.append("file", file);
+  formData.append("name", name);
+  formData.append("version", version);
+  formData.append("install_dir", installDir);
+  formData.append("dependencies", dependencies.join(","));
+  return await SendWithoutResponse("/plugins/upload", {
+    method: "POST",
+    body: formData,
+  });
+}
+
+export async function verifyPlugins(repair: boolean): Promise<VerifyResult> {
+  return await PostJson<VerifyResult>("/plugins/verify", { repair: repair });
+}
+
+export async function uninstallPlugin(name: string) {
+  return await DeleteWithoutResponse(`/plugins/${encodeURIComponent(name)}`);
 }
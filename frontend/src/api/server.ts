@@ -8,6 +8,16 @@ export enum State {
   SteamcmdUpdating = "steamcmd-updating",
   PluginInstalling = "plugin-installing",
   PluginUninstalling = "plugin-uninstalling",
+  PluginUpgrading = "plugin-upgrading",
+  CrashLoop = "crash-loop",
+}
+
+export interface UpdateProgress {
+  phase: string;
+  percent: number;
+  bytes_done: number;
+  bytes_total: number;
+  eta_seconds: number | null;
 }
 
 export interface Status {
@@ -20,6 +30,15 @@ export interface Status {
   ip: string;
   port: string;
   password: string;
+  game_mode: string;
+  workshop_map: string;
+  workshop_collection: string;
+  tv_enable: boolean;
+  update_available: boolean;
+  installed_build_id: string;
+  installed_branch: string;
+  latest_build_id: string;
+  update_progress: UpdateProgress | null;
 }
 
 export interface LogEntry {
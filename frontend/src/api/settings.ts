@@ -6,6 +6,17 @@ export class Settings {
   start_map: string | undefined;
   max_players: number | undefined;
   steam_login_token: string | undefined;
+  game_mode: string | undefined;
+  workshop_map: string | undefined;
+  workshop_collection: string | undefined;
+  tv_enable: boolean | undefined;
+  launch_options: string[] | undefined;
+  convars: Convar[] | undefined;
+}
+
+export interface Convar {
+  name: string;
+  value: string;
 }
 
 export async function getSettings(): Promise<Settings> {
@@ -15,3 +26,12 @@ export async function getSettings(): Promise<Settings> {
 export async function updateSettings(settings: Settings): Promise<Settings> {
   return await PostJson<Settings>("/settings", settings);
 }
+
+export const GameModes = [
+  "casual",
+  "competitive",
+  "wingman",
+  "deathmatch",
+  "armsrace",
+  "demolition",
+];
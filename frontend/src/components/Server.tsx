@@ -36,7 +36,10 @@ export default function Server() {
           ></button>
         </>
       );
-    } else if (status.state !== State.Idle) {
+    } else if (
+      status.state !== State.Idle &&
+      status.state !== State.CrashLoop
+    ) {
       return (
         <>
           <button className="spinner-grow border-0 align-self-center black">
@@ -83,7 +86,13 @@ export default function Server() {
           className="d-none d-sm-block text-nowrap text-end black ps-2"
           onClick={() => navigateTo(getConnectionUrl(defaultContext.status!))}
         >
-          <span className="pe-2 fs-5">{defaultContext.status.map}</span>[
+          <span className="pe-2 fs-5">{defaultContext.status.map}</span>
+          {defaultContext.status.game_mode !== "" && (
+            <span className="pe-2 fs-5">
+              ({defaultContext.status.game_mode})
+            </span>
+          )}
+          [
           <span className="fs-5">
             {defaultContext.status.player_count} /{" "}
             {defaultContext.status.max_player_count}]
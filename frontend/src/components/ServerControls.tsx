@@ -30,6 +30,9 @@ export default function ServerControls() {
             {status.is_game_server_installed
               ? "Cancel Update"
               : "Cancel install"}
+            {status.update_progress
+              ? ` (${status.update_progress.phase} ${status.update_progress.percent.toFixed(0)}%)`
+              : ""}
           </button>
         </>
       );
@@ -39,7 +42,9 @@ export default function ServerControls() {
           <button
             onClick={startUpdate}
             className="col-3 btn btn-outline-info"
-            disabled={status.state !== State.Idle}
+            disabled={
+              status.state !== State.Idle && status.state !== State.CrashLoop
+            }
           >
             {status.is_game_server_installed ? "Update" : "Install"}
           </button>
@@ -49,7 +54,7 @@ export default function ServerControls() {
   }
 
   function StartStopButton(status: Status) {
-    if (status.state === State.Idle) {
+    if (status.state === State.Idle || status.state === State.CrashLoop) {
       return (
         <button
           onClick={startServer}
@@ -96,7 +101,8 @@ export default function ServerControls() {
           disabled={
             defaultContext.status.is_game_server_installed === false ||
             (defaultContext.status.state !== State.ServerStarted &&
-              defaultContext.status.state !== State.Idle)
+              defaultContext.status.state !== State.Idle &&
+              defaultContext.status.state !== State.CrashLoop)
           }
         >
           Restart
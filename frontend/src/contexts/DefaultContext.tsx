@@ -24,6 +24,15 @@ export default function DefaultContextWrapper({ children }: ChildrenProps) {
     ip: "",
     port: "",
     password: "",
+    game_mode: "",
+    workshop_map: "",
+    workshop_collection: "",
+    tv_enable: false,
+    update_available: false,
+    installed_build_id: "",
+    installed_branch: "",
+    latest_build_id: "",
+    update_progress: null,
   });
 
   const [logs, setLogs] = useState<LogEntry[]>([]);
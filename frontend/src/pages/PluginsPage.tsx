@@ -1,10 +1,16 @@
 import { ChangeEvent, useContext, useEffect, useState } from "react";
+import { ErrorResponseError } from "../api/api";
 import {
   Dependency,
+  getInstallPlan,
   getPlugins,
   installPlugin,
   PluginResp,
   uninstallPlugin,
+  upgradePlugin,
+  uploadPlugin,
+  verifyPlugins,
+  VerifyResult,
 } from "../api/plugins";
 import { State } from "../api/server";
 import ConfirmModal from "../components/ConfirmModal";
@@ -21,6 +27,16 @@ export default function PluginsPage() {
     | { title?: string; message: string; handleConfirmation: () => void }
     | undefined
   >(undefined);
+  const [upload, setUpload] = useState<{
+    file?: File;
+    name: string;
+    version: string;
+    installDir: string;
+  }>({
+    name: "",
+    version: "",
+    installDir: "/addons/counterstrikesharp/plugins/",
+  });
 
   useEffect(() => {
     updatePlugins();
@@ -30,16 +46,21 @@ export default function PluginsPage() {
     return <Loading />;
   }
 
-  function isAnyPluginInstalled(plugins: PluginResp[]) {
+  function getInstalledVersion(
+    plugins: PluginResp[],
+    name: string,
+  ): string | undefined {
     for (const plugin of plugins) {
-      for (const pluginVersion of plugin.versions) {
-        if (pluginVersion.installed === true) {
-          return true;
+      if (plugin.name === name) {
+        for (const pluginVersion of plugin.versions) {
+          if (pluginVersion.installed === true) {
+            return pluginVersion.name;
+          }
         }
       }
     }
 
-    return false;
+    return undefined;
   }
 
   function isPluginInstalled(
@@ -61,23 +82,135 @@ export default function PluginsPage() {
   }
 
   function install(plugins: PluginResp[], pluginName: string, version: string) {
-    if (isAnyPluginInstalled(plugins)) {
+    const installedVersion = getInstalledVersion(plugins, pluginName);
+    if (installedVersion !== undefined) {
       setConfirm({
-        message: `Are you sure you want to uninstall the current plugin and install ${pluginName} (${version})?`,
+        message: `Are you sure you want to switch ${pluginName} from ${installedVersion} to ${version}? Modified config files are kept.`,
         handleConfirmation: () => {
           setConfirm(undefined);
-          uninstallPlugin().then(() => {
-            installPlugin(pluginName, version).then(() => {
-              updatePlugins();
+          upgradePlugin(pluginName, version)
+            .then(() => updatePlugins())
+            .catch((error) => {
+              if (error instanceof ErrorResponseError) {
+                setConfirm({
+                  title: `Can not switch ${pluginName} to ${version}`,
+                  message: error.errorResponse.message,
+                  handleConfirmation: () => setConfirm(undefined),
+                });
+              }
             });
-          });
         },
       });
     } else {
-      installPlugin(pluginName, version).then(() => {
-        updatePlugins();
+      installWithDependencies(pluginName, version);
+    }
+  }
+
+  function installWithDependencies(pluginName: string, version: string) {
+    getInstallPlan(pluginName, version)
+      .then((plan) => {
+        const dependencies = plan.steps
+          .filter(
+            (step) => step.action === "install" && step.name !== pluginName,
+          )
+          .map((step) => `${step.name} (${step.version})`);
+
+        if (dependencies.length === 0) {
+          installPlugin(pluginName, version).then(() => updatePlugins());
+          return;
+        }
+
+        setConfirm({
+          message: `Installing ${pluginName} (${version}) also installs ${dependencies.join(", ")}. Do you want to continue?`,
+          handleConfirmation: () => {
+            setConfirm(undefined);
+            installPlugin(pluginName, version).then(() => updatePlugins());
+          },
+        });
+      })
+      .catch((error) => {
+        if (error instanceof ErrorResponseError) {
+          setConfirm({
+            title: `Can not install ${pluginName} (${version})`,
+            message: error.errorResponse.message,
+            handleConfirmation: () => setConfirm(undefined),
+          });
+        }
       });
+  }
+
+  function uploadAndInstall() {
+    if (upload.file === undefined) {
+      return;
     }
+
+    uploadPlugin(
+      upload.file,
+      upload.name,
+      upload.version,
+      upload.installDir,
+      [],
+    )
+      .then(() => updatePlugins())
+      .catch((error) => {
+        if (error instanceof ErrorResponseError) {
+          setConfirm({
+            title: `Can not install ${upload.name} (${upload.version})`,
+            message: error.errorResponse.message,
+            handleConfirmation: () => setConfirm(undefined),
+          });
+        }
+      });
+  }
+
+  function verifyAndRepair() {
+    verifyPlugins(true)
+      .then((result) => {
+        setConfirm({
+          title: "Plugins verified",
+          message: getVerifySummary(result),
+          handleConfirmation: () => setConfirm(undefined),
+        });
+      })
+      .catch((error) => {
+        if (error instanceof ErrorResponseError) {
+          setConfirm({
+            title: "Can not verify plugins",
+            message: error.errorResponse.message,
+            handleConfirmation: () => setConfirm(undefined),
+          });
+        }
+      });
+  }
+
+  function getVerifySummary(result: VerifyResult): string {
+    if (result.plugins.length === 0 && result.unexpected_files.length === 0) {
+      return "All plugin files are unchanged.";
+    }
+
+    const lines = result.plugins.map((plugin) => {
+      if (plugin.error !== undefined) {
+        return `${plugin.name} (${plugin.version}): repair failed: ${plugin.error}`;
+      }
+
+      const repaired =
+        plugin.files.filter((file) => file.repaired).length +
+        plugin.actions.filter((action) => action.repaired).length;
+      const kept = plugin.files
+        .filter((file) => !file.repaired)
+        .map((file) => file.path);
+      let line = `${plugin.name} (${plugin.version}): ${repaired} repaired`;
+      if (kept.length > 0) {
+        line += `, modified config files kept: ${kept.join(", ")}`;
+      }
+      return line;
+    });
+
+    if (result.unexpected_files.length > 0) {
+      lines.push(`Unexpected files: ${result.unexpected_files.join(", ")}`);
+    }
+
+    return lines.join(". ");
   }
 
   function updatePlugins() {
@@ -128,18 +261,24 @@ export default function PluginsPage() {
     return "";
   }
 
+  function getRequiredBy(plugin: PluginResp) {
+    if (!isRequired(plugin)) {
+      return "";
+    }
+
+    return <span>Required by: {plugin.required_by.join(", ")}</span>;
+  }
+
+  function isRequired(plugin: PluginResp): boolean {
+    return plugin.required_by.length > 0;
+  }
+
   function getAllDependencies(deps: Dependency[]): string[] {
     if (deps === null) {
       return [];
     }
 
-    let result: string[] = [];
-    deps.forEach((dep) => {
-      result = [...result, `${dep.name}(${dep.version})`];
-      result = [...result, ...getAllDependencies(dep.dependencies)];
-    });
-
-    return result;
+    return deps.map((dep) => `${dep.name}(${dep.version})`);
   }
 
   function onSelectedVersionChanged(
@@ -165,15 +304,61 @@ export default function PluginsPage() {
       {defaultContext.status.state === State.PluginUninstalling && (
         <Loading message="Uninstalling plugin" />
       )}
+      {defaultContext.status.state === State.PluginUpgrading && (
+        <Loading message="Upgrading plugin" />
+      )}
+      <div className="input-group mb-3">
+        <input
+          type="file"
+          className="form-control"
+          accept=".zip,.tar.gz"
+          onChange={(e) =>
+            setUpload({ ...upload, file: e.target.files?.[0] ?? undefined })
+          }
+        />
+        <input
+          className="form-control"
+          placeholder="Name"
+          value={upload.name}
+          onChange={(e) => setUpload({ ...upload, name: e.target.value })}
+        />
+        <input
+          className="form-control"
+          placeholder="Version"
+          value={upload.version}
+          onChange={(e) => setUpload({ ...upload, version: e.target.value })}
+        />
+        <input
+          className="form-control"
+          placeholder="Install dir"
+          value={upload.installDir}
+          onChange={(e) => setUpload({ ...upload, installDir: e.target.value })}
+        />
+        <button
+          className="btn btn-outline-info"
+          disabled={
+            upload.file === undefined ||
+            upload.name === "" ||
+            upload.version === ""
+          }
+          onClick={uploadAndInstall}
+        >
+          Upload
+        </button>
+        <button className="btn btn-outline-info" onClick={verifyAndRepair}>
+          Verify & repair
+        </button>
+      </div>
       <table className="table">
         <tbody>
           {plugins.map((plugin) => (
-            <tr key={plugin.url} className="d-table-row">
+            <tr key={plugin.name} className="d-table-row">
               <td className="col-2 fs-5 align-middle text-center">
                 <a className="link" href={plugin.url} target="_blank">
                   {plugin.name}
                 </a>
                 <br />
+                {plugin.uploaded && <span className="small">uploaded</span>}
               </td>
               <td className="col-6">
                 {plugin.description}
@@ -181,6 +366,9 @@ export default function PluginsPage() {
                 <div className="small bg-info bg-opacity-25 text-center">
                   {getDependencies(plugins, plugin.name)}
                 </div>
+                <div className="small bg-warning bg-opacity-25 text-center">
+                  {getRequiredBy(plugin)}
+                </div>
               </td>
               <td className="col-2">
                 <select
@@ -202,8 +390,9 @@ export default function PluginsPage() {
                 ) ? (
                   <button
                     className="btn btn-outline-info w-100"
+                    disabled={isRequired(plugin)}
                     onClick={() =>
-                      uninstallPlugin().then((_) => updatePlugins())
+                      uninstallPlugin(plugin.name).then((_) => updatePlugins())
                     }
                   >
                     Uninstall
@@ -1,6 +1,11 @@
 import { ChangeEvent, useEffect, useState } from "react";
 import { restartServer } from "../api/server";
-import { getSettings, Settings, updateSettings } from "../api/settings";
+import {
+  GameModes,
+  getSettings,
+  Settings,
+  updateSettings,
+} from "../api/settings";
 import Loading from "../components/Loading";
 
 export default function SettingsPage() {
@@ -120,6 +125,122 @@ export default function SettingsPage() {
               </a>
             </small>
           </div>
+          <div className="input-group mb-3">
+            <span className="input-group-text">Game mode</span>
+            <select
+              className="form-select"
+              defaultValue={settings.game_mode}
+              onChange={(event: ChangeEvent<HTMLSelectElement>) => {
+                setSettings((s) => {
+                  s!.game_mode = event.target.value;
+                  return s;
+                });
+              }}
+            >
+              <option value="">Server default</option>
+              {GameModes.map((gameMode) => (
+                <option key={gameMode} value={gameMode}>
+                  {gameMode}
+                </option>
+              ))}
+            </select>
+          </div>
+          <div className="input-group mb-3">
+            <span className="input-group-text">Workshop map</span>
+            <input
+              type="text"
+              className="form-control"
+              aria-describedby="workshopMapHelp"
+              defaultValue={settings.workshop_map}
+              onChange={(event: ChangeEvent<HTMLInputElement>) => {
+                setSettings((s) => {
+                  s!.workshop_map = event.target.value;
+                  return s;
+                });
+              }}
+            />
+            <small id="workshopMapHelp" className="input-group text-muted">
+              Workshop item id. If set, the workshop map is loaded instead of
+              the start map
+            </small>
+          </div>
+          <div className="input-group mb-3">
+            <span className="input-group-text">Workshop collection</span>
+            <input
+              type="text"
+              className="form-control"
+              defaultValue={settings.workshop_collection}
+              onChange={(event: ChangeEvent<HTMLInputElement>) => {
+                setSettings((s) => {
+                  s!.workshop_collection = event.target.value;
+                  return s;
+                });
+              }}
+            />
+          </div>
+          <div className="form-check mb-3">
+            <input
+              type="checkbox"
+              className="form-check-input"
+              id="tvEnable"
+              defaultChecked={settings.tv_enable}
+              onChange={(event: ChangeEvent<HTMLInputElement>) => {
+                setSettings((s) => {
+                  s!.tv_enable = event.target.checked;
+                  return s;
+                });
+              }}
+            />
+            <label className="form-check-label" htmlFor="tvEnable">
+              Enable CSTV
+            </label>
+          </div>
+          <div className="input-group mb-3">
+            <span className="input-group-text">Launch options</span>
+            <textarea
+              className="form-control"
+              aria-describedby="launchOptionsHelp"
+              defaultValue={settings.launch_options?.join("\n")}
+              onChange={(event: ChangeEvent<HTMLTextAreaElement>) => {
+                setSettings((s) => {
+                  s!.launch_options = event.target.value
+                    .split("\n")
+                    .map((l) => l.trim())
+                    .filter((l) => l !== "");
+                  return s;
+                });
+              }}
+            />
+            <small id="launchOptionsHelp" className="input-group text-muted">
+              One launch option per line. For example: -tickrate 128
+            </small>
+          </div>
+          <div className="input-group mb-3">
+            <span className="input-group-text">Convars</span>
+            <textarea
+              className="form-control"
+              aria-describedby="convarsHelp"
+              defaultValue={settings.convars
+                ?.map((c) => `${c.name} ${c.value}`.trim())
+                .join("\n")}
+              onChange={(event: ChangeEvent<HTMLTextAreaElement>) => {
+                setSettings((s) => {
+                  s!.convars = event.target.value
+                    .split("\n")
+                    .map((l) => l.trim())
+                    .filter((l) => l !== "")
+                    .map((l) => {
+                      const [name, ...value] = l.split(" ");
+                      return { name: name, value: value.join(" ").trim() };
+                    });
+                  return s;
+                });
+              }}
+            />
+            <small id="convarsHelp" className="input-group text-muted">
+              One convar per line. For example: game_type 0
+            </small>
+          </div>
           <button
             onClick={() => save(true)}
             type="submit"